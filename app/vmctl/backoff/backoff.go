@@ -5,6 +5,8 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"math/rand"
+	"sync"
 	"time"
 
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
@@ -27,8 +29,18 @@ type Backoff struct {
 	retries     int
 	factor      float64
 	minDuration time.Duration
+	// maxDuration limits the delay between attempts. Zero means no limit.
+	maxDuration time.Duration
+	// jitter defines whether to randomize the delay between attempts
+	jitter bool
 }
 
+var (
+	// rnd is seeded explicitly, so delays differ between vmctl instances
+	rnd   = rand.New(rand.NewSource(time.Now().UnixNano()))
+	rndMu sync.Mutex
+)
+
 // New initialize backoff object
 func New() *Backoff {
 	return &Backoff{
@@ -38,11 +50,53 @@ func New() *Backoff {
 	}
 }
 
+// NewWithParams initialize backoff object with the given number of attempts
+// and bounds of the delay between them. Zero maxDuration means no limit.
+func NewWithParams(retries int, minDuration, maxDuration time.Duration) (*Backoff, error) {
+	if retries < 1 {
+		return nil, fmt.Errorf("the number of attempts must be positive; got %d", retries)
+	}
+	if minDuration <= 0 {
+		return nil, fmt.Errorf("min delay must be positive; got %s", minDuration)
+	}
+	if maxDuration != 0 && maxDuration < minDuration {
+		return nil, fmt.Errorf("max delay %s can't be lower than min delay %s", maxDuration, minDuration)
+	}
+	return &Backoff{
+		retries:     retries,
+		factor:      backoffFactor,
+		minDuration: minDuration,
+		maxDuration: maxDuration,
+	}, nil
+}
+
+// SetJitter enables full jitter for the delay between attempts.
+// The delay is chosen randomly in the range [0, d), where d is the delay without jitter,
+// so concurrent callers hitting the same error don't retry in lockstep.
+func (b *Backoff) SetJitter(jitter bool) {
+	b.jitter = jitter
+}
+
+// delay returns the delay before the next attempt after the given number of failed attempts
+func (b *Backoff) delay(attempt int) time.Duration {
+	backoff := float64(b.minDuration) * math.Pow(b.factor, float64(attempt))
+	dur := time.Duration(backoff)
+	if b.maxDuration > 0 && (dur > b.maxDuration || backoff > math.MaxInt64) {
+		dur = b.maxDuration
+	}
+	if !b.jitter || dur <= 0 {
+		return dur
+	}
+	rndMu.Lock()
+	dur = time.Duration(rnd.Int63n(int64(dur)))
+	rndMu.Unlock()
+	return dur
+}
+
 // Retry process retries until all attempts are completed
 func (b *Backoff) Retry(ctx context.Context, cb retryableFunc) (uint64, error) {
 	var attempt uint64
 	for i := 0; i < b.retries; i++ {
-		// @TODO we should use context to cancel retries
 		err := cb()
 		if err == nil {
 			return attempt, nil
@@ -51,11 +105,20 @@ func (b *Backoff) Retry(ctx context.Context, cb retryableFunc) (uint64, error) {
 			logger.Errorf("unrecoverable error: %s", err)
 			return attempt, err // fail fast if not recoverable
 		}
+		if ctx.Err() != nil {
+			// the context is canceled or its deadline is exceeded, so retries make no sense
+			return attempt, err
+		}
 		attempt++
-		backoff := float64(b.minDuration) * math.Pow(b.factor, float64(i))
-		dur := time.Duration(backoff)
+		dur := b.delay(i)
 		logger.Errorf("got error: %s on attempt: %d; will retry in %v", err, attempt, dur)
-		time.Sleep(time.Duration(backoff))
+		t := time.NewTimer(dur)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return attempt, err
+		case <-t.C:
+		}
 	}
 	return attempt, fmt.Errorf("execution failed after %d retry attempts", b.retries)
 }
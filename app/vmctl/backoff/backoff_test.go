@@ -94,3 +94,67 @@ func TestRetry_Do(t *testing.T) {
 		})
 	}
 }
+
+func TestNewWithParams(t *testing.T) {
+	f := func(retries int, minDuration, maxDuration time.Duration) {
+		t.Helper()
+		if _, err := NewWithParams(retries, minDuration, maxDuration); err == nil {
+			t.Fatalf("expecting error for retries=%d, minDuration=%s, maxDuration=%s", retries, minDuration, maxDuration)
+		}
+	}
+	f(0, time.Second, 0)
+	f(5, 0, 0)
+	f(5, time.Second, time.Millisecond)
+
+	b, err := NewWithParams(4, 10*time.Millisecond, 20*time.Millisecond)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	calls := 0
+	start := time.Now()
+	attempts, err := b.Retry(context.Background(), func() error {
+		calls++
+		return fmt.Errorf("got some error")
+	})
+	if err == nil {
+		t.Fatalf("expecting error after all attempts failed")
+	}
+	if calls != 4 || attempts != 4 {
+		t.Fatalf("unexpected number of calls %d and attempts %d; want 4", calls, attempts)
+	}
+	// delays are 10ms, 17ms, 20ms and 20ms because of the max delay
+	if d := time.Since(start); d < 67*time.Millisecond || d > 5*time.Second {
+		t.Fatalf("unexpected duration of retries: %s", d)
+	}
+}
+
+func TestBackoffJitter(t *testing.T) {
+	b, err := NewWithParams(10, 10*time.Millisecond, time.Second)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	f := func(attempt int, exp time.Duration) {
+		t.Helper()
+		seen := make(map[time.Duration]struct{})
+		for i := 0; i < 100; i++ {
+			d := b.delay(attempt)
+			if d < 0 || d >= exp {
+				t.Fatalf("delay %s for attempt %d is out of range [0, %s)", d, attempt, exp)
+			}
+			seen[d] = struct{}{}
+		}
+		if len(seen) < 2 {
+			t.Fatalf("expecting random delays for attempt %d; got %v", attempt, seen)
+		}
+	}
+
+	// without jitter delays are exponential
+	if d := b.delay(1); d != 17*time.Millisecond {
+		t.Fatalf("unexpected delay without jitter: %s", d)
+	}
+	b.SetJitter(true)
+	f(0, 10*time.Millisecond)
+	f(1, 17*time.Millisecond)
+	// the window is limited by max delay
+	f(9, time.Second)
+}
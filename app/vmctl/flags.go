@@ -2,16 +2,20 @@ package main
 
 import (
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/urfave/cli/v2"
 
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmctl/native"
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmctl/stepper"
 )
 
 const (
-	globalSilent  = "s"
-	globalVerbose = "verbose"
+	globalSilent      = "s"
+	globalVerbose     = "verbose"
+	globalRetryJitter = "retry-jitter"
+	globalLogFormat   = "log-format"
 )
 
 var (
@@ -26,6 +30,17 @@ var (
 			Value: false,
 			Usage: "Whether to enable verbosity in logs output.",
 		},
+		&cli.BoolFlag{
+			Name: globalRetryJitter,
+			Usage: "Whether to randomize the delay between retries of failed requests in the range from zero to the computed exponential delay." +
+				" This prevents concurrent workers and vmctl instances from retrying in lockstep after the same transient failure.",
+		},
+		&cli.StringFlag{
+			Name: globalLogFormat,
+			Usage: fmt.Sprintf("Format of logs. Supported values: '%s', '%s'. In '%s' format every log message is written to stderr as a JSON line,", logFormatText, logFormatJSON, logFormatJSON) +
+				" and key migration events contain fields such as tenant, metric_count, src and dst. See https://docs.victoriametrics.com/vmctl.html#structured-logs",
+			Value: logFormatText,
+		},
 	}
 )
 
@@ -320,59 +335,436 @@ var (
 )
 
 const (
-	vmNativeFilterMatch     = "vm-native-filter-match"
-	vmNativeFilterTimeStart = "vm-native-filter-time-start"
-	vmNativeFilterTimeEnd   = "vm-native-filter-time-end"
-	vmNativeStepInterval    = "vm-native-step-interval"
+	vmNativeFilterMatch        = "vm-native-filter-match"
+	vmNativeFilterExcludeMatch = "vm-native-filter-exclude-match"
+	vmNativeFilterTimeStart    = "vm-native-filter-time-start"
+	vmNativeFilterTimeEnd      = "vm-native-filter-time-end"
+	vmNativeStepInterval       = "vm-native-step-interval"
+	vmNativeChunkOrder         = "vm-native-chunk-order"
+	vmNativeChunkOverlap       = "vm-native-chunk-overlap"
 
 	vmNativeDisableHTTPKeepAlive = "vm-native-disable-http-keep-alive"
+	vmNativeConnectionsPerDst    = "vm-native-connections-per-dst"
+	vmNativeHTTPTimeout          = "vm-native-http-timeout"
+	vmNativeExploreMatchLimit    = "vm-native-explore-match-limit"
+	vmNativeSuccessFile          = "vm-native-success-file"
+	vmNativeMetricDeadline       = "vm-native-metric-deadline"
+	vmNativeRequestTimeout       = "vm-native-request-timeout"
+	vmNativeDisableRedirects     = "vm-native-disable-redirects"
+	vmNativeMaxRedirects         = "vm-native-max-redirects"
+	vmNativeIntraUnitParallelism = "vm-native-intra-unit-parallelism"
+	vmNativeRetrySubRanges       = "vm-native-retry-sub-ranges"
+	vmNativeExportFormat         = "vm-native-export-format"
+
+	vmNativeThrottleOnSource5xx     = "vm-native-throttle-on-source-5xx"
+	vmNativeThrottleReductionFactor = "vm-native-throttle-reduction-factor"
+	vmNativeThrottleRecoveryRate    = "vm-native-throttle-recovery-rate"
+
+	vmNativeDiscoveryConcurrency = "vm-native-max-concurrent-tenants-discovery"
+	vmNativeSrcTenants           = "vm-native-src-tenants"
+	vmNativeTenantFilter         = "vm-native-tenant-filter"
+
+	vmNativeRequestTimeoutFactor = "vm-native-request-timeout-factor"
+	vmNativeRequestTimeoutMax    = "vm-native-request-timeout-max"
+
+	vmNativeSkipEmptyTenants = "vm-native-skip-empty-tenants"
+	vmNativeTenantsCacheFile = "vm-native-tenants-cache-file"
+	vmNativeTenantsCacheTTL  = "vm-native-tenants-cache-ttl"
+	vmNativeRefreshTenants   = "vm-native-refresh-tenants"
+
+	vmNativeMaxClockSkew = "vm-native-max-clock-skew"
+	vmNativeSkipProbe    = "vm-native-skip-probe"
+	vmNativeYes          = "vm-native-yes"
+
+	vmNativeContinueOnError       = "vm-native-continue-on-error"
+	vmNativeFailuresFile          = "vm-native-failures-file"
+	vmNativeRetryFailedUnitsAtEnd = "vm-native-retry-failed-units-at-end"
+	vmNativeRetryFailedUnitsDelay = "vm-native-retry-failed-units-delay"
+
+	vmNativeDstBufferDir     = "vm-native-dst-buffer-dir"
+	vmNativeDstBufferMaxSize = "vm-native-dst-buffer-max-size"
+
+	vmNativeStickyRouteBy  = "vm-native-sticky-route-by"
+	vmNativeStickyRouteVia = "vm-native-sticky-route-via"
+	vmNativeStickyRouteKey = "vm-native-sticky-route-key"
+
+	vmNativeOnDuplicateTS = "vm-native-on-duplicate-ts"
+	vmNativeNonFinite     = "vm-native-nonfinite"
+	vmNativeValueScale    = "vm-native-value-scale"
+	vmNativeRelabelConfig = "vm-native-relabel-config"
+	vmNativeDownsample    = "vm-native-downsample"
+	vmNativeDedupStream   = "vm-native-dedup-stream"
+
+	vmNativeStampLabel         = "vm-native-stamp-label"
+	vmNativeStampLabelConflict = "vm-native-stamp-label-conflict"
+
+	vmNativeStateFile          = "vm-native-state-file"
+	vmNativeCheckpointInterval = "vm-native-checkpoint-interval"
+	vmNativeRestart            = "vm-native-restart"
+	vmNativeMaxTotalBytes      = "vm-native-max-total-bytes"
+	vmNativeMaxTotalRequests   = "vm-native-max-total-requests"
+	vmNativeGlobalRateLimit    = "vm-native-global-rate-limit"
+	vmNativeSrcRateLimit       = "vm-native-src-rate-limit"
+
+	vmNativeSlowStart         = "vm-native-slow-start"
+	vmNativeSlowStartFraction = "vm-native-slow-start-fraction"
+
+	vmNativeStatsFormat  = "vm-native-stats-format"
+	vmNativeVerboseStats = "vm-native-verbose-stats"
+	vmNativeDigest       = "vm-native-digest"
+
+	vmNativeExploreStream = "vm-native-explore-stream"
+
+	vmNativeMetricNameFilter  = "vm-native-metric-name-filter"
+	vmNativeMetricNameExclude = "vm-native-metric-name-exclude"
+
+	vmNativeDryRun    = "vm-native-dry-run"
+	vmNativeDryRunOut = "vm-native-dry-run-out"
+
+	vmNativeDryRunTopMetrics = "vm-native-dry-run-top-metrics"
+
+	vmNativeRetryMaxAttempts = "vm-native-retry-max-attempts"
+	vmNativeRetryMinDelay    = "vm-native-retry-min-delay"
+	vmNativeRetryMaxDelay    = "vm-native-retry-max-delay"
+
+	vmNativeSrcQPS = "vm-native-src-qps"
+
+	vmNativeChunkByLabel = "vm-native-chunk-by-label"
+
+	vmNativeMaxMetrics      = "vm-native-max-metrics"
+	vmNativeEstimateMetrics = "vm-native-estimate-metrics"
+
+	vmNativeVerifyPerMetric = "vm-native-verify-per-metric"
+	vmNativeVerifyReimport  = "vm-native-verify-reimport"
+	vmNativeVerifyAddr      = "vm-native-verify-addr"
+	vmNativeVerifyCounts    = "vm-native-verify-counts"
+
+	vmNativeSkipExisting = "vm-native-skip-existing"
+
+	vmNativeWarmupQuery = "vm-native-warmup-query"
+
+	vmNativeAutoChunk = "vm-native-auto-chunk"
+
+	vmNativeOtelEndpoint = "vm-native-otel-endpoint"
+
+	vmNativeMetricsListenAddr = "vm-native-metrics-listen-addr"
+
+	vmNativeDstTenantFromLabel  = "vm-native-dst-tenant-from-label"
+	vmNativeDstTenantStripLabel = "vm-native-dst-tenant-strip-label"
+	vmNativeDstTenantDefault    = "vm-native-dst-tenant-default"
+
+	vmNativeDstTenant    = "vm-native-dst-tenant"
+	vmNativeDstTenantMap = "vm-native-dst-tenant-map"
+
+	vmNativeTenantConcurrency          = "vm-native-tenant-concurrency"
+	vmNativeImportConcurrencyPerTenant = "vm-native-import-concurrency-per-tenant"
+
+	vmNativeOverallProgressBar = "vm-native-overall-progress-bar"
+
+	vmNativeConcurrencyAuto         = "vm-native-concurrency-auto"
+	vmNativeConcurrencyMin          = "vm-native-concurrency-min"
+	vmNativeConcurrencyMax          = "vm-native-concurrency-max"
+	vmNativeConcurrencyMaxRetryRate = "vm-native-concurrency-max-retry-rate"
+
+	vmNativePlanOut   = "vm-native-plan-out"
+	vmNativePlanIn    = "vm-native-plan-in"
+	vmNativePlanForce = "vm-native-plan-force"
+
+	vmNativeWaitDurable        = "vm-native-wait-durable"
+	vmNativeWaitDurableAddr    = "vm-native-wait-durable-addr"
+	vmNativeWaitDurableDelay   = "vm-native-wait-durable-delay"
+	vmNativeWaitDurableAuthKey = "vm-native-wait-durable-auth-key"
 
 	vmNativeSrcAddr        = "vm-native-src-addr"
 	vmNativeSrcUser        = "vm-native-src-user"
 	vmNativeSrcPassword    = "vm-native-src-password"
 	vmNativeSrcHeaders     = "vm-native-src-headers"
 	vmNativeSrcBearerToken = "vm-native-src-bearer-token"
+	vmNativeSrcExtraLabel  = "vm-native-src-extra-label"
+	vmNativeSrcFile        = "vm-native-src-file"
+	vmNativeSrcExportPath  = "vm-native-src-export-path"
+
+	vmNativeTransferFormat = "vm-native-transfer-format"
 
 	vmNativeDstAddr        = "vm-native-dst-addr"
+	vmNativeDstFile        = "vm-native-dst-file"
+	vmNativeDstImportPath  = "vm-native-dst-import-path"
+	vmNativeDstRemoteWrite = "vm-native-dst-remote-write"
 	vmNativeDstUser        = "vm-native-dst-user"
 	vmNativeDstPassword    = "vm-native-dst-password"
 	vmNativeDstHeaders     = "vm-native-dst-headers"
 	vmNativeDstBearerToken = "vm-native-dst-bearer-token"
+
+	vmNativeSrcUserFile        = "vm-native-src-user-file"
+	vmNativeSrcPasswordFile    = "vm-native-src-password-file"
+	vmNativeSrcBearerTokenFile = "vm-native-src-bearer-token-file"
+
+	vmNativeDstUserFile        = "vm-native-dst-user-file"
+	vmNativeDstPasswordFile    = "vm-native-dst-password-file"
+	vmNativeDstBearerTokenFile = "vm-native-dst-bearer-token-file"
+
+	vmNativeConfig = "vm-native-config"
+
+	vmNativeAutoSplitBytes = "vm-native-auto-split-bytes"
+
+	vmNativeProgressInterval = "vm-native-progress-interval"
+
+	vmNativeBatchSize     = "vm-native-batch-size"
+	vmNativeBatchMaxBytes = "vm-native-batch-max-bytes"
+
+	vmNativeExportMaxRowsPerLine = "vm-native-export-max-rows-per-line"
+
+	vmNativeSamplePercentage = "vm-native-sample-percentage"
+	vmNativeSampleSeed       = "vm-native-sample-seed"
+
+	vmNativeMaxIdleConns    = "vm-native-max-idle-conns"
+	vmNativeMaxConnsPerHost = "vm-native-max-conns-per-host"
+	vmNativeForceHTTP2      = "vm-native-force-http2"
+
+	vmNativeFlushOnFinish = "vm-native-flush-on-finish"
+
+	vmNativeLabelFilter = "vm-native-label-filter"
+
+	vmNativeSorted = "vm-native-sorted"
+
+	vmNativeBarStyle = "vm-native-bar-style"
+
+	vmNativeSrcCertFile           = "vm-native-src-cert-file"
+	vmNativeSrcKeyFile            = "vm-native-src-key-file"
+	vmNativeSrcCAFile             = "vm-native-src-ca-file"
+	vmNativeSrcServerName         = "vm-native-src-server-name"
+	vmNativeSrcInsecureSkipVerify = "vm-native-src-insecure-skip-verify"
+
+	vmNativeDstCertFile           = "vm-native-dst-cert-file"
+	vmNativeDstKeyFile            = "vm-native-dst-key-file"
+	vmNativeDstCAFile             = "vm-native-dst-ca-file"
+	vmNativeDstServerName         = "vm-native-dst-server-name"
+	vmNativeDstInsecureSkipVerify = "vm-native-dst-insecure-skip-verify"
 )
 
 var (
 	vmNativeFlags = []cli.Flag{
 		&cli.StringFlag{
+			Name: vmNativeConfig,
+			Usage: "Optional path to YAML file with migration params, so complex migrations can be reproduced and kept under version control. " +
+				"The file may contain the following keys: match, time_start, time_end, step_interval, rate_limit, concurrency. " +
+				"Params set via command-line flags take precedence over params from the file. " +
+				"See https://docs.victoriametrics.com/vmctl.html#migration-config-file",
+		},
+		&cli.GenericFlag{
 			Name: vmNativeFilterMatch,
 			Usage: "Time series selector to match series for export. For example, select {instance!=\"localhost\"} will " +
 				"match all series with \"instance\" label different to \"localhost\".\n" +
+				" Flag can be set multiple times in order to migrate series matching any of the selectors.\n" +
 				" See more details here https://github.com/VictoriaMetrics/VictoriaMetrics#how-to-export-data-in-native-format",
-			Value: `{__name__!=""}`,
+			Value:       &matchSelectors{},
+			DefaultText: defaultFilterMatch,
 		},
 		&cli.StringFlag{
-			Name:     vmNativeFilterTimeStart,
-			Usage:    "The time filter may contain either unix timestamp in seconds or RFC3339 values. E.g. '2020-01-01T20:07:00Z'",
-			Required: true,
+			Name: vmNativeFilterExcludeMatch,
+			Usage: "Optional series selector with negative matchers only for excluding series from migration. " +
+				"For example, {__name__!~\"go_.*\"} excludes all the metrics with \"go_\" prefix. " +
+				fmt.Sprintf("Matchers are added to --%s, so excluded metrics aren't discovered and exported. ", vmNativeFilterMatch) +
+				"See https://docs.victoriametrics.com/vmctl.html#excluding-series-from-migration",
+		},
+		&cli.GenericFlag{
+			Name: vmNativeLabelFilter,
+			Usage: "Optional label constraint in the form label=value for migrated series, e.g. job=node. " +
+				fmt.Sprintf("Constraints are added to --%s as exact matchers, so only matching series are discovered and exported.\n", vmNativeFilterMatch) +
+				" Flag can be set multiple times. Multiple values of the same label are matched via regexp OR, e.g. job=~\"node|vmagent\". " +
+				"See https://docs.victoriametrics.com/vmctl.html#filtering-by-label-values",
+			Value: &labelFilters{},
+		},
+		&cli.StringFlag{
+			Name:  vmNativeFilterTimeStart,
+			Usage: fmt.Sprintf("The time filter may contain either RFC3339 values, Unix timestamps in seconds or milliseconds or relative time expressions. E.g. '2020-01-01T20:07:00Z', '1577909220', 'now-7d'. Required unless --%s is set", vmNativeSrcFile),
 		},
 		&cli.StringFlag{
 			Name:  vmNativeFilterTimeEnd,
-			Usage: "The time filter may contain either unix timestamp in seconds or RFC3339 values. E.g. '2020-01-01T20:07:00Z'",
+			Usage: "The time filter may contain either RFC3339 values, Unix timestamps in seconds or milliseconds or relative time expressions. E.g. '2020-01-01T20:07:00Z', '1577909220', 'now-1h'",
 		},
 		&cli.StringFlag{
 			Name:  vmNativeStepInterval,
-			Usage: fmt.Sprintf("Split export data into chunks. Requires setting --%s. Valid values are '%s','%s','%s','%s'.", vmNativeFilterTimeStart, stepper.StepMonth, stepper.StepDay, stepper.StepHour, stepper.StepMinute),
+			Usage: fmt.Sprintf("Split export data into chunks. Requires setting --%s. Valid values are '%s','%s','%s','%s','%s','%s'.", vmNativeFilterTimeStart, stepper.StepMonth, stepper.StepDay, stepper.StepHour, stepper.StepMinute, stepper.StepCalendarMonth, stepper.StepWeek),
+		},
+		&cli.StringFlag{
+			Name: vmNativeChunkOrder,
+			Usage: fmt.Sprintf("The order of migrating time ranges of every metric split via --%s. Valid values are 'asc', 'desc'.", vmNativeStepInterval) +
+				" Use 'desc' for migrating the newest data first, so the most recent data is migrated if the migration is interrupted",
+			Value: "asc",
+		},
+		&cli.DurationFlag{
+			Name: vmNativeChunkOverlap,
+			Usage: fmt.Sprintf("Optional duration to extend the end of every time range split via --%s by, so adjacent ranges overlap.", vmNativeStepInterval) +
+				" Samples in the overlapping windows are migrated twice and are deduplicated by the destination. See https://docs.victoriametrics.com/vmctl.html#using-time-based-chunking-of-migration",
 		},
 		&cli.BoolFlag{
 			Name:  vmNativeDisableHTTPKeepAlive,
 			Usage: "Disable HTTP persistent connections for requests made to VictoriaMetrics components during export",
 			Value: false,
 		},
+		&cli.DurationFlag{
+			Name: vmNativeHTTPTimeout,
+			Usage: "Optional timeout for requests to VictoriaMetrics components. Requests such as metrics and tenants discovery must finish within the timeout,\n" +
+				" while streaming export and import requests fail and are retried if no data is transferred between them during the timeout. Zero disables the timeout.",
+		},
+		&cli.IntFlag{
+			Name: vmNativeConnectionsPerDst,
+			Usage: "Optional number of connections to the destination host shared by all the workers. Connections are kept open and reused between requests.\n" +
+				fmt.Sprintf(" Requests wait for a free connection if all of them are busy, so values lower than --%s limit the import concurrency.", vmConcurrency) +
+				" By default, every request uses a new connection.",
+		},
+		&cli.IntFlag{
+			Name: vmNativeMaxIdleConns,
+			Usage: "Optional number of idle connections per source and destination host kept open for reuse by the workers.\n" +
+				fmt.Sprintf(" Set it to --%s or higher for reducing connection churn on high-concurrency migrations.", vmConcurrency) +
+				" See https://docs.victoriametrics.com/vmctl.html#tuning-connections",
+		},
+		&cli.IntFlag{
+			Name: vmNativeMaxConnsPerHost,
+			Usage: "Optional limit on the number of connections per source and destination host. Requests wait for a free connection if all of them are busy.\n" +
+				fmt.Sprintf(" It can't be used together with --%s. Zero means no limit.", vmNativeConnectionsPerDst),
+		},
+		&cli.BoolFlag{
+			Name: vmNativeForceHTTP2,
+			Usage: "Whether to use HTTP/2 for TLS connections to source and destination, so concurrent requests are multiplexed over a few connections.\n" +
+				" HTTP/2 is negotiated via TLS, so it has no effect for plain HTTP addresses.",
+		},
+		&cli.IntFlag{
+			Name: vmNativeExploreMatchLimit,
+			Usage: "Optional limit on the number of series returned by a single discovery request. If set, discovery is split into multiple requests\n" +
+				" by metric name prefix, and requests reaching the limit are split further. This bounds the size of discovery responses on sources with huge number of series.\n" +
+				" By default, metrics are discovered via a single request.",
+		},
+		&cli.StringFlag{
+			Name: vmNativeSuccessFile,
+			Usage: "Optional path for writing JSON marker with final stats, timestamps and hash of migration parameters on fully successful migration.\n" +
+				" The marker is written atomically. It is removed at the start of every run, so it is absent if the migration fails or is incomplete.",
+		},
+		&cli.DurationFlag{
+			Name: vmNativeRequestTimeout,
+			Usage: "Optional limit on the duration of every attempt to migrate a time range of a metric. The limit is multiplied by\n" +
+				fmt.Sprintf(" --%s on every retry up to --%s, so slow time ranges get more time on later attempts. It applies together with --%s,", vmNativeRequestTimeoutFactor, vmNativeRequestTimeoutMax, vmNativeHTTPTimeout) +
+				" so stalled requests still fail fast. By default, there is no limit. See https://docs.victoriametrics.com/vmctl.html#continue-on-errors",
+		},
+		&cli.Float64Flag{
+			Name:  vmNativeRequestTimeoutFactor,
+			Usage: fmt.Sprintf("Multiplier of --%s on every retry. Must be greater or equal to 1", vmNativeRequestTimeout),
+			Value: 2,
+		},
+		&cli.DurationFlag{
+			Name:  vmNativeRequestTimeoutMax,
+			Usage: fmt.Sprintf("Optional cap for --%s escalated on retries. By default, the timeout isn't capped", vmNativeRequestTimeout),
+		},
+		&cli.DurationFlag{
+			Name: vmNativeMetricDeadline,
+			Usage: "Optional limit on the overall time of migrating a single metric. Once the limit is exceeded, in-flight requests for the metric are interrupted,\n" +
+				fmt.Sprintf(" while the remaining time ranges of the metric are skipped and recorded as failed requests, see --%s. By default, there is no limit.", vmNativeFailuresFile),
+		},
+		&cli.BoolFlag{
+			Name: vmNativeThrottleOnSource5xx,
+			Usage: "Whether to adaptively reduce the number of concurrent export requests when the source responds with 5xx status code.\n" +
+				fmt.Sprintf(" The concurrency is multiplied by --%s on 5xx response and is increased by one after --%s successful requests,", vmNativeThrottleReductionFactor, vmNativeThrottleRecoveryRate) +
+				fmt.Sprintf(" up to --%s.", vmConcurrency),
+		},
+		&cli.Float64Flag{
+			Name:  vmNativeThrottleReductionFactor,
+			Usage: fmt.Sprintf("Multiplier of the number of concurrent export requests on source 5xx response when --%s is set. Must be in range (0..1)", vmNativeThrottleOnSource5xx),
+			Value: 0.5,
+		},
+		&cli.IntFlag{
+			Name:  vmNativeThrottleRecoveryRate,
+			Usage: fmt.Sprintf("The number of successful export requests needed for increasing the concurrency reduced because of --%s by one", vmNativeThrottleOnSource5xx),
+			Value: 10,
+		},
+		&cli.BoolFlag{
+			Name: vmNativeDisableRedirects,
+			Usage: "Whether to deny following HTTP redirects from source and destination. By default, redirects for export and discovery\n" +
+				" requests are followed, while redirects for streaming import requests result in error, since they can't be safely replayed.",
+			Value: false,
+		},
+		&cli.IntFlag{
+			Name:  vmNativeMaxRedirects,
+			Usage: "Maximum number of HTTP redirects to follow for a single request to source or destination",
+			Value: 10,
+		},
+		&cli.IntFlag{
+			Name: vmNativeIntraUnitParallelism,
+			Usage: "Number of sub-ranges each (metric, time range) request is split into for concurrent export and import.\n" +
+				" Every sub-range uses its own pair of export and import requests. It may help to saturate fast networks\n" +
+				" when migrating heavy metrics. The number of in-flight requests is multiplied by this value.\n" +
+				" On retry, only the sub-ranges which failed to migrate are exported and imported again.",
+			Value: 1,
+		},
+		&cli.IntFlag{
+			Name: vmNativeRetrySubRanges,
+			Usage: "Number of sub-ranges each (metric, time range) request is split into for sequential export and import.\n" +
+				" On retry, the migration of the request is resumed from the first sub-range which failed to migrate,\n" +
+				" so already migrated sub-ranges aren't transferred again. It is useful for heavy requests over unreliable networks." +
+				" See https://docs.victoriametrics.com/vmctl.html#continue-on-errors",
+			Value: 1,
+		},
+		&cli.Int64Flag{
+			Name: vmNativeAutoSplitBytes,
+			Usage: "Optional max size in bytes of data exported by a single (metric, time range) request. Up to this size of exported data is written to a temporary file before import,\n" +
+				fmt.Sprintf(" which is created at --%s if set.", vmNativeDstBufferDir) +
+				" If the export exceeds the limit, its time range is split in half and every half is exported and imported separately, recursively.\n" +
+				" Zero means no limit. See https://docs.victoriametrics.com/vmctl.html#splitting-large-exports",
+		},
+		&cli.IntFlag{
+			Name: vmNativeBatchSize,
+			Usage: "Max number of (metric, time range) requests coalesced into a single import request.\n" +
+				" Data of coalesced requests is still exported one by one, while the import is made via a single request per batch.\n" +
+				" It reduces the number of import requests when migrating many small metrics. The whole batch is retried on failure." +
+				" See https://docs.victoriametrics.com/vmctl.html#batching-import-requests",
+			Value: 1,
+		},
+		&cli.Int64Flag{
+			Name: vmNativeBatchMaxBytes,
+			Usage: fmt.Sprintf("Optional max size in bytes of a single import request coalescing requests via --%s.", vmNativeBatchSize) +
+				" Once the limit is exceeded, the rest of the batch is imported via a new request. Zero means no limit.",
+		},
 		&cli.StringFlag{
+			Name: vmNativeExportFormat,
+			Usage: fmt.Sprintf("Optional version of native format to request from source and to expect at destination. Supported values: %s.\n", strings.Join(native.SupportedFormats, ", ")) +
+				" By default, the format negotiated by src and dst is used. The support of the chosen version is verified according to the version of src and dst before migration.",
+		},
+		&cli.DurationFlag{
+			Name: vmNativeMaxClockSkew,
+			Usage: "Maximum allowed clock skew between vmctl and source or destination. Clock skew is measured before the migration\n" +
+				" via Date header of HTTP responses. Exceeding the skew results in a warning, since it may lead to missing recent data.\n" +
+				" Zero value disables the warning.",
+			Value: 10 * time.Second,
+		},
+		&cli.BoolFlag{
+			Name: vmNativeYes,
+			Usage: fmt.Sprintf("Whether to confirm all the prompts automatically. Unlike -%s flag, progress bars, transfer estimates and stats are still printed,", globalSilent) +
+				" so unattended migrations remain observable. See https://docs.victoriametrics.com/vmctl.html#silent-mode",
+		},
+		&cli.BoolFlag{
+			Name: vmNativeSkipProbe,
+			Usage: "Whether to skip checking that source and destination are reachable and accept the configured credentials before the migration starts.\n" +
+				" The check may be skipped for sources and destinations without /api/v1/status/buildinfo API, e.g. for old versions or custom proxies",
+		},
+		&cli.StringSliceFlag{
 			Name: vmNativeSrcAddr,
 			Usage: "VictoriaMetrics address to perform export from. \n" +
 				" Should be the same as --httpListenAddr value for single-node version or vmselect component." +
-				" If exporting from cluster version see https://docs.victoriametrics.com/Cluster-VictoriaMetrics.html#url-format",
-			Required: true,
+				" If exporting from cluster version see https://docs.victoriametrics.com/Cluster-VictoriaMetrics.html#url-format. \n" +
+				" The flag can be set multiple times for migrating from multiple sources into a single destination concurrently." +
+				" See https://docs.victoriametrics.com/vmctl.html#migrating-from-multiple-sources",
+		},
+		&cli.StringFlag{
+			Name: vmNativeSrcFile,
+			Usage: "Optional path to the file with data in native format, e.g. saved from /api/v1/export/native, to import instead of exporting from --vm-native-src-addr." +
+				" Discovery of metrics and tenants is skipped in this mode, and the file is streamed into the destination as is." +
+				" See https://docs.victoriametrics.com/vmctl.html#importing-from-native-file",
+		},
+		&cli.StringSliceFlag{
+			Name: vmNativeSrcExtraLabel,
+			Usage: "Extra label in form 'label=value' to add to all the series migrated from the corresponding --vm-native-src-addr." +
+				" If set, the flag must be set the same number of times as --vm-native-src-addr, in the same order." +
+				" Use it for distinguishing series with identical labels from different sources at destination",
 		},
 		&cli.StringFlag{
 			Name:    vmNativeSrcUser,
@@ -394,12 +786,82 @@ var (
 			Name:  vmNativeSrcBearerToken,
 			Usage: "Optional bearer auth token to use for the corresponding `--vm-native-src-addr`",
 		},
+		&cli.StringFlag{
+			Name:  vmNativeSrcUserFile,
+			Usage: fmt.Sprintf("Optional path to file with username for basic auth at `--vm-native-src-addr`. It takes precedence over --%s", vmNativeSrcUser),
+		},
+		&cli.StringFlag{
+			Name:  vmNativeSrcPasswordFile,
+			Usage: fmt.Sprintf("Optional path to file with password for basic auth at `--vm-native-src-addr`. It takes precedence over --%s", vmNativeSrcPassword),
+		},
+		&cli.StringFlag{
+			Name:  vmNativeSrcBearerTokenFile,
+			Usage: fmt.Sprintf("Optional path to file with bearer auth token for `--vm-native-src-addr`. It takes precedence over --%s", vmNativeSrcBearerToken),
+		},
+		&cli.StringFlag{
+			Name:  vmNativeSrcCertFile,
+			Usage: "Optional path to client-side TLS certificate file to use when connecting to `--vm-native-src-addr`",
+		},
+		&cli.StringFlag{
+			Name:  vmNativeSrcKeyFile,
+			Usage: "Optional path to client-side TLS key to use when connecting to `--vm-native-src-addr`",
+		},
+		&cli.StringFlag{
+			Name:  vmNativeSrcCAFile,
+			Usage: "Optional path to TLS CA file to use for verifying connections to `--vm-native-src-addr`. By default, system CA is used",
+		},
+		&cli.StringFlag{
+			Name:  vmNativeSrcServerName,
+			Usage: "Optional TLS server name to use for connections to `--vm-native-src-addr`. By default, the server name from `--vm-native-src-addr` is used",
+		},
+		&cli.BoolFlag{
+			Name:  vmNativeSrcInsecureSkipVerify,
+			Usage: "Whether to skip TLS certificate verification when connecting to `--vm-native-src-addr`",
+			Value: false,
+		},
 		&cli.StringFlag{
 			Name: vmNativeDstAddr,
 			Usage: "VictoriaMetrics address to perform import to. \n" +
 				" Should be the same as --httpListenAddr value for single-node version or vminsert component." +
 				" If importing into cluster version see https://docs.victoriametrics.com/Cluster-VictoriaMetrics.html#url-format",
-			Required: true,
+		},
+		&cli.StringFlag{
+			Name: vmNativeSrcExportPath,
+			Usage: fmt.Sprintf("Path of export API at --%s. It may be changed if the source is behind a path-rewriting proxy.", vmNativeSrcAddr) +
+				fmt.Sprintf(" In --%s mode the path is appended to /select/<tenant>/prometheus/.", vmInterCluster) +
+				fmt.Sprintf(" Defaults to %s or to %s for --%s=%s", nativeExportAddr, jsonlExportAddr, vmNativeTransferFormat, transferFormatJSONL),
+		},
+		&cli.StringFlag{
+			Name: vmNativeDstImportPath,
+			Usage: fmt.Sprintf("Path of import API at --%s. It may be changed if the destination is behind a path-rewriting proxy.", vmNativeDstAddr) +
+				fmt.Sprintf(" In --%s mode the path is appended to /insert/<tenant>/prometheus/.", vmInterCluster) +
+				fmt.Sprintf(" Defaults to %s or to %s for --%s=%s", nativeImportAddr, jsonlImportAddr, vmNativeTransferFormat, transferFormatJSONL),
+		},
+		&cli.StringFlag{
+			Name: vmNativeTransferFormat,
+			Usage: fmt.Sprintf("Format of data transferred from source to destination. Supported values: %s, %s.\n", transferFormatNative, transferFormatJSONL) +
+				fmt.Sprintf(" '%s' transfers data via JSON line export and import APIs, so exported data can be inspected, e.g. with --%s.", transferFormatJSONL, vmNativeDstFile) +
+				" It is slower than native format. See https://docs.victoriametrics.com/vmctl.html#json-line-format",
+			Value: transferFormatNative,
+		},
+		&cli.IntFlag{
+			Name: vmNativeExportMaxRowsPerLine,
+			Usage: "Optional max number of samples per line of JSON line export, so the source doesn't materialize too many samples per series at once.\n" +
+				fmt.Sprintf(" It bounds memory usage of sources with tight memory limits. Requires --%s=%s, since native export doesn't support it.", vmNativeTransferFormat, transferFormatJSONL) +
+				" See https://docs.victoriametrics.com/vmctl.html#json-line-format",
+		},
+		&cli.StringFlag{
+			Name: vmNativeDstFile,
+			Usage: "Optional path template of local files to write exported data to instead of importing it into --vm-native-dst-addr." +
+				" Every request is written into a separate file, so the template must contain {tenant}, {metric}, {bucket}, {start} and {end} placeholders" +
+				" needed for making file names unique. See https://docs.victoriametrics.com/vmctl.html#exporting-to-native-files",
+		},
+		&cli.BoolFlag{
+			Name: vmNativeDstRemoteWrite,
+			Usage: fmt.Sprintf("Whether to send data to --%s via Prometheus remote write protocol instead of VictoriaMetrics native import. ", vmNativeDstAddr) +
+				fmt.Sprintf("In this mode --%s must contain the full URL of remote write endpoint, e.g. http://prometheus:9090/api/v1/write.\n", vmNativeDstAddr) +
+				" Exported blocks are decoded and re-encoded into snappy-compressed remote write requests, which increases CPU usage." +
+				" See https://docs.victoriametrics.com/vmctl.html#migrating-to-remote-write-destinations",
 		},
 		&cli.StringFlag{
 			Name:    vmNativeDstUser,
@@ -421,17 +883,463 @@ var (
 			Name:  vmNativeDstBearerToken,
 			Usage: "Optional bearer auth token to use for the corresponding `--vm-native-dst-addr`",
 		},
+		&cli.StringFlag{
+			Name:  vmNativeDstUserFile,
+			Usage: fmt.Sprintf("Optional path to file with username for basic auth at `--vm-native-dst-addr`. It takes precedence over --%s", vmNativeDstUser),
+		},
+		&cli.StringFlag{
+			Name:  vmNativeDstPasswordFile,
+			Usage: fmt.Sprintf("Optional path to file with password for basic auth at `--vm-native-dst-addr`. It takes precedence over --%s", vmNativeDstPassword),
+		},
+		&cli.StringFlag{
+			Name:  vmNativeDstBearerTokenFile,
+			Usage: fmt.Sprintf("Optional path to file with bearer auth token for `--vm-native-dst-addr`. It takes precedence over --%s", vmNativeDstBearerToken),
+		},
+		&cli.StringFlag{
+			Name:  vmNativeDstCertFile,
+			Usage: "Optional path to client-side TLS certificate file to use when connecting to `--vm-native-dst-addr`",
+		},
+		&cli.StringFlag{
+			Name:  vmNativeDstKeyFile,
+			Usage: "Optional path to client-side TLS key to use when connecting to `--vm-native-dst-addr`",
+		},
+		&cli.StringFlag{
+			Name:  vmNativeDstCAFile,
+			Usage: "Optional path to TLS CA file to use for verifying connections to `--vm-native-dst-addr`. By default, system CA is used",
+		},
+		&cli.StringFlag{
+			Name:  vmNativeDstServerName,
+			Usage: "Optional TLS server name to use for connections to `--vm-native-dst-addr`. By default, the server name from `--vm-native-dst-addr` is used",
+		},
+		&cli.BoolFlag{
+			Name:  vmNativeDstInsecureSkipVerify,
+			Usage: "Whether to skip TLS certificate verification when connecting to `--vm-native-dst-addr`",
+			Value: false,
+		},
 		&cli.StringSliceFlag{
 			Name:  vmExtraLabel,
 			Value: nil,
 			Usage: "Extra labels, that will be added to imported timeseries. In case of collision, label value defined by flag" +
 				"will have priority. Flag can be set multiple times, to add few additional labels.",
 		},
+		&cli.BoolFlag{
+			Name: vmNativeContinueOnError,
+			Usage: "Whether to continue the migration when a request fails after all the retry attempts.\n" +
+				fmt.Sprintf(" Failed requests are retried at the end according to --%s and reported into --%s.", vmNativeRetryFailedUnitsAtEnd, vmNativeFailuresFile) +
+				" vmctl exits with non-zero code if failed requests remain.",
+		},
+		&cli.StringFlag{
+			Name: vmNativeFailuresFile,
+			Usage: "Optional path to the file for writing requests failed to migrate. Every failed request is written as JSON line with tenant, metric, match, start, end and error fields." +
+				" Requests are appended to the file as soon as they fail, and the file is rewritten with requests still failing at the end of migration.",
+		},
+		&cli.IntFlag{
+			Name: vmNativeRetryFailedUnitsAtEnd,
+			Usage: fmt.Sprintf("Number of retry passes over failed requests after all other requests were processed. Requires --%s.\n", vmNativeContinueOnError) +
+				" Transient issues often resolve by the end of migration, so this improves overall success rate without blocking early progress.",
+		},
+		&cli.DurationFlag{
+			Name:  vmNativeRetryFailedUnitsDelay,
+			Usage: fmt.Sprintf("Delay before every retry pass over failed requests. See --%s", vmNativeRetryFailedUnitsAtEnd),
+			Value: 10 * time.Second,
+		},
+		&cli.StringFlag{
+			Name: vmNativeDstBufferDir,
+			Usage: "Optional path to the directory for spooling exported data on disk before importing it to destination.\n" +
+				" It decouples export and import speeds when destination is slower than source without buffering data in memory.\n" +
+				" Spool files are removed once the corresponding request is processed.",
+		},
+		&cli.Int64Flag{
+			Name:  vmNativeDstBufferMaxSize,
+			Usage: fmt.Sprintf("Maximum total size in bytes of spool files at --%s. Requests exceeding the limit fail and are retried. Zero means no limit.", vmNativeDstBufferDir),
+		},
+		&cli.StringFlag{
+			Name: vmNativeStickyRouteBy,
+			Usage: fmt.Sprintf("Optional routing of import requests via write-path load balancer in front of destination. Supported values: %q.\n", stickyRouteByMetric) +
+				" If set to 'metric', every import request contains a routing key derived from the metric name hash,\n" +
+				" so the balancer with consistent hashing by this key sends all the data for a given metric to the same backend.",
+		},
+		&cli.StringFlag{
+			Name:  vmNativeStickyRouteVia,
+			Usage: fmt.Sprintf("Mechanism of passing the routing key. Supported values: %q, %q. See --%s", stickyRouteViaHeader, stickyRouteViaQuery, vmNativeStickyRouteBy),
+			Value: stickyRouteViaHeader,
+		},
+		&cli.StringFlag{
+			Name: vmNativeStickyRouteKey,
+			Usage: fmt.Sprintf("Name of the header or query arg for passing the routing key. See --%s.\n", vmNativeStickyRouteBy) +
+				fmt.Sprintf(" Defaults to %q for header and to %q for query arg.", defaultStickyRouteHeader, defaultStickyRouteQueryArg),
+		},
+		&cli.StringFlag{
+			Name: vmNativeOnDuplicateTS,
+			Usage: fmt.Sprintf("Defines how to handle samples with duplicate timestamps within exported blocks. Supported values: %q, %q, %q.\n", onDuplicateTSKeep, onDuplicateTSWarn, onDuplicateTSCollapse) +
+				" 'keep' passes data as is; 'warn' reports affected series; 'collapse' leaves only the last sample per timestamp.\n" +
+				" 'warn' and 'collapse' require decoding of exported blocks, which increases CPU usage.",
+			Value: onDuplicateTSKeep,
+		},
+		&cli.BoolFlag{
+			Name: vmNativeDedupStream,
+			Usage: "Whether to drop samples with timestamps already seen for the series across exported blocks of every request,\n" +
+				" e.g. when migrating data from sources with overlapping time ranges. Dedup is best-effort and requires decoding of exported blocks,\n" +
+				" which increases CPU and memory usage. See https://docs.victoriametrics.com/vmctl.html#duplicate-timestamps",
+		},
+		&cli.StringFlag{
+			Name: vmNativeNonFinite,
+			Usage: fmt.Sprintf("Defines how to handle samples with NaN and Inf values within exported blocks. Supported values: %q, %q.\n", nonFiniteKeep, nonFiniteDrop) +
+				" 'keep' passes data as is; 'drop' removes such samples except of staleness markers.\n" +
+				" 'drop' requires decoding of exported blocks, which increases CPU usage.",
+			Value: nonFiniteKeep,
+		},
+		&cli.StringSliceFlag{
+			Name: vmNativeStampLabel,
+			Usage: "Optional label in `name=value` format to add to every migrated series, e.g. 'source_cluster=east'. Flag can be set multiple times.\n" +
+				fmt.Sprintf(" Unlike --%s, labels are added to the exported data itself, so they are present for any destination.", vmExtraLabel) +
+				" It requires decoding of exported blocks, which increases CPU usage. See https://docs.victoriametrics.com/vmctl.html#stamping-labels",
+		},
+		&cli.StringFlag{
+			Name: vmNativeStampLabelConflict,
+			Usage: fmt.Sprintf("Defines how to handle series already having a label from --%s with another value. Supported values: %q, %q.\n", vmNativeStampLabel, stampLabelConflictOverwrite, stampLabelConflictError) +
+				" 'overwrite' replaces the value; 'error' fails the request for the conflicting series.",
+			Value: stampLabelConflictOverwrite,
+		},
+		&cli.StringSliceFlag{
+			Name: vmNativeValueScale,
+			Usage: "Optional transformation of sample values in `metricRegex:factor[:offset]` format, e.g. 'node_network_.+_bytes_total:8' for converting bytes to bits.\n" +
+				" Values of metrics with names matching the regex are replaced with 'value*factor+offset'. Flag can be set multiple times; the first matching rule is applied.\n" +
+				" Transformation requires decoding of exported blocks, which increases CPU usage.",
+		},
+		&cli.StringFlag{
+			Name: vmNativeDownsample,
+			Usage: "Optional aggregation of samples of every series over the interval before import in `interval[:func]` format, e.g. '5m' or '1h:max'.\n" +
+				" Supported functions: 'avg' (default), 'min', 'max', 'last'. Intervals are aligned to Unix epoch and intervals without samples are skipped.\n" +
+				" Downsampling requires decoding of exported blocks, which increases CPU usage. See https://docs.victoriametrics.com/vmctl.html#downsampling",
+		},
+		&cli.StringFlag{
+			Name: vmNativeRelabelConfig,
+			Usage: "Optional path to a file with relabeling rules in Prometheus relabel_configs format applied to exported series before import,\n" +
+				" e.g. for renaming metrics. Series without metric name after relabeling are dropped.\n" +
+				" Relabeling requires decoding of exported blocks, which increases CPU usage. See https://docs.victoriametrics.com/vmctl.html#relabeling-series",
+		},
+		&cli.IntFlag{
+			Name: vmNativeMaxMetrics,
+			Usage: "Optional limit on the number of metrics discovered for migration per tenant. If exceeded,\n" +
+				" vmctl asks for confirmation or aborts the migration in silent mode. It protects from accidental migrations\n" +
+				fmt.Sprintf(" caused by too broad --%s filter. Zero means no limit.", vmNativeFilterMatch),
+		},
+		&cli.IntFlag{
+			Name: vmNativeEstimateMetrics,
+			Usage: "The number of randomly chosen metrics exported for estimating the transfer size and ETA shown in the confirmation prompt.\n" +
+				" Only the last 10 minutes of the time range are exported for every chosen metric. Zero disables the estimation." +
+				" See https://docs.victoriametrics.com/vmctl.html#estimating-transfer-size",
+			Value: 5,
+		},
+		&cli.IntFlag{
+			Name: vmNativeVerifyPerMetric,
+			Usage: "Optional number of randomly sampled points to verify for every metric right after all its requests are migrated.\n" +
+				" Points are sampled from the last time range of the metric at source and compared with the destination. Zero disables verification.",
+		},
+		&cli.BoolFlag{
+			Name:  vmNativeVerifyReimport,
+			Usage: fmt.Sprintf("Whether to re-migrate the metric once if its verification fails. See --%s", vmNativeVerifyPerMetric),
+		},
+		&cli.BoolFlag{
+			Name: vmNativeVerifyCounts,
+			Usage: "Whether to compare the number of samples of every migrated metric and time range between source and destination after the migration.\n" +
+				fmt.Sprintf(" Requests with mismatching counts are reported as failed and recorded in --%s", vmNativeFailuresFile),
+		},
+		&cli.BoolFlag{
+			Name: vmNativeSkipExisting,
+			Usage: "Whether to skip migration of a metric for a time range if the destination already contains samples for it in this range.\n" +
+				fmt.Sprintf(" Every request is preceded by a query to the destination. See also --%s", vmNativeVerifyAddr),
+		},
+		&cli.StringFlag{
+			Name: vmNativeVerifyAddr,
+			Usage: fmt.Sprintf("Optional VictoriaMetrics address for reading data from the destination during verification, warmup and skipping of existing data. See --%s, --%s, --%s and --%s.\n", vmNativeVerifyPerMetric, vmNativeVerifyCounts, vmNativeWarmupQuery, vmNativeSkipExisting) +
+				fmt.Sprintf(" Defaults to --%s. Must be set to vmselect address if the destination is the cluster version.", vmNativeDstAddr),
+		},
+		&cli.StringFlag{
+			Name: vmNativeDstTenantFromLabel,
+			Usage: "Optional label name containing destination tenant in `accountID` or `accountID:projectID` format for every series.\n" +
+				fmt.Sprintf(" If set, series are imported into the corresponding tenants of the cluster version at --%s, ", vmNativeDstAddr) +
+				"which must be vminsert address without tenant in the path. It requires decoding of exported blocks, which increases CPU usage.",
+		},
+		&cli.StringFlag{
+			Name: vmNativeDstTenant,
+			Usage: fmt.Sprintf("Optional tenant in `accountID` or `accountID:projectID` format to import all the migrated tenants into in --%s mode.", vmInterCluster) +
+				" By default, every tenant is imported into the same tenant at the destination. See https://docs.victoriametrics.com/vmctl.html#cluster-to-cluster-migration-mode",
+		},
+		&cli.StringFlag{
+			Name: vmNativeDstTenantMap,
+			Usage: fmt.Sprintf("Optional path to JSON file mapping source tenants to destination tenants in --%s mode, e.g. {\"1:0\": \"0:0\", \"2:0\": \"0:0\"}.", vmInterCluster) +
+				" Tenants without mapping are imported into the same tenant at the destination. See https://docs.victoriametrics.com/vmctl.html#cluster-to-cluster-migration-mode",
+		},
+		&cli.BoolFlag{
+			Name:  vmNativeDstTenantStripLabel,
+			Usage: fmt.Sprintf("Whether to remove the label defined via --%s from imported series", vmNativeDstTenantFromLabel),
+		},
+		&cli.StringFlag{
+			Name:  vmNativeDstTenantDefault,
+			Usage: fmt.Sprintf("Destination tenant for series without the label defined via --%s or with invalid tenant in it", vmNativeDstTenantFromLabel),
+			Value: "0",
+		},
+		&cli.IntFlag{
+			Name: vmNativeTenantConcurrency,
+			Usage: fmt.Sprintf("Number of tenants migrated concurrently in --%s mode. ", vmInterCluster) +
+				fmt.Sprintf("The total number of concurrent requests across all tenants is still limited by --%s.", vmConcurrency),
+			Value: 1,
+		},
+		&cli.IntFlag{
+			Name: vmNativeImportConcurrencyPerTenant,
+			Usage: fmt.Sprintf("Maximum number of concurrent requests per tenant when --%s is greater than 1. ", vmNativeTenantConcurrency) +
+				"It prevents a big tenant from occupying all the workers.\n" +
+				fmt.Sprintf(" By default, --%s is split evenly between concurrently migrated tenants.", vmConcurrency),
+		},
+		&cli.BoolFlag{
+			Name: vmNativeOverallProgressBar,
+			Usage: fmt.Sprintf("Whether to show a single progress bar for all the tenants in --%s mode instead of a progress bar per tenant. ", vmInterCluster) +
+				fmt.Sprintf("It is also shown when tenants are migrated concurrently via --%s", vmNativeTenantConcurrency),
+		},
+		&cli.StringFlag{
+			Name: vmNativeBarStyle,
+			Usage: fmt.Sprintf("Style of progress bars. Supported values: '%s', '%s' for terminals and CI consoles with broken unicode rendering, ", barStyleUnicode, barStyleASCII) +
+				fmt.Sprintf("'%s' for disabling progress bars while keeping the rest of the output, ", barStyleNone) +
+				"or a custom progress bar template, e.g. '{{ string . \"prefix\" }}: {{ counters . }} {{ percent . }}'." +
+				" See https://docs.victoriametrics.com/vmctl.html#progress-bar-style",
+			Value: barStyleUnicode,
+		},
+		&cli.BoolFlag{
+			Name: vmNativeConcurrencyAuto,
+			Usage: fmt.Sprintf("Whether to adjust the number of concurrent requests automatically between --%s and --%s ", vmNativeConcurrencyMin, vmNativeConcurrencyMax) +
+				fmt.Sprintf("according to the observed throughput instead of using --%s.\n", vmConcurrency) +
+				fmt.Sprintf(" Concurrency starts at --%s and is increased while it improves the throughput, ", vmNativeConcurrencyMin) +
+				fmt.Sprintf("and is halved once the rate of retried requests exceeds --%s", vmNativeConcurrencyMaxRetryRate),
+		},
+		&cli.IntFlag{
+			Name:  vmNativeConcurrencyMin,
+			Usage: fmt.Sprintf("The minimum number of concurrent requests for --%s", vmNativeConcurrencyAuto),
+			Value: 1,
+		},
+		&cli.IntFlag{
+			Name:  vmNativeConcurrencyMax,
+			Usage: fmt.Sprintf("The maximum number of concurrent requests for --%s", vmNativeConcurrencyAuto),
+			Value: 16,
+		},
+		&cli.Float64Flag{
+			Name: vmNativeConcurrencyMaxRetryRate,
+			Usage: fmt.Sprintf("The maximum ratio of retried requests to all the requests for --%s. ", vmNativeConcurrencyAuto) +
+				"Concurrency is halved if the ratio is exceeded during the adjustment interval",
+			Value: 0.05,
+		},
+		&cli.StringFlag{
+			Name: vmNativePlanOut,
+			Usage: "Optional path for writing migration plan with discovered tenants, metrics, time ranges and estimated number of requests.\n" +
+				fmt.Sprintf(" vmctl exits after writing the plan without migrating data. The plan can be reviewed and executed via --%s.", vmNativePlanIn),
+		},
+		&cli.StringFlag{
+			Name: vmNativePlanIn,
+			Usage: fmt.Sprintf("Optional path to migration plan created via --%s. Only tenants and metrics from the plan are migrated.\n", vmNativePlanOut) +
+				" vmctl must be run with the same source, destination and filter settings as during the plan creation.\n" +
+				fmt.Sprintf(" Migration fails if discovered tenants or metrics differ from the plan unless --%s is set.", vmNativePlanForce),
+		},
+		&cli.BoolFlag{
+			Name:  vmNativePlanForce,
+			Usage: fmt.Sprintf("Whether to execute the plan from --%s even if the current source state doesn't match it", vmNativePlanIn),
+		},
+		&cli.BoolFlag{
+			Name: vmNativeWaitDurable,
+			Usage: "Whether to confirm persistence of imported data before marking the request as completed. If set, vmctl calls /internal/force_flush\n" +
+				fmt.Sprintf(" at --%s after every import request and marks the request as done in --%s only after --%s.", vmNativeWaitDurableAddr, vmNativeStateFile, vmNativeWaitDurableDelay) +
+				" It reduces import throughput.",
+		},
+		&cli.StringSliceFlag{
+			Name: vmNativeWaitDurableAddr,
+			Usage: fmt.Sprintf("Addresses of single-node VictoriaMetrics or vmstorage nodes for confirming persistence of data via --%s and for flushing data via --%s. ", vmNativeWaitDurable, vmNativeFlushOnFinish) +
+				fmt.Sprintf("Defaults to --%s for single-node destination. Must be set to all the vmstorage nodes in --%s mode.", vmNativeDstAddr, vmInterCluster),
+		},
+		&cli.DurationFlag{
+			Name:  vmNativeWaitDurableDelay,
+			Usage: fmt.Sprintf("Time needed by the destination for saving flushed data to disk when --%s is set. Must be not less than -inmemoryDataFlushInterval at the destination.", vmNativeWaitDurable),
+			Value: 5 * time.Second,
+		},
+		&cli.StringFlag{
+			Name:  vmNativeWaitDurableAuthKey,
+			Usage: fmt.Sprintf("Optional auth key for /internal/force_flush requests made by --%s and --%s. Must match -forceFlushAuthKey at the destination.", vmNativeWaitDurable, vmNativeFlushOnFinish),
+		},
+		&cli.BoolFlag{
+			Name: vmNativeFlushOnFinish,
+			Usage: fmt.Sprintf("Whether to make the imported data searchable via /internal/force_flush requests to --%s once the migration is finished,", vmNativeWaitDurableAddr) +
+				" so verification and queries right after the migration see all the data. Destinations without the handler are reported with warning." +
+				" See https://docs.victoriametrics.com/vmctl.html#verifying-migrated-metrics",
+		},
+		&cli.IntFlag{
+			Name: vmNativeAutoChunk,
+			Usage: "Optional target number of samples per request. If set, vmctl estimates the sample rate of every metric\n" +
+				" from the last 10 minutes of the migrated time range and splits the time range into chunks expected to contain the given number of samples.\n" +
+				fmt.Sprintf(" It overrides --%s for the metric. --%s is used if the sample rate can't be detected.", vmNativeStepInterval, vmNativeStepInterval),
+		},
+		&cli.StringSliceFlag{
+			Name: vmNativeWarmupQuery,
+			Usage: "Optional MetricsQL query to execute against the destination before the migration starts in order to warm up its caches.\n" +
+				fmt.Sprintf(" Flag can be set multiple times. Queries are sent to --%s. Failed queries are logged and don't stop the migration.", vmNativeVerifyAddr),
+		},
+		&cli.StringFlag{
+			Name: vmNativeOtelEndpoint,
+			Usage: "Optional OpenTelemetry collector endpoint for exporting migration traces via OTLP/HTTP protocol, e.g. http://otel-collector:4318.\n" +
+				" vmctl emits a root span for the migration and child spans per tenant, per request and per request attempt with export and import spans.",
+		},
+		&cli.StringFlag{
+			Name: vmNativeMetricsListenAddr,
+			Usage: "Optional TCP address for exposing migration progress metrics in Prometheus text exposition format at /metrics path, e.g. :8080.\n" +
+				" Metrics contain the number of transferred bytes, requests and retries, the index of the current tenant and the number of completed and total metrics.",
+		},
+		&cli.DurationFlag{
+			Name: vmNativeProgressInterval,
+			Usage: "Optional interval for printing a single-line progress summary with the number of completed and total metrics, transferred bytes and elapsed time to stdout.\n" +
+				fmt.Sprintf(" It is printed in --%s mode as well, so unattended runs without progress bars have a heartbeat in their output. Zero disables the summary.", globalSilent),
+		},
+		&cli.StringFlag{
+			Name: vmNativeChunkByLabel,
+			Usage: "Optional splitting of every metric export into buckets by the first character of the given label value in `name:buckets` format.\n" +
+				" For example, --vm-native-chunk-by-label=instance:8 results in 8 requests per metric and time range.\n" +
+				" It bounds the size of requests for metrics with high cardinality concentrated in a single label.",
+		},
+		&cli.StringFlag{
+			Name: vmNativeStateFile,
+			Usage: "Optional path to the file for persisting the list of successfully migrated requests.\n" +
+				" If the file exists on start, requests listed in it are skipped, so interrupted migration could be resumed.\n" +
+				fmt.Sprintf(" Make sure --%s is set explicitly, so time ranges match between runs.", vmNativeFilterTimeEnd),
+		},
+		&cli.StringFlag{
+			Name: vmNativeCheckpointInterval,
+			Usage: fmt.Sprintf("How often to persist --%s. Either the number of migrated requests, e.g. '1000', or a duration, e.g. '30s'.", vmNativeStateFile) +
+				" Migrated requests are buffered in memory in between and persisted on graceful shutdown." +
+				" Requests which weren't persisted are migrated again after crash. By default, the file is persisted after every migrated request",
+			Value: "1",
+		},
+		&cli.IntFlag{
+			Name:  vmNativeRetryMaxAttempts,
+			Usage: "The max number of attempts to migrate every request. Retries are stopped early on bad request errors",
+			Value: 5,
+		},
+		&cli.DurationFlag{
+			Name:  vmNativeRetryMinDelay,
+			Usage: "The delay before the first retry of a failed request. Every next delay is 1.7 times longer",
+			Value: time.Second,
+		},
+		&cli.DurationFlag{
+			Name:  vmNativeRetryMaxDelay,
+			Usage: "The max delay between retries of a failed request. Zero means no limit",
+		},
+		&cli.BoolFlag{
+			Name: vmNativeDryRun,
+			Usage: "Whether to discover metrics and split them into requests without migrating data." +
+				" The number of metrics and requests to make is printed instead. No data is written to the destination." +
+				" See https://docs.victoriametrics.com/vmctl.html#dry-run",
+		},
+		&cli.StringFlag{
+			Name: vmNativeDryRunOut,
+			Usage: fmt.Sprintf("Optional path for writing the list of requests discovered in --%s mode, one JSON object per line.", vmNativeDryRun) +
+				" The list is sorted, so lists of different runs could be compared via diff. Use '-' for writing the list to stdout",
+		},
+		&cli.IntFlag{
+			Name: vmNativeDryRunTopMetrics,
+			Usage: fmt.Sprintf("If set in --%s mode, the number of samples of every request is queried from the source via count_over_time,", vmNativeDryRun) +
+				" and the total number of samples is printed together with the given number of the heaviest metrics. No data is exported." +
+				" See https://docs.victoriametrics.com/vmctl.html#dry-run",
+		},
+		&cli.StringFlag{
+			Name: vmNativeStatsFormat,
+			Usage: "Format of the stats printed when the migration is finished. Supported values: 'text', 'json'." +
+				" In 'json' format the stats are printed to stdout as a single JSON object, so they could be parsed by scripts." +
+				" See https://docs.victoriametrics.com/vmctl.html#importer-stats",
+			Value: "text",
+		},
+		&cli.BoolFlag{
+			Name: vmNativeVerboseStats,
+			Usage: "Whether to print per-tenant stats and the histogram of request durations in the final stats even for a single tenant." +
+				" The breakdown is always printed if multiple tenants are migrated. See https://docs.victoriametrics.com/vmctl.html#importer-stats",
+		},
+		&cli.BoolFlag{
+			Name: vmNativeDigest,
+			Usage: "Whether to calculate sha256 digest of data written to the destination and include the digest per tenant into the final stats." +
+				" Data is hashed while being streamed, so it isn't buffered. See https://docs.victoriametrics.com/vmctl.html#digests-of-migrated-data",
+		},
+		&cli.BoolFlag{
+			Name: vmNativeExploreStream,
+			Usage: "Whether to start migrating metrics while they are discovered instead of waiting for the discovery to finish." +
+				" Only distinct metric names are kept in memory, so it reduces time to first import and memory usage on sources with huge number of series." +
+				" See https://docs.victoriametrics.com/vmctl.html#streaming-metrics-discovery",
+		},
+		&cli.BoolFlag{
+			Name: vmNativeSorted,
+			Usage: "Whether to migrate discovered metrics in the order of their names instead of random order." +
+				" It makes runs with the same flags reproducible, so resumed and budgeted runs continue with the same metrics." +
+				fmt.Sprintf(" Can't be used together with --%s. See https://docs.victoriametrics.com/vmctl.html#resuming-migration", vmNativeExploreStream),
+		},
+		&cli.StringFlag{
+			Name: vmNativeMetricNameFilter,
+			Usage: fmt.Sprintf("Optional regexp matching the whole name of discovered metrics to migrate, e.g. 'node_.*'. It is applied on the client side in addition to --%s.", vmNativeFilterMatch) +
+				" See https://docs.victoriametrics.com/vmctl.html#filtering-metric-names",
+		},
+		&cli.StringFlag{
+			Name: vmNativeMetricNameExclude,
+			Usage: fmt.Sprintf("Optional regexp matching the whole name of discovered metrics to skip, e.g. 'go_.*'. It is applied on the client side after --%s.", vmNativeMetricNameFilter) +
+				" See https://docs.victoriametrics.com/vmctl.html#filtering-metric-names",
+		},
+		&cli.Float64Flag{
+			Name: vmNativeSamplePercentage,
+			Usage: "Optional percentage in range (0..100] of discovered metrics to migrate, e.g. 1 for validating the migration pipeline on a representative subset of data.\n" +
+				fmt.Sprintf(" Metrics are selected by the hash of their names and --%s, so the same metrics are selected by repeated runs. Zero means all the metrics are migrated.", vmNativeSampleSeed) +
+				" See https://docs.victoriametrics.com/vmctl.html#sampling-metrics",
+		},
+		&cli.Uint64Flag{
+			Name:  vmNativeSampleSeed,
+			Usage: fmt.Sprintf("Seed for selecting metrics via --%s. Change it for selecting another subset of metrics.", vmNativeSamplePercentage),
+		},
+		&cli.BoolFlag{
+			Name: vmNativeRestart,
+			Usage: fmt.Sprintf("Whether to ignore the existing --%s and migrate all the requests from scratch.", vmNativeStateFile) +
+				" The file is overwritten once the new state is persisted for the first time",
+		},
+		&cli.Int64Flag{
+			Name: vmNativeMaxTotalBytes,
+			Usage: "Optional budget of bytes to transfer during the run. Once the budget is reached, no new requests are started,\n" +
+				fmt.Sprintf(" while in-flight requests are finished gracefully. Combine with --%s in order to resume the migration later. Zero means no limit.", vmNativeStateFile),
+		},
+		&cli.Int64Flag{
+			Name: vmNativeMaxTotalRequests,
+			Usage: "Optional budget of requests to start during the run. Once the budget is reached, no new requests are started,\n" +
+				fmt.Sprintf(" while in-flight requests are finished gracefully. Combine with --%s in order to resume the migration later. Zero means no limit.", vmNativeStateFile),
+		},
 		&cli.Int64Flag{
 			Name: vmRateLimit,
 			Usage: "Optional data transfer rate limit in bytes per second.\n" +
 				"By default the rate limit is disabled. It can be useful for limiting load on source or destination databases.",
 		},
+		&cli.IntFlag{
+			Name: vmNativeSrcQPS,
+			Usage: "Optional limit on the number of export and explore requests per second to the source.\n" +
+				fmt.Sprintf(" It protects sources with limited query slots. Applies together with --%s. Zero means no limit.", vmRateLimit),
+		},
+		&cli.Int64Flag{
+			Name: vmNativeGlobalRateLimit,
+			Usage: "Optional limit on the total data transfer rate in bytes per second shared between all the concurrent requests, tenants and sources.\n" +
+				fmt.Sprintf(" Unlike --%s, which limits every request, the total rate doesn't depend on --%s. Both limits apply if both flags are set. Zero means no limit.", vmRateLimit, vmConcurrency),
+		},
+		&cli.Int64Flag{
+			Name: vmNativeSrcRateLimit,
+			Usage: "Optional limit on the total rate of reading exported data from every source in bytes per second shared between all the concurrent export requests.\n" +
+				fmt.Sprintf(" It protects fragile sources from overload when the destination is fast. Applies together with --%s and --%s. Zero means no limit.", vmRateLimit, vmNativeGlobalRateLimit),
+		},
+		&cli.DurationFlag{
+			Name: vmNativeSlowStart,
+			Usage: fmt.Sprintf("Optional duration to ramp up write rate limits set via --%s and --%s from --%s fraction of the limits to the full limits.\n", vmRateLimit, vmNativeGlobalRateLimit, vmNativeSlowStartFraction) +
+				" The ramp up starts with the first request. It smooths the initial load spike at fragile destinations. See https://docs.victoriametrics.com/vmctl.html#slow-start",
+		},
+		&cli.Float64Flag{
+			Name:  vmNativeSlowStartFraction,
+			Usage: fmt.Sprintf("The fraction of write rate limits to start with if --%s is set. Must be in range (0..1]", vmNativeSlowStart),
+			Value: 0.1,
+		},
 		&cli.BoolFlag{
 			Name: vmInterCluster,
 			Usage: "Enables cluster-to-cluster migration mode with automatic tenants data migration.\n" +
@@ -443,6 +1351,42 @@ var (
 			Usage: "Number of workers concurrently performing import requests to VM",
 			Value: 2,
 		},
+		&cli.IntFlag{
+			Name: vmNativeDiscoveryConcurrency,
+			Usage: fmt.Sprintf("Number of tenants to explore concurrently before the migration starts in --%s mode.\n", vmInterCluster) +
+				fmt.Sprintf(" Discovery is query-heavy, while migration is bandwidth-heavy, so this flag is independent from --%s.", vmConcurrency),
+			Value: 1,
+		},
+		&cli.StringSliceFlag{
+			Name: vmNativeSrcTenants,
+			Usage: fmt.Sprintf("Optional comma-separated list of tenants to migrate in --%s mode, e.g. '0:0,1:0'.", vmInterCluster) +
+				" It is used only if the source doesn't support tenants discovery, e.g. for old cluster versions without tenants API." +
+				" See https://docs.victoriametrics.com/vmctl.html#cluster-to-cluster-migration-mode",
+		},
+		&cli.StringSliceFlag{
+			Name: vmNativeTenantFilter,
+			Usage: fmt.Sprintf("Optional list of tenants to migrate in --%s mode, e.g. '0:0,1:0'. Every item is either a tenant or a regexp matching the whole tenant,", vmInterCluster) +
+				" e.g. '1[0-9]:0'. Discovered tenants not matching any item are skipped. See https://docs.victoriametrics.com/vmctl.html#cluster-to-cluster-migration-mode",
+		},
+		&cli.BoolFlag{
+			Name: vmNativeSkipEmptyTenants,
+			Usage: fmt.Sprintf("Whether to skip tenants without metrics matching the filters in --%s mode with a warning instead of failing the migration.", vmInterCluster) +
+				" See https://docs.victoriametrics.com/vmctl.html#cluster-to-cluster-migration-mode",
+		},
+		&cli.StringFlag{
+			Name: vmNativeTenantsCacheFile,
+			Usage: fmt.Sprintf("Optional path to the file for caching tenants discovered in --%s mode. The cached tenants are reused by subsequent runs", vmInterCluster) +
+				fmt.Sprintf(" with the same --%s, --%s and --%s instead of discovering them again. See https://docs.victoriametrics.com/vmctl.html#cluster-to-cluster-migration-mode", vmNativeSrcAddr, vmNativeFilterTimeStart, vmNativeFilterTimeEnd),
+		},
+		&cli.DurationFlag{
+			Name:  vmNativeTenantsCacheTTL,
+			Usage: fmt.Sprintf("The duration after which tenants cached in --%s are discovered again. Zero means the cache never expires", vmNativeTenantsCacheFile),
+			Value: 24 * time.Hour,
+		},
+		&cli.BoolFlag{
+			Name:  vmNativeRefreshTenants,
+			Usage: fmt.Sprintf("Whether to discover tenants again and update --%s regardless of its age", vmNativeTenantsCacheFile),
+		},
 	}
 )
 
@@ -502,7 +1446,7 @@ var (
 		},
 		&cli.StringFlag{
 			Name:     remoteReadStepInterval,
-			Usage:    fmt.Sprintf("Split export data into chunks. Requires setting --%s. Valid values are %q,%q,%q,%q.", remoteReadFilterTimeStart, stepper.StepMonth, stepper.StepDay, stepper.StepHour, stepper.StepMinute),
+			Usage:    fmt.Sprintf("Split export data into chunks. Requires setting --%s. Valid values are %q,%q,%q,%q,%q,%q.", remoteReadFilterTimeStart, stepper.StepMonth, stepper.StepDay, stepper.StepHour, stepper.StepMinute, stepper.StepCalendarMonth, stepper.StepWeek),
 			Required: true,
 		},
 		&cli.StringFlag{
@@ -2,6 +2,7 @@ package limiter
 
 import (
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/timerpool"
@@ -16,6 +17,7 @@ func NewLimiter(perSecondLimit int64) *Limiter {
 // Limiter controls the amount of budget
 // that can be spent according to configured perSecondLimit
 type Limiter struct {
+	// perSecondLimit is accessed atomically, so it could be changed via SetLimit at any time
 	perSecondLimit int64
 
 	// mu protects budget and deadline from concurrent access.
@@ -28,12 +30,26 @@ type Limiter struct {
 	deadline time.Time
 }
 
+// Limit returns the configured per-second limit.
+// It returns zero for nil Limiter.
+func (l *Limiter) Limit() int64 {
+	if l == nil {
+		return 0
+	}
+	return atomic.LoadInt64(&l.perSecondLimit)
+}
+
+// SetLimit changes the per-second limit of l.
+// The new limit is applied starting from the next budget increase.
+func (l *Limiter) SetLimit(perSecondLimit int64) {
+	atomic.StoreInt64(&l.perSecondLimit, perSecondLimit)
+}
+
 // Register blocks for amount of time
 // needed to process the given dataLen according
 // to the configured perSecondLimit.
 func (l *Limiter) Register(dataLen int) {
-	limit := l.perSecondLimit
-	if limit <= 0 {
+	if l.Limit() <= 0 {
 		return
 	}
 
@@ -46,6 +62,12 @@ func (l *Limiter) Register(dataLen int) {
 			<-t.C
 			timerpool.Put(t)
 		}
+		limit := l.Limit()
+		if limit <= 0 {
+			// the limit was removed via SetLimit
+			l.budget = 0
+			return
+		}
 		l.budget += limit
 		l.deadline = time.Now().Add(time.Second)
 	}
@@ -0,0 +1,28 @@
+package limiter
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLimiterSetLimit(t *testing.T) {
+	l := NewLimiter(100)
+	// spend the budget of the first second
+	l.Register(100)
+	l.SetLimit(1000)
+	if got := l.Limit(); got != 1000 {
+		t.Fatalf("unexpected limit %d", got)
+	}
+	start := time.Now()
+	// the new limit is applied on the next budget increase, so it takes a single second instead of ten
+	l.Register(999)
+	l.Register(1)
+	if d := time.Since(start); d > 1500*time.Millisecond {
+		t.Fatalf("the new limit wasn't applied; registering took %s", d)
+	}
+
+	var nl *Limiter
+	if got := nl.Limit(); got != 0 {
+		t.Fatalf("unexpected limit %d for nil limiter", got)
+	}
+}
@@ -0,0 +1,42 @@
+package limiter
+
+import (
+	"io"
+)
+
+// NewReadLimiter creates a new ReadLimiter object
+// for the given reader and Limiter.
+func NewReadLimiter(r io.Reader, limiter *Limiter) *ReadLimiter {
+	return &ReadLimiter{
+		reader:  r,
+		limiter: limiter,
+	}
+}
+
+// ReadLimiter limits the amount of bytes read
+// per second via Read() method.
+// Must be created via NewReadLimiter.
+type ReadLimiter struct {
+	reader  io.Reader
+	limiter *Limiter
+}
+
+// Close implements io.Closer
+// also calls Close for wrapped io.ReadCloser
+func (rl *ReadLimiter) Close() error {
+	if c, ok := rl.reader.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// Read implements io.Reader.
+// The amount of read bytes is registered after the read,
+// so the following reads are delayed if the limit is exceeded.
+func (rl *ReadLimiter) Read(p []byte) (n int, err error) {
+	n, err = rl.reader.Read(p)
+	if n > 0 {
+		rl.limiter.Register(n)
+	}
+	return n, err
+}
@@ -0,0 +1,33 @@
+package limiter
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestReadLimiter(t *testing.T) {
+	data := bytes.Repeat([]byte("a"), 2000)
+	rl := NewReadLimiter(bytes.NewReader(data), NewLimiter(1000))
+	buf := make([]byte, 1000)
+	start := time.Now()
+	var got []byte
+	for {
+		n, err := rl.Read(buf)
+		got = append(got, buf[:n]...)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatalf("unexpected data read; got %d bytes; want %d bytes", len(got), len(data))
+	}
+	// the budget of the first second is spent immediately, so reading 2x of the limit takes at least a second
+	if d := time.Since(start); d < 900*time.Millisecond {
+		t.Fatalf("reading finished too fast: %s", d)
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	logFormatText = "text"
+	logFormatJSON = "json"
+)
+
+// jsonLogs defines whether logs are written as JSON lines.
+// It is set once at startup according to --log-format.
+var jsonLogs bool
+
+// setupLogFormat configures logs according to format
+func setupLogFormat(format string) error {
+	switch format {
+	case "", logFormatText:
+		jsonLogs = false
+		return nil
+	case logFormatJSON:
+	default:
+		return fmt.Errorf("unsupported value %q for --%s; supported values: %q, %q", format, globalLogFormat, logFormatText, logFormatJSON)
+	}
+	// lib/logger is used for warnings and errors, so its format must match
+	if err := flag.Set("loggerFormat", logFormatJSON); err != nil {
+		return fmt.Errorf("cannot set logger format: %w", err)
+	}
+	jsonLogs = true
+	// free-form messages are wrapped into JSON lines as well
+	log.SetFlags(0)
+	log.SetOutput(&jsonLogWriter{w: os.Stderr})
+	return nil
+}
+
+// logFields contains fields of structured log event
+type logFields map[string]interface{}
+
+// logEvent logs msg with the given fields.
+// Fields are logged only if JSON logs are enabled, since msg already contains them in human-readable form.
+func logEvent(msg string, fields logFields) {
+	if !jsonLogs {
+		log.Print(msg)
+		return
+	}
+	writeJSONLog(os.Stderr, "info", msg, fields)
+}
+
+// logFatal logs err and exits with non-zero code
+func logFatal(err error) {
+	if !jsonLogs {
+		log.Fatalln(err)
+	}
+	writeJSONLog(os.Stderr, "error", err.Error(), logFields{"event": "error"})
+	os.Exit(1)
+}
+
+var jsonLogMu sync.Mutex
+
+// writeJSONLog writes a single JSON line with ts, level and msg fields followed by fields sorted by name
+func writeJSONLog(w io.Writer, level, msg string, fields logFields) {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `{"ts":%q,"level":%q,"msg":%s`, time.Now().UTC().Format("2006-01-02T15:04:05.000Z"), level, mustMarshal(msg))
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(&buf, `,%q:%s`, k, mustMarshal(fields[k]))
+	}
+	buf.WriteString("}\n")
+
+	jsonLogMu.Lock()
+	_, _ = w.Write(buf.Bytes())
+	jsonLogMu.Unlock()
+}
+
+func mustMarshal(v interface{}) []byte {
+	data, err := json.Marshal(v)
+	if err != nil {
+		// fields are always marshalable, so this is a bug
+		panic(fmt.Errorf("BUG: cannot marshal log field %v: %s", v, err))
+	}
+	return data
+}
+
+// jsonLogWriter wraps messages written via the standard log package into JSON lines
+type jsonLogWriter struct {
+	w io.Writer
+}
+
+func (jw *jsonLogWriter) Write(p []byte) (int, error) {
+	writeJSONLog(jw.w, "info", string(bytes.TrimRight(p, "\n")), nil)
+	return len(p), nil
+}
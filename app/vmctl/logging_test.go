@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestWriteJSONLog(t *testing.T) {
+	var buf bytes.Buffer
+	writeJSONLog(&buf, "info", `Initing import process from "a" to "b"`, logFields{
+		"tenant":       "1:0",
+		"metric_count": 42,
+		"src":          "http://src",
+	})
+	line := buf.String()
+	if !strings.HasSuffix(line, "}\n") || strings.Count(line, "\n") != 1 {
+		t.Fatalf("expecting a single JSON line; got %q", line)
+	}
+	// ts, level and msg go first, while other fields are sorted
+	if n := strings.Index(line, `"level":"info","msg":"Initing import process from \"a\" to \"b\"","metric_count":42,"src":"http://src","tenant":"1:0"}`); n < 0 {
+		t.Fatalf("unexpected fields order in %s", line)
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal([]byte(line), &m); err != nil {
+		t.Fatalf("cannot parse log line %q: %s", line, err)
+	}
+	if m["ts"] == "" || m["metric_count"] != float64(42) {
+		t.Fatalf("unexpected log fields %v", m)
+	}
+
+	buf.Reset()
+	jw := &jsonLogWriter{w: &buf}
+	if _, err := jw.Write([]byte("Import finished!\n")); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !strings.HasSuffix(buf.String(), `"level":"info","msg":"Import finished!"}`+"\n") {
+		t.Fatalf("unexpected log line %q", buf.String())
+	}
+}
+
+func TestSetupLogFormat(t *testing.T) {
+	if err := setupLogFormat(logFormatText); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if jsonLogs {
+		t.Fatalf("JSON logs mustn't be enabled in text format")
+	}
+	if err := setupLogFormat("logfmt"); err == nil {
+		t.Fatalf("expecting error for unsupported format")
+	}
+}
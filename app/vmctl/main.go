@@ -2,8 +2,10 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
 	"os/signal"
 	"strings"
@@ -13,9 +15,13 @@ import (
 
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmctl/auth"
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmctl/backoff"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmctl/limiter"
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmctl/native"
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmctl/remoteread"
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmctl/terminal"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmctl/tracing"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmctl/utils"
+	"github.com/VictoriaMetrics/metrics"
 	"github.com/urfave/cli/v2"
 
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmctl/influx"
@@ -23,6 +29,7 @@ import (
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmctl/prometheus"
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmctl/vm"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/buildinfo"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promrelabel"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/protoparser/common"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/protoparser/native/stream"
 )
@@ -197,55 +204,195 @@ func main() {
 				Action: func(c *cli.Context) error {
 					fmt.Println("VictoriaMetrics Native import mode")
 
-					if c.String(vmNativeFilterMatch) == "" {
-						return fmt.Errorf("flag %q can't be empty", vmNativeFilterMatch)
+					if err := readNativeConfigFile(c); err != nil {
+						return err
+					}
+					for _, match := range filterMatches(c) {
+						if match == "" {
+							return fmt.Errorf("flag %q can't be empty", vmNativeFilterMatch)
+						}
+					}
+					if err := readNativeCredentialFiles(c); err != nil {
+						return err
 					}
 
-					var srcExtraLabels []string
-					srcAddr := strings.Trim(c.String(vmNativeSrcAddr), "/")
-					srcAuthConfig, err := auth.Generate(
-						auth.WithBasicAuth(c.String(vmNativeSrcUser), c.String(vmNativeSrcPassword)),
-						auth.WithBearer(c.String(vmNativeSrcBearerToken)),
-						auth.WithHeaders(c.String(vmNativeSrcHeaders)))
-					if err != nil {
-						return fmt.Errorf("error initilize auth config for source: %s", srcAddr)
+					var srcAddrs []string
+					for _, addr := range c.StringSlice(vmNativeSrcAddr) {
+						srcAddrs = append(srcAddrs, strings.Trim(addr, "/"))
+					}
+					srcFile := c.String(vmNativeSrcFile)
+					switch {
+					case srcFile != "" && len(srcAddrs) > 0:
+						return fmt.Errorf("flags --%s and --%s can't be used together", vmNativeSrcFile, vmNativeSrcAddr)
+					case srcFile != "" && c.Bool(vmNativeVerifyCounts):
+						return fmt.Errorf("--%s can't be used together with --%s, since it requires querying the source", vmNativeVerifyCounts, vmNativeSrcFile)
+					case len(srcAddrs) > 1 && c.Bool(vmNativeVerifyCounts):
+						return fmt.Errorf("--%s isn't supported for migration from multiple sources, since their samples are counted together at destination", vmNativeVerifyCounts)
+					case srcFile != "" && c.Bool(vmInterCluster):
+						return fmt.Errorf("--%s isn't supported in --%s mode; set tenant in --%s instead", vmNativeSrcFile, vmInterCluster, vmNativeDstAddr)
+					case srcFile != "":
+						// the file is imported via the only processor
+						srcAddrs = []string{""}
+					case len(srcAddrs) == 0:
+						return fmt.Errorf("either --%s or --%s must be set", vmNativeSrcAddr, vmNativeSrcFile)
+					case c.String(vmNativeFilterTimeStart) == "":
+						return fmt.Errorf("flag %q must be set", vmNativeFilterTimeStart)
+					}
+					dstFile := c.String(vmNativeDstFile)
+					switch {
+					case dstFile != "" && c.String(vmNativeDstAddr) != "":
+						return fmt.Errorf("flags --%s and --%s can't be used together", vmNativeDstFile, vmNativeDstAddr)
+					case dstFile == "" && c.String(vmNativeDstAddr) == "":
+						return fmt.Errorf("either --%s or --%s must be set", vmNativeDstAddr, vmNativeDstFile)
+					case dstFile != "" && srcFile != "":
+						return fmt.Errorf("flags --%s and --%s can't be used together", vmNativeDstFile, vmNativeSrcFile)
+					case dstFile != "" && len(srcAddrs) > 1:
+						return fmt.Errorf("--%s isn't supported for migration from multiple sources", vmNativeDstFile)
+					case dstFile != "" && (c.String(vmNativeDstTenantFromLabel) != "" || c.Bool(vmNativeWaitDurable) ||
+						c.Int(vmNativeVerifyPerMetric) > 0 || c.Bool(vmNativeVerifyCounts) || c.Bool(vmNativeSkipExisting) || len(c.StringSlice(vmNativeWarmupQuery)) > 0):
+						return fmt.Errorf("--%s can't be used together with --%s, --%s, --%s, --%s, --%s and --%s, since they require destination",
+							vmNativeDstFile, vmNativeDstTenantFromLabel, vmNativeWaitDurable, vmNativeVerifyPerMetric, vmNativeVerifyCounts, vmNativeSkipExisting, vmNativeWarmupQuery)
+					}
+					if c.Bool(vmNativeDstRemoteWrite) {
+						switch {
+						case c.String(vmNativeDstAddr) == "":
+							return fmt.Errorf("--%s requires --%s to be set to the URL of remote write endpoint", vmNativeDstRemoteWrite, vmNativeDstAddr)
+						case srcFile != "":
+							return fmt.Errorf("flags --%s and --%s can't be used together", vmNativeDstRemoteWrite, vmNativeSrcFile)
+						case c.Bool(vmInterCluster):
+							return fmt.Errorf("--%s isn't supported in --%s mode", vmNativeDstRemoteWrite, vmInterCluster)
+						case c.String(vmNativeDstTenantFromLabel) != "" || c.Bool(vmNativeWaitDurable) ||
+							c.Int(vmNativeVerifyPerMetric) > 0 || c.Bool(vmNativeVerifyCounts) || c.Bool(vmNativeSkipExisting) || len(c.StringSlice(vmNativeWarmupQuery)) > 0:
+							return fmt.Errorf("--%s can't be used together with --%s, --%s, --%s, --%s, --%s and --%s, since they require VictoriaMetrics at destination",
+								vmNativeDstRemoteWrite, vmNativeDstTenantFromLabel, vmNativeWaitDurable, vmNativeVerifyPerMetric, vmNativeVerifyCounts, vmNativeSkipExisting, vmNativeWarmupQuery)
+						}
+					}
+					srcLabels := c.StringSlice(vmNativeSrcExtraLabel)
+					if len(srcLabels) > 0 && len(srcLabels) != len(srcAddrs) {
+						return fmt.Errorf("the number of --%s flags must match the number of --%s flags; got %d and %d",
+							vmNativeSrcExtraLabel, vmNativeSrcAddr, len(srcLabels), len(srcAddrs))
 					}
 
-					dstAddr := strings.Trim(c.String(vmNativeDstAddr), "/")
-					dstExtraLabels := c.StringSlice(vmExtraLabel)
-					dstAuthConfig, err := auth.Generate(
-						auth.WithBasicAuth(c.String(vmNativeDstUser), c.String(vmNativeDstPassword)),
-						auth.WithBearer(c.String(vmNativeDstBearerToken)),
-						auth.WithHeaders(c.String(vmNativeDstHeaders)))
-					if err != nil {
-						return fmt.Errorf("error initilize auth config for destination: %s", dstAddr)
+					var tracer *tracing.Tracer
+					if endpoint := c.String(vmNativeOtelEndpoint); endpoint != "" {
+						var err error
+						tracer, err = tracing.New(endpoint, "vmctl")
+						if err != nil {
+							return err
+						}
+						defer tracer.Shutdown()
+					}
+					var metricsSet *metrics.Set
+					if addr := c.String(vmNativeMetricsListenAddr); addr != "" {
+						metricsSet = metrics.NewSet()
+						srv, err := startMetricsServer(addr, metricsSet)
+						if err != nil {
+							return err
+						}
+						defer func() { _ = srv.Close() }()
+					}
+					var dstTransport *http.Transport
+					if conns := c.Int(vmNativeConnectionsPerDst); conns > 0 {
+						if c.Int(vmNativeMaxConnsPerHost) > 0 {
+							return fmt.Errorf("--%s can't be used together with --%s, since both limit connections to destination", vmNativeConnectionsPerDst, vmNativeMaxConnsPerHost)
+						}
+						dstTransport = native.NewPooledTransport(conns, c.Bool(vmNativeDisableHTTPKeepAlive))
+						dstTransport.TLSClientConfig, err = newNativeDstTLSConfig(c)
+						if err != nil {
+							return err
+						}
+						if cc := c.Int(vmConcurrency) * len(srcAddrs); conns < cc {
+							log.Printf("--%s=%d is lower than the total concurrency %d; import requests will wait for free connections",
+								vmNativeConnectionsPerDst, conns, cc)
+						}
+					} else if tc := nativeTransportConfig(c); tc != nil {
+						// the transport is shared between all the sources, so connections to destination are reused by all the workers
+						tlsConfig, err := newNativeDstTLSConfig(c)
+						if err != nil {
+							return err
+						}
+						dstTransport = native.NewTransport(*tc, tlsConfig)
 					}
 
-					p := vmNativeProcessor{
-						rateLimit:    c.Int64(vmRateLimit),
-						interCluster: c.Bool(vmInterCluster),
-						filter: native.Filter{
-							Match:     c.String(vmNativeFilterMatch),
-							TimeStart: c.String(vmNativeFilterTimeStart),
-							TimeEnd:   c.String(vmNativeFilterTimeEnd),
-							Chunk:     c.String(vmNativeStepInterval),
-						},
-						src: &native.Client{
-							AuthCfg:              srcAuthConfig,
-							Addr:                 srcAddr,
-							ExtraLabels:          srcExtraLabels,
-							DisableHTTPKeepAlive: c.Bool(vmNativeDisableHTTPKeepAlive),
-						},
-						dst: &native.Client{
-							AuthCfg:              dstAuthConfig,
-							Addr:                 dstAddr,
-							ExtraLabels:          dstExtraLabels,
-							DisableHTTPKeepAlive: c.Bool(vmNativeDisableHTTPKeepAlive),
-						},
-						backoff: backoff.New(),
-						cc:      c.Int(vmConcurrency),
+					// the global rate limit is shared between all the sources
+					var globalRateLimiter *limiter.Limiter
+					if limit := c.Int64(vmNativeGlobalRateLimit); limit > 0 {
+						globalRateLimiter = limiter.NewLimiter(limit)
+					}
+					// slow start is shared between all the sources, since they share globalRateLimiter
+					var ss *slowStart
+					if d := c.Duration(vmNativeSlowStart); d > 0 {
+						fraction := c.Float64(vmNativeSlowStartFraction)
+						if fraction <= 0 || fraction > 1 {
+							return fmt.Errorf("--%s must be in range (0..1]; got %v", vmNativeSlowStartFraction, fraction)
+						}
+						if c.Int64(vmRateLimit) <= 0 && globalRateLimiter == nil {
+							return fmt.Errorf("--%s requires --%s or --%s", vmNativeSlowStart, vmRateLimit, vmNativeGlobalRateLimit)
+						}
+						ss = newSlowStart(d, fraction)
+					}
+
+					if len(srcAddrs) == 1 {
+						p, err := newNativeProcessor(c, srcAddrs[0], "", 0, tracer, dstTransport)
+						if err != nil {
+							return err
+						}
+						p.globalRateLimiter = globalRateLimiter
+						p.slowStart = ss
+						if metricsSet != nil {
+							p.progress = newProgressMetrics(metricsSet, 0)
+						}
+						return p.run(ctx, isNonInteractive(c))
 					}
-					return p.run(ctx, isNonInteractive(c))
+
+					if !isNonInteractive(c) {
+						return fmt.Errorf("migration from multiple sources requires --%s flag, since it can't ask for confirmations", globalSilent)
+					}
+					if c.String(vmNativePlanOut) != "" || c.String(vmNativePlanIn) != "" {
+						return fmt.Errorf("migration plans aren't supported for migration from multiple sources")
+					}
+					var ps []*vmNativeProcessor
+					for i, addr := range srcAddrs {
+						var label string
+						if len(srcLabels) > 0 {
+							label = srcLabels[i]
+						}
+						p, err := newNativeProcessor(c, addr, label, i+1, tracer, dstTransport)
+						if err != nil {
+							return fmt.Errorf("cannot configure migration from source %q: %w", addr, err)
+						}
+						p.globalRateLimiter = globalRateLimiter
+						p.slowStart = ss
+						if metricsSet != nil {
+							p.progress = newProgressMetrics(metricsSet, i+1)
+						}
+						ps = append(ps, p)
+					}
+					successFile := c.String(vmNativeSuccessFile)
+					if c.Bool(vmNativeDryRun) {
+						successFile = ""
+					}
+					return runMultiSource(ctx, ps, successFile)
+				},
+			},
+			{
+				Name:      "vm-native-state",
+				Usage:     fmt.Sprintf("Prints summary of the state file created via --%s without running a migration", vmNativeStateFile),
+				ArgsUsage: "<path to state file>",
+				Action: func(c *cli.Context) error {
+					path := c.Args().First()
+					if len(path) == 0 {
+						return cli.Exit("you must provide path to the state file", 1)
+					}
+					if _, err := os.Stat(path); err != nil {
+						return cli.Exit(fmt.Errorf("cannot access state file: %w", err), 1)
+					}
+					cp, err := loadCheckpoint(path)
+					if err != nil {
+						return cli.Exit(err, 1)
+					}
+					cp.writeSummary(os.Stdout)
+					return nil
 				},
 			},
 			{
@@ -283,23 +430,32 @@ func main() {
 			},
 		},
 	}
+	for _, cmd := range app.Commands {
+		cmd.Before = func(c *cli.Context) error {
+			return setupLogFormat(c.String(globalLogFormat))
+		}
+	}
 
 	c := make(chan os.Signal, 1)
 	signal.Notify(c, syscall.SIGINT, syscall.SIGTERM)
 	go func() {
 		<-c
-		fmt.Println("\r- Execution cancelled")
+		fmt.Println("\r- Execution cancelled; waiting for in-flight requests to finish. Repeat the signal in order to force exit")
 		if importer != nil {
 			importer.Close()
 		}
 		cancelCtx()
+		<-c
+		fmt.Println("\r- Forced exit")
+		os.Exit(1)
 	}()
 
 	err = app.Run(os.Args)
 	if err != nil {
-		log.Fatalln(err)
+		logFatal(err)
 	}
-	log.Printf("Total time: %v", time.Since(start))
+	d := time.Since(start)
+	logEvent(fmt.Sprintf("Total time: %v", d), logFields{"event": "finish", "duration_seconds": d.Seconds()})
 }
 
 func initConfigVM(c *cli.Context) vm.Config {
@@ -316,7 +472,382 @@ func initConfigVM(c *cli.Context) vm.Config {
 		ExtraLabels:        c.StringSlice(vmExtraLabel),
 		RateLimit:          c.Int64(vmRateLimit),
 		DisableProgressBar: c.Bool(vmDisableProgressBar),
+		RetryJitter:        c.Bool(globalRetryJitter),
+	}
+}
+
+// nativeCredentialFiles maps flags with paths to credential files to the flags overridden by the file contents
+var nativeCredentialFiles = []struct{ file, flag string }{
+	{vmNativeSrcUserFile, vmNativeSrcUser},
+	{vmNativeSrcPasswordFile, vmNativeSrcPassword},
+	{vmNativeSrcBearerTokenFile, vmNativeSrcBearerToken},
+	{vmNativeDstUserFile, vmNativeDstUser},
+	{vmNativeDstPasswordFile, vmNativeDstPassword},
+	{vmNativeDstBearerTokenFile, vmNativeDstBearerToken},
+}
+
+// readNativeCredentialFiles reads credentials from the files set via flags in c once at startup
+// and overrides the corresponding inline flags with them, so secrets don't need to be passed via command line.
+func readNativeCredentialFiles(c *cli.Context) error {
+	for _, cf := range nativeCredentialFiles {
+		path := c.String(cf.file)
+		if path == "" {
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("cannot read --%s: %w", cf.file, err)
+		}
+		// files created by editors and `echo` usually end with a newline
+		value := strings.TrimRight(string(data), "\r\n")
+		if value == "" {
+			return fmt.Errorf("--%s=%q is empty", cf.file, path)
+		}
+		if err := c.Set(cf.flag, value); err != nil {
+			return fmt.Errorf("cannot set --%s from --%s: %w", cf.flag, cf.file, err)
+		}
+	}
+	return nil
+}
+
+// nativeTransportConfig returns options for tuning connection reuse set via flags in c.
+// It returns nil if none of them is set, so every request uses a new connection.
+func nativeTransportConfig(c *cli.Context) *native.TransportConfig {
+	tc := &native.TransportConfig{
+		MaxIdleConns:     c.Int(vmNativeMaxIdleConns),
+		MaxConnsPerHost:  c.Int(vmNativeMaxConnsPerHost),
+		ForceHTTP2:       c.Bool(vmNativeForceHTTP2),
+		DisableKeepAlive: c.Bool(vmNativeDisableHTTPKeepAlive),
+	}
+	if tc.MaxIdleConns <= 0 && tc.MaxConnsPerHost <= 0 && !tc.ForceHTTP2 {
+		return nil
+	}
+	return tc
+}
+
+// newNativeDstTLSConfig returns TLS config for connections to --vm-native-dst-addr
+func newNativeDstTLSConfig(c *cli.Context) (*tls.Config, error) {
+	tlsConfig, err := utils.TLSConfig(c.String(vmNativeDstCertFile), c.String(vmNativeDstKeyFile),
+		c.String(vmNativeDstCAFile), c.String(vmNativeDstServerName), c.Bool(vmNativeDstInsecureSkipVerify))
+	if err != nil {
+		return nil, fmt.Errorf("cannot create TLS config for destination: %w", err)
+	}
+	return tlsConfig, nil
+}
+
+// newNativeProcessor creates processor for migration from srcAddr configured via flags from c.
+// srcLabel is an optional extra label added to all the series from srcAddr.
+// source is the number of the source in multi-source migration or zero otherwise.
+func newNativeProcessor(c *cli.Context, srcAddr, srcLabel string, source int, tracer *tracing.Tracer, dstTransport *http.Transport) (*vmNativeProcessor, error) {
+	var srcExtraLabels []string
+	srcAuthConfig, err := auth.Generate(
+		auth.WithBasicAuth(c.String(vmNativeSrcUser), c.String(vmNativeSrcPassword)),
+		auth.WithBearer(c.String(vmNativeSrcBearerToken)),
+		auth.WithHeaders(c.String(vmNativeSrcHeaders)))
+	if err != nil {
+		return nil, fmt.Errorf("error initilize auth config for source: %s", srcAddr)
+	}
+
+	dstAddr := strings.Trim(c.String(vmNativeDstAddr), "/")
+	dstExtraLabels := c.StringSlice(vmExtraLabel)
+	if srcLabel != "" {
+		// copy the slice, since it is shared between sources
+		dstExtraLabels = append(append([]string{}, dstExtraLabels...), srcLabel)
+	}
+	dstAuthConfig, err := auth.Generate(
+		auth.WithBasicAuth(c.String(vmNativeDstUser), c.String(vmNativeDstPassword)),
+		auth.WithBearer(c.String(vmNativeDstBearerToken)),
+		auth.WithHeaders(c.String(vmNativeDstHeaders)))
+	if err != nil {
+		return nil, fmt.Errorf("error initilize auth config for destination: %s", dstAddr)
+	}
+
+	srcTLSConfig, err := utils.TLSConfig(c.String(vmNativeSrcCertFile), c.String(vmNativeSrcKeyFile),
+		c.String(vmNativeSrcCAFile), c.String(vmNativeSrcServerName), c.Bool(vmNativeSrcInsecureSkipVerify))
+	if err != nil {
+		return nil, fmt.Errorf("cannot create TLS config for source: %w", err)
+	}
+	var srcTransport *http.Transport
+	if tc := nativeTransportConfig(c); tc != nil {
+		srcTransport = native.NewTransport(*tc, srcTLSConfig)
+	}
+	dstTLSConfig, err := newNativeDstTLSConfig(c)
+	if err != nil {
+		return nil, err
+	}
+
+	bf, err := backoff.NewWithParams(c.Int(vmNativeRetryMaxAttempts), c.Duration(vmNativeRetryMinDelay), c.Duration(vmNativeRetryMaxDelay))
+	if err != nil {
+		return nil, fmt.Errorf("invalid retry params: %w", err)
+	}
+	bf.SetJitter(c.Bool(globalRetryJitter))
+
+	matches := filterMatches(c)
+	lf, _ := c.Generic(vmNativeLabelFilter).(*labelFilters)
+	p := &vmNativeProcessor{
+		rateLimit:    c.Int64(vmRateLimit),
+		interCluster: c.Bool(vmInterCluster),
+		matches:      matches,
+		labelFilters: lf,
+		filter: native.Filter{
+			Match:     matches[0],
+			TimeStart: c.String(vmNativeFilterTimeStart),
+			TimeEnd:   c.String(vmNativeFilterTimeEnd),
+			Chunk:     c.String(vmNativeStepInterval),
+			Exclude:   c.String(vmNativeFilterExcludeMatch),
+		},
+		src: &native.Client{
+			Transport:            srcTransport,
+			AuthCfg:              srcAuthConfig,
+			Addr:                 srcAddr,
+			ExtraLabels:          srcExtraLabels,
+			DisableHTTPKeepAlive: c.Bool(vmNativeDisableHTTPKeepAlive),
+			Format:               c.String(vmNativeExportFormat),
+			DisableRedirects:     c.Bool(vmNativeDisableRedirects),
+			MaxRedirects:         c.Int(vmNativeMaxRedirects),
+			RequestTimeout:       c.Duration(vmNativeHTTPTimeout),
+			TLSConfig:            srcTLSConfig,
+			MaxRowsPerLine:       c.Int(vmNativeExportMaxRowsPerLine),
+		},
+		dst: &native.Client{
+			Transport:            dstTransport,
+			AuthCfg:              dstAuthConfig,
+			Addr:                 dstAddr,
+			ExtraLabels:          dstExtraLabels,
+			DisableHTTPKeepAlive: c.Bool(vmNativeDisableHTTPKeepAlive),
+			Format:               c.String(vmNativeExportFormat),
+			DisableRedirects:     c.Bool(vmNativeDisableRedirects),
+			MaxRedirects:         c.Int(vmNativeMaxRedirects),
+			RequestTimeout:       c.Duration(vmNativeHTTPTimeout),
+			TLSConfig:            dstTLSConfig,
+		},
+		backoff:      bf,
+		cc:           c.Int(vmConcurrency),
+		discoveryCC:  c.Int(vmNativeDiscoveryConcurrency),
+		maxClockSkew: c.Duration(vmNativeMaxClockSkew),
+		httpTimeout:  c.Duration(vmNativeHTTPTimeout),
+		stickyRouteCfg: stickyRouteConfig{
+			by:  c.String(vmNativeStickyRouteBy),
+			via: c.String(vmNativeStickyRouteVia),
+			key: c.String(vmNativeStickyRouteKey),
+		},
+		intraUnitParallelism: c.Int(vmNativeIntraUnitParallelism),
+		retrySubRanges:       c.Int(vmNativeRetrySubRanges),
+		autoSplitBytes:       c.Int64(vmNativeAutoSplitBytes),
+		progressInterval:     c.Duration(vmNativeProgressInterval),
+		batchSize:            c.Int(vmNativeBatchSize),
+		batchMaxBytes:        c.Int64(vmNativeBatchMaxBytes),
+		continueOnError:      c.Bool(vmNativeContinueOnError),
+		failuresFile:         sourceFilePath(c.String(vmNativeFailuresFile), source),
+		retryPasses:          c.Int(vmNativeRetryFailedUnitsAtEnd),
+		retryPassDelay:       c.Duration(vmNativeRetryFailedUnitsDelay),
+		onDuplicateTS:        c.String(vmNativeOnDuplicateTS),
+		dedupStream:          c.Bool(vmNativeDedupStream),
+		nonFinite:            c.String(vmNativeNonFinite),
+		maxTotalBytes:        c.Int64(vmNativeMaxTotalBytes),
+		maxTotalRequests:     c.Int64(vmNativeMaxTotalRequests),
+		maxMetrics:           c.Int(vmNativeMaxMetrics),
+		estimateMetrics:      c.Int(vmNativeEstimateMetrics),
+		verifyPerMetric:      c.Int(vmNativeVerifyPerMetric),
+		verifyReimport:       c.Bool(vmNativeVerifyReimport),
+		tenantCC:             c.Int(vmNativeTenantConcurrency),
+		overallProgressBar:   c.Bool(vmNativeOverallProgressBar),
+		barStyle:             c.String(vmNativeBarStyle),
+		perTenantCC:          c.Int(vmNativeImportConcurrencyPerTenant),
+		warmupQueries:        c.StringSlice(vmNativeWarmupQuery),
+		autoChunkSamples:     c.Int(vmNativeAutoChunk),
+		planOut:              c.String(vmNativePlanOut),
+		exploreLimit:         c.Int(vmNativeExploreMatchLimit),
+		successFile:          c.String(vmNativeSuccessFile),
+		srcFile:              c.String(vmNativeSrcFile),
+		transferFormat:       c.String(vmNativeTransferFormat),
+		exportPath:           strings.Trim(c.String(vmNativeSrcExportPath), "/"),
+		importPath:           strings.Trim(c.String(vmNativeDstImportPath), "/"),
+		dstRemoteWrite:       c.Bool(vmNativeDstRemoteWrite),
+		skipExistingData:     c.Bool(vmNativeSkipExisting),
+		digest:               c.Bool(vmNativeDigest),
+		exploreStream:        c.Bool(vmNativeExploreStream),
+		sortedMetrics:        c.Bool(vmNativeSorted),
+		srcTenants:           c.StringSlice(vmNativeSrcTenants),
+		tracer:               tracer,
+		metricDeadline:       c.Duration(vmNativeMetricDeadline),
+		statsFormat:          c.String(vmNativeStatsFormat),
+		verboseStats:         c.Bool(vmNativeVerboseStats),
+		chunkOrder:           c.String(vmNativeChunkOrder),
+		chunkOverlap:         c.Duration(vmNativeChunkOverlap),
+		skipProbe:            c.Bool(vmNativeSkipProbe),
+		assumeYes:            c.Bool(vmNativeYes),
+		skipEmptyTenants:     c.Bool(vmNativeSkipEmptyTenants),
+	}
+	if path := c.String(vmNativeDstFile); path != "" {
+		p.dstFile = newDstFileWriter(path)
+	}
+	if c.Bool(vmNativeDryRun) {
+		out := c.String(vmNativeDryRunOut)
+		if out != "-" {
+			out = sourceFilePath(out, source)
+		}
+		p.dryRun = newDryRunRecorder(out, c.Int(vmNativeDryRunTopMetrics))
+	} else if c.String(vmNativeDryRunOut) != "" || c.Int(vmNativeDryRunTopMetrics) > 0 {
+		return nil, fmt.Errorf("--%s and --%s require --%s", vmNativeDryRunOut, vmNativeDryRunTopMetrics, vmNativeDryRun)
+	}
+	if path := c.String(vmNativePlanIn); path != "" {
+		if c.String(vmNativePlanOut) != "" {
+			return nil, fmt.Errorf("flags --%s and --%s can't be used together", vmNativePlanIn, vmNativePlanOut)
+		}
+		p.planIn, err = loadPlan(path)
+		if err != nil {
+			return nil, err
+		}
+		p.planForce = c.Bool(vmNativePlanForce)
+	}
+	if c.Bool(vmNativeWaitDurable) || c.Bool(vmNativeFlushOnFinish) {
+		addrs := c.StringSlice(vmNativeWaitDurableAddr)
+		if len(addrs) == 0 {
+			if p.interCluster {
+				return nil, fmt.Errorf("--%s must contain vmstorage addresses when --%s or --%s is set in --%s mode",
+					vmNativeWaitDurableAddr, vmNativeWaitDurable, vmNativeFlushOnFinish, vmInterCluster)
+			}
+			addrs = []string{dstAddr}
+		}
+		for i := range addrs {
+			addrs[i] = strings.Trim(addrs[i], "/")
+		}
+		if c.Bool(vmNativeWaitDurable) {
+			p.durable = &durableConfig{
+				addrs:   addrs,
+				authKey: c.String(vmNativeWaitDurableAuthKey),
+				delay:   c.Duration(vmNativeWaitDurableDelay),
+			}
+		}
+		if c.Bool(vmNativeFlushOnFinish) {
+			p.flushAddrs = addrs
+			p.flushAuthKey = c.String(vmNativeWaitDurableAuthKey)
+		}
+	}
+	if c.Bool(vmNativeThrottleOnSource5xx) {
+		factor := c.Float64(vmNativeThrottleReductionFactor)
+		if factor <= 0 || factor >= 1 {
+			return nil, fmt.Errorf("--%s must be in range (0..1); got %v", vmNativeThrottleReductionFactor, factor)
+		}
+		maxCC := p.cc
+		if maxCC < 1 {
+			maxCC = 1
+		}
+		if p.intraUnitParallelism > 1 {
+			maxCC *= p.intraUnitParallelism
+		}
+		p.srcThrottle = newSourceThrottle(maxCC, factor, c.Int(vmNativeThrottleRecoveryRate))
+	}
+	p.dstTenantMap, err = readDstTenantMap(c.String(vmNativeDstTenantMap))
+	if err != nil {
+		return nil, err
+	}
+	p.dstTenantOverride = c.String(vmNativeDstTenant)
+	if label := c.String(vmNativeDstTenantFromLabel); label != "" {
+		p.tenantRoute = &tenantRouteConfig{
+			label:         label,
+			strip:         c.Bool(vmNativeDstTenantStripLabel),
+			defaultTenant: c.String(vmNativeDstTenantDefault),
+		}
+	}
+	if c.Bool(vmNativeConcurrencyAuto) {
+		p.autoConcurrency = newAutoConcurrency(c.Int(vmNativeConcurrencyMin), c.Int(vmNativeConcurrencyMax), c.Float64(vmNativeConcurrencyMaxRetryRate))
+		// workers are limited by p.autoConcurrency
+		p.cc = p.autoConcurrency.max
+	}
+	if c.Bool(vmNativeVerifyCounts) {
+		p.countVerification = &countVerification{}
+	}
+	if p.verifyPerMetric > 0 || p.countVerification != nil || p.skipExistingData || len(p.warmupQueries) > 0 {
+		dstReader := *p.dst
+		if addr := strings.Trim(c.String(vmNativeVerifyAddr), "/"); addr != "" {
+			dstReader.Addr = addr
+		}
+		p.dstReader = &dstReader
+	}
+	p.valueScales, err = parseValueScales(c.StringSlice(vmNativeValueScale))
+	if err != nil {
+		return nil, err
+	}
+	p.stampLabels, err = parseStampLabels(c.StringSlice(vmNativeStampLabel))
+	if err != nil {
+		return nil, err
+	}
+	p.stampLabelConflict = c.String(vmNativeStampLabelConflict)
+	p.downsample, err = parseDownsample(c.String(vmNativeDownsample))
+	if err != nil {
+		return nil, err
+	}
+	p.metricNameFilter, err = newMetricNameFilter(c.String(vmNativeMetricNameFilter), c.String(vmNativeMetricNameExclude))
+	if err != nil {
+		return nil, err
+	}
+	p.metricSampler, err = newMetricSampler(c.Float64(vmNativeSamplePercentage), c.Uint64(vmNativeSampleSeed))
+	if err != nil {
+		return nil, err
+	}
+	if d := c.Duration(vmNativeRequestTimeout); d > 0 {
+		p.requestTimeout = &requestTimeout{
+			timeout: d,
+			factor:  c.Float64(vmNativeRequestTimeoutFactor),
+			max:     c.Duration(vmNativeRequestTimeoutMax),
+		}
+	}
+	p.tenantFilter, err = parseTenantFilter(c.StringSlice(vmNativeTenantFilter))
+	if err != nil {
+		return nil, err
+	}
+	if path := c.String(vmNativeTenantsCacheFile); path != "" {
+		p.tenantsCache = &tenantsCache{
+			path:    path,
+			ttl:     c.Duration(vmNativeTenantsCacheTTL),
+			refresh: c.Bool(vmNativeRefreshTenants),
+		}
+	}
+	if path := c.String(vmNativeRelabelConfig); path != "" {
+		p.relabelConfigs, err = promrelabel.LoadRelabelConfigs(path)
+		if err != nil {
+			return nil, fmt.Errorf("cannot load --%s: %w", vmNativeRelabelConfig, err)
+		}
+	}
+	p.labelChunks, err = parseLabelChunkConfig(c.String(vmNativeChunkByLabel))
+	if err != nil {
+		return nil, err
+	}
+	if qps := c.Int(vmNativeSrcQPS); qps > 0 {
+		p.srcQPS = limiter.NewLimiter(int64(qps))
+	}
+	if limit := c.Int64(vmNativeSrcRateLimit); limit > 0 {
+		p.srcRateLimiter = limiter.NewLimiter(limit)
+	}
+	if path := c.String(vmNativeStateFile); path != "" {
+		path = sourceFilePath(path, source)
+		if c.Bool(vmNativeRestart) {
+			p.checkpoint = newCheckpoint(path)
+		} else {
+			p.checkpoint, err = loadCheckpoint(path)
+			if err != nil {
+				return nil, err
+			}
+		}
+		p.checkpoint.flushEvery, p.checkpoint.flushInterval, err = parseCheckpointFlushInterval(c.String(vmNativeCheckpointInterval))
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse --%s: %w", vmNativeCheckpointInterval, err)
+		}
+	}
+	if dir := c.String(vmNativeDstBufferDir); dir != "" {
+		p.spool, err = newSpool(sourceFilePath(dir, source), c.Int64(vmNativeDstBufferMaxSize))
+		if err != nil {
+			return nil, err
+		}
+	}
+	p.failures.path = p.failuresFile
+	if source > 0 {
+		// the marker of multi-source migration is written by runMultiSource
+		p.successFile = ""
 	}
+	return p, nil
 }
 
 func isNonInteractive(c *cli.Context) bool {
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/urfave/cli/v2"
+)
+
+func TestReadNativeCredentialFiles(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	for _, f := range vmNativeFlags {
+		if err := f.Apply(fs); err != nil {
+			t.Fatalf("cannot apply flag %s: %s", f.Names()[0], err)
+		}
+	}
+	path := filepath.Join(t.TempDir(), "password")
+	if err := os.WriteFile(path, []byte("secret\n"), 0600); err != nil {
+		t.Fatalf("cannot write file: %s", err)
+	}
+	args := []string{"--" + vmNativeSrcPassword + "=inline", "--" + vmNativeSrcPasswordFile + "=" + path, "--" + vmNativeDstUser + "=foo"}
+	if err := fs.Parse(args); err != nil {
+		t.Fatalf("cannot parse flags: %s", err)
+	}
+	c := cli.NewContext(cli.NewApp(), fs, nil)
+	if err := readNativeCredentialFiles(c); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	// the file takes precedence over the inline flag
+	if got := c.String(vmNativeSrcPassword); got != "secret" {
+		t.Fatalf("unexpected password %q", got)
+	}
+	if got := c.String(vmNativeDstUser); got != "foo" {
+		t.Fatalf("unexpected user %q", got)
+	}
+
+	if err := fs.Set(vmNativeDstBearerTokenFile, filepath.Join(t.TempDir(), "missing")); err != nil {
+		t.Fatalf("cannot set flag: %s", err)
+	}
+	if err := readNativeCredentialFiles(c); err == nil {
+		t.Fatalf("expecting error for missing file")
+	}
+}
+
+func TestNewNativeProcessorSrcQPS(t *testing.T) {
+	// parse the same set of flags as vm-native command does
+	fs := flag.NewFlagSet("vm-native", flag.ContinueOnError)
+	for _, f := range mergeFlags(globalFlags, vmNativeFlags) {
+		if err := f.Apply(fs); err != nil {
+			t.Fatalf("cannot apply flag %s: %s", f.Names()[0], err)
+		}
+	}
+	args := []string{"--" + vmNativeSrcAddr + "=http://localhost:8428", "--" + vmNativeDstAddr + "=http://localhost:8429", "--" + vmNativeSrcQPS + "=5"}
+	if err := fs.Parse(args); err != nil {
+		t.Fatalf("cannot parse flags: %s", err)
+	}
+	c := cli.NewContext(cli.NewApp(), fs, nil)
+	p, err := newNativeProcessor(c, "http://localhost:8428", "", 0, nil, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if p.srcQPS == nil {
+		t.Fatalf("expecting --%s to enable the source requests limiter", vmNativeSrcQPS)
+	}
+}
@@ -1,11 +1,18 @@
 package native
 
 import (
+	"bytes"
 	"context"
+	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmctl/auth"
 )
@@ -13,7 +20,12 @@ import (
 const (
 	nativeTenantsAddr = "admin/tenants"
 	nativeSeriesAddr  = "api/v1/series"
+	nativeHealthAddr  = "health"
+	nativeQueryAddr   = "api/v1/query"
+	nativeFlushAddr   = "internal/force_flush"
 	nameLabel         = "__name__"
+
+	defaultMaxRedirects = 10
 )
 
 // Client is an HTTP client for exporting and importing
@@ -23,6 +35,77 @@ type Client struct {
 	Addr                 string
 	ExtraLabels          []string
 	DisableHTTPKeepAlive bool
+	// Format is an optional native format version passed via `format` query arg
+	// to export and import requests. See SupportedFormats.
+	Format string
+	// DisableRedirects prohibits following HTTP redirects
+	DisableRedirects bool
+	// MaxRedirects limits the number of redirects to follow. Zero means default limit of 10 redirects.
+	MaxRedirects int
+	// Transport is an optional transport shared between requests.
+	// If nil, every request uses a new transport. See NewPooledTransport.
+	Transport *http.Transport
+	// TLSConfig is an optional TLS config for requests.
+	// It is used only if Transport is nil, otherwise it must be set in Transport.
+	TLSConfig *tls.Config
+	// RequestTimeout is an optional timeout for non-streaming requests,
+	// such as metrics and tenants discovery. Streaming export and import requests aren't limited by it.
+	RequestTimeout time.Duration
+	// MaxRowsPerLine is an optional limit on the number of samples per line of JSON line export,
+	// passed via `max_rows_per_line` query arg. Native export doesn't support it.
+	MaxRowsPerLine int
+}
+
+// withTimeout returns ctx limited by c.RequestTimeout
+func (c *Client) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if c.RequestTimeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, c.RequestTimeout)
+}
+
+// NewPooledTransport returns transport, which keeps up to conns connections per host
+// and reuses them between requests. Requests wait for a free connection
+// if all conns connections to the host are busy.
+func NewPooledTransport(conns int, disableKeepAlive bool) *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.MaxConnsPerHost = conns
+	t.MaxIdleConnsPerHost = conns
+	if t.MaxIdleConns < conns {
+		t.MaxIdleConns = conns
+	}
+	t.DisableKeepAlives = disableKeepAlive
+	return t
+}
+
+// TransportConfig contains options for tuning connection reuse of transport shared between requests
+type TransportConfig struct {
+	// MaxIdleConns limits the number of idle connections per host kept open for reuse.
+	// Zero means the default limit of http.Transport.
+	MaxIdleConns int
+	// MaxConnsPerHost limits the number of connections per host. Requests wait for a free connection
+	// if all of them are busy. Zero means no limit.
+	MaxConnsPerHost int
+	// ForceHTTP2 enables HTTP/2 for TLS connections. HTTP/2 multiplexes concurrent requests over a single connection.
+	ForceHTTP2 bool
+	// DisableKeepAlive disables connection reuse
+	DisableKeepAlive bool
+}
+
+// NewTransport returns transport configured according to cfg. tlsConfig is optional.
+func NewTransport(cfg TransportConfig, tlsConfig *tls.Config) *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	if cfg.MaxIdleConns > 0 {
+		t.MaxIdleConnsPerHost = cfg.MaxIdleConns
+		if t.MaxIdleConns < cfg.MaxIdleConns {
+			t.MaxIdleConns = cfg.MaxIdleConns
+		}
+	}
+	t.MaxConnsPerHost = cfg.MaxConnsPerHost
+	t.ForceAttemptHTTP2 = cfg.ForceHTTP2
+	t.DisableKeepAlives = cfg.DisableKeepAlive
+	t.TLSClientConfig = tlsConfig
+	return t
 }
 
 // LabelValues represents series from api/v1/series response
@@ -36,61 +119,90 @@ type Response struct {
 
 // Explore finds series by provided filter from api/v1/series
 func (c *Client) Explore(ctx context.Context, f Filter, tenantID string) (map[string]struct{}, error) {
-	url := fmt.Sprintf("%s/%s", c.Addr, nativeSeriesAddr)
+	names := make(map[string]struct{})
+	if _, err := c.explorePage(ctx, c.seriesURL(tenantID), f, f.Match, 0, names); err != nil {
+		return nil, err
+	}
+	return names, nil
+}
+
+func (c *Client) seriesURL(tenantID string) string {
 	if tenantID != "" {
-		url = fmt.Sprintf("%s/select/%s/prometheus/%s", c.Addr, tenantID, nativeSeriesAddr)
+		return fmt.Sprintf("%s/select/%s/prometheus/%s", c.Addr, tenantID, nativeSeriesAddr)
 	}
+	return fmt.Sprintf("%s/%s", c.Addr, nativeSeriesAddr)
+}
+
+// explorePage adds metric names of series matching the given match to names
+// and returns the number of series in response.
+// Optional limit is passed to api/v1/series via `limit` query arg.
+func (c *Client) explorePage(ctx context.Context, url string, f Filter, match string, limit int, names map[string]struct{}) (int, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
-		return nil, fmt.Errorf("cannot create request to %q: %s", url, err)
+		return 0, fmt.Errorf("cannot create request to %q: %s", url, err)
 	}
 
 	params := req.URL.Query()
-	if f.TimeStart != "" {
-		params.Set("start", f.TimeStart)
-	}
-	if f.TimeEnd != "" {
-		params.Set("end", f.TimeEnd)
-	}
-	params.Set("match[]", f.Match)
+	setSeriesParams(params, f, match, limit)
 	req.URL.RawQuery = params.Encode()
 
 	resp, err := c.do(req, http.StatusOK)
 	if err != nil {
-		return nil, fmt.Errorf("series request failed: %s", err)
+		return 0, fmt.Errorf("series request failed: %s", err)
 	}
 
 	var response Response
 	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
-		return nil, fmt.Errorf("cannot decode series response: %s", err)
+		return 0, fmt.Errorf("cannot decode series response: %s", err)
 	}
 
 	if err := resp.Body.Close(); err != nil {
-		return nil, fmt.Errorf("cannot close series response body: %s", err)
+		return 0, fmt.Errorf("cannot close series response body: %s", err)
 	}
-	names := make(map[string]struct{})
 	for _, series := range response.Series {
 		// TODO: consider tweaking /api/v1/series API to return metric names only
 		// this could make explore response much lighter.
-		for key, value := range series {
-			if key != nameLabel {
-				continue
-			}
-			if _, ok := names[value]; ok {
-				continue
-			}
+		if value, ok := series[nameLabel]; ok {
 			names[value] = struct{}{}
 		}
 	}
-	return names, nil
+	return len(response.Series), nil
 }
 
-// ImportPipe uses pipe reader in request to process data
-func (c *Client) ImportPipe(ctx context.Context, dstURL string, pr *io.PipeReader) error {
+// setSeriesParams sets query args of api/v1/series request for the given match.
+// Optional limit is passed via `limit` query arg.
+func setSeriesParams(params url.Values, f Filter, match string, limit int) {
+	if f.TimeStart != "" {
+		params.Set("start", f.TimeStart)
+	}
+	if f.TimeEnd != "" {
+		params.Set("end", f.TimeEnd)
+	}
+	params.Set("match[]", f.WithExclude(match))
+	if limit > 0 {
+		params.Set("limit", strconv.Itoa(limit))
+	}
+}
+
+// ImportPipe uses pipe reader in request to process data.
+// The optional header is added to the import request.
+func (c *Client) ImportPipe(ctx context.Context, dstURL string, pr *io.PipeReader, header http.Header) error {
 	req, err := http.NewRequestWithContext(ctx, http.MethodPost, dstURL, pr)
 	if err != nil {
 		return fmt.Errorf("cannot create import request to %q: %s", c.Addr, err)
 	}
+	for k, vs := range header {
+		for _, v := range vs {
+			req.Header.Add(k, v)
+		}
+	}
+	if c.Format != "" {
+		params := req.URL.Query()
+		params.Set("format", c.Format)
+		req.URL.RawQuery = params.Encode()
+	}
 
 	importResp, err := c.do(req, http.StatusNoContent)
 	if err != nil {
@@ -102,6 +214,146 @@ func (c *Client) ImportPipe(ctx context.Context, dstURL string, pr *io.PipeReade
 	return nil
 }
 
+// RemoteWrite sends snappy-compressed Prometheus remote write request body to dstURL.
+// The optional header is added to the request.
+func (c *Client) RemoteWrite(ctx context.Context, dstURL string, body []byte, header http.Header) error {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, dstURL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("cannot create remote write request to %q: %s", dstURL, err)
+	}
+	for k, vs := range header {
+		for _, v := range vs {
+			req.Header.Add(k, v)
+		}
+	}
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+
+	resp, err := c.do(req, http.StatusNoContent)
+	var sce *StatusCodeError
+	if errors.As(err, &sce) && sce.StatusCode == http.StatusOK {
+		// some remote write receivers respond with 200 instead of 204
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("remote write request failed: %w", err)
+	}
+	if err := resp.Body.Close(); err != nil {
+		return fmt.Errorf("cannot close remote write response body: %s", err)
+	}
+	return nil
+}
+
+// ForceFlush makes the recently ingested data at addr searchable via /internal/force_flush handler.
+// The handler is supported by single-node VictoriaMetrics and vmstorage.
+// Optional authKey must match -forceFlushAuthKey at addr.
+func (c *Client) ForceFlush(ctx context.Context, addr, authKey string) error {
+	url := fmt.Sprintf("%s/%s", addr, nativeFlushAddr)
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("cannot create request to %q: %s", url, err)
+	}
+	if authKey != "" {
+		params := req.URL.Query()
+		params.Set("authKey", authKey)
+		req.URL.RawQuery = params.Encode()
+	}
+	resp, err := c.do(req, http.StatusOK)
+	if err != nil {
+		return fmt.Errorf("force flush request to %q failed: %s", url, err)
+	}
+	_ = resp.Body.Close()
+	return nil
+}
+
+// queryResponse represents response from api/v1/query
+type queryResponse struct {
+	Status string `json:"status"`
+	Data   struct {
+		Result []json.RawMessage `json:"result"`
+	} `json:"data"`
+}
+
+// Query performs instant query via api/v1/query and returns the number of series in response.
+// In cluster mode addr must contain tenant path, e.g. http://vmselect:8481/select/0/prometheus
+func (c *Client) Query(ctx context.Context, addr, query string) (int, error) {
+	response, err := c.query(ctx, addr, query, "")
+	if err != nil {
+		return 0, err
+	}
+	return len(response.Data.Result), nil
+}
+
+// QueryValue performs instant query at ts via api/v1/query
+// and returns the value of the first series in response. Zero is returned
+// if response is empty. The query must return a single series, e.g. `sum(...)`.
+// In cluster mode addr must contain tenant path, e.g. http://vmselect:8481/select/0/prometheus
+func (c *Client) QueryValue(ctx context.Context, addr, query, ts string) (float64, error) {
+	response, err := c.query(ctx, addr, query, ts)
+	if err != nil {
+		return 0, err
+	}
+	if len(response.Data.Result) == 0 {
+		return 0, nil
+	}
+	var series struct {
+		Value []interface{} `json:"value"`
+	}
+	if err := json.Unmarshal(response.Data.Result[0], &series); err != nil {
+		return 0, fmt.Errorf("cannot decode query result: %s", err)
+	}
+	if len(series.Value) != 2 {
+		return 0, fmt.Errorf("unexpected value in query result: %v", series.Value)
+	}
+	s, ok := series.Value[1].(string)
+	if !ok {
+		return 0, fmt.Errorf("unexpected value in query result: %v", series.Value)
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("cannot parse value in query result: %s", err)
+	}
+	return v, nil
+}
+
+func (c *Client) query(ctx context.Context, addr, query, ts string) (*queryResponse, error) {
+	url := fmt.Sprintf("%s/%s", addr, nativeQueryAddr)
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create request to %q: %s", url, err)
+	}
+	params := req.URL.Query()
+	params.Set("query", query)
+	if ts != "" {
+		params.Set("time", ts)
+		// cached results may be outdated for the freshly imported data
+		params.Set("nocache", "1")
+	}
+	req.URL.RawQuery = params.Encode()
+
+	resp, err := c.do(req, http.StatusOK)
+	if err != nil {
+		return nil, fmt.Errorf("query request failed: %s", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	var response queryResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("cannot decode query response: %s", err)
+	}
+	if response.Status != "success" {
+		return nil, fmt.Errorf("unexpected query response status %q", response.Status)
+	}
+	return &response, nil
+}
+
 // ExportPipe makes request by provided filter and return io.ReadCloser which can be used to get data
 func (c *Client) ExportPipe(ctx context.Context, url string, f Filter) (io.ReadCloser, error) {
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
@@ -110,13 +362,19 @@ func (c *Client) ExportPipe(ctx context.Context, url string, f Filter) (io.ReadC
 	}
 
 	params := req.URL.Query()
-	params.Set("match[]", f.Match)
+	params.Set("match[]", f.WithExclude(f.Match))
 	if f.TimeStart != "" {
 		params.Set("start", f.TimeStart)
 	}
 	if f.TimeEnd != "" {
 		params.Set("end", f.TimeEnd)
 	}
+	if c.Format != "" {
+		params.Set("format", c.Format)
+	}
+	if c.MaxRowsPerLine > 0 {
+		params.Set("max_rows_per_line", strconv.Itoa(c.MaxRowsPerLine))
+	}
 	req.URL.RawQuery = params.Encode()
 
 	// disable compression since it is meaningless for native format
@@ -132,6 +390,8 @@ func (c *Client) ExportPipe(ctx context.Context, url string, f Filter) (io.ReadC
 // GetSourceTenants discovers tenants by provided filter
 func (c *Client) GetSourceTenants(ctx context.Context, f Filter) ([]string, error) {
 	u := fmt.Sprintf("%s/%s", c.Addr, nativeTenantsAddr)
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
 	if err != nil {
 		return nil, fmt.Errorf("cannot create request to %q: %s", u, err)
@@ -148,7 +408,7 @@ func (c *Client) GetSourceTenants(ctx context.Context, f Filter) ([]string, erro
 
 	resp, err := c.do(req, http.StatusOK)
 	if err != nil {
-		return nil, fmt.Errorf("tenants request failed: %s", err)
+		return nil, fmt.Errorf("tenants request failed: %w", err)
 	}
 
 	var r struct {
@@ -165,22 +425,141 @@ func (c *Client) GetSourceTenants(ctx context.Context, f Filter) ([]string, erro
 	return r.Tenants, nil
 }
 
+func (c *Client) httpClient() *http.Client {
+	transport := c.Transport
+	if transport == nil {
+		transport = &http.Transport{
+			DisableKeepAlives: c.DisableHTTPKeepAlive,
+			TLSClientConfig:   c.TLSConfig,
+		}
+	}
+	return &http.Client{
+		Transport:     transport,
+		CheckRedirect: c.checkRedirect,
+	}
+}
+
+// checkRedirect re-issues requests to the redirected URL within the hop limit.
+// Streaming requests with body, such as import requests, can't be safely replayed,
+// so redirect for them results in error instead of silent data loss.
+func (c *Client) checkRedirect(req *http.Request, via []*http.Request) error {
+	orig := via[0]
+	if c.DisableRedirects {
+		return fmt.Errorf("redirect from %q to %q isn't allowed; use the redirected URL as the address or allow following redirects", orig.URL, req.URL)
+	}
+	if orig.Body != nil && orig.Body != http.NoBody && orig.GetBody == nil {
+		return fmt.Errorf("cannot follow redirect from %q to %q for %s request, since its streaming body can't be replayed; use the redirected URL as the address",
+			orig.URL, req.URL, orig.Method)
+	}
+	maxRedirects := c.MaxRedirects
+	if maxRedirects <= 0 {
+		maxRedirects = defaultMaxRedirects
+	}
+	if len(via) > maxRedirects {
+		return fmt.Errorf("stopped after %d redirects from %q; last redirect to %q", maxRedirects, orig.URL, req.URL)
+	}
+	return nil
+}
+
 func (c *Client) do(req *http.Request, expSC int) (*http.Response, error) {
 	if c.AuthCfg != nil {
 		c.AuthCfg.SetHeaders(req, true)
 	}
-	var httpClient = &http.Client{Transport: &http.Transport{DisableKeepAlives: c.DisableHTTPKeepAlive}}
-	resp, err := httpClient.Do(req)
+	resp, err := c.httpClient().Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("unexpected error when performing request: %w", err)
 	}
 
+	if resp.StatusCode != expSC && resp.StatusCode >= 300 && resp.StatusCode < 400 {
+		_ = resp.Body.Close()
+		return nil, fmt.Errorf("unexpected redirect with response code %d from %q to %q; streaming %s request can't be safely replayed, use the redirected URL as the address",
+			resp.StatusCode, req.URL, resp.Header.Get("Location"), req.Method)
+	}
 	if resp.StatusCode != expSC {
 		body, err := io.ReadAll(resp.Body)
 		if err != nil {
 			return nil, fmt.Errorf("failed to read response body for status code %d: %s", resp.StatusCode, err)
 		}
-		return nil, fmt.Errorf("unexpected response code %d: %s", resp.StatusCode, string(body))
+		return nil, &StatusCodeError{StatusCode: resp.StatusCode, Body: string(body)}
 	}
 	return resp, err
 }
+
+// StatusCodeError is returned when the server responds with unexpected status code
+type StatusCodeError struct {
+	StatusCode int
+	Body       string
+}
+
+// Error implements error interface
+func (e *StatusCodeError) Error() string {
+	return fmt.Sprintf("unexpected response code %d: %s", e.StatusCode, e.Body)
+}
+
+// ServerTime returns the current time of the server at c.Addr
+// according to `Date` header of the response to a light-weight request.
+// The returned time is adjusted by half of the request round trip.
+func (c *Client) ServerTime(ctx context.Context) (time.Time, error) {
+	u := fmt.Sprintf("%s/%s", c.Addr, nativeHealthAddr)
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("cannot create request to %q: %s", u, err)
+	}
+	if c.AuthCfg != nil {
+		c.AuthCfg.SetHeaders(req, true)
+	}
+	sent := time.Now()
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("unexpected error when performing request to %q: %w", u, err)
+	}
+	received := time.Now()
+	_ = resp.Body.Close()
+
+	date := resp.Header.Get("Date")
+	if date == "" {
+		return time.Time{}, fmt.Errorf("response from %q doesn't contain Date header", u)
+	}
+	t, err := http.ParseTime(date)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("cannot parse Date header %q from %q: %s", date, u, err)
+	}
+	// Date header has seconds precision, so add half of second to reduce the average error
+	t = t.Add(500 * time.Millisecond)
+	return t.Add(-received.Sub(sent) / 2), nil
+}
+
+// Probe performs a light-weight request with the given method and empty body to u
+// in order to verify that u is reachable and accepts the configured credentials.
+// The returned error contains u and the response status code.
+func (c *Client) Probe(ctx context.Context, method, u string) error {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, method, u, nil)
+	if err != nil {
+		return fmt.Errorf("cannot create request to %q: %s", u, err)
+	}
+	if c.AuthCfg != nil {
+		c.AuthCfg.SetHeaders(req, true)
+	}
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return fmt.Errorf("%q is unreachable: %w", u, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	// the body may be huge if u points to unexpected server
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	hint := ""
+	switch resp.StatusCode {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		hint = "; check the credentials"
+	case http.StatusNotFound:
+		hint = "; check the address"
+	}
+	return fmt.Errorf("%s %q responded with status code %d%s: %s", method, u, resp.StatusCode, hint, strings.TrimSpace(string(body)))
+}
@@ -0,0 +1,452 @@
+package native
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmctl/auth"
+)
+
+func TestClientRedirects(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/old/api/v1/export/native", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/new/api/v1/export/native?"+r.URL.RawQuery, http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/new/api/v1/export/native", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("data"))
+	})
+	mux.HandleFunc("/loop", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/loop", http.StatusFound)
+	})
+	mux.HandleFunc("/old/api/v1/import/native", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/new/api/v1/import/native", http.StatusTemporaryRedirect)
+	})
+	mux.HandleFunc("/moved/api/v1/import/native", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/new/api/v1/import/native", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/new/api/v1/import/native", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	ctx := context.Background()
+	c := &Client{Addr: srv.URL}
+
+	// export requests are replayed to the redirected URL
+	r, err := c.ExportPipe(ctx, srv.URL+"/old/api/v1/export/native", Filter{Match: "{__name__!=\"\"}"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	data, _ := io.ReadAll(r)
+	_ = r.Close()
+	if string(data) != "data" {
+		t.Fatalf("unexpected data; got %q; want %q", data, "data")
+	}
+
+	// hop limit
+	c.MaxRedirects = 3
+	if _, err := c.ExportPipe(ctx, srv.URL+"/loop", Filter{}); err == nil || !strings.Contains(err.Error(), "stopped after 3 redirects") {
+		t.Fatalf("expected hop limit error; got %v", err)
+	}
+
+	// streaming import requests can't be replayed
+	for _, path := range []string{"/old/api/v1/import/native", "/moved/api/v1/import/native"} {
+		pr, pw := io.Pipe()
+		go func() {
+			_, _ = pw.Write([]byte("data"))
+			_ = pw.Close()
+		}()
+		err = c.ImportPipe(ctx, srv.URL+path, pr, nil)
+		if err == nil || !strings.Contains(err.Error(), "redirected URL") {
+			t.Fatalf("expected redirect error for %q; got %v", path, err)
+		}
+	}
+
+	// redirects are denied
+	c.DisableRedirects = true
+	if _, err := c.ExportPipe(ctx, srv.URL+"/old/api/v1/export/native", Filter{}); err == nil || !strings.Contains(err.Error(), "isn't allowed") {
+		t.Fatalf("expected error for denied redirect; got %v", err)
+	}
+}
+
+func TestClientHeaders(t *testing.T) {
+	var mu sync.Mutex
+	paths := make(map[string]bool)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, password, ok := r.BasicAuth()
+		if !ok || user != "foo" || password != "bar" {
+			t.Errorf("unexpected basic auth for %q: %q:%q", r.URL.Path, user, password)
+		}
+		if v := r.Header.Get("X-Scope-OrgID"); v != "tenant1" {
+			t.Errorf("unexpected X-Scope-OrgID header for %q: %q", r.URL.Path, v)
+		}
+		if v := r.Header.Get("X-Env"); v != "prod" {
+			t.Errorf("unexpected X-Env header for %q: %q", r.URL.Path, v)
+		}
+		mu.Lock()
+		paths[r.URL.Path] = true
+		mu.Unlock()
+		switch r.URL.Path {
+		case "/api/v1/series":
+			_, _ = w.Write([]byte(`{"status":"success","data":[{"__name__":"foo"}]}`))
+		case "/admin/tenants":
+			_, _ = w.Write([]byte(`{"status":"success","data":["0:0"]}`))
+		case "/api/v1/export/native":
+			_, _ = w.Write([]byte("data"))
+		case "/api/v1/import/native":
+			_, _ = io.Copy(io.Discard, r.Body)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	ac, err := auth.Generate(auth.WithBasicAuth("foo", "bar"), auth.WithHeaders("X-Scope-OrgID: tenant1^^X-Env: prod"))
+	if err != nil {
+		t.Fatalf("cannot create auth config: %s", err)
+	}
+	ctx := context.Background()
+	c := &Client{Addr: srv.URL, AuthCfg: ac}
+	if _, err := c.Explore(ctx, Filter{Match: "foo"}, ""); err != nil {
+		t.Fatalf("unexpected explore error: %s", err)
+	}
+	if _, err := c.GetSourceTenants(ctx, Filter{}); err != nil {
+		t.Fatalf("unexpected tenants error: %s", err)
+	}
+	r, err := c.ExportPipe(ctx, srv.URL+"/api/v1/export/native", Filter{Match: "foo"})
+	if err != nil {
+		t.Fatalf("unexpected export error: %s", err)
+	}
+	_ = r.Close()
+	pr, pw := io.Pipe()
+	go func() {
+		_, _ = pw.Write([]byte("data"))
+		_ = pw.Close()
+	}()
+	if err := c.ImportPipe(ctx, srv.URL+"/api/v1/import/native", pr, nil); err != nil {
+		t.Fatalf("unexpected import error: %s", err)
+	}
+	if len(paths) != 4 {
+		t.Fatalf("expecting requests to 4 handlers; got %v", paths)
+	}
+}
+
+func TestClientQuery(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/query" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if q := r.URL.Query().Get("query"); q != "up" {
+			t.Errorf("unexpected query %q", q)
+		}
+		_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[1,"1"]},{"metric":{},"value":[1,"0"]}]}}`))
+	}))
+	defer srv.Close()
+
+	c := &Client{Addr: srv.URL}
+	n, err := c.Query(context.Background(), srv.URL, "up")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if n != 2 {
+		t.Fatalf("expecting 2 series; got %d", n)
+	}
+	if _, err := c.Query(context.Background(), srv.URL+"/missing", "up"); err == nil {
+		t.Fatalf("expecting error for missing path")
+	}
+}
+
+func TestClientExportMaxRowsPerLine(t *testing.T) {
+	var got []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, r.URL.Query().Get("max_rows_per_line"))
+	}))
+	defer srv.Close()
+
+	for _, n := range []int{0, 100} {
+		c := &Client{Addr: srv.URL, MaxRowsPerLine: n}
+		r, err := c.ExportPipe(context.Background(), srv.URL+"/api/v1/export", Filter{Match: "foo"})
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		_ = r.Close()
+	}
+	if len(got) != 2 || got[0] != "" || got[1] != "100" {
+		t.Fatalf("unexpected max_rows_per_line args %q; want [\"\" \"100\"]", got)
+	}
+}
+
+func TestClientRequestTimeout(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	c := &Client{Addr: srv.URL, RequestTimeout: 50 * time.Millisecond}
+	if _, err := c.Explore(context.Background(), Filter{Match: "foo"}, ""); err == nil || !strings.Contains(err.Error(), "deadline exceeded") {
+		t.Fatalf("expecting timeout error for explore; got %v", err)
+	}
+	if _, err := c.GetSourceTenants(context.Background(), Filter{}); err == nil || !strings.Contains(err.Error(), "deadline exceeded") {
+		t.Fatalf("expecting timeout error for tenants; got %v", err)
+	}
+}
+
+func TestClientTLS(t *testing.T) {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+			t.Errorf("expecting client certificate")
+		}
+		_, _ = w.Write([]byte(`{"status":"success","data":["0:0"]}`))
+	}))
+	srv.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	srv.StartTLS()
+	defer srv.Close()
+
+	ctx := context.Background()
+	rootCAs := x509.NewCertPool()
+	rootCAs.AddCert(srv.Certificate())
+	c := &Client{Addr: srv.URL, TLSConfig: &tls.Config{
+		RootCAs:      rootCAs,
+		Certificates: srv.TLS.Certificates,
+	}}
+	if _, err := c.GetSourceTenants(ctx, Filter{}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// the server certificate isn't trusted by default
+	c = &Client{Addr: srv.URL, TLSConfig: &tls.Config{Certificates: srv.TLS.Certificates}}
+	if _, err := c.GetSourceTenants(ctx, Filter{}); err == nil {
+		t.Fatalf("expecting certificate verification error")
+	}
+	c.TLSConfig.InsecureSkipVerify = true
+	if _, err := c.GetSourceTenants(ctx, Filter{}); err != nil {
+		t.Fatalf("unexpected error with skipped verification: %s", err)
+	}
+
+	// the server requires client certificate
+	c = &Client{Addr: srv.URL, TLSConfig: &tls.Config{RootCAs: rootCAs}}
+	if _, err := c.GetSourceTenants(ctx, Filter{}); err == nil {
+		t.Fatalf("expecting error without client certificate")
+	}
+}
+
+func TestClientPooledTransport(t *testing.T) {
+	const conns = 3
+	var newConns int32
+	var inflight, maxInflight int32
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&inflight, 1)
+		for {
+			m := atomic.LoadInt32(&maxInflight)
+			if n <= m || atomic.CompareAndSwapInt32(&maxInflight, m, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		atomic.AddInt32(&inflight, -1)
+		_, _ = w.Write([]byte("data"))
+	}))
+	srv.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&newConns, 1)
+		}
+	}
+	srv.Start()
+	defer srv.Close()
+
+	c := &Client{Addr: srv.URL, Transport: NewPooledTransport(conns, false)}
+	var wg sync.WaitGroup
+	for i := 0; i < 4*conns; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r, err := c.ExportPipe(context.Background(), srv.URL+"/api/v1/export/native", Filter{Match: "foo"})
+			if err != nil {
+				t.Errorf("unexpected error: %s", err)
+				return
+			}
+			_, _ = io.Copy(io.Discard, r)
+			_ = r.Close()
+		}()
+	}
+	wg.Wait()
+
+	if n := atomic.LoadInt32(&newConns); n != conns {
+		t.Fatalf("expecting %d connections; got %d", conns, n)
+	}
+	if n := atomic.LoadInt32(&maxInflight); n != conns {
+		t.Fatalf("expecting %d concurrent requests; got %d", conns, n)
+	}
+}
+
+func TestClientTransportHTTP2(t *testing.T) {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.Proto))
+	}))
+	srv.EnableHTTP2 = true
+	srv.StartTLS()
+	defer srv.Close()
+
+	f := func(forceHTTP2 bool, expProto string) {
+		t.Helper()
+		tlsConfig := &tls.Config{InsecureSkipVerify: true}
+		c := &Client{Addr: srv.URL, Transport: NewTransport(TransportConfig{MaxIdleConns: 4, ForceHTTP2: forceHTTP2}, tlsConfig)}
+		r, err := c.ExportPipe(context.Background(), srv.URL+"/api/v1/export/native", Filter{Match: "foo"})
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		defer func() { _ = r.Close() }()
+		proto, _ := io.ReadAll(r)
+		if string(proto) != expProto {
+			t.Fatalf("unexpected protocol %q; want %q", proto, expProto)
+		}
+	}
+	f(false, "HTTP/1.1")
+	f(true, "HTTP/2.0")
+}
+
+// BenchmarkClientTransport reports the number of new connections per request
+// for clients with a new transport per request and with a shared tuned transport.
+func BenchmarkClientTransport(b *testing.B) {
+	var newConns int64
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("data"))
+	}))
+	srv.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt64(&newConns, 1)
+		}
+	}
+	srv.Start()
+	defer srv.Close()
+
+	f := func(name string, c *Client) {
+		b.Run(name, func(b *testing.B) {
+			atomic.StoreInt64(&newConns, 0)
+			b.SetParallelism(4)
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					r, err := c.ExportPipe(context.Background(), srv.URL+"/api/v1/export/native", Filter{Match: "foo"})
+					if err != nil {
+						panic(err)
+					}
+					_, _ = io.Copy(io.Discard, r)
+					_ = r.Close()
+				}
+			})
+			b.ReportMetric(float64(atomic.LoadInt64(&newConns))/float64(b.N), "conns/op")
+		})
+	}
+	f("transport-per-request", &Client{Addr: srv.URL})
+	f("shared-transport", &Client{Addr: srv.URL, Transport: NewTransport(TransportConfig{MaxIdleConns: 64}, nil)})
+}
+
+func TestClientQueryValue(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if ts := q.Get("time"); ts != "2022-01-01T00:00:00Z" {
+			t.Errorf("unexpected time %q", ts)
+		}
+		switch q.Get("query") {
+		case "sum(foo)":
+			_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[1640995200,"42"]}]}}`))
+		case "sum(bar)":
+			_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[]}}`))
+		default:
+			_, _ = w.Write([]byte(`{"status":"error"}`))
+		}
+	}))
+	defer srv.Close()
+
+	c := &Client{Addr: srv.URL}
+	f := func(query string, want float64, wantErr bool) {
+		t.Helper()
+		v, err := c.QueryValue(context.Background(), srv.URL, query, "2022-01-01T00:00:00Z")
+		if wantErr {
+			if err == nil {
+				t.Fatalf("expecting error for query %q", query)
+			}
+			return
+		}
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if v != want {
+			t.Fatalf("unexpected value for query %q; got %v; want %v", query, v, want)
+		}
+	}
+	f("sum(foo)", 42, false)
+	f("sum(bar)", 0, false)
+	f("baz", 0, true)
+}
+
+func TestClientProbe(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "foo" || pass != "bar" {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte("missing credentials"))
+			return
+		}
+		switch r.URL.Path {
+		case "/api/v1/status/buildinfo":
+			_, _ = w.Write([]byte(`{"status":"success"}`))
+		case "/api/v1/import/native":
+			if r.Method != http.MethodPost {
+				t.Errorf("unexpected method %q", r.Method)
+			}
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	authCfg, err := auth.Generate(auth.WithBasicAuth("foo", "bar"))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	c := &Client{Addr: srv.URL, AuthCfg: authCfg}
+	f := func(c *Client, method, path, expErr string) {
+		t.Helper()
+		err := c.Probe(context.Background(), method, srv.URL+path)
+		if expErr == "" {
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			return
+		}
+		if err == nil || !strings.Contains(err.Error(), expErr) {
+			t.Fatalf("expecting error containing %q; got %v", expErr, err)
+		}
+		if !strings.Contains(err.Error(), srv.URL+path) {
+			t.Fatalf("expecting url %q in error; got %v", srv.URL+path, err)
+		}
+	}
+	f(c, http.MethodGet, "/api/v1/status/buildinfo", "")
+	f(c, http.MethodPost, "/api/v1/import/native", "")
+	f(c, http.MethodGet, "/typo/api/v1/status/buildinfo", "status code 404; check the address")
+	f(&Client{Addr: srv.URL}, http.MethodGet, "/api/v1/status/buildinfo", "status code 401; check the credentials: missing credentials")
+
+	unreachable := &Client{Addr: "http://127.0.0.1:1"}
+	if err := unreachable.Probe(context.Background(), http.MethodGet, "http://127.0.0.1:1/health"); err == nil || !strings.Contains(err.Error(), "is unreachable") {
+		t.Fatalf("expecting error for unreachable address; got %v", err)
+	}
+}
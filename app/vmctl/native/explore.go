@@ -0,0 +1,219 @@
+package native
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// metricNameAlphabet contains characters allowed in metric names.
+// Pages for names with other characters aren't split further.
+const metricNameAlphabet = "0123456789:ABCDEFGHIJKLMNOPQRSTUVWXYZ_abcdefghijklmnopqrstuvwxyz"
+
+// maxExplorePrefixLen limits the depth of splitting pages by metric name prefix
+const maxExplorePrefixLen = 16
+
+// errTooManySeries is a part of VictoriaMetrics error message returned
+// when the number of matching series exceeds the `limit` query arg
+const errTooManySeries = "the number of matching timeseries exceeds"
+
+// explorePage defines a subset of metric names requested via a single api/v1/series request
+type explorePage struct {
+	prefix string
+	// exact means the page matches only the metric name equal to prefix
+	exact bool
+	// other means the page matches names starting with prefix followed by a character out of metricNameAlphabet
+	other bool
+}
+
+func (ep explorePage) regex() string {
+	prefix := regexp.QuoteMeta(ep.prefix)
+	switch {
+	case ep.exact:
+		return prefix
+	case ep.other:
+		return prefix + "[^" + regexp.QuoteMeta(metricNameAlphabet) + "].*"
+	default:
+		return prefix + ".*"
+	}
+}
+
+// split returns pages covering the same names as ep
+func (ep explorePage) split() []explorePage {
+	var pages []explorePage
+	if ep.prefix != "" {
+		pages = append(pages, explorePage{prefix: ep.prefix, exact: true})
+	}
+	for _, c := range metricNameAlphabet {
+		pages = append(pages, explorePage{prefix: ep.prefix + string(c)})
+	}
+	return append(pages, explorePage{prefix: ep.prefix, other: true})
+}
+
+func (ep explorePage) canSplit() bool {
+	return !ep.exact && !ep.other && len(ep.prefix) < maxExplorePrefixLen
+}
+
+// ExplorePaged finds metric names like Explore, but splits discovery into pages
+// by metric name prefix, so every api/v1/series response contains less than limit series.
+// Pages reaching the limit are split further by the next character of metric name.
+// It falls back to a single api/v1/series request if the source doesn't support paging.
+// Optional beforePage is called before every request.
+// It returns the number of performed requests.
+func (c *Client) ExplorePaged(ctx context.Context, f Filter, tenantID string, limit int, beforePage func()) (map[string]struct{}, int, error) {
+	url := c.seriesURL(tenantID)
+	names := make(map[string]struct{})
+	queue := explorePage{}.split()
+	requests := 0
+	for len(queue) > 0 {
+		ep := queue[0]
+		queue = queue[1:]
+		if beforePage != nil {
+			beforePage()
+		}
+		requests++
+		pageNames := make(map[string]struct{})
+		n, err := c.explorePage(ctx, url, f, addNameMatcher(f.Match, ep.regex()), limit, pageNames)
+		tooMany := n >= limit || (err != nil && strings.Contains(err.Error(), errTooManySeries))
+		if err != nil && !tooMany {
+			if requests == 1 {
+				// the source may not support paging, e.g. because of unsupported matchers
+				if beforePage != nil {
+					beforePage()
+				}
+				names, err := c.Explore(ctx, f, tenantID)
+				return names, requests + 1, err
+			}
+			return nil, requests, err
+		}
+		if tooMany {
+			if !ep.canSplit() {
+				return nil, requests, fmt.Errorf("cannot split page of metric names matching %q into pages with less than %d series", ep.regex(), limit)
+			}
+			queue = append(ep.split(), queue...)
+			continue
+		}
+		for name := range pageNames {
+			names[name] = struct{}{}
+		}
+	}
+	return names, requests, nil
+}
+
+// addNameMatcher adds `__name__=~"re"` matcher to the series selector match
+func addNameMatcher(match, re string) string {
+	return addMatchers(match, nameLabel+"=~"+strconv.Quote(re))
+}
+
+// addMatchers adds comma-separated matchers to the series selector match
+func addMatchers(match, matcher string) string {
+	if !strings.HasSuffix(match, "}") {
+		// metric name selector, e.g. `foo`
+		return match + "{" + matcher + "}"
+	}
+	match = strings.TrimSuffix(match, "}")
+	if strings.HasSuffix(match, "{") {
+		return match + matcher + "}"
+	}
+	return match + "," + matcher + "}"
+}
+
+// ExploreStream finds metric names like Explore, but sends them to names as soon as they are read
+// from api/v1/series response instead of loading the whole response into memory.
+// Every metric name is sent only once. names isn't closed by ExploreStream.
+func (c *Client) ExploreStream(ctx context.Context, f Filter, tenantID string, names chan<- string) error {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+	url := c.seriesURL(tenantID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("cannot create request to %q: %s", url, err)
+	}
+	params := req.URL.Query()
+	setSeriesParams(params, f, f.Match, 0)
+	req.URL.RawQuery = params.Encode()
+
+	resp, err := c.do(req, http.StatusOK)
+	if err != nil {
+		return fmt.Errorf("series request failed: %s", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if err := streamSeriesNames(ctx, resp.Body, names); err != nil {
+		return fmt.Errorf("cannot decode series response: %w", err)
+	}
+	return nil
+}
+
+// streamSeriesNames decodes api/v1/series response from r series by series
+// and sends distinct metric names to names
+func streamSeriesNames(ctx context.Context, r io.Reader, names chan<- string) error {
+	dec := json.NewDecoder(r)
+	if err := expectDelim(dec, '{'); err != nil {
+		return err
+	}
+	seen := make(map[string]struct{})
+	for dec.More() {
+		t, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		switch t {
+		case "status":
+			var status string
+			if err := dec.Decode(&status); err != nil {
+				return err
+			}
+			if status != "success" {
+				return fmt.Errorf("unexpected response status %q", status)
+			}
+		case "data":
+			if err := expectDelim(dec, '['); err != nil {
+				return err
+			}
+			for dec.More() {
+				var series LabelValues
+				if err := dec.Decode(&series); err != nil {
+					return err
+				}
+				name, ok := series[nameLabel]
+				if !ok {
+					continue
+				}
+				if _, ok := seen[name]; ok {
+					continue
+				}
+				seen[name] = struct{}{}
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case names <- name:
+				}
+			}
+			if err := expectDelim(dec, ']'); err != nil {
+				return err
+			}
+		default:
+			var skip json.RawMessage
+			if err := dec.Decode(&skip); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func expectDelim(dec *json.Decoder, delim json.Delim) error {
+	t, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if t != delim {
+		return fmt.Errorf("unexpected token %v; want %q", t, delim)
+	}
+	return nil
+}
@@ -0,0 +1,183 @@
+package native
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func newExploreServer(t *testing.T, names []string, supportPaging bool) *httptest.Server {
+	matcherRe := regexp.MustCompile(`__name__=~("(?:[^"\\]|\\.)*")`)
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		match := r.URL.Query().Get("match[]")
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		var re *regexp.Regexp
+		if m := matcherRe.FindStringSubmatch(match); m != nil {
+			if !supportPaging {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			expr, err := strconv.Unquote(m[1])
+			if err != nil {
+				t.Errorf("cannot unquote %q: %s", m[1], err)
+			}
+			re = regexp.MustCompile("^(?:" + expr + ")$")
+		}
+		var series []string
+		for _, name := range names {
+			if re == nil || re.MatchString(name) {
+				series = append(series, fmt.Sprintf(`{"__name__":%q}`, name))
+			}
+		}
+		if limit > 0 && len(series) > limit {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			_, _ = fmt.Fprintf(w, "the number of matching timeseries exceeds %d; either narrow down the search", limit)
+			return
+		}
+		_, _ = fmt.Fprintf(w, `{"status":"success","data":[%s]}`, strings.Join(series, ","))
+	}))
+}
+
+func TestClientExplorePaged(t *testing.T) {
+	var names []string
+	for i := 0; i < 50; i++ {
+		names = append(names, fmt.Sprintf("foo_%d", i))
+	}
+	names = append(names, "foo", "bar", "a.b", "ß")
+
+	f := func(supportPaging bool, limit, expMinRequests int) {
+		t.Helper()
+		srv := newExploreServer(t, names, supportPaging)
+		defer srv.Close()
+
+		c := &Client{Addr: srv.URL}
+		calls := 0
+		got, requests, err := c.ExplorePaged(context.Background(), Filter{Match: `{__name__!=""}`}, "", limit, func() { calls++ })
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if len(got) != len(names) {
+			t.Fatalf("expecting %d metrics; got %d: %v", len(names), len(got), got)
+		}
+		for _, name := range names {
+			if _, ok := got[name]; !ok {
+				t.Fatalf("missing metric %q", name)
+			}
+		}
+		if requests < expMinRequests || calls != requests {
+			t.Fatalf("unexpected number of requests %d; beforePage calls: %d", requests, calls)
+		}
+	}
+	f(true, 20, len(metricNameAlphabet)+1)
+	f(true, 1000, len(metricNameAlphabet)+1)
+	// fallback to single-shot discovery
+	f(false, 20, 2)
+}
+
+func TestAddNameMatcher(t *testing.T) {
+	f := func(match, exp string) {
+		t.Helper()
+		if got := addNameMatcher(match, "a.*"); got != exp {
+			t.Fatalf("unexpected result for %q; got %s; want %s", match, got, exp)
+		}
+	}
+	f(`{__name__!=""}`, `{__name__!="",__name__=~"a.*"}`)
+	f(`{}`, `{__name__=~"a.*"}`)
+	f(`foo`, `foo{__name__=~"a.*"}`)
+	f(`foo{job="bar"}`, `foo{job="bar",__name__=~"a.*"}`)
+}
+
+func TestFilterWithExclude(t *testing.T) {
+	f := func(exclude, match, exp string) {
+		t.Helper()
+		got := Filter{Exclude: exclude}.WithExclude(match)
+		if got != exp {
+			t.Fatalf("unexpected result for %q and %q; got %s; want %s", match, exclude, got, exp)
+		}
+	}
+	f(``, `{__name__!=""}`, `{__name__!=""}`)
+	f(`{__name__!~"go_.*"}`, `{__name__=~"foo.*"}`, `{__name__=~"foo.*",__name__!~"go_.*"}`)
+	f(`{__name__!~"go_.*", job!="test"}`, `foo`, `foo{__name__!~"go_.*", job!="test"}`)
+}
+
+func TestClientExploreExclude(t *testing.T) {
+	var gotMatch string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotMatch = r.URL.Query().Get("match[]")
+		_, _ = fmt.Fprint(w, `{"status":"success","data":[{"__name__":"foo_bar"}]}`)
+	}))
+	defer srv.Close()
+
+	c := &Client{Addr: srv.URL}
+	f := Filter{Match: `{__name__=~"foo_.*"}`, Exclude: `{__name__!~"foo_baz.*"}`}
+	if _, err := c.Explore(context.Background(), f, ""); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if exp := `{__name__=~"foo_.*",__name__!~"foo_baz.*"}`; gotMatch != exp {
+		t.Fatalf("unexpected match[] in explore request; got %s; want %s", gotMatch, exp)
+	}
+	r, err := c.ExportPipe(context.Background(), srv.URL, f)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	_ = r.Close()
+	if exp := `{__name__=~"foo_.*",__name__!~"foo_baz.*"}`; gotMatch != exp {
+		t.Fatalf("unexpected match[] in export request; got %s; want %s", gotMatch, exp)
+	}
+}
+
+func TestExploreStream(t *testing.T) {
+	names := []string{"foo", "bar", "foo_bar"}
+	srv := newExploreServer(t, append(names, "foo"), false)
+	defer srv.Close()
+
+	c := &Client{Addr: srv.URL}
+	ch := make(chan string)
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- c.ExploreStream(context.Background(), Filter{Match: `{__name__!=""}`}, "", ch)
+		close(ch)
+	}()
+	var got []string
+	for name := range ch {
+		got = append(got, name)
+	}
+	if err := <-errCh; err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	// duplicate names are sent once
+	if strings.Join(got, ",") != strings.Join(names, ",") {
+		t.Fatalf("unexpected names; got %q; want %q", got, names)
+	}
+}
+
+func TestStreamSeriesNames(t *testing.T) {
+	f := func(data string, expNames []string, expErr bool) {
+		t.Helper()
+		ch := make(chan string, 10)
+		err := streamSeriesNames(context.Background(), strings.NewReader(data), ch)
+		close(ch)
+		if (err != nil) != expErr {
+			t.Fatalf("unexpected error for %q: %v", data, err)
+		}
+		var got []string
+		for name := range ch {
+			got = append(got, name)
+		}
+		if strings.Join(got, ",") != strings.Join(expNames, ",") {
+			t.Fatalf("unexpected names for %q; got %q; want %q", data, got, expNames)
+		}
+	}
+	f(`{"status":"success","data":[{"__name__":"foo","job":"a"},{"job":"b"},{"__name__":"foo"},{"__name__":"bar"}]}`, []string{"foo", "bar"}, false)
+	f(`{"data":[{"__name__":"foo"}],"status":"success","isPartial":false}`, []string{"foo"}, false)
+	f(`{"status":"success","data":[]}`, nil, false)
+	f(`{"status":"error","data":[{"__name__":"foo"}]}`, nil, true)
+	// names are sent before the response is fully read
+	f(`{"status":"success","data":[{"__name__":"foo"},`, []string{"foo"}, true)
+	f(`[]`, nil, true)
+}
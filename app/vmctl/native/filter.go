@@ -1,6 +1,9 @@
 package native
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+)
 
 // Filter represents request filter
 type Filter struct {
@@ -8,10 +11,26 @@ type Filter struct {
 	TimeStart string
 	TimeEnd   string
 	Chunk     string
+	// Exclude is an optional series selector with negative matchers only,
+	// e.g. `{__name__!~"go_.*"}`. Its matchers are added to series selectors
+	// of export and discovery requests, so matching series are excluded.
+	Exclude string
+}
+
+// WithExclude returns the series selector match with f.Exclude matchers added to it.
+func (f Filter) WithExclude(match string) string {
+	matchers := strings.TrimSuffix(strings.TrimPrefix(f.Exclude, "{"), "}")
+	if matchers == "" {
+		return match
+	}
+	return addMatchers(match, matchers)
 }
 
 func (f Filter) String() string {
 	s := fmt.Sprintf("\n\tfilter: match[]=%s", f.Match)
+	if f.Exclude != "" {
+		s += fmt.Sprintf("\n\texclude: %s", f.Exclude)
+	}
 	if f.TimeStart != "" {
 		s += fmt.Sprintf("\n\tstart: %s", f.TimeStart)
 	}
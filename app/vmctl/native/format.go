@@ -0,0 +1,126 @@
+package native
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const nativeMetricsAddr = "metrics"
+
+const (
+	// FormatV1 is the native format supported by VictoriaMetrics since v1.42.0
+	FormatV1 = "native-v1"
+	// FormatV2 is the next version of native format supported by VictoriaMetrics since v1.91.0
+	FormatV2 = "native-v2"
+)
+
+// SupportedFormats contains the list of native format versions
+// which could be requested via `format` query arg
+var SupportedFormats = []string{FormatV1, FormatV2}
+
+// formatMinVersions contains the minimum version of VictoriaMetrics supporting every format
+var formatMinVersions = map[string]version{
+	FormatV1: {1, 42, 0},
+	FormatV2: {1, 91, 0},
+}
+
+// ErrUnknownVersion is returned by CheckFormat if the version of VictoriaMetrics can't be determined,
+// e.g. if /metrics page isn't exposed via proxy in front of VictoriaMetrics.
+var ErrUnknownVersion = errors.New("cannot determine VictoriaMetrics version")
+
+// CheckFormat verifies that c.Format is supported by VictoriaMetrics at addr
+// according to its version exposed via vm_app_version metric at /metrics page.
+// addr must point to the root of VictoriaMetrics component,
+// e.g. http://vmsingle:8428, http://vmselect:8481 or http://vminsert:8480.
+// The returned error wraps ErrUnknownVersion if the version can't be determined.
+func (c *Client) CheckFormat(ctx context.Context, addr string) error {
+	if c.Format == "" {
+		return nil
+	}
+	minVersion, ok := formatMinVersions[c.Format]
+	if !ok {
+		return fmt.Errorf("unsupported native format %q; supported formats: %s", c.Format, strings.Join(SupportedFormats, ", "))
+	}
+	v, err := c.version(ctx, addr)
+	if err != nil {
+		return fmt.Errorf("%w at %q: %s", ErrUnknownVersion, addr, err)
+	}
+	if v.less(minVersion) {
+		return fmt.Errorf("native format %q isn't supported by %q running %s; it requires VictoriaMetrics %s or newer",
+			c.Format, addr, v, minVersion)
+	}
+	return nil
+}
+
+// version returns the version of VictoriaMetrics at addr
+func (c *Client) version(ctx context.Context, addr string) (version, error) {
+	u := fmt.Sprintf("%s/%s", addr, nativeMetricsAddr)
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return version{}, fmt.Errorf("cannot create request to %q: %s", u, err)
+	}
+	resp, err := c.do(req, http.StatusOK)
+	if err != nil {
+		return version{}, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	sc := bufio.NewScanner(resp.Body)
+	sc.Buffer(nil, 1024*1024)
+	for sc.Scan() {
+		line := sc.Text()
+		if !strings.HasPrefix(line, "vm_app_version{") {
+			continue
+		}
+		return parseVersion(line)
+	}
+	if err := sc.Err(); err != nil {
+		return version{}, fmt.Errorf("cannot read response from %q: %s", u, err)
+	}
+	return version{}, fmt.Errorf("response from %q doesn't contain vm_app_version metric", u)
+}
+
+// version is a semantic version of VictoriaMetrics
+type version [3]int
+
+var versionRe = regexp.MustCompile(`short_version="v(\d+)\.(\d+)\.(\d+)`)
+
+// parseVersion parses version from vm_app_version metric line, e.g.:
+//
+//	vm_app_version{version="victoria-metrics-20230407-010146-tags-v1.90.0-0-gb5d18c0d2", short_version="v1.90.0"} 1
+func parseVersion(line string) (version, error) {
+	m := versionRe.FindStringSubmatch(line)
+	if m == nil {
+		return version{}, fmt.Errorf("cannot find short_version in %q", line)
+	}
+	var v version
+	for i := range v {
+		n, err := strconv.Atoi(m[i+1])
+		if err != nil {
+			return version{}, fmt.Errorf("cannot parse version in %q: %s", line, err)
+		}
+		v[i] = n
+	}
+	return v, nil
+}
+
+func (v version) less(o version) bool {
+	for i := range v {
+		if v[i] != o[i] {
+			return v[i] < o[i]
+		}
+	}
+	return false
+}
+
+func (v version) String() string {
+	return fmt.Sprintf("v%d.%d.%d", v[0], v[1], v[2])
+}
@@ -0,0 +1,61 @@
+package native
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCheckFormat(t *testing.T) {
+	var page string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/metrics" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(page))
+	}))
+	defer srv.Close()
+
+	f := func(format, metricsPage, expErr string) {
+		t.Helper()
+		page = metricsPage
+		c := &Client{Addr: srv.URL, Format: format}
+		err := c.CheckFormat(context.Background(), srv.URL)
+		if expErr == "" {
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			return
+		}
+		if err == nil || !strings.Contains(err.Error(), expErr) {
+			t.Fatalf("expecting error containing %q; got %v", expErr, err)
+		}
+	}
+	v190 := "vm_app_uptime_seconds 10\n" +
+		`vm_app_version{version="victoria-metrics-20230407-010146-tags-v1.90.0-0-gb5d18c0d2", short_version="v1.90.0"} 1` + "\n"
+	v191 := `vm_app_version{version="vminsert-20230501-000000-tags-v1.91.0-cluster-0-g0", short_version="v1.91.0"} 1` + "\n"
+
+	f("", "", "")
+	f("native-v3", v191, "unsupported native format")
+	f(FormatV1, v190, "")
+	f(FormatV2, v191, "")
+	f(FormatV2, v190, `isn't supported by "`+srv.URL+`" running v1.90.0; it requires VictoriaMetrics v1.91.0 or newer`)
+	f(FormatV1, `vm_app_version{version="victoria-metrics-20200101-000000-tags-v1.41.1-0-g0", short_version="v1.41.1"} 1`, "requires VictoriaMetrics v1.42.0")
+
+	// the version of dev builds and endpoints without /metrics page is unknown
+	for _, metricsPage := range []string{"vm_app_uptime_seconds 10\n", `vm_app_version{version="victoria-metrics-dev", short_version=""} 1`} {
+		page = metricsPage
+		c := &Client{Addr: srv.URL, Format: FormatV2}
+		if err := c.CheckFormat(context.Background(), srv.URL); !errors.Is(err, ErrUnknownVersion) {
+			t.Fatalf("expecting ErrUnknownVersion; got %v", err)
+		}
+	}
+	c := &Client{Addr: srv.URL, Format: FormatV2}
+	if err := c.CheckFormat(context.Background(), srv.URL+"/proxy"); !errors.Is(err, ErrUnknownVersion) {
+		t.Fatalf("expecting ErrUnknownVersion; got %v", err)
+	}
+}
@@ -0,0 +1,242 @@
+package native
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/decimal"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/encoding"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/storage"
+)
+
+// maxBlockPartSize is the maximum size of metric name or block
+// in native format accepted by VictoriaMetrics import
+const maxBlockPartSize = 1024 * 1024
+
+// Block is a single decoded block of series samples from native format stream
+type Block struct {
+	MetricName storage.MetricName
+	Timestamps []int64
+	Values     []float64
+}
+
+// Reset resets b
+func (b *Block) Reset() {
+	b.MetricName.Reset()
+	b.Timestamps = b.Timestamps[:0]
+	b.Values = b.Values[:0]
+}
+
+// Decoder sequentially reads blocks from native format stream.
+// Unlike lib/protoparser/native/stream, it doesn't process blocks concurrently,
+// so blocks are returned in the order of the stream.
+type Decoder struct {
+	br *bufio.Reader
+	tr storage.TimeRange
+
+	headerRead bool
+	sizeBuf    []byte
+	buf        []byte
+	block      storage.Block
+}
+
+// NewDecoder returns new Decoder reading from r
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{
+		br:      bufio.NewReaderSize(r, 64*1024),
+		sizeBuf: make([]byte, 4),
+	}
+}
+
+// TimeRange returns the time range of the stream
+func (d *Decoder) TimeRange() (storage.TimeRange, error) {
+	if err := d.readHeader(); err != nil {
+		return storage.TimeRange{}, err
+	}
+	return d.tr, nil
+}
+
+func (d *Decoder) readHeader() error {
+	if d.headerRead {
+		return nil
+	}
+	trBuf := make([]byte, 16)
+	if _, err := io.ReadFull(d.br, trBuf); err != nil {
+		return fmt.Errorf("cannot read time range: %w", err)
+	}
+	d.tr.MinTimestamp = encoding.UnmarshalInt64(trBuf)
+	d.tr.MaxTimestamp = encoding.UnmarshalInt64(trBuf[8:])
+	d.headerRead = true
+	return nil
+}
+
+// Next reads the next block from the stream into b.
+// Only samples within the stream time range are returned.
+// It returns io.EOF when the stream ends.
+func (d *Decoder) Next(b *Block) error {
+	if err := d.readHeader(); err != nil {
+		return err
+	}
+	b.Reset()
+
+	mnBuf, err := d.readPart("metricName", true)
+	if err != nil {
+		return err
+	}
+	if err := b.MetricName.Unmarshal(mnBuf); err != nil {
+		return fmt.Errorf("cannot unmarshal metricName from %d bytes: %w", len(mnBuf), err)
+	}
+
+	blockBuf, err := d.readPart("native block", false)
+	if err != nil {
+		return err
+	}
+	tail, err := d.block.UnmarshalPortable(blockBuf)
+	if err != nil {
+		return fmt.Errorf("cannot unmarshal native block from %d bytes: %w", len(blockBuf), err)
+	}
+	if len(tail) > 0 {
+		return fmt.Errorf("unexpected non-empty tail left after unmarshaling native block from %d bytes; len(tail)=%d bytes", len(blockBuf), len(tail))
+	}
+	b.Timestamps, b.Values = d.block.AppendRowsWithTimeRangeFilter(b.Timestamps[:0], b.Values[:0], d.tr)
+	return nil
+}
+
+func (d *Decoder) readPart(name string, eofAllowed bool) ([]byte, error) {
+	if _, err := io.ReadFull(d.br, d.sizeBuf); err != nil {
+		if err == io.EOF && eofAllowed {
+			return nil, io.EOF
+		}
+		return nil, fmt.Errorf("cannot read %s size: %w", name, err)
+	}
+	size := encoding.UnmarshalUint32(d.sizeBuf)
+	if size > maxBlockPartSize {
+		return nil, fmt.Errorf("too big %s size; got %d; shouldn't exceed %d", name, size, maxBlockPartSize)
+	}
+	if cap(d.buf) < int(size) {
+		d.buf = make([]byte, size)
+	}
+	d.buf = d.buf[:size]
+	if _, err := io.ReadFull(d.br, d.buf); err != nil {
+		return nil, fmt.Errorf("cannot read %s with size %d bytes: %w", name, size, err)
+	}
+	return d.buf, nil
+}
+
+// Encoder writes blocks in native format
+type Encoder struct {
+	w  io.Writer
+	tr storage.TimeRange
+
+	headerWritten bool
+	buf           []byte
+	tmp           []byte
+	mantissas     []int64
+	block         storage.Block
+}
+
+// NewEncoder returns new Encoder writing to w stream with the given time range
+func NewEncoder(w io.Writer, tr storage.TimeRange) *Encoder {
+	return &Encoder{w: w, tr: tr}
+}
+
+// Encode writes b to the stream. Blocks without samples are skipped.
+func (e *Encoder) Encode(b *Block) error {
+	if err := e.writeHeader(); err != nil {
+		return err
+	}
+	if len(b.Timestamps) == 0 {
+		return nil
+	}
+	if len(b.Timestamps) != len(b.Values) {
+		return fmt.Errorf("BUG: the number of timestamps must match the number of values; got %d vs %d", len(b.Timestamps), len(b.Values))
+	}
+
+	sortTags(&b.MetricName)
+	e.buf = e.buf[:0]
+	e.tmp = b.MetricName.Marshal(e.tmp[:0])
+	e.buf = encoding.MarshalUint32(e.buf, uint32(len(e.tmp)))
+	e.buf = append(e.buf, e.tmp...)
+
+	var scale int16
+	e.mantissas, scale = decimal.AppendFloatToDecimal(e.mantissas[:0], b.Values)
+	e.block.Init(&storage.TSID{}, b.Timestamps, e.mantissas, scale, 64)
+	e.tmp = e.block.MarshalPortable(e.tmp[:0])
+	e.buf = encoding.MarshalUint32(e.buf, uint32(len(e.tmp)))
+	e.buf = append(e.buf, e.tmp...)
+
+	_, err := e.w.Write(e.buf)
+	return err
+}
+
+// Close writes the stream header if no blocks were written
+func (e *Encoder) Close() error {
+	return e.writeHeader()
+}
+
+func (e *Encoder) writeHeader() error {
+	if e.headerWritten {
+		return nil
+	}
+	e.headerWritten = true
+	trBuf := make([]byte, 0, 16)
+	trBuf = encoding.MarshalInt64(trBuf, e.tr.MinTimestamp)
+	trBuf = encoding.MarshalInt64(trBuf, e.tr.MaxTimestamp)
+	_, err := e.w.Write(trBuf)
+	return err
+}
+
+// sortTags sorts tags of mn by key, as required by storage.MetricName.Marshal.
+// The last value wins for duplicate keys.
+func sortTags(mn *storage.MetricName) {
+	tags := mn.Tags
+	sort.SliceStable(tags, func(i, j int) bool {
+		return string(tags[i].Key) < string(tags[j].Key)
+	})
+	n := 0
+	for i := range tags {
+		if n > 0 && string(tags[n-1].Key) == string(tags[i].Key) {
+			tags[n-1].Value = append(tags[n-1].Value[:0], tags[i].Value...)
+			continue
+		}
+		if n != i {
+			tags[n].Key = append(tags[n].Key[:0], tags[i].Key...)
+			tags[n].Value = append(tags[n].Value[:0], tags[i].Value...)
+		}
+		n++
+	}
+	mn.Tags = tags[:n]
+}
+
+// Transform decodes native format stream from src, calls fn for every block
+// and encodes the modified block to dst. Blocks left without samples after fn are dropped.
+func Transform(dst io.Writer, src io.Reader, fn func(b *Block) error) error {
+	d := NewDecoder(src)
+	tr, err := d.TimeRange()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			// empty export response
+			return nil
+		}
+		return err
+	}
+	e := NewEncoder(dst, tr)
+	var b Block
+	for {
+		if err := d.Next(&b); err != nil {
+			if err == io.EOF {
+				return e.Close()
+			}
+			return err
+		}
+		if err := fn(&b); err != nil {
+			return err
+		}
+		if err := e.Encode(&b); err != nil {
+			return fmt.Errorf("cannot encode block: %w", err)
+		}
+	}
+}
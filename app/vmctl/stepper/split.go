@@ -14,10 +14,16 @@ const (
 	StepHour string = "hour"
 	// StepMinute represents a one minute interval
 	StepMinute string = "minute"
+	// StepCalendarMonth represents a calendar month interval
+	StepCalendarMonth string = "1M"
+	// StepWeek represents a calendar week interval starting on Monday
+	StepWeek string = "1w"
 )
 
 // SplitDateRange splits start-end range in a subset of ranges respecting the given step
 // Ranges with granularity of StepMonth are aligned to 1st of each month in order to improve export efficiency at block transfer level
+// Ranges with granularity of StepCalendarMonth and StepWeek are aligned to the start of month and week in the location of start.
+// Adjacent ranges share the boundary, so there are no gaps between them.
 func SplitDateRange(start, end time.Time, step string) ([][]time.Time, error) {
 
 	if start.After(end) {
@@ -48,9 +54,20 @@ func SplitDateRange(start, end time.Time, step string) ([][]time.Time, error) {
 		nextStep = func(t time.Time) (time.Time, time.Time) {
 			return t, t.Add(time.Minute * 1)
 		}
+	case StepCalendarMonth:
+		nextStep = func(t time.Time) (time.Time, time.Time) {
+			return t, time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		}
+	case StepWeek:
+		nextStep = func(t time.Time) (time.Time, time.Time) {
+			// calendar arithmetic is used instead of adding 7*24h,
+			// so weeks with DST transitions are shorter or longer accordingly
+			daysSinceMonday := (int(t.Weekday()) + 6) % 7
+			return t, time.Date(t.Year(), t.Month(), t.Day()-daysSinceMonday+7, 0, 0, 0, 0, t.Location())
+		}
 	default:
-		return nil, fmt.Errorf("failed to parse step value, valid values are: '%s', '%s', '%s', '%s'. provided: '%s'",
-			StepMonth, StepDay, StepHour, StepMinute, step)
+		return nil, fmt.Errorf("failed to parse step value, valid values are: '%s', '%s', '%s', '%s', '%s', '%s'. provided: '%s'",
+			StepMonth, StepDay, StepHour, StepMinute, StepCalendarMonth, StepWeek, step)
 	}
 
 	currentStep := start
@@ -68,3 +85,46 @@ func SplitDateRange(start, end time.Time, step string) ([][]time.Time, error) {
 
 	return ranges, nil
 }
+
+// SplitDateRangeEvenly splits start-end range into n adjacent ranges of equal duration.
+// Ranges are aligned to seconds, so less than n ranges could be returned for short intervals.
+func SplitDateRangeEvenly(start, end time.Time, n int) ([][]time.Time, error) {
+	if start.After(end) {
+		return nil, fmt.Errorf("start time %q should come before end time %q", start.Format(time.RFC3339), end.Format(time.RFC3339))
+	}
+	if n <= 1 {
+		return [][]time.Time{{start, end}}, nil
+	}
+
+	step := (end.Sub(start) / time.Duration(n)).Truncate(time.Second)
+	if step <= 0 {
+		return [][]time.Time{{start, end}}, nil
+	}
+
+	ranges := make([][]time.Time, 0, n)
+	currentStep := start
+	for i := 0; i < n-1; i++ {
+		e := currentStep.Add(step)
+		ranges = append(ranges, []time.Time{currentStep, e})
+		currentStep = e
+	}
+	ranges = append(ranges, []time.Time{currentStep, end})
+	return ranges, nil
+}
+
+// OverlapRanges extends the end of every range except the last one by overlap, so adjacent ranges overlap.
+// Ends are limited by the end of the last range. The given ranges are modified in place.
+func OverlapRanges(ranges [][]time.Time, overlap time.Duration) [][]time.Time {
+	if overlap <= 0 || len(ranges) < 2 {
+		return ranges
+	}
+	end := ranges[len(ranges)-1][1]
+	for _, r := range ranges[:len(ranges)-1] {
+		e := r[1].Add(overlap)
+		if e.After(end) {
+			e = end
+		}
+		r[1] = e
+	}
+	return ranges
+}
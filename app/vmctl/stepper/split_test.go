@@ -121,6 +121,99 @@ func Test_splitDateRange(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "calendar month chunking",
+			args: args{
+				start:       "2022-01-03T11:11:11Z",
+				end:         "2022-04-03T12:12:12Z",
+				granularity: StepCalendarMonth,
+			},
+			want: []testTimeRange{
+				{
+					"2022-01-03T11:11:11Z",
+					"2022-02-01T00:00:00Z",
+				},
+				{
+					"2022-02-01T00:00:00Z",
+					"2022-03-01T00:00:00Z",
+				},
+				{
+					"2022-03-01T00:00:00Z",
+					"2022-04-01T00:00:00Z",
+				},
+				{
+					"2022-04-01T00:00:00Z",
+					"2022-04-03T12:12:12Z",
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "calendar month chunking across the year",
+			args: args{
+				start:       "2023-12-01T00:00:00Z",
+				end:         "2024-03-01T00:00:00Z",
+				granularity: StepCalendarMonth,
+			},
+			want: []testTimeRange{
+				{
+					"2023-12-01T00:00:00Z",
+					"2024-01-01T00:00:00Z",
+				},
+				{
+					"2024-01-01T00:00:00Z",
+					"2024-02-01T00:00:00Z",
+				},
+				{
+					"2024-02-01T00:00:00Z",
+					"2024-03-01T00:00:00Z",
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "week chunking",
+			args: args{
+				// Wednesday
+				start:       "2022-01-05T11:11:11Z",
+				end:         "2022-01-18T12:12:12Z",
+				granularity: StepWeek,
+			},
+			want: []testTimeRange{
+				{
+					"2022-01-05T11:11:11Z",
+					"2022-01-10T00:00:00Z",
+				},
+				{
+					"2022-01-10T00:00:00Z",
+					"2022-01-17T00:00:00Z",
+				},
+				{
+					"2022-01-17T00:00:00Z",
+					"2022-01-18T12:12:12Z",
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "week chunking from Sunday",
+			args: args{
+				start:       "2022-01-09T23:00:00Z",
+				end:         "2022-01-10T01:00:00Z",
+				granularity: StepWeek,
+			},
+			want: []testTimeRange{
+				{
+					"2022-01-09T23:00:00Z",
+					"2022-01-10T00:00:00Z",
+				},
+				{
+					"2022-01-10T00:00:00Z",
+					"2022-01-10T01:00:00Z",
+				},
+			},
+			wantErr: false,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -150,3 +243,162 @@ func Test_splitDateRange(t *testing.T) {
 		})
 	}
 }
+
+func Test_splitDateRangeEvenly(t *testing.T) {
+	tests := []struct {
+		name    string
+		start   string
+		end     string
+		n       int
+		want    []testTimeRange
+		wantErr bool
+	}{
+		{
+			name:    "validates start is before end",
+			start:   "2022-02-01T00:00:00Z",
+			end:     "2022-01-01T00:00:00Z",
+			n:       2,
+			wantErr: true,
+		},
+		{
+			name:  "single range",
+			start: "2022-01-01T00:00:00Z",
+			end:   "2022-01-02T00:00:00Z",
+			n:     1,
+			want: []testTimeRange{
+				{"2022-01-01T00:00:00Z", "2022-01-02T00:00:00Z"},
+			},
+		},
+		{
+			name:  "split into 3 ranges",
+			start: "2022-01-01T00:00:00Z",
+			end:   "2022-01-01T03:00:00Z",
+			n:     3,
+			want: []testTimeRange{
+				{"2022-01-01T00:00:00Z", "2022-01-01T01:00:00Z"},
+				{"2022-01-01T01:00:00Z", "2022-01-01T02:00:00Z"},
+				{"2022-01-01T02:00:00Z", "2022-01-01T03:00:00Z"},
+			},
+		},
+		{
+			name:  "last range absorbs the remainder",
+			start: "2022-01-01T00:00:00Z",
+			end:   "2022-01-01T00:00:10Z",
+			n:     3,
+			want: []testTimeRange{
+				{"2022-01-01T00:00:00Z", "2022-01-01T00:00:03Z"},
+				{"2022-01-01T00:00:03Z", "2022-01-01T00:00:06Z"},
+				{"2022-01-01T00:00:06Z", "2022-01-01T00:00:10Z"},
+			},
+		},
+		{
+			name:  "too short interval",
+			start: "2022-01-01T00:00:00Z",
+			end:   "2022-01-01T00:00:01Z",
+			n:     4,
+			want: []testTimeRange{
+				{"2022-01-01T00:00:00Z", "2022-01-01T00:00:01Z"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := mustParseDatetime(tt.start)
+			end := mustParseDatetime(tt.end)
+
+			got, err := SplitDateRangeEvenly(start, end, tt.n)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SplitDateRangeEvenly() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			var testExpectedResults [][]time.Time
+			if tt.want != nil {
+				testExpectedResults = make([][]time.Time, 0)
+				for _, dr := range tt.want {
+					testExpectedResults = append(testExpectedResults, []time.Time{
+						mustParseDatetime(dr[0]),
+						mustParseDatetime(dr[1]),
+					})
+				}
+			}
+
+			if !reflect.DeepEqual(got, testExpectedResults) {
+				t.Errorf("SplitDateRangeEvenly() got = %v, want %v", got, testExpectedResults)
+			}
+		})
+	}
+}
+
+func Test_splitDateRangeDST(t *testing.T) {
+	loc, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skipf("cannot load location: %s", err)
+	}
+	// DST starts on 2022-03-27 and ends on 2022-10-30 in Europe/Berlin
+	f := func(start, end time.Time, step string, wantBoundaries []string) {
+		t.Helper()
+		ranges, err := SplitDateRange(start, end, step)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if len(ranges) != len(wantBoundaries)+1 {
+			t.Fatalf("expecting %d ranges; got %d: %v", len(wantBoundaries)+1, len(ranges), ranges)
+		}
+		for i, r := range ranges {
+			if i > 0 && !r[0].Equal(ranges[i-1][1]) {
+				t.Fatalf("gap or overlap between ranges %v and %v", ranges[i-1], r)
+			}
+			if i < len(wantBoundaries) {
+				if got := r[1].Format(time.RFC3339); got != wantBoundaries[i] {
+					t.Fatalf("unexpected end of range %d; got %s; want %s", i, got, wantBoundaries[i])
+				}
+			}
+		}
+	}
+	f(time.Date(2022, 3, 23, 12, 0, 0, 0, loc), time.Date(2022, 4, 5, 0, 0, 0, 0, loc), StepWeek,
+		[]string{"2022-03-28T00:00:00+02:00", "2022-04-04T00:00:00+02:00"})
+	f(time.Date(2022, 10, 15, 0, 0, 0, 0, loc), time.Date(2022, 11, 15, 0, 0, 0, 0, loc), StepCalendarMonth,
+		[]string{"2022-11-01T00:00:00+01:00"})
+}
+
+func TestOverlapRanges(t *testing.T) {
+	f := func(start, end, step string, overlap time.Duration) {
+		t.Helper()
+		ranges, err := SplitDateRange(mustParseDatetime(start), mustParseDatetime(end), step)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		starts := make([]time.Time, len(ranges))
+		for i, r := range ranges {
+			starts[i] = r[0]
+		}
+		ranges = OverlapRanges(ranges, overlap)
+		for i, r := range ranges {
+			if !r[0].Equal(starts[i]) {
+				t.Fatalf("start of range %d must be left as is; got %s; want %s", i, r[0], starts[i])
+			}
+			if i == 0 {
+				continue
+			}
+			if got := ranges[i-1][1].Sub(r[0]); got != overlap {
+				t.Fatalf("unexpected overlap between ranges %v and %v; got %s; want %s", ranges[i-1], r, got, overlap)
+			}
+		}
+		if got := ranges[len(ranges)-1][1]; !got.Equal(mustParseDatetime(end)) {
+			t.Fatalf("the end of the last range must be left as is; got %s", got)
+		}
+	}
+	f("2022-01-01T00:00:00Z", "2022-01-05T00:00:00Z", StepDay, time.Minute)
+	f("2022-01-01T00:00:00Z", "2022-01-01T05:30:00Z", StepHour, time.Second)
+	f("2022-01-01T00:00:00Z", "2022-01-01T05:30:00Z", StepHour, 0)
+
+	// ends are limited by the end of the last range
+	ranges, err := SplitDateRange(mustParseDatetime("2022-01-01T00:00:00Z"), mustParseDatetime("2022-01-01T01:10:00Z"), StepHour)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	ranges = OverlapRanges(ranges, 30*time.Minute)
+	if got := ranges[0][1]; !got.Equal(mustParseDatetime("2022-01-01T01:10:00Z")) {
+		t.Fatalf("unexpected end of the first range %s", got)
+	}
+}
@@ -43,6 +43,7 @@ func NewRemoteWriteServer(t *testing.T) *RemoteWriteServer {
 
 	mux.Handle("/api/v1/import", rws.getWriteHandler(t))
 	mux.Handle("/health", rws.handlePing())
+	mux.Handle("/api/v1/status/buildinfo", rws.buildInfoHandler())
 	mux.Handle("/api/v1/series", rws.seriesHandler())
 	mux.Handle("/api/v1/export/native", rws.exportNativeHandler())
 	mux.Handle("/api/v1/import/native", rws.importNativeHandler(t))
@@ -117,6 +118,12 @@ func (rws *RemoteWriteServer) handlePing() http.Handler {
 	})
 }
 
+func (rws *RemoteWriteServer) buildInfoHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"status":"success","data":{"version":"2.24.0"}}`))
+	})
+}
+
 func (rws *RemoteWriteServer) seriesHandler() http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		var labelValues []LabelValues
@@ -162,6 +169,11 @@ func (rws *RemoteWriteServer) exportNativeHandler() http.Handler {
 
 func (rws *RemoteWriteServer) importNativeHandler(t *testing.T) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ContentLength == 0 {
+			// VictoriaMetrics accepts empty import requests, e.g. connectivity checks
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
 		common.StartUnmarshalWorkers()
 		defer common.StopUnmarshalWorkers()
 
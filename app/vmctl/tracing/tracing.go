@@ -0,0 +1,253 @@
+// Package tracing implements minimal tracing of vmctl migration
+// with exporting spans to OpenTelemetry collector via OTLP/HTTP JSON protocol.
+//
+// All the methods are safe to call on nil *Tracer and nil *Span,
+// so tracing has no overhead when it isn't configured.
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+)
+
+const (
+	tracesPath = "/v1/traces"
+
+	flushInterval = 5 * time.Second
+	maxBatchSize  = 512
+)
+
+// Tracer collects finished spans and periodically exports them to OTLP endpoint.
+type Tracer struct {
+	url         string
+	serviceName string
+	client      *http.Client
+
+	mu    sync.Mutex
+	spans []otlpSpan
+
+	stopCh chan struct{}
+	wg     sync.WaitGroup
+}
+
+// New returns Tracer exporting spans to the given OTLP/HTTP endpoint.
+//
+// If endpoint has no path, the default `/v1/traces` path is used.
+func New(endpoint, serviceName string) (*Tracer, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse OTLP endpoint %q: %w", endpoint, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported scheme %q in OTLP endpoint %q; supported schemes: http, https", u.Scheme, endpoint)
+	}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = tracesPath
+	}
+	t := &Tracer{
+		url:         u.String(),
+		serviceName: serviceName,
+		client:      &http.Client{Timeout: 10 * time.Second},
+		stopCh:      make(chan struct{}),
+	}
+	t.wg.Add(1)
+	go func() {
+		defer t.wg.Done()
+		ticker := time.NewTicker(flushInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-t.stopCh:
+				return
+			case <-ticker.C:
+				t.flush()
+			}
+		}
+	}()
+	return t, nil
+}
+
+// Shutdown stops background flushing and exports the remaining spans.
+func (t *Tracer) Shutdown() {
+	if t == nil {
+		return
+	}
+	close(t.stopCh)
+	t.wg.Wait()
+	t.flush()
+}
+
+// Start starts a new span with the given name.
+// The span is a child of the span from ctx if any.
+// The returned context contains the started span.
+func (t *Tracer) Start(ctx context.Context, name string) (context.Context, *Span) {
+	if t == nil {
+		return ctx, nil
+	}
+	s := &Span{
+		t:     t,
+		name:  name,
+		start: time.Now(),
+		id:    newID(8),
+	}
+	if parent := SpanFromContext(ctx); parent != nil {
+		s.traceID = parent.traceID
+		s.parentID = parent.id
+	} else {
+		s.traceID = newID(16)
+	}
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+type spanKey struct{}
+
+// SpanFromContext returns span from ctx or nil if ctx has no span
+func SpanFromContext(ctx context.Context) *Span {
+	s, _ := ctx.Value(spanKey{}).(*Span)
+	return s
+}
+
+// Span represents a single operation of the migration
+type Span struct {
+	t *Tracer
+
+	name     string
+	traceID  string
+	id       string
+	parentID string
+	start    time.Time
+
+	mu    sync.Mutex
+	attrs []attribute
+}
+
+// SetAttr sets attribute with the given key and value to s.
+// Supported value types are string, bool, int, int64, uint64 and float64.
+func (s *Span) SetAttr(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+	var v attributeValue
+	switch x := value.(type) {
+	case string:
+		v.StringValue = &x
+	case bool:
+		v.BoolValue = &x
+	case int:
+		str := strconv.Itoa(x)
+		v.IntValue = &str
+	case int64:
+		str := strconv.FormatInt(x, 10)
+		v.IntValue = &str
+	case uint64:
+		str := strconv.FormatUint(x, 10)
+		v.IntValue = &str
+	case float64:
+		v.DoubleValue = &x
+	default:
+		str := fmt.Sprintf("%v", x)
+		v.StringValue = &str
+	}
+	s.mu.Lock()
+	s.attrs = append(s.attrs, attribute{Key: key, Value: v})
+	s.mu.Unlock()
+}
+
+// End finishes s with the given error and schedules it for export
+func (s *Span) End(err error) {
+	if s == nil {
+		return
+	}
+	end := time.Now()
+	s.t.add(s.toOTLP(end, err))
+}
+
+func (t *Tracer) add(s otlpSpan) {
+	t.mu.Lock()
+	t.spans = append(t.spans, s)
+	full := len(t.spans) >= maxBatchSize
+	t.mu.Unlock()
+	if full {
+		t.flush()
+	}
+}
+
+func (t *Tracer) flush() {
+	t.mu.Lock()
+	spans := t.spans
+	t.spans = nil
+	t.mu.Unlock()
+	if len(spans) == 0 {
+		return
+	}
+	if err := t.export(spans); err != nil {
+		logger.Warnf("cannot export %d spans to %q: %s", len(spans), t.url, err)
+	}
+}
+
+func (t *Tracer) export(spans []otlpSpan) error {
+	serviceName := t.serviceName
+	req := exportRequest{
+		ResourceSpans: []resourceSpans{{
+			Resource: resource{
+				Attributes: []attribute{{Key: "service.name", Value: attributeValue{StringValue: &serviceName}}},
+			},
+			ScopeSpans: []scopeSpans{{
+				Scope: scope{Name: serviceName},
+				Spans: spans,
+			}},
+		}},
+	}
+	data, err := json.Marshal(&req)
+	if err != nil {
+		return fmt.Errorf("cannot marshal spans: %w", err)
+	}
+	resp, err := t.client.Post(t.url, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("unexpected response code %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (s *Span) toOTLP(end time.Time, err error) otlpSpan {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := status{Code: statusOK}
+	if err != nil {
+		st = status{Code: statusError, Message: err.Error()}
+	}
+	return otlpSpan{
+		TraceID:           s.traceID,
+		SpanID:            s.id,
+		ParentSpanID:      s.parentID,
+		Name:              s.name,
+		Kind:              spanKindInternal,
+		StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(end.UnixNano(), 10),
+		Attributes:        s.attrs,
+		Status:            st,
+	}
+}
+
+func newID(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		logger.Panicf("FATAL: cannot generate random span id: %s", err)
+	}
+	return hex.EncodeToString(b)
+}
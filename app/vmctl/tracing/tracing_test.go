@@ -0,0 +1,82 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestNilTracer(t *testing.T) {
+	var tr *Tracer
+	ctx, s := tr.Start(context.Background(), "foo")
+	if s != nil {
+		t.Fatalf("expecting nil span")
+	}
+	if SpanFromContext(ctx) != nil {
+		t.Fatalf("expecting no span in context")
+	}
+	s.SetAttr("foo", "bar")
+	s.End(nil)
+	tr.Shutdown()
+}
+
+func TestTracerExport(t *testing.T) {
+	var mu sync.Mutex
+	var reqs []exportRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != tracesPath {
+			t.Errorf("unexpected path %q", r.URL.Path)
+		}
+		var req exportRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("cannot decode request: %s", err)
+		}
+		mu.Lock()
+		reqs = append(reqs, req)
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	tr, err := New(srv.URL, "vmctl")
+	if err != nil {
+		t.Fatalf("cannot create tracer: %s", err)
+	}
+	ctx, root := tr.Start(context.Background(), "run")
+	_, child := tr.Start(ctx, "unit")
+	child.SetAttr("metric", "foo")
+	child.SetAttr("bytes", uint64(42))
+	child.End(fmt.Errorf("some error"))
+	root.End(nil)
+	tr.Shutdown()
+
+	if len(reqs) != 1 {
+		t.Fatalf("expecting 1 export request; got %d", len(reqs))
+	}
+	spans := reqs[0].ResourceSpans[0].ScopeSpans[0].Spans
+	if len(spans) != 2 {
+		t.Fatalf("expecting 2 spans; got %d", len(spans))
+	}
+	c, r := spans[0], spans[1]
+	if c.TraceID != r.TraceID {
+		t.Fatalf("spans must belong to the same trace; got %q and %q", c.TraceID, r.TraceID)
+	}
+	if c.ParentSpanID != r.SpanID || r.ParentSpanID != "" {
+		t.Fatalf("unexpected parent span ids: child=%q, root=%q, root id=%q", c.ParentSpanID, r.ParentSpanID, r.SpanID)
+	}
+	if c.Status.Code != statusError || c.Status.Message != "some error" {
+		t.Fatalf("unexpected child status %+v", c.Status)
+	}
+	if len(c.Attributes) != 2 || *c.Attributes[1].Value.IntValue != "42" {
+		t.Fatalf("unexpected child attributes %+v", c.Attributes)
+	}
+}
+
+func TestNewInvalidEndpoint(t *testing.T) {
+	if _, err := New("localhost:4318", "vmctl"); err == nil {
+		t.Fatalf("expecting error for endpoint without scheme")
+	}
+}
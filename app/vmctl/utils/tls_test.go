@@ -0,0 +1,34 @@
+package utils
+
+import "testing"
+
+func TestTLSConfig(t *testing.T) {
+	var certFile, keyFile, CAFile, serverName string
+	var insecureSkipVerify bool
+	serverName = "test"
+	insecureSkipVerify = true
+	tlsCfg, err := TLSConfig(certFile, keyFile, CAFile, serverName, insecureSkipVerify)
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	if tlsCfg.ServerName != serverName {
+		t.Fatalf("unexpected ServerName, want %s, got %s", serverName, tlsCfg.ServerName)
+	}
+	if tlsCfg.InsecureSkipVerify != insecureSkipVerify {
+		t.Fatalf("unexpected InsecureSkipVerify, want %v, got %v", insecureSkipVerify, tlsCfg.InsecureSkipVerify)
+	}
+	certFile = "/path/to/nonexisting/cert/file"
+	if _, err = TLSConfig(certFile, keyFile, CAFile, serverName, insecureSkipVerify); err == nil {
+		t.Fatalf("expected keypair error, got nil")
+	}
+	certFile = ""
+	keyFile = "/path/to/nonexisting/key/file"
+	if _, err = TLSConfig(certFile, keyFile, CAFile, serverName, insecureSkipVerify); err == nil {
+		t.Fatalf("expected keypair error for key without cert, got nil")
+	}
+	keyFile = ""
+	CAFile = "/path/to/nonexisting/cert/file"
+	if _, err = TLSConfig(certFile, keyFile, CAFile, serverName, insecureSkipVerify); err == nil {
+		t.Fatalf("expected read error, got nil")
+	}
+}
@@ -55,6 +55,8 @@ type Config struct {
 	RateLimit int64
 	// Whether to disable progress bar per VM worker
 	DisableProgressBar bool
+	// Whether to randomize delays between retries of failed import requests
+	RetryJitter bool
 }
 
 // Importer performs insertion of timeseries
@@ -140,6 +142,7 @@ func NewImporter(ctx context.Context, cfg Config) (*Importer, error) {
 		errors:     make(chan *ImportError, cfg.Concurrency),
 		backoff:    backoff.New(),
 	}
+	im.backoff.SetJitter(cfg.RetryJitter)
 	if err := im.Ping(); err != nil {
 		return nil, fmt.Errorf("ping to %q failed: %s", addr, err)
 	}
@@ -2,19 +2,29 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"regexp"
+	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmctl/backoff"
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmctl/limiter"
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmctl/native"
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmctl/stepper"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmctl/tracing"
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmctl/vm"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompbmarshal"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promrelabel"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promutils"
+	"github.com/VictoriaMetrics/metricsql"
 	"github.com/cheggaaa/pb/v3"
 )
 
@@ -29,35 +39,342 @@ type vmNativeProcessor struct {
 	rateLimit    int64
 	interCluster bool
 	cc           int
+
+	// matches contains all the series selectors from --vm-native-filter-match.
+	// filter.Match is set to the first of them.
+	matches []string
+	// metricSelectors contains selectors from matches, which discovered every metric
+	// of every tenant. It is set only if there are multiple matches.
+	metricSelectors   map[string]map[string][]string
+	metricSelectorsMu sync.Mutex
+	// labelFilters optionally contains label constraints from --vm-native-label-filter,
+	// which are added to series selectors of discovery and export requests
+	labelFilters *labelFilters
+
+	// globalRateLimiter optionally limits the total transfer rate of all the requests.
+	// It is shared between all the workers, tenants and sources.
+	globalRateLimiter *limiter.Limiter
+	// slowStart optionally ramps up write rate limits at the start of the migration
+	slowStart *slowStart
+	// srcRateLimiter optionally limits the total rate of reads from the source.
+	// It is shared between all the export requests to the source.
+	srcRateLimiter *limiter.Limiter
+
+	// assumeYes defines whether to confirm prompts automatically.
+	// Unlike silent mode, progress bars and estimates are still shown.
+	assumeYes bool
+
+	// skipProbe disables checking src and dst connectivity before the migration starts
+	skipProbe bool
+
+	// maxClockSkew defines the clock skew between vmctl and src or dst
+	// exceeding which results in a warning
+	maxClockSkew time.Duration
+
+	// httpTimeout is an optional timeout for streaming export and import requests
+	// to not transfer any data before they are retried
+	httpTimeout time.Duration
+
+	// discoveryCC defines how many tenants are explored concurrently
+	// before the migration starts
+	discoveryCC int
+
+	stickyRouteCfg stickyRouteConfig
+
+	// continueOnError defines whether to collect failed units
+	// instead of breaking the migration
+	continueOnError bool
+	// failuresFile is the path to write the failures manifest to
+	failuresFile string
+	// retryPasses is the number of passes over failed units after the migration
+	retryPasses int
+	// retryPassDelay is the delay before every retry pass
+	retryPassDelay time.Duration
+	failures       failures
+
+	// spool is an optional disk buffer between export and import
+	spool *spool
+
+	// intraUnitParallelism defines how many sub-ranges of a single
+	// (metric, time range) unit are migrated concurrently
+	intraUnitParallelism int
+	// retrySubRanges defines how many sub-ranges of a single unit are migrated one by one,
+	// so only the failed sub-ranges are migrated again on retry
+	retrySubRanges int
+	// autoSplitBytes is the max size of a single export, which is imported as is.
+	// The time range of bigger exports is split in half. Zero means no limit.
+	autoSplitBytes int64
+	// batchSize defines how many units may be coalesced into a single import request
+	batchSize int
+	// batchMaxBytes limits the size of import requests coalescing units. Zero means no limit.
+	batchMaxBytes int64
+
+	// onDuplicateTS defines how to handle samples with duplicate timestamps
+	onDuplicateTS string
+	// dedupStream defines whether to drop samples with timestamps already seen for the series within a migration unit
+	dedupStream bool
+	// nonFinite defines how to handle samples with NaN and Inf values
+	nonFinite string
+	// valueScales defines transformations of sample values for matching metrics
+	valueScales []*valueScale
+	// downsample optionally defines aggregation of samples over interval before import
+	downsample *downsampleConfig
+	// relabelConfigs defines optional relabeling of exported series
+	relabelConfigs *promrelabel.ParsedConfigs
+
+	// checkpoint tracks migrated units if state file is configured
+	checkpoint *checkpoint
+	// maxTotalBytes is the budget of transferred bytes, after which
+	// no new units are started. Zero means no limit.
+	maxTotalBytes int64
+	// maxTotalRequests is the budget of started requests. Zero means no limit.
+	maxTotalRequests int64
+	// startedRequests is the number of requests started during the run
+	startedRequests int64
+	// budgetLeft collects requests and metrics left because of the budget
+	budgetLeft budgetLeft
+
+	// srcQPS limits the rate of export and explore requests to src.
+	// It is nil if no limit is set.
+	srcQPS *limiter.Limiter
+
+	// labelChunks optionally splits export of every metric
+	// into buckets by label value
+	labelChunks *labelChunkConfig
+
+	// maxMetrics is the number of discovered metrics per tenant
+	// exceeding which requires confirmation. Zero means no limit.
+	maxMetrics int
+	// estimateMetrics is the number of metrics sampled for estimating the transfer size
+	// before the confirmation prompt. Zero disables the estimation.
+	estimateMetrics int
+
+	// verifyPerMetric is the number of points to verify for every migrated metric
+	verifyPerMetric int
+	// verifyReimport defines whether to re-migrate metrics failed verification
+	verifyReimport bool
+	// completed collects time ranges migrated during the run
+	completed *completedRanges
+	// countVerification collects migrated units for comparing their sample counts
+	// between source and destination after the migration. It is nil if disabled.
+	countVerification *countVerification
+	// skipExistingData defines whether to skip units with data already present at the destination
+	skipExistingData bool
+	// dstReader is the client for reading data from the destination
+	// during verification, warmup and skipping of existing data
+	dstReader *native.Client
+
+	// autoChunkSamples is the target number of samples per request used for
+	// deriving time chunks per metric from its sample rate. Zero disables auto chunking.
+	autoChunkSamples int
+
+	// warmupQueries are executed against the destination before the migration starts
+	warmupQueries []string
+
+	// tracer exports migration traces. It is nil if tracing isn't configured.
+	tracer *tracing.Tracer
+	// progress exposes migration progress metrics. It is nil if --vm-native-metrics-listen-addr isn't set.
+	progress *progressMetrics
+	// progressInterval is the interval for printing progress summary to stdout. Zero disables the summary.
+	progressInterval time.Duration
+
+	// tenantCC defines how many tenants are migrated concurrently
+	tenantCC int
+	// perTenantCC limits the number of concurrent requests per tenant
+	// when tenants are migrated concurrently
+	perTenantCC int
+	// importSem limits the total number of concurrent requests
+	// when tenants are migrated concurrently
+	importSem chan struct{}
+	// overallProgressBar defines whether to show a single progress bar for all the tenants
+	overallProgressBar bool
+	// overallBar is the progress bar shared between all the tenants if overallProgressBar is set
+	overallBar           *pb.ProgressBar
+	overallBarStartBytes uint64
+	// autoConcurrency optionally adjusts the number of concurrent requests
+	// according to the observed throughput. It is nil if disabled.
+	autoConcurrency *autoConcurrency
+
+	// tenantRoute optionally routes series to destination tenants by label value
+	tenantRoute *tenantRouteConfig
+
+	// planOut is the path for writing migration plan without running the migration
+	planOut string
+	// planIn is the approved migration plan to execute
+	planIn *migrationPlan
+	// planForce allows executing planIn when it doesn't match the source state
+	planForce bool
+
+	// successFile is the path for writing marker of successful migration
+	successFile string
+
+	// srcThrottle optionally reduces concurrency of export requests on source 5xx responses
+	srcThrottle *sourceThrottle
+
+	// requestTimeout optionally limits the duration of every attempt to migrate a unit
+	requestTimeout *requestTimeout
+
+	// metricDeadline limits the overall time of migrating a single metric
+	metricDeadline time.Duration
+
+	// exploreLimit is the max number of series per discovery request
+	exploreLimit int
+
+	// durable optionally defines waiting for persistence of imported data
+	durable *durableConfig
+
+	// statsFormat defines the format of the final stats
+	statsFormat string
+	// verboseStats defines whether to print per-tenant stats and durations histogram for a single tenant
+	verboseStats bool
+	// digest defines whether to include digests of imported data into the final stats
+	digest bool
+
+	// transferFormat is the format of data transferred from src to dst
+	transferFormat string
+
+	// exportPath and importPath optionally override default export and import paths,
+	// e.g. for sources and destinations behind path-rewriting proxies
+	exportPath string
+	importPath string
+
+	// dstTenantOverride is an optional destination tenant for all the migrated tenants
+	dstTenantOverride string
+	// dstTenantMap optionally maps source tenants to destination tenants
+	dstTenantMap map[string]string
+
+	// srcTenants is an optional list of tenants to migrate if the source doesn't support tenants discovery
+	srcTenants []string
+	// tenantFilter optionally limits the migrated tenants
+	tenantFilter *regexp.Regexp
+	// stampLabels are added to every migrated series during re-encoding
+	stampLabels []prompbmarshal.Label
+	// stampLabelConflict defines how to handle series already having stamped labels with other values
+	stampLabelConflict string
+
+	// metricNameFilter optionally filters discovered metric names
+	metricNameFilter *metricNameFilter
+	// metricSampler optionally selects a fraction of discovered metrics for test migrations
+	metricSampler *metricSampler
+
+	// flushAddrs contains addresses for making imported data searchable once the migration is finished
+	flushAddrs []string
+	// flushAuthKey must match -forceFlushAuthKey at flushAddrs
+	flushAuthKey string
+	// skipEmptyTenants defines whether to skip tenants without metrics instead of failing the migration
+	skipEmptyTenants bool
+	// tenantsCache optionally caches discovered tenants between runs
+	tenantsCache *tenantsCache
+
+	// exploreStream defines whether to start migrating metrics while they are discovered
+	exploreStream bool
+	// sortedMetrics defines whether to migrate discovered metrics in the order of their names,
+	// so runs with the same flags process metrics in the same order
+	sortedMetrics bool
+
+	// dryRun collects units without migrating them. It is nil if dry run isn't enabled.
+	dryRun *dryRunRecorder
+
+	// chunkOrder defines the order of migrating time ranges of every metric
+	chunkOrder string
+	// chunkOverlap is the duration adjacent time ranges overlap by
+	chunkOverlap time.Duration
+
+	// srcFile is an optional path to the file with native data to import instead of exporting from src
+	srcFile string
+	// dstFile optionally writes exported data to local files instead of importing it into dst
+	dstFile *dstFileWriter
+	// dstRemoteWrite defines whether to send exported data to dst via Prometheus remote write protocol
+	dstRemoteWrite bool
+
+	// barStyle is either a preset of progress bars, such as barStyleASCII, or a custom template
+	barStyle string
 }
 
 const (
 	nativeExportAddr = "api/v1/export/native"
 	nativeImportAddr = "api/v1/import/native"
-	nativeBarTpl     = `{{ blue "%s:" }} {{ counters . }} {{ bar . "[" "█" (cycle . "█") "▒" "]" }} {{ percent . }}`
+	nativeBarTpl     = `{{ blue "%s:" }} {{ counters . }} {{ bar . "[" "█" (cycle . "█") "▒" "]" }} {{ percent . }} {{ string . "speed" }}`
+	// nativeSpinnerTpl is used if the number of requests is unknown
+	nativeSpinnerTpl = `{{ blue "%s:" }} {{ cycle . "⠋" "⠙" "⠹" "⠸" "⠼" "⠴" "⠦" "⠧" "⠇" "⠏" }} {{ counters . }} {{ string . "speed" }}`
 )
 
-func (p *vmNativeProcessor) run(ctx context.Context, silent bool) error {
+// srcExportPath returns the path of export API at src
+func (p *vmNativeProcessor) srcExportPath() string {
+	switch {
+	case p.exportPath != "":
+		return p.exportPath
+	case p.isJSONL():
+		return jsonlExportAddr
+	default:
+		return nativeExportAddr
+	}
+}
+
+// dstImportPath returns the path of import API at dst
+func (p *vmNativeProcessor) dstImportPath() string {
+	switch {
+	case p.importPath != "":
+		return p.importPath
+	case p.isJSONL():
+		return jsonlImportAddr
+	default:
+		return nativeImportAddr
+	}
+}
+
+func (p *vmNativeProcessor) run(ctx context.Context, silent bool) (err error) {
 	if p.cc == 0 {
 		p.cc = 1
 	}
 	p.s = &stats{
 		startTime: time.Now(),
+		verbose:   p.verboseStats,
+	}
+	if p.digest {
+		p.s.digests = newTransferDigests()
 	}
+	if p.srcFile != "" {
+		return p.runFromFile(ctx, silent)
+	}
+
+	ctx, span := p.tracer.Start(ctx, "migration")
+	span.SetAttr("src", p.src.Addr)
+	span.SetAttr("dst", p.dst.Addr)
+	span.SetAttr("match", p.matchString())
+	defer func() {
+		span.SetAttr("bytes", p.s.bytesTotal())
+		span.End(err)
+	}()
 
-	start, err := time.Parse(time.RFC3339, p.filter.TimeStart)
+	// relative time filters are resolved against the same time
+	now := time.Now().UTC()
+	start, err := parseTimeFilter(vmNativeFilterTimeStart, p.filter.TimeStart, now)
 	if err != nil {
-		return fmt.Errorf("failed to parse %s, provided: %s, expected format: %s, error: %w",
-			vmNativeFilterTimeStart, p.filter.TimeStart, time.RFC3339, err)
+		return err
+	}
+	if isResolvedTimeFilter(p.filter.TimeStart) {
+		// the resolved time is used by requests, state file and migration plan
+		p.filter.TimeStart = start.Format(time.RFC3339)
+		log.Printf("Resolved --%s to %s", vmNativeFilterTimeStart, p.filter.TimeStart)
 	}
 
-	end := time.Now().In(start.Location())
+	if p.filter.TimeEnd == "" && p.planIn != nil {
+		p.filter.TimeEnd = p.planIn.Settings.TimeEnd
+	}
+	end := now.In(start.Location())
 	if p.filter.TimeEnd != "" {
-		end, err = time.Parse(time.RFC3339, p.filter.TimeEnd)
+		end, err = parseTimeFilter(vmNativeFilterTimeEnd, p.filter.TimeEnd, now)
 		if err != nil {
-			return fmt.Errorf("failed to parse %s, provided: %s, expected format: %s, error: %w",
-				vmNativeFilterTimeEnd, p.filter.TimeEnd, time.RFC3339, err)
+			return err
+		}
+		if isResolvedTimeFilter(p.filter.TimeEnd) {
+			p.filter.TimeEnd = end.Format(time.RFC3339)
+			log.Printf("Resolved --%s to %s", vmNativeFilterTimeEnd, p.filter.TimeEnd)
 		}
+	} else if p.planOut != "" {
+		// pin the end of time range, so the plan could be executed later
+		p.filter.TimeEnd = end.Format(time.RFC3339)
 	}
 
 	ranges := [][]time.Time{{start, end}}
@@ -66,94 +383,784 @@ func (p *vmNativeProcessor) run(ctx context.Context, silent bool) error {
 		if err != nil {
 			return fmt.Errorf("failed to create date ranges for the given time filters: %w", err)
 		}
+		ranges = stepper.OverlapRanges(ranges, p.chunkOverlap)
+	}
+
+	if err := p.validate(); err != nil {
+		return err
+	}
+	if p.planIn != nil {
+		if err := p.checkPlanSettings(); err != nil {
+			return err
+		}
+	}
+
+	if p.dryRun == nil {
+		if err := p.removeSuccessFile(); err != nil {
+			return err
+		}
+	}
+	if err := p.preflight(ctx); err != nil {
+		return err
+	}
+
+	if p.checkpoint != nil && p.checkpoint.len() > 0 {
+		log.Printf("Loaded %d migrated requests from --%s; they will be skipped", p.checkpoint.len(), vmNativeStateFile)
+	}
+	if p.checkpoint != nil {
+		// buffered units must be persisted after the pending durable units are marked as done
+		stopFlusher := p.checkpoint.runFlusher()
+		defer func() {
+			if ferr := stopFlusher(); ferr != nil && err == nil {
+				err = fmt.Errorf("failed to update state: %s", ferr)
+			}
+		}()
 	}
 
 	tenants := []string{""}
 	if p.interCluster {
 		log.Printf("Discovering tenants...")
-		tenants, err = p.src.GetSourceTenants(ctx, p.filter)
+		tenants, err = p.discoverTenants(ctx)
 		if err != nil {
-			return fmt.Errorf("failed to get tenants: %w", err)
+			return err
+		}
+		tenants, err = p.filterTenants(tenants)
+		if err != nil {
+			return err
 		}
 		question := fmt.Sprintf("The following tenants were discovered: %s.\n Continue?", tenants)
-		if !silent && !prompt(question) {
+		if !silent && p.planIn == nil && !p.confirm(question) {
 			return nil
 		}
 	}
 
-	for _, tenantID := range tenants {
-		err := p.runBackfilling(ctx, tenantID, ranges, silent)
+	var tenantMetrics map[string]map[string]struct{}
+	if !p.exploreStream {
+		tenantMetrics, err = p.explore(ctx, tenants)
 		if err != nil {
-			return fmt.Errorf("migration failed: %s", err)
+			return err
 		}
+		// metrics are filtered before planning, so plans and progress bars account only for the metrics to migrate
+		for _, tenantID := range tenants {
+			metrics := p.metricNameFilter.filter(tenantID, tenantMetrics[tenantID])
+			tenantMetrics[tenantID] = p.metricSampler.sample(tenantID, metrics)
+		}
+	}
+
+	if p.planOut != "" {
+		return p.writePlan(tenants, tenantMetrics, ranges)
+	}
+	if p.planIn != nil {
+		tenants, tenantMetrics, err = p.applyPlan(tenants, tenantMetrics)
+		if err != nil {
+			return err
+		}
+	}
+
+	if p.dryRun != nil {
+		if err := p.runTenants(ctx, tenants, tenantMetrics, ranges, silent); err != nil {
+			return fmt.Errorf("dry run failed: %s", err)
+		}
+		if p.dryRun.topMetrics > 0 {
+			p.estimateSamples(ctx)
+		}
+		return p.dryRun.finish()
+	}
+
+	p.warmup(ctx, tenants)
+
+	// pending units must be marked as done before exit
+	defer p.waitDurable()
+	p.completed = newCompletedRanges()
+	if p.overallProgressBar && !silent && p.showBars() {
+		// all the tenants are explored at this point, so the total number of requests is known
+		prefix := fmt.Sprintf("Requests to make for %d tenants", len(tenants))
+		p.overallBar = p.newNativeBar(prefix, p.totalRequests(tenantMetrics, ranges))
+		p.overallBarStartBytes = p.s.bytesTotal()
+	}
+	stopProgressReport := p.startProgressReport()
+	defer stopProgressReport()
+	for _, tenantID := range tenants {
+		p.progress.addTotalMetrics(len(tenantMetrics[tenantID]))
+	}
+	stopAutoConcurrency := p.runAutoConcurrency(ctx)
+	defer stopAutoConcurrency()
+	err = p.runTenants(ctx, tenants, tenantMetrics, ranges, silent)
+	if p.overallBar != nil {
+		p.overallBar.Finish()
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			return p.reportInterrupted()
+		}
+		return fmt.Errorf("migration failed: %s", err)
+	}
+
+	budgetReached := p.budgetReached()
+	if budgetReached {
+		msg := p.budgetMessage()
+		if p.checkpoint != nil {
+			msg += fmt.Sprintf(" Run vmctl with the same --%s in order to migrate the remaining data.", vmNativeStateFile)
+		}
+		log.Print(msg)
+		p.finishRetries(ctx)
+	} else if err := p.retryFailed(ctx); err != nil && ctx.Err() == nil {
+		return fmt.Errorf("failed to retry failed requests: %s", err)
+	}
+	if ctx.Err() != nil {
+		return p.reportInterrupted()
+	}
+	p.flushOnFinish(ctx)
+	if p.countVerification != nil {
+		p.verifyCounts(ctx)
 	}
 
 	log.Println("Import finished!")
-	log.Print(p.s)
+	if err := p.printStats(); err != nil {
+		return err
+	}
+
+	if err := p.reportFailures(); err != nil {
+		return err
+	}
+	if budgetReached {
+		// migration isn't complete
+		return nil
+	}
+	return p.writeSuccessFile()
+}
+
+// validate checks the configuration of p
+func (p *vmNativeProcessor) validate() error {
+	if err := p.stickyRouteCfg.validate(); err != nil {
+		return err
+	}
+	if err := validateOnDuplicateTS(p.onDuplicateTS); err != nil {
+		return err
+	}
+	if err := p.validateTransferFormat(); err != nil {
+		return err
+	}
+	if err := p.validateBatch(); err != nil {
+		return err
+	}
+	if len(p.flushAddrs) > 0 && (p.dstFile != nil || p.dstRemoteWrite) {
+		return fmt.Errorf("--%s can't be used together with --%s and --%s", vmNativeFlushOnFinish, vmNativeDstFile, vmNativeDstRemoteWrite)
+	}
+	if err := validateNonFinite(p.nonFinite); err != nil {
+		return err
+	}
+	if err := validateStatsFormat(p.statsFormat); err != nil {
+		return err
+	}
+	if err := validateStampLabelConflict(p.stampLabelConflict); err != nil {
+		return err
+	}
+	if p.relabelConfigs.Len() > 0 && p.verifyPerMetric > 0 {
+		return fmt.Errorf("--%s can't be used together with --%s, since relabeled series can't be found at destination by source filters",
+			vmNativeRelabelConfig, vmNativeVerifyPerMetric)
+	}
+	if ac := p.autoConcurrency; ac != nil {
+		if ac.min < 1 || ac.max < ac.min {
+			return fmt.Errorf("--%s must be positive and not exceed --%s; got %d and %d",
+				vmNativeConcurrencyMin, vmNativeConcurrencyMax, ac.min, ac.max)
+		}
+		if p.tenantCC > 1 {
+			return fmt.Errorf("--%s can't be used together with --%s greater than 1", vmNativeConcurrencyAuto, vmNativeTenantConcurrency)
+		}
+	}
+	if p.digest && (p.tenantRoute != nil || p.dstRemoteWrite) {
+		return fmt.Errorf("--%s can't be used together with --%s and --%s, since imported data is split into multiple requests",
+			vmNativeDigest, vmNativeDstTenantFromLabel, vmNativeDstRemoteWrite)
+	}
+	if p.exploreStream {
+		switch {
+		case p.planOut != "" || p.planIn != nil:
+			return fmt.Errorf("--%s can't be used together with --%s and --%s, since the plan requires all the metrics to be discovered in advance",
+				vmNativeExploreStream, vmNativePlanOut, vmNativePlanIn)
+		case p.overallProgressBar || p.maxMetrics > 0:
+			return fmt.Errorf("--%s can't be used together with --%s and --%s, since the number of metrics is unknown in advance",
+				vmNativeExploreStream, vmNativeOverallProgressBar, vmNativeMaxMetrics)
+		case p.exploreLimit > 0:
+			return fmt.Errorf("--%s can't be used together with --%s, since paged discovery can't be streamed",
+				vmNativeExploreStream, vmNativeExploreMatchLimit)
+		case len(p.matches) > 1:
+			return fmt.Errorf("--%s can't be used together with multiple --%s selectors", vmNativeExploreStream, vmNativeFilterMatch)
+		case p.sortedMetrics:
+			return fmt.Errorf("--%s can't be used together with --%s, since metrics are migrated in the order of discovery",
+				vmNativeExploreStream, vmNativeSorted)
+		}
+	}
+	if p.retrySubRanges > 1 && p.intraUnitParallelism > 1 {
+		return fmt.Errorf("--%s can't be used together with --%s, since sub-ranges of concurrently migrated requests are already retried individually",
+			vmNativeRetrySubRanges, vmNativeIntraUnitParallelism)
+	}
+	if p.requestTimeout != nil {
+		switch {
+		case p.requestTimeout.factor < 1:
+			return fmt.Errorf("--%s must be greater or equal to 1; got %v", vmNativeRequestTimeoutFactor, p.requestTimeout.factor)
+		case p.requestTimeout.max > 0 && p.requestTimeout.max < p.requestTimeout.timeout:
+			return fmt.Errorf("--%s=%s can't be lower than --%s=%s", vmNativeRequestTimeoutMax, p.requestTimeout.max, vmNativeRequestTimeout, p.requestTimeout.timeout)
+		}
+	}
+	if p.tenantFilter != nil && !p.interCluster {
+		return fmt.Errorf("--%s requires --%s", vmNativeTenantFilter, vmInterCluster)
+	}
+	if p.dstTenantOverride != "" || p.dstTenantMap != nil {
+		switch {
+		case !p.interCluster:
+			return fmt.Errorf("--%s and --%s require --%s", vmNativeDstTenant, vmNativeDstTenantMap, vmInterCluster)
+		case p.dstTenantOverride != "" && p.dstTenantMap != nil:
+			return fmt.Errorf("--%s can't be used together with --%s", vmNativeDstTenant, vmNativeDstTenantMap)
+		case p.dstTenantOverride != "" && !isValidTenant(p.dstTenantOverride):
+			return fmt.Errorf("invalid tenant %q in --%s; expecting `accountID` or `accountID:projectID`", p.dstTenantOverride, vmNativeDstTenant)
+		case p.tenantRoute != nil:
+			return fmt.Errorf("--%s and --%s can't be used together with --%s", vmNativeDstTenant, vmNativeDstTenantMap, vmNativeDstTenantFromLabel)
+		case p.skipExistingData || p.countVerification != nil:
+			return fmt.Errorf("--%s and --%s can't be used together with --%s and --%s, since a destination tenant may contain data of other source tenants",
+				vmNativeDstTenant, vmNativeDstTenantMap, vmNativeSkipExisting, vmNativeVerifyCounts)
+		}
+	}
+	if p.skipEmptyTenants && !p.interCluster {
+		return fmt.Errorf("--%s requires --%s", vmNativeSkipEmptyTenants, vmInterCluster)
+	}
+	if p.tenantsCache != nil && !p.interCluster {
+		return fmt.Errorf("--%s requires --%s", vmNativeTenantsCacheFile, vmInterCluster)
+	}
+	if len(p.srcTenants) > 0 {
+		if !p.interCluster {
+			return fmt.Errorf("--%s requires --%s", vmNativeSrcTenants, vmInterCluster)
+		}
+		for _, tenant := range p.srcTenants {
+			if !isValidTenant(tenant) {
+				return fmt.Errorf("invalid tenant %q in --%s; expecting `accountID` or `accountID:projectID`", tenant, vmNativeSrcTenants)
+			}
+		}
+	}
+	if p.downsample != nil {
+		switch {
+		case p.dstRemoteWrite || p.tenantRoute != nil:
+			return fmt.Errorf("--%s can't be used together with --%s and --%s", vmNativeDownsample, vmNativeDstRemoteWrite, vmNativeDstTenantFromLabel)
+		case p.verifyPerMetric > 0 || p.countVerification != nil:
+			return fmt.Errorf("--%s can't be used together with --%s and --%s, since downsampled data doesn't match the source",
+				vmNativeDownsample, vmNativeVerifyPerMetric, vmNativeVerifyCounts)
+		}
+	}
+	if p.skipExistingData && (p.relabelConfigs.Len() > 0 || p.tenantRoute != nil) {
+		return fmt.Errorf("--%s can't be used together with --%s and --%s, since series can't be found at destination by source filters",
+			vmNativeSkipExisting, vmNativeRelabelConfig, vmNativeDstTenantFromLabel)
+	}
+	if p.countVerification != nil && (p.relabelConfigs.Len() > 0 || p.tenantRoute != nil) {
+		return fmt.Errorf("--%s can't be used together with --%s and --%s, since series can't be found at destination by source filters",
+			vmNativeVerifyCounts, vmNativeRelabelConfig, vmNativeDstTenantFromLabel)
+	}
+	if p.filter.Exclude != "" {
+		exclude, err := parseExcludeMatch(p.filter.Exclude)
+		if err != nil {
+			return fmt.Errorf("invalid --%s: %w", vmNativeFilterExcludeMatch, err)
+		}
+		p.filter.Exclude = exclude
+	}
+	if p.chunkOverlap < 0 {
+		return fmt.Errorf("--%s can't be negative; got %s", vmNativeChunkOverlap, p.chunkOverlap)
+	}
+	if p.chunkOverlap > 0 && p.filter.Chunk == "" {
+		return fmt.Errorf("--%s requires --%s", vmNativeChunkOverlap, vmNativeStepInterval)
+	}
+	if err := validateChunkOrder(p.chunkOrder); err != nil {
+		return err
+	}
+	if err := p.validateBarStyle(); err != nil {
+		return err
+	}
+	if p.tenantRoute != nil {
+		if err := p.tenantRoute.validate(); err != nil {
+			return err
+		}
+		var err error
+		p.tenantRoute.importPath, err = vm.AddExtraLabelsToImportPath(p.dstImportPath(), p.dst.ExtraLabels)
+		if err != nil {
+			return fmt.Errorf("failed to add labels to import path: %s", err)
+		}
+	}
+	return nil
+}
 
+// exploreTenant discovers metrics to migrate for the given tenant.
+// If multiple selectors are set, metrics matching any of them are discovered.
+func (p *vmNativeProcessor) exploreTenant(ctx context.Context, tenantID string) (map[string]struct{}, error) {
+	if len(p.matches) > 1 {
+		return p.exploreMatches(ctx, tenantID)
+	}
+	return p.exploreSelector(ctx, p.filter, tenantID)
+}
+
+// exploreSelector discovers metrics matching f.Match for the given tenant.
+// Discovery is split into pages if p.exploreLimit is set.
+func (p *vmNativeProcessor) exploreSelector(ctx context.Context, f native.Filter, tenantID string) (map[string]struct{}, error) {
+	f.Match = p.labelFilters.addTo(f.Match)
+	if p.exploreLimit <= 0 {
+		p.waitSrcQPS("explore")
+		return p.src.Explore(ctx, f, tenantID)
+	}
+	metrics, pages, err := p.src.ExplorePaged(ctx, f, tenantID, p.exploreLimit, func() { p.waitSrcQPS("explore") })
+	if err != nil {
+		return nil, err
+	}
+	if p.interCluster {
+		log.Printf("Discovered %d metrics for tenant %q via %d requests", len(metrics), tenantID, pages)
+	} else {
+		log.Printf("Discovered %d metrics via %d requests", len(metrics), pages)
+	}
+	return metrics, nil
+}
+
+// explore discovers metrics to migrate for every tenant from tenants.
+// Tenants are explored concurrently with p.discoveryCC workers,
+// since discovery is query-heavy and its optimal concurrency differs from the migration one.
+func (p *vmNativeProcessor) explore(ctx context.Context, tenants []string) (map[string]map[string]struct{}, error) {
+	cc := p.discoveryCC
+	if cc < 1 {
+		cc = 1
+	}
+	if cc > len(tenants) {
+		cc = len(tenants)
+	}
+	if p.interCluster {
+		log.Printf("Exploring metrics for %d tenants with concurrency %d...", len(tenants), cc)
+	} else {
+		log.Printf("Exploring metrics...")
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		mu            sync.Mutex
+		firstErr      error
+		tenantMetrics = make(map[string]map[string]struct{}, len(tenants))
+	)
+	tenantsCh := make(chan string)
+	var wg sync.WaitGroup
+	for i := 0; i < cc; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for tenantID := range tenantsCh {
+				metrics, err := p.exploreTenant(ctx, tenantID)
+				mu.Lock()
+				if err != nil {
+					if firstErr == nil {
+						firstErr = fmt.Errorf("cannot get metrics from source %s for tenant %q: %w", p.src.Addr, tenantID, err)
+					}
+					cancel()
+				} else {
+					tenantMetrics[tenantID] = metrics
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+feed:
+	for _, tenantID := range tenants {
+		select {
+		case <-ctx.Done():
+			break feed
+		case tenantsCh <- tenantID:
+		}
+	}
+	close(tenantsCh)
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("metrics exploration interrupted: %w", err)
+	}
+	return tenantMetrics, nil
+}
+
+// confirm asks for confirmation of the question unless p.assumeYes is set,
+// in which case the question is logged and confirmed automatically.
+func (p *vmNativeProcessor) confirm(question string) bool {
+	if p.assumeYes {
+		log.Printf("%s [Y/n] yes (--%s)", question, vmNativeYes)
+		return true
+	}
+	return prompt(question)
+}
+
+// preflight performs checks of src and dst before the migration starts
+func (p *vmNativeProcessor) preflight(ctx context.Context) error {
+	if !p.skipProbe {
+		if err := p.probe(ctx); err != nil {
+			return err
+		}
+	}
+	if err := p.checkFormat(ctx); err != nil {
+		return err
+	}
+	p.checkClockSkew(ctx, "source", p.src)
+	if p.dstFile == nil && !p.dstRemoteWrite {
+		p.checkClockSkew(ctx, "destination", p.dst)
+	}
 	return nil
 }
 
-func (p *vmNativeProcessor) do(ctx context.Context, f native.Filter, srcURL, dstURL string) error {
+// checkClockSkew compares the current time of the given client's server with the local time.
+// Big clock skew breaks relative time filters and `now` calculations,
+// which may result in missing recent data. So it only warns, since migration still can be performed.
+func (p *vmNativeProcessor) checkClockSkew(ctx context.Context, name string, c *native.Client) {
+	serverTime, err := c.ServerTime(ctx)
+	if err != nil {
+		logger.Warnf("cannot measure clock skew for %s %q: %s", name, c.Addr, err)
+		return
+	}
+	skew := time.Until(serverTime)
+	log.Printf("Clock skew between vmctl and %s %q: %s", name, c.Addr, skew.Truncate(time.Millisecond))
+	if p.maxClockSkew > 0 && (skew > p.maxClockSkew || skew < -p.maxClockSkew) {
+		logger.Warnf("clock skew %s between vmctl and %s %q exceeds %s; "+
+			"this may result in missing recent data when relying on the current time; "+
+			"consider synchronizing clocks or set explicit time filters", skew.Truncate(time.Millisecond), name, c.Addr, p.maxClockSkew)
+	}
+}
+
+// checkFormat verifies that the requested native format is supported by src and dst.
+// The version of vmselect and vminsert is checked in intercluster mode, since it doesn't depend on tenant.
+// If the version can't be determined, a warning is logged and the migration proceeds.
+func (p *vmNativeProcessor) checkFormat(ctx context.Context) error {
+	err := p.src.CheckFormat(ctx, p.src.Addr)
+	if errors.Is(err, native.ErrUnknownVersion) {
+		logger.Warnf("cannot verify support of native format %q by source: %s; make sure the source supports it", p.src.Format, err)
+	} else if err != nil {
+		return fmt.Errorf("failed to verify export format at source: %w", err)
+	}
+	if p.dstFile != nil || p.dstRemoteWrite {
+		// exported data is written to files or re-encoded into remote write requests
+		return nil
+	}
+	err = p.dst.CheckFormat(ctx, p.dst.Addr)
+	if errors.Is(err, native.ErrUnknownVersion) {
+		logger.Warnf("cannot verify support of native format %q by destination: %s; make sure the destination supports it", p.dst.Format, err)
+	} else if err != nil {
+		return fmt.Errorf("failed to verify import format at destination: %w", err)
+	}
+	return nil
+}
+
+const (
+	chunkOrderAsc  = "asc"
+	chunkOrderDesc = "desc"
+)
+
+func validateChunkOrder(order string) error {
+	switch order {
+	case "", chunkOrderAsc, chunkOrderDesc:
+		return nil
+	default:
+		return fmt.Errorf("unsupported --%s=%q; supported values: %s, %s", vmNativeChunkOrder, order, chunkOrderAsc, chunkOrderDesc)
+	}
+}
+
+// orderUnits returns units of a metric in the order of migration.
+// units are sorted by time ranges, so they are reversed for chunkOrderDesc.
+// The original slice isn't modified, since verification relies on its order.
+func orderUnits(units []*migrationUnit, order string) []*migrationUnit {
+	if order != chunkOrderDesc || len(units) < 2 {
+		return units
+	}
+	reversed := make([]*migrationUnit, len(units))
+	for i, u := range units {
+		reversed[len(units)-1-i] = u
+	}
+	return reversed
+}
+
+// migrationUnit represents a single export/import request
+// of the given metric for the given time range
+type migrationUnit struct {
+	tenantID string
+	metric   string
+	// bucket is an optional label bucket of the metric if --vm-native-chunk-by-label is set
+	bucket string
+	filter native.Filter
+	srcURL string
+	dstURL string
+
+	// tracker is set if the metric must be verified after migration
+	tracker *metricTracker
+	// progress is set if the metric completion must be exposed via progress metrics
+	progress *metricProgress
+	// migrated is set to 1 once the unit is successfully migrated
+	migrated int32
+	// deadline is set if the overall migration time of the metric is limited
+	deadline *metricDeadline
+	// batch contains units migrated via shared import requests instead of the unit itself
+	batch []*migrationUnit
+}
+
+func (p *vmNativeProcessor) do(ctx context.Context, u *migrationUnit) error {
+	ctx, span := p.tracer.Start(ctx, "unit")
+	span.SetAttr("tenant", u.tenantID)
+	span.SetAttr("metric", u.metric)
+	span.SetAttr("start", u.filter.TimeStart)
+	span.SetAttr("end", u.filter.TimeEnd)
 
-	retryableFunc := func() error { return p.runSingle(ctx, f, srcURL, dstURL) }
+	migrate := func(ctx context.Context) error { return p.runSingle(ctx, u) }
+	sr := &subRanges{}
+	switch {
+	case len(u.batch) > 0:
+		migrate = func(ctx context.Context) error { return p.runBatch(ctx, u, sr) }
+	case p.intraUnitParallelism > 1:
+		migrate = func(ctx context.Context) error { return p.runParallel(ctx, u, sr) }
+	case p.retrySubRanges > 1:
+		migrate = func(ctx context.Context) error { return p.runSequential(ctx, u, sr) }
+	}
+	attempt := 0
+	retryableFunc := func() error {
+		err := p.requestTimeout.run(ctx, attempt, migrate)
+		attempt++
+		return err
+	}
+	if p.durable != nil {
+		migrate := retryableFunc
+		retryableFunc = func() error {
+			if err := migrate(); err != nil {
+				return err
+			}
+			return p.flushDurable(ctx)
+		}
+	}
+	start := time.Now()
 	attempts, err := p.backoff.Retry(ctx, retryableFunc)
+	p.s.countUnitDuration(u, time.Since(start))
 	p.s.Lock()
 	p.s.retries += attempts
 	p.s.Unlock()
+	span.SetAttr("retries", attempts)
 	if err != nil {
-		return fmt.Errorf("failed to migrate from %s to %s (retry attempts: %d): %w\nwith fileter %s", srcURL, dstURL, attempts, err, f)
+		err = fmt.Errorf("failed to migrate from %s to %s (retry attempts: %d): %w\nwith fileter %s", u.srcURL, u.dstURL, attempts, err, u.filter)
+		span.End(err)
+		return err
+	}
+	span.End(nil)
+	for _, u := range u.units() {
+		switch {
+		case p.checkpoint == nil:
+		case p.durable != nil:
+			p.markDoneDurable(u)
+		default:
+			if err := p.checkpoint.markDone(u); err != nil {
+				logger.Errorf("failed to update state: %s", err)
+			}
+		}
 	}
 
 	return nil
 }
 
-func (p *vmNativeProcessor) runSingle(ctx context.Context, f native.Filter, srcURL, dstURL string) error {
+func (p *vmNativeProcessor) runSingle(ctx context.Context, u *migrationUnit) error {
+	subUnits, err := p.runSingleAttempt(ctx, u)
+	if err != nil {
+		return err
+	}
+	// the export of u exceeded --vm-native-auto-split-bytes, so its halves are migrated instead
+	for _, subUnit := range subUnits {
+		if err := p.runSingle(ctx, subUnit); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runSingleAttempt migrates u via a single export/import pipe.
+// It returns halves of u instead of migrating it if the export of u exceeds p.autoSplitBytes.
+func (p *vmNativeProcessor) runSingleAttempt(ctx context.Context, u *migrationUnit) (subUnits []*migrationUnit, err error) {
+	ctx, span := p.tracer.Start(ctx, "attempt")
+	defer func() { span.End(err) }()
 
-	exportReader, err := p.src.ExportPipe(ctx, srcURL, f)
+	var throttledAt time.Time
+	if p.srcThrottle != nil {
+		throttledAt, err = p.srcThrottle.acquire(ctx)
+		if err != nil {
+			return nil, err
+		}
+		defer p.srcThrottle.release()
+	}
+	var sw *stallWatchdog
+	if p.httpTimeout > 0 {
+		ctx, sw = newStallWatchdog(ctx, p.httpTimeout)
+		defer func() { err = sw.stop(err) }()
+	}
+	p.waitSrcQPS("export")
+	_, exportSpan := p.tracer.Start(ctx, "export")
+	exportReader, err := p.src.ExportPipe(ctx, u.srcURL, u.filter)
+	if p.srcThrottle != nil {
+		p.srcThrottle.observe(throttledAt, err)
+	}
 	if err != nil {
-		return fmt.Errorf("failed to init export pipe: %w", err)
+		exportSpan.End(err)
+		return nil, fmt.Errorf("failed to init export pipe: %w", err)
+	}
+	defer func() { _ = exportReader.Close() }()
+	if p.srcRateLimiter != nil {
+		exportReader = limiter.NewReadLimiter(exportReader, p.srcRateLimiter)
+	}
+
+	spooled := false
+	if p.autoSplitBytes > 0 {
+		sf, exceeded, err := peekExport(p.peekSpool(), sw.reader(exportReader), p.autoSplitBytes)
+		if err != nil {
+			exportSpan.End(err)
+			return nil, fmt.Errorf("failed to read exported data: %w", err)
+		}
+		if exceeded {
+			if subUnits := p.autoSplit(u); subUnits != nil {
+				_ = sf.Close()
+				exportSpan.End(nil)
+				return subUnits, nil
+			}
+		}
+		if p.spool != nil {
+			// the rest of exported data is spooled into the same file
+			_, err = sf.write(sw.reader(exportReader), -1)
+			if err == nil {
+				err = sf.rewind()
+			}
+			if err != nil {
+				_ = sf.Close()
+				exportSpan.End(err)
+				return nil, fmt.Errorf("failed to spool exported data: %w", err)
+			}
+			_ = exportReader.Close()
+			exportReader = sf
+			spooled = true
+		} else {
+			pr, err := newPeekedReader(sf, exportReader)
+			if err != nil {
+				_ = sf.Close()
+				exportSpan.End(err)
+				return nil, fmt.Errorf("failed to read exported data: %w", err)
+			}
+			exportReader = pr
+		}
+	}
+
+	if p.spool != nil && !spooled {
+		sf, err := p.spool.store(sw.reader(exportReader))
+		if err != nil {
+			exportSpan.End(err)
+			return nil, fmt.Errorf("failed to spool exported data: %w", err)
+		}
+		_ = exportReader.Close()
+		exportReader = sf
 	}
 
+	written, err := p.importData(ctx, u, sw.reader(exportReader))
+	exportSpan.SetAttr("bytes", written)
+	exportSpan.End(err)
+	span.SetAttr("bytes", written)
+	return nil, err
+}
+
+// importData imports native data read from r into the destination of u
+// and returns the number of imported bytes
+func (p *vmNativeProcessor) importData(ctx context.Context, u *migrationUnit, r io.Reader) (int64, error) {
+	r = &contextReader{ctx: ctx, r: r}
+	if p.tenantRoute != nil {
+		written, err := p.importRouted(ctx, u, r)
+		if err != nil {
+			return written, fmt.Errorf("failed to import data routed by %q label: %w", p.tenantRoute.label, err)
+		}
+		p.s.countRequest(u, written)
+		return written, nil
+	}
+
+	if p.dstRemoteWrite {
+		return p.importRemoteWrite(ctx, u, r)
+	}
+
+	var bp *blockProcessor
+	if p.needsDecode() {
+		bp = p.newBlockProcessor()
+		dr := bp.decodePipe(r)
+		defer func() { _ = dr.Close() }()
+		r = dr
+	}
+
+	h := p.s.digests.newHash()
+	if p.dstFile != nil {
+		rl := p.newRequestRateLimiter()
+		written, err := p.dstFile.write(u, r, func(w io.Writer) io.Writer { return teeDigest(p.limitWriter(w, rl), h) })
+		if err != nil {
+			return written, err
+		}
+		p.s.digests.add(u, h)
+		p.s.countRequest(u, written)
+		if bp != nil {
+			bp.flushStats(p.s)
+		}
+		return written, nil
+	}
+
+	dstURL, header := p.stickyRoute(u.dstURL, u.metric)
 	pr, pw := io.Pipe()
 	done := make(chan struct{})
+	var importErr error
 	go func() {
 		defer func() { close(done) }()
-		if err := p.dst.ImportPipe(ctx, dstURL, pr); err != nil {
-			logger.Errorf("error initialize import pipe: %s", err)
-			return
-		}
+		_, importSpan := p.tracer.Start(ctx, "import")
+		importErr = p.dst.ImportPipe(ctx, dstURL, pr, header)
+		importSpan.End(importErr)
 	}()
 
-	w := io.Writer(pw)
-	if p.rateLimit > 0 {
-		rl := limiter.NewLimiter(p.rateLimit)
-		w = limiter.NewWriteLimiter(pw, rl)
-	}
+	// the digest is calculated over the data written to the import pipe
+	w := teeDigest(p.limitWriter(pw, p.newRequestRateLimiter()), h)
 
-	written, err := io.Copy(w, exportReader)
+	stopClosing := closePipeOnDone(ctx, pw)
+	written, err := io.Copy(w, r)
+	stopClosing()
 	if err != nil {
-		return fmt.Errorf("failed to write into %q: %s", p.dst.Addr, err)
+		// the import request must be finished before returning, since it reads from pr
+		_ = pw.CloseWithError(err)
+		<-done
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return written, fmt.Errorf("failed to write into %q: %w", p.dst.Addr, ctxErr)
+		}
+		return written, fmt.Errorf("failed to write into %q: %s", p.dst.Addr, err)
 	}
 
-	p.s.Lock()
-	p.s.bytes += uint64(written)
-	p.s.requests++
-	p.s.Unlock()
+	p.s.countRequest(u, written)
 
 	if err := pw.Close(); err != nil {
-		return err
+		return written, err
 	}
 	<-done
+	if importErr != nil {
+		// the error is returned, so the request is retried
+		return written, importErr
+	}
+	p.s.digests.add(u, h)
 
-	return nil
+	if bp != nil {
+		bp.flushStats(p.s)
+	}
+
+	return written, nil
 }
 
-func (p *vmNativeProcessor) runBackfilling(ctx context.Context, tenantID string, ranges [][]time.Time, silent bool) error {
-	exportAddr := nativeExportAddr
+func (p *vmNativeProcessor) runBackfilling(ctx context.Context, tenantID string, metrics map[string]struct{}, ranges [][]time.Time, silent bool) (err error) {
+	ctx, span := p.tracer.Start(ctx, "tenant")
+	span.SetAttr("tenant", tenantID)
+	span.SetAttr("metrics", len(metrics))
+	defer func() { span.End(err) }()
+
+	exportAddr := p.srcExportPath()
 	srcURL := fmt.Sprintf("%s/%s", p.src.Addr, exportAddr)
 
-	importAddr, err := vm.AddExtraLabelsToImportPath(nativeImportAddr, p.dst.ExtraLabels)
+	importAddr, err := vm.AddExtraLabelsToImportPath(p.dstImportPath(), p.dst.ExtraLabels)
 	if err != nil {
 		return fmt.Errorf("failed to add labels to import path: %s", err)
 	}
@@ -161,97 +1168,356 @@ func (p *vmNativeProcessor) runBackfilling(ctx context.Context, tenantID string,
 
 	if p.interCluster {
 		srcURL = fmt.Sprintf("%s/select/%s/prometheus/%s", p.src.Addr, tenantID, exportAddr)
-		dstURL = fmt.Sprintf("%s/insert/%s/prometheus/%s", p.dst.Addr, tenantID, importAddr)
+		dstURL = fmt.Sprintf("%s/insert/%s/prometheus/%s", p.dst.Addr, p.dstTenant(tenantID), importAddr)
+	}
+	if p.dstFile != nil {
+		dstURL = p.dstFile.template
+	}
+	if p.dstRemoteWrite {
+		// the address is the URL of remote write endpoint
+		dstURL = p.dst.Addr
 	}
 
 	barPrefix := "Requests to make"
 	initMessage := "Initing import process from %q to %q with filter %s"
-	initParams := []interface{}{srcURL, dstURL, p.filter.String()}
+	filter := p.filter
+	filter.Match = p.matchString()
+	initParams := []interface{}{srcURL, dstURL, filter.String()}
 	if p.interCluster {
 		barPrefix = fmt.Sprintf("Requests to make for tenant %s", tenantID)
 		initMessage = "Initing import process from %q to %q with filter %s for tenant %s"
-		initParams = []interface{}{srcURL, dstURL, p.filter.String(), tenantID}
+		initParams = []interface{}{srcURL, dstURL, filter.String(), tenantID}
 	}
 
 	fmt.Println("") // extra line for better output formatting
-	log.Printf(initMessage, initParams...)
+	initFields := logFields{"event": "init", "src": srcURL, "dst": dstURL, "filter": filter.String()}
+	if p.interCluster {
+		initFields["tenant"] = tenantID
+		if dstTenant := p.dstTenant(tenantID); dstTenant != tenantID {
+			initFields["dst_tenant"] = dstTenant
+		}
+	}
+	logEvent(fmt.Sprintf(initMessage, initParams...), initFields)
 
-	log.Printf("Exploring metrics...")
-	metrics, err := p.src.Explore(ctx, p.filter, tenantID)
-	if err != nil {
-		return fmt.Errorf("cannot get metrics from source %s: %w", p.src.Addr, err)
+	if len(metrics) == 0 && !p.exploreStream {
+		switch {
+		case p.metricSampler != nil:
+			return fmt.Errorf("%w sampled via --%s", errNoMetrics, vmNativeSamplePercentage)
+		case p.metricNameFilter != nil:
+			return fmt.Errorf("%w matching --%s and --%s", errNoMetrics, vmNativeMetricNameFilter, vmNativeMetricNameExclude)
+		}
+		return errNoMetrics
 	}
 
-	if len(metrics) == 0 {
-		return fmt.Errorf("no metrics found")
+	if p.maxMetrics > 0 && len(metrics) > p.maxMetrics {
+		msg := fmt.Sprintf("Found %d metrics to import, which exceeds --%s=%d. Sample of matched metrics: %s",
+			len(metrics), vmNativeMaxMetrics, p.maxMetrics, strings.Join(sampleMetrics(metrics, 10), ", "))
+		if silent {
+			return fmt.Errorf("%s; refine %s filter or increase --%s", msg, vmNativeFilterMatch, vmNativeMaxMetrics)
+		}
+		if !p.confirm(msg + ".\n Continue?") {
+			return nil
+		}
 	}
 
 	foundSeriesMsg := fmt.Sprintf("Found %d metrics to import", len(metrics))
+	if p.exploreStream {
+		foundSeriesMsg = "Metrics to import are discovered during the import"
+	}
 	if !p.interCluster {
 		// do not prompt for intercluster because there could be many tenants,
 		// and we don't want to interrupt the process when moving to the next tenant.
+		if !silent && p.estimateMetrics > 0 && len(metrics) > 0 && !p.isJSONL() {
+			te, err := p.estimateTransfer(ctx, srcURL, tenantID, metrics, ranges[0][0], ranges[len(ranges)-1][1])
+			if err != nil {
+				logger.Warnf("cannot estimate transfer size: %s", err)
+			} else {
+				foundSeriesMsg += ". " + te.message(p.transferRate())
+				p.dryRun.addEstimate(te)
+			}
+		}
 		question := foundSeriesMsg + ". Continue?"
-		if !silent && !prompt(question) {
+		if !silent && !p.confirm(question) {
 			return nil
 		}
 	} else {
-		log.Print(foundSeriesMsg)
+		logEvent(foundSeriesMsg, logFields{"event": "explore", "tenant": tenantID, "metric_count": len(metrics)})
 	}
 
-	processingMsg := fmt.Sprintf("Requests to make: %d", len(metrics)*len(ranges))
+	var bucketRegexps []string
+	if p.labelChunks != nil {
+		bucketRegexps = p.labelChunks.regexps()
+	}
+	buckets := len(bucketRegexps)
+	if buckets == 0 {
+		buckets = 1
+	}
+	// the number of requests is unknown in advance if metrics are discovered during the import,
+	// so it is increased once metrics are discovered
+	requests := p.metricRequests(tenantID, metrics) * len(ranges) * buckets
+	if p.checkpoint != nil {
+		p.checkpoint.setTotal(tenantID, requests)
+	}
+	processingMsg := fmt.Sprintf("Requests to make: %d", requests)
+	if p.exploreStream {
+		processingMsg = fmt.Sprintf("Requests to make per metric: %d", len(ranges)*buckets)
+	}
 	if len(ranges) > 1 {
 		processingMsg = fmt.Sprintf("Selected time range will be split into %d ranges according to %q step. %s", len(ranges), p.filter.Chunk, processingMsg)
 	}
+	if len(bucketRegexps) > 0 {
+		processingMsg = fmt.Sprintf("Every metric will be split into %d buckets by %q label. %s", len(bucketRegexps), p.labelChunks.label, processingMsg)
+	}
 	log.Print(processingMsg)
 
 	var bar *pb.ProgressBar
-	if !silent {
-		bar = pb.ProgressBarTemplate(fmt.Sprintf(nativeBarTpl, barPrefix)).New(len(metrics) * len(ranges))
-		bar.Start()
+	barStartBytes := p.s.bytesTotal()
+	switch {
+	case p.overallBar != nil:
+		// the bar is shared between all the tenants
+		bar, barStartBytes = p.overallBar, p.overallBarStartBytes
+	case silent || !p.showBars():
+		// progress bars are disabled
+	case p.importSem == nil && p.dryRun == nil && p.exploreStream:
+		bar = p.newNativeSpinner(barPrefix)
+		defer bar.Finish()
+	case p.importSem == nil && p.dryRun == nil:
+		// progress bars of concurrently migrated tenants can't be rendered together
+		bar = p.newNativeBar(barPrefix, requests)
 		defer bar.Finish()
 	}
 
-	filterCh := make(chan native.Filter)
-	errCh := make(chan error, p.cc)
+	filterCh := make(chan *migrationUnit)
+	workers := p.cc
+	if p.importSem != nil {
+		workers = p.perTenantCC
+	}
+	if p.dryRun != nil {
+		// units are only recorded
+		workers = 0
+	}
+	errCh := make(chan error, workers)
 
 	var wg sync.WaitGroup
-	for i := 0; i < p.cc; i++ {
+	for i := 0; i < workers; i++ {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			for f := range filterCh {
-				if err := p.do(ctx, f, srcURL, dstURL); err != nil {
+			if p.batchSize > 1 {
+				if err := p.runBatchWorker(ctx, filterCh, func() { p.incrementBar(bar, barStartBytes) }); err != nil {
 					errCh <- err
+				}
+				return
+			}
+			for u := range filterCh {
+				if u.deadline.expired() {
+					p.skipUnit(u)
+					p.unitDone(ctx, u, errMetricDeadline)
+					p.incrementBar(bar, barStartBytes)
+					continue
+				}
+				if p.skipExisting(ctx, u) {
+					p.unitDone(ctx, u, nil)
+					p.incrementBar(bar, barStartBytes)
+					continue
+				}
+				if !p.acquireImportSlot(ctx) {
+					errCh <- ctx.Err()
 					return
 				}
-				if bar != nil {
-					bar.Increment()
+				if !p.autoConcurrency.acquire(ctx) {
+					p.releaseImportSlot()
+					errCh <- ctx.Err()
+					return
 				}
+				uctx, cancel := u.deadline.context(ctx)
+				err := p.do(uctx, u)
+				cancel()
+				p.autoConcurrency.release()
+				p.releaseImportSlot()
+				if err != nil && u.deadline.expired() && ctx.Err() == nil {
+					// the unit was interrupted because of the metric deadline
+					p.skipUnit(u)
+					p.unitDone(ctx, u, errMetricDeadline)
+					p.incrementBar(bar, barStartBytes)
+					continue
+				}
+				if err != nil && ctx.Err() != nil {
+					// the unit is recorded in the failures file, so it could be migrated
+					// after the interruption
+					p.failures.add(u, err)
+					continue
+				}
+				if err != nil {
+					if !p.continueOnError {
+						// the unit is recorded in the failures file, so it could be found
+						// after the migration is stopped
+						p.unitFailed(ctx, u, err)
+						errCh <- err
+						return
+					}
+					// the failure is isolated to the time range of the unit,
+					// so the rest of ranges of the metric proceed
+					logger.Errorf("request for metric %q and time range %s - %s failed; the rest of time ranges of the metric proceed: %s",
+						u.metric, u.filter.TimeStart, u.filter.TimeEnd, err)
+					p.unitFailed(ctx, u, err)
+				} else {
+					p.unitDone(ctx, u, nil)
+					p.completed.add(u)
+					p.countVerification.add(u)
+				}
+				p.incrementBar(bar, barStartBytes)
 			}
 		}()
 	}
 
+	var skipped, overlapping int
+	// fed and iterated are used for reporting the requests and metrics left because of the budget
+	var fed, iterated int
+	var deadlines []*metricDeadline
+	stream := p.streamMetrics(ctx, tenantID, metrics)
+	defer func() { _ = stream.stop() }()
+	// units of a window of metrics are interleaved, so workers process different metrics
+	// and a single metric with slow ranges doesn't occupy all the workers
+	ui := newUnitInterleaver(workers)
+	metricsCh := stream.ch
 	// any error breaks the import
-	for s := range metrics {
+feed:
+	for {
+		for metricsCh != nil && !ui.full() {
+			s, ok := <-metricsCh
+			if !ok {
+				metricsCh = nil
+				break
+			}
+			iterated++
 
-		match, err := buildMatchWithFilter(p.filter.Match, s)
-		if err != nil {
-			logger.Errorf("failed to build export filters: %s", err)
-			continue
+			matches, err := p.metricMatches(tenantID, s)
+			if err != nil {
+				logger.Errorf("failed to build export filters: %s", err)
+				continue
+			}
+			if len(matches) > 1 {
+				overlapping++
+			}
+			if p.exploreStream {
+				n := len(matches) * len(ranges) * buckets
+				requests += n
+				if p.checkpoint != nil {
+					p.checkpoint.addTotal(tenantID, n)
+				}
+				p.progress.addTotalMetrics(1)
+			}
+
+			var units []*migrationUnit
+			for _, match := range matches {
+				// exclusion is applied to the match of every unit,
+				// so it is respected by retries and recorded in failures
+				match = p.filter.WithExclude(match)
+
+				metricRanges := ranges
+				if p.autoChunkSamples > 0 {
+					metricRanges = p.autoChunkRanges(ctx, srcURL, match, ranges)
+					delta := (len(metricRanges) - len(ranges)) * buckets
+					requests += delta
+					if bar != nil {
+						bar.AddTotal(int64(delta))
+					}
+					if p.checkpoint != nil {
+						p.checkpoint.addTotal(tenantID, delta)
+					}
+				}
+
+				for _, times := range metricRanges {
+					for i := 0; i < buckets; i++ {
+						u := &migrationUnit{
+							tenantID: tenantID,
+							metric:   s,
+							filter: native.Filter{
+								Match:     match,
+								TimeStart: times[0].Format(time.RFC3339),
+								TimeEnd:   times[1].Format(time.RFC3339),
+							},
+							srcURL: srcURL,
+							dstURL: dstURL,
+						}
+						if len(bucketRegexps) > 0 {
+							u.bucket = fmt.Sprintf("%s:%d/%d", p.labelChunks.label, i+1, len(bucketRegexps))
+							u.filter.Match = addLabelMatcher(match, p.labelChunks.label, bucketRegexps[i])
+						}
+						if p.checkpoint != nil && p.checkpoint.isDone(u) {
+							skipped++
+							p.incrementBar(bar, barStartBytes)
+							continue
+						}
+						units = append(units, u)
+					}
+				}
+			}
+
+			if md := newMetricDeadline(s, p.metricDeadline); md != nil && len(units) > 0 {
+				md.units = len(units)
+				for _, u := range units {
+					u.deadline = md
+				}
+				deadlines = append(deadlines, md)
+			}
+
+			if p.progress != nil {
+				if len(units) == 0 {
+					// all the units were migrated before
+					p.progress.metricCompleted()
+				}
+				mp := &metricProgress{pending: int32(len(units))}
+				for _, u := range units {
+					u.progress = mp
+				}
+			}
+
+			if p.verifyPerMetric > 0 && len(units) > 0 {
+				mt := &metricTracker{
+					tenantID: tenantID,
+					metric:   s,
+					units:    units,
+					pending:  int32(len(units)),
+				}
+				for _, u := range units {
+					u.tracker = mt
+				}
+			}
+
+			ui.add(orderUnits(units, p.chunkOrder))
 		}
 
-		for _, times := range ranges {
-			select {
-			case <-ctx.Done():
-				return fmt.Errorf("context canceled")
-			case infErr := <-errCh:
-				return fmt.Errorf("native error: %s", infErr)
-			case filterCh <- native.Filter{
-				Match:     match,
-				TimeStart: times[0].Format(time.RFC3339),
-				TimeEnd:   times[1].Format(time.RFC3339),
-			}:
+		u := ui.peek()
+		if u == nil {
+			break
+		}
+		if p.dryRun != nil {
+			p.dryRun.add(u)
+			ui.pop()
+			continue
+		}
+		if p.budgetReached() {
+			// metrics with pending units are left as well, since not all of their requests were started
+			left := len(metrics) - iterated + ui.len()
+			if p.exploreStream {
+				// metrics, which weren't discovered yet, are unknown
+				left = ui.len()
 			}
+			p.budgetLeft.add(left, requests-fed-skipped)
+			break feed
+		}
+		select {
+		case <-ctx.Done():
+			// in-flight requests must be finished before return
+			break feed
+		case infErr := <-errCh:
+			return fmt.Errorf("native error: %s", infErr)
+		case filterCh <- u:
+			fed++
+			atomic.AddInt64(&p.startedRequests, 1)
+			ui.pop()
 		}
 	}
 
@@ -259,13 +1525,67 @@ func (p *vmNativeProcessor) runBackfilling(ctx context.Context, tenantID string,
 	wg.Wait()
 	close(errCh)
 
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	for err := range errCh {
 		return fmt.Errorf("import process failed: %s", err)
 	}
+	if err := stream.stop(); err != nil {
+		return fmt.Errorf("failed to discover metrics: %w", err)
+	}
+	if p.exploreStream && iterated == 0 {
+		return errNoMetrics
+	}
+
+	reportDeadlines(deadlines)
+	if overlapping > 0 {
+		logger.Warnf("%d metrics were matched by multiple --%s selectors with different label matchers; "+
+			"series matching several of them are migrated once per selector", overlapping, vmNativeFilterMatch)
+	}
+	if skipped > 0 {
+		log.Printf("Skipped %d requests already migrated according to --%s", skipped, vmNativeStateFile)
+	}
 
 	return nil
 }
 
+// waitSrcQPS blocks until the next request to src is allowed by p.srcQPS
+func (p *vmNativeProcessor) waitSrcQPS(requestType string) {
+	if p.srcQPS == nil {
+		return
+	}
+	start := time.Now()
+	p.srcQPS.Register(1)
+	if d := time.Since(start); d >= 10*time.Millisecond {
+		logger.WithThrottler("src-qps", 5*time.Second).Warnf("%s request to source was delayed for %s by --%s limiter",
+			requestType, d.Truncate(time.Millisecond), vmNativeSrcQPS)
+	}
+}
+
+// sampleMetrics returns up to n sorted metric names from metrics
+func sampleMetrics(metrics map[string]struct{}, n int) []string {
+	names := make([]string, 0, len(metrics))
+	for name := range metrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if len(names) > n {
+		names = names[:n]
+	}
+	return names
+}
+
+// budgetReached returns true if p.maxTotalBytes were transferred
+// or p.maxTotalRequests were started
+func (p *vmNativeProcessor) budgetReached() bool {
+	if p.maxTotalRequests > 0 && atomic.LoadInt64(&p.startedRequests) >= p.maxTotalRequests {
+		return true
+	}
+	return p.maxTotalBytes > 0 && p.s.bytesTotal() >= uint64(p.maxTotalBytes)
+}
+
 // stats represents client statistic
 // when processing data
 type stats struct {
@@ -274,6 +1594,141 @@ type stats struct {
 	bytes     uint64
 	requests  uint64
 	retries   uint64
+
+	duplicateSeries  uint64
+	duplicateSamples uint64
+
+	nonFiniteSeries  uint64
+	nonFiniteSamples uint64
+
+	scaledSeries  uint64
+	scaledSamples uint64
+
+	relabelDroppedSeries  uint64
+	relabelDroppedSamples uint64
+
+	downsampledSeries     uint64
+	downsampledSamples    uint64
+	downsampledOutSamples uint64
+
+	dedupSeries  uint64
+	dedupSamples uint64
+
+	stampOverwrittenSeries uint64
+
+	resumedSubRanges uint64
+	// autoSplits is the number of time ranges split because of --vm-native-auto-split-bytes
+	autoSplits uint64
+
+	// emptyTenants is the number of tenants skipped because of --vm-native-skip-empty-tenants
+	emptyTenants uint64
+
+	verifiedMetrics   uint64
+	mismatchedMetrics uint64
+
+	countVerifiedRequests   uint64
+	countMismatchedRequests uint64
+
+	skippedExisting uint64
+
+	// tenants contains per-tenant stats
+	tenants map[string]*tenantStats
+	// verbose defines whether to print the breakdown of stats for a single tenant
+	verbose bool
+
+	// digests collects digests of imported data. It is nil if disabled.
+	digests *transferDigests
+}
+
+// newRequestRateLimiter returns limiter for a single request according to p.rateLimit.
+// It returns nil if p.rateLimit isn't set.
+func (p *vmNativeProcessor) newRequestRateLimiter() *limiter.Limiter {
+	if p.rateLimit <= 0 {
+		return nil
+	}
+	l := limiter.NewLimiter(p.rateLimit)
+	p.slowStart.register(l)
+	return l
+}
+
+// limitWriter limits the rate of writes to w according to optional per-request limiter rl
+// and p.globalRateLimiter shared between all the requests.
+func (p *vmNativeProcessor) limitWriter(w io.Writer, rl *limiter.Limiter) io.Writer {
+	if rl != nil {
+		w = limiter.NewWriteLimiter(w, rl)
+	}
+	if p.globalRateLimiter != nil {
+		p.slowStart.register(p.globalRateLimiter)
+		w = limiter.NewWriteLimiter(w, p.globalRateLimiter)
+	}
+	return w
+}
+
+// newNativeBar starts the progress bar for total requests
+func (p *vmNativeProcessor) newNativeBar(prefix string, total int) *pb.ProgressBar {
+	bar := p.barTemplate(prefix, false).New(total)
+	bar.Set("prefix", prefix)
+	bar.Set("speed", byteCountSI(0)+"/s")
+	bar.Start()
+	return bar
+}
+
+// newNativeSpinner returns started bar without total for the case when the number of requests is unknown
+func (p *vmNativeProcessor) newNativeSpinner(prefix string) *pb.ProgressBar {
+	bar := p.barTemplate(prefix, true).New(0)
+	bar.Set("prefix", prefix)
+	bar.Set("speed", byteCountSI(0)+"/s")
+	bar.Start()
+	return bar
+}
+
+// totalRequests returns the number of requests for migrating tenantMetrics split into ranges
+func (p *vmNativeProcessor) totalRequests(tenantMetrics map[string]map[string]struct{}, ranges [][]time.Time) int {
+	buckets := 1
+	if p.labelChunks != nil {
+		if n := len(p.labelChunks.regexps()); n > 0 {
+			buckets = n
+		}
+	}
+	var requests int
+	for tenantID, metrics := range tenantMetrics {
+		requests += p.metricRequests(tenantID, metrics) * len(ranges) * buckets
+	}
+	return requests
+}
+
+// tenantRequests returns the number of requests for migrating metrics of the given tenant
+func (p *vmNativeProcessor) tenantRequests(tenantID string, metrics map[string]struct{}, ranges [][]time.Time) int {
+	return p.totalRequests(map[string]map[string]struct{}{tenantID: metrics}, ranges)
+}
+
+// incrementBar increments the given bar and updates the import speed displayed in it.
+// Progress metrics are updated as well, since the bar may be disabled.
+// startBytes is the number of bytes imported before the bar was started.
+func (p *vmNativeProcessor) incrementBar(bar *pb.ProgressBar, startBytes uint64) {
+	p.progress.update(p.s)
+	if bar == nil {
+		return
+	}
+	bar.Increment()
+	var bytesPerS int64
+	if d := time.Since(bar.StartTime()).Seconds(); d > 0 {
+		bytesPerS = int64(float64(p.s.bytesTotal()-startBytes) / d)
+	}
+	bar.Set("speed", byteCountSI(bytesPerS)+"/s")
+}
+
+func (s *stats) bytesTotal() uint64 {
+	s.Lock()
+	defer s.Unlock()
+	return s.bytes
+}
+
+// counters returns the number of transferred bytes, requests and retries
+func (s *stats) counters() (uint64, uint64, uint64) {
+	s.Lock()
+	defer s.Unlock()
+	return s.bytes, s.requests, s.retries
 }
 
 func (s *stats) String() string {
@@ -287,7 +1742,7 @@ func (s *stats) String() string {
 		bytesPerS = byteCountSI(int64(float64(s.bytes) / totalImportDurationS))
 	}
 
-	return fmt.Sprintf("VictoriaMetrics importer stats:\n"+
+	str := fmt.Sprintf("VictoriaMetrics importer stats:\n"+
 		"  time spent while importing: %v;\n"+
 		"  total bytes: %s;\n"+
 		"  bytes/s: %s;\n"+
@@ -296,6 +1751,138 @@ func (s *stats) String() string {
 		totalImportDuration,
 		byteCountSI(int64(s.bytes)), bytesPerS,
 		s.requests, s.retries)
+	if s.duplicateSeries > 0 {
+		str += fmt.Sprintf("\n  series with duplicate timestamps: %d;\n"+
+			"  samples with duplicate timestamps: %d;",
+			s.duplicateSeries, s.duplicateSamples)
+	}
+	if s.nonFiniteSeries > 0 {
+		str += fmt.Sprintf("\n  series with dropped NaN/Inf values: %d;\n"+
+			"  dropped NaN/Inf samples: %d;",
+			s.nonFiniteSeries, s.nonFiniteSamples)
+	}
+	if s.scaledSeries > 0 {
+		str += fmt.Sprintf("\n  series with scaled values: %d;\n"+
+			"  scaled samples: %d;",
+			s.scaledSeries, s.scaledSamples)
+	}
+	if s.relabelDroppedSeries > 0 {
+		str += fmt.Sprintf("\n  series dropped by relabeling: %d;\n"+
+			"  samples dropped by relabeling: %d;",
+			s.relabelDroppedSeries, s.relabelDroppedSamples)
+	}
+	if s.emptyTenants > 0 {
+		str += fmt.Sprintf("\n  tenants skipped without metrics: %d;", s.emptyTenants)
+	}
+	if s.resumedSubRanges > 0 {
+		str += fmt.Sprintf("\n  sub-ranges skipped on retries: %d;", s.resumedSubRanges)
+	}
+	if s.autoSplits > 0 {
+		str += fmt.Sprintf("\n  time ranges split because of export size: %d;", s.autoSplits)
+	}
+	if s.downsampledSeries > 0 {
+		str += fmt.Sprintf("\n  downsampled series: %d;\n"+
+			"  downsampled samples: %d into %d;",
+			s.downsampledSeries, s.downsampledSamples, s.downsampledOutSamples)
+	}
+	if s.dedupSeries > 0 {
+		str += fmt.Sprintf("\n  series with samples dropped by stream dedup: %d;\n"+
+			"  samples dropped by stream dedup: %d;",
+			s.dedupSeries, s.dedupSamples)
+	}
+	if s.stampOverwrittenSeries > 0 {
+		str += fmt.Sprintf("\n  series with labels overwritten by stamping: %d;", s.stampOverwrittenSeries)
+	}
+	if s.verifiedMetrics > 0 || s.mismatchedMetrics > 0 {
+		str += fmt.Sprintf("\n  verified metrics: %d;\n"+
+			"  metrics failed verification: %d;",
+			s.verifiedMetrics, s.mismatchedMetrics)
+	}
+	if s.countVerifiedRequests > 0 || s.countMismatchedRequests > 0 {
+		str += fmt.Sprintf("\n  requests with verified sample counts: %d;\n"+
+			"  requests with mismatching sample counts: %d;",
+			s.countVerifiedRequests, s.countMismatchedRequests)
+	}
+	if s.skippedExisting > 0 {
+		str += fmt.Sprintf("\n  requests skipped because of existing data at destination: %d;", s.skippedExisting)
+	}
+	str += s.breakdown()
+	if s.digests != nil {
+		str += s.digests.String()
+	}
+	return str
+}
+
+const (
+	statsFormatText = "text"
+	statsFormatJSON = "json"
+)
+
+func validateStatsFormat(format string) error {
+	switch format {
+	case "", statsFormatText, statsFormatJSON:
+		return nil
+	default:
+		return fmt.Errorf("unsupported --%s=%q; supported values: %s, %s", vmNativeStatsFormat, format, statsFormatText, statsFormatJSON)
+	}
+}
+
+// statsJSON is the JSON representation of stats
+type statsJSON struct {
+	DurationSeconds float64 `json:"duration_seconds"`
+	TotalBytes      uint64  `json:"total_bytes"`
+	BytesPerSecond  uint64  `json:"bytes_per_second"`
+	Requests        uint64  `json:"requests"`
+	Retries         uint64  `json:"retries"`
+
+	Digests map[string]string `json:"sha256_digests,omitempty"`
+}
+
+// MarshalJSON implements json.Marshaler interface
+func (s *stats) MarshalJSON() ([]byte, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	totalImportDurationS := time.Since(s.startTime).Seconds()
+	var bytesPerS uint64
+	if s.bytes > 0 && totalImportDurationS > 0 {
+		bytesPerS = uint64(float64(s.bytes) / totalImportDurationS)
+	}
+	sj := statsJSON{
+		DurationSeconds: totalImportDurationS,
+		TotalBytes:      s.bytes,
+		BytesPerSecond:  bytesPerS,
+		Requests:        s.requests,
+		Retries:         s.retries,
+	}
+	if s.digests != nil {
+		sj.Digests = s.digests.digests()
+	}
+	return json.Marshal(sj)
+}
+
+// printStats prints the final stats in p.statsFormat.
+// JSON stats are printed to stdout as a single line, so they could be parsed by scripts.
+func (p *vmNativeProcessor) printStats() error {
+	if p.statsFormat != statsFormatJSON && !jsonLogs {
+		log.Print(p.s)
+		return nil
+	}
+	data, err := json.Marshal(p.s)
+	if err != nil {
+		return fmt.Errorf("cannot marshal stats: %w", err)
+	}
+	if p.statsFormat == statsFormatJSON {
+		fmt.Println(string(data))
+		return nil
+	}
+	var fields logFields
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return fmt.Errorf("cannot unmarshal stats: %w", err)
+	}
+	fields["event"] = "stats"
+	logEvent("VictoriaMetrics importer stats", fields)
+	return nil
 }
 
 func byteCountSI(b int64) string {
@@ -312,12 +1899,33 @@ func byteCountSI(b int64) string {
 		float64(b)/float64(div), "kMGTPE"[exp])
 }
 
-func buildMatchWithFilter(filter string, metricName string) (string, error) {
+// parseExcludeMatch parses series selector s containing negative matchers only
+// and returns it in canonical form.
+func parseExcludeMatch(s string) (string, error) {
+	expr, err := metricsql.Parse(s)
+	if err != nil {
+		return "", fmt.Errorf("cannot parse series selector %q: %w", s, err)
+	}
+	me, ok := expr.(*metricsql.MetricExpr)
+	if !ok || len(me.LabelFilters) == 0 {
+		return "", fmt.Errorf("%q must be a series selector with at least one matcher, e.g. {__name__!~\"go_.*\"}", s)
+	}
+	for _, lf := range me.LabelFilters {
+		if !lf.IsNegative {
+			return "", fmt.Errorf("%q must contain only negative matchers, e.g. `!=` or `!~`; got %s", s, lf.AppendString(nil))
+		}
+	}
+	return string(me.AppendString(nil)), nil
+}
+
+// buildMatchWithFilter returns selector for metricName built from filter.
+// Matchers from optional lf are added to the selector as additional constraints.
+func buildMatchWithFilter(filter string, metricName string, lf *labelFilters) (string, error) {
 	labels, err := promutils.NewLabelsFromString(filter)
 	if err != nil {
 		return "", err
 	}
 	labels.Set("__name__", metricName)
 
-	return labels.String(), nil
+	return lf.addTo(labels.String()), nil
 }
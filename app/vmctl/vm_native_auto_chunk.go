@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmctl/native"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmctl/stepper"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+)
+
+const (
+	// autoChunkWindow is the time window at the end of the migrated time range
+	// used for estimating the sample rate of a metric
+	autoChunkWindow = 10 * time.Minute
+	// autoChunkMinStep is the minimum chunk derived from the sample rate
+	autoChunkMinStep = time.Minute
+)
+
+// autoChunkRanges splits the time range covered by ranges into chunks expected
+// to contain p.autoChunkSamples samples according to the sample rate of the metric selected by match.
+// It returns ranges as is if the sample rate can't be detected.
+func (p *vmNativeProcessor) autoChunkRanges(ctx context.Context, srcURL, match string, ranges [][]time.Time) [][]time.Time {
+	start, end := ranges[0][0], ranges[len(ranges)-1][1]
+	chunk, err := p.estimateChunk(ctx, srcURL, match, start, end)
+	if err != nil {
+		logger.Warnf("cannot detect sample rate for %s: %s; falling back to --%s", match, err, vmNativeStepInterval)
+		return ranges
+	}
+	n := int(math.Ceil(float64(end.Sub(start)) / float64(chunk)))
+	autoRanges, err := stepper.SplitDateRangeEvenly(start, end, n)
+	if err != nil {
+		logger.Warnf("cannot split time range for %s: %s; falling back to --%s", match, err, vmNativeStepInterval)
+		return ranges
+	}
+	return stepper.OverlapRanges(autoRanges, p.chunkOverlap)
+}
+
+// estimateChunk returns the duration of time range expected to contain p.autoChunkSamples samples
+// for the metric selected by match. The sample rate is estimated by exporting
+// the last autoChunkWindow of start-end time range.
+func (p *vmNativeProcessor) estimateChunk(ctx context.Context, srcURL, match string, start, end time.Time) (time.Duration, error) {
+	windowStart := end.Add(-autoChunkWindow)
+	if windowStart.Before(start) {
+		windowStart = start
+	}
+	window := end.Sub(windowStart)
+	if window <= 0 {
+		return 0, fmt.Errorf("empty time range")
+	}
+
+	p.waitSrcQPS("export")
+	r, err := p.src.ExportPipe(ctx, srcURL, native.Filter{
+		Match:     match,
+		TimeStart: windowStart.Format(time.RFC3339),
+		TimeEnd:   end.Format(time.RFC3339),
+	})
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = r.Close() }()
+
+	samples := 0
+	err = native.Transform(io.Discard, r, func(b *native.Block) error {
+		samples += len(b.Timestamps)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	if samples == 0 {
+		return 0, fmt.Errorf("no samples found between %s and %s", windowStart.Format(time.RFC3339), end.Format(time.RFC3339))
+	}
+
+	samplesPerSecond := float64(samples) / window.Seconds()
+	chunk := time.Duration(float64(p.autoChunkSamples) / samplesPerSecond * float64(time.Second))
+	if chunk < autoChunkMinStep {
+		chunk = autoChunkMinStep
+	}
+	return chunk, nil
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmctl/native"
+)
+
+func TestAutoChunkRanges(t *testing.T) {
+	// 10 series with 15s interval within 10m window result in 400 samples
+	var blocks []*native.Block
+	for i := 0; i < 10; i++ {
+		var ts []int64
+		var vs []float64
+		for j := 0; j < 40; j++ {
+			ts = append(ts, int64(j)*15e3)
+			vs = append(vs, float64(j))
+		}
+		b := &native.Block{Timestamps: ts, Values: vs}
+		b.MetricName.MetricGroup = []byte("foo")
+		b.MetricName.AddTag("instance", string(rune('a'+i)))
+		blocks = append(blocks, b)
+	}
+	data := encodeTestBlocks(t, blocks...)
+	empty := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if empty {
+			return
+		}
+		_, _ = w.Write(data)
+	}))
+	defer srv.Close()
+
+	p := &vmNativeProcessor{
+		src: &native.Client{Addr: srv.URL},
+		// 2/3s of samples per second result in 1h chunks
+		autoChunkSamples: 2400,
+	}
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := start.Add(4 * time.Hour)
+	ranges := [][]time.Time{{start, start.Add(2 * time.Hour)}, {start.Add(2 * time.Hour), end}}
+
+	got := p.autoChunkRanges(context.Background(), srv.URL, `{__name__="foo"}`, ranges)
+	if len(got) != 4 {
+		t.Fatalf("expecting 4 ranges; got %d: %v", len(got), got)
+	}
+	if !got[0][0].Equal(start) || !got[3][1].Equal(end) {
+		t.Fatalf("unexpected boundaries of ranges: %v", got)
+	}
+
+	// fall back to the given ranges if there are no samples
+	empty = true
+	got = p.autoChunkRanges(context.Background(), srv.URL, `{__name__="foo"}`, ranges)
+	if len(got) != len(ranges) {
+		t.Fatalf("expecting %d ranges; got %d", len(ranges), len(got))
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	// autoConcurrencyInterval is the interval between concurrency adjustments
+	autoConcurrencyInterval = 10 * time.Second
+	// autoConcurrencyTolerance is the minimum relative throughput improvement
+	// required for keeping the increased concurrency
+	autoConcurrencyTolerance = 0.05
+)
+
+// autoConcurrency limits the number of concurrent requests and adjusts
+// the limit within [min, max] according to the observed throughput and retry rate
+type autoConcurrency struct {
+	min          int
+	max          int
+	maxRetryRate float64
+
+	mu     sync.Mutex
+	cond   *sync.Cond
+	limit  int
+	active int
+
+	// prevThroughput is the throughput in bytes/s observed during the previous interval
+	prevThroughput float64
+	// lastDelta is the last change of the limit
+	lastDelta int
+}
+
+func newAutoConcurrency(min, max int, maxRetryRate float64) *autoConcurrency {
+	ac := &autoConcurrency{
+		min:          min,
+		max:          max,
+		maxRetryRate: maxRetryRate,
+		limit:        min,
+	}
+	ac.cond = sync.NewCond(&ac.mu)
+	return ac
+}
+
+// acquire blocks until the number of active requests is below the limit.
+// It returns false if ctx is canceled. It is no-op if ac is nil.
+func (ac *autoConcurrency) acquire(ctx context.Context) bool {
+	if ac == nil {
+		return true
+	}
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+	for ac.active >= ac.limit {
+		if ctx.Err() != nil {
+			return false
+		}
+		ac.cond.Wait()
+	}
+	ac.active++
+	return true
+}
+
+func (ac *autoConcurrency) release() {
+	if ac == nil {
+		return
+	}
+	ac.mu.Lock()
+	ac.active--
+	ac.mu.Unlock()
+	ac.cond.Signal()
+}
+
+func (ac *autoConcurrency) getLimit() int {
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+	return ac.limit
+}
+
+// adjust updates the limit according to the throughput and retry rate observed
+// during the last interval and returns the new limit.
+//
+// The limit is halved if the retry rate exceeds ac.maxRetryRate. Otherwise, it is increased by one
+// while this improves the throughput. The increase which didn't improve the throughput is reverted,
+// and the limit is kept for the next interval before probing again.
+func (ac *autoConcurrency) adjust(throughput, retryRate float64) int {
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+
+	limit := ac.limit
+	switch {
+	case retryRate > ac.maxRetryRate:
+		limit /= 2
+	case ac.lastDelta > 0 && throughput < ac.prevThroughput*(1+autoConcurrencyTolerance):
+		limit--
+	case ac.lastDelta < 0:
+		// keep the limit after the decrease in order to measure its throughput
+	default:
+		limit++
+	}
+	if limit < ac.min {
+		limit = ac.min
+	}
+	if limit > ac.max {
+		limit = ac.max
+	}
+	ac.lastDelta = limit - ac.limit
+	if ac.lastDelta == 0 && retryRate > ac.maxRetryRate {
+		// the limit is already at the minimum; keep it until retries stop
+		ac.lastDelta = -1
+	}
+	ac.prevThroughput = throughput
+	ac.limit = limit
+	ac.cond.Broadcast()
+	return limit
+}
+
+// runAutoConcurrency adjusts p.autoConcurrency every autoConcurrencyInterval
+// until the returned stop func is called
+func (p *vmNativeProcessor) runAutoConcurrency(ctx context.Context) (stop func()) {
+	ac := p.autoConcurrency
+	if ac == nil {
+		return func() {}
+	}
+	log.Printf("Concurrency is adjusted automatically between %d and %d, starting with %d", ac.min, ac.max, ac.getLimit())
+
+	doneCh := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		t := time.NewTicker(autoConcurrencyInterval)
+		defer t.Stop()
+
+		prevTime := time.Now()
+		prevBytes, prevRequests, prevRetries := p.s.counters()
+		for {
+			select {
+			case <-doneCh:
+				return
+			case <-ctx.Done():
+				// wake up blocked workers, so they notice the cancellation
+				ac.mu.Lock()
+				ac.cond.Broadcast()
+				ac.mu.Unlock()
+				return
+			case <-t.C:
+			}
+			now := time.Now()
+			bytes, requests, retries := p.s.counters()
+			throughput := float64(bytes-prevBytes) / now.Sub(prevTime).Seconds()
+			var retryRate float64
+			if attempts := requests - prevRequests + retries - prevRetries; attempts > 0 {
+				retryRate = float64(retries-prevRetries) / float64(attempts)
+			}
+			prevTime, prevBytes, prevRequests, prevRetries = now, bytes, requests, retries
+
+			prevLimit := ac.getLimit()
+			if limit := ac.adjust(throughput, retryRate); limit != prevLimit {
+				log.Printf("Concurrency changed from %d to %d; throughput: %s/s; retry rate: %.2f",
+					prevLimit, limit, byteCountSI(int64(throughput)), retryRate)
+			}
+		}
+	}()
+	return func() {
+		close(doneCh)
+		wg.Wait()
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestAutoConcurrencyAdjust(t *testing.T) {
+	ac := newAutoConcurrency(2, 5, 0.1)
+	f := func(throughput, retryRate float64, expLimit int) {
+		t.Helper()
+		if limit := ac.adjust(throughput, retryRate); limit != expLimit {
+			t.Fatalf("unexpected limit for throughput %v and retry rate %v; got %d; want %d", throughput, retryRate, limit, expLimit)
+		}
+	}
+	// ramp up while the throughput grows
+	f(100, 0, 3)
+	f(200, 0, 4)
+	f(300, 0, 5)
+	// the limit doesn't exceed the max
+	f(400, 0, 5)
+	f(400, 0, 5)
+	// the limit is halved on retries, but doesn't go below the min
+	f(400, 0.5, 2)
+	f(200, 0.5, 2)
+	// the limit is kept after the decrease
+	f(200, 0, 2)
+	// the increase without throughput improvement is reverted
+	f(200, 0, 3)
+	f(201, 0, 2)
+	f(200, 0, 2)
+	f(200, 0, 3)
+}
+
+func TestAutoConcurrencyAcquire(t *testing.T) {
+	ac := newAutoConcurrency(1, 2, 0.1)
+	ctx, cancel := context.WithCancel(context.Background())
+	if !ac.acquire(ctx) {
+		t.Fatalf("expecting acquired slot")
+	}
+
+	acquired := make(chan bool)
+	go func() { acquired <- ac.acquire(ctx) }()
+	select {
+	case <-acquired:
+		t.Fatalf("the slot mustn't be acquired over the limit")
+	case <-time.After(50 * time.Millisecond):
+	}
+	// increasing the limit unblocks the waiting request
+	ac.adjust(100, 0)
+	if !<-acquired {
+		t.Fatalf("expecting acquired slot after increasing the limit")
+	}
+
+	go func() { acquired <- ac.acquire(ctx) }()
+	cancel()
+	ac.mu.Lock()
+	ac.cond.Broadcast()
+	ac.mu.Unlock()
+	if <-acquired {
+		t.Fatalf("the slot mustn't be acquired after the cancellation")
+	}
+	ac.release()
+	ac.release()
+
+	var nilAC *autoConcurrency
+	if !nilAC.acquire(ctx) {
+		t.Fatalf("disabled auto concurrency mustn't limit requests")
+	}
+	nilAC.release()
+}
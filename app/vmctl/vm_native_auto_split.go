@@ -0,0 +1,87 @@
+package main
+
+import (
+	"io"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+)
+
+// autoSplitMinRange is the minimum duration of halves of a time range split because of --vm-native-auto-split-bytes
+const autoSplitMinRange = time.Minute
+
+// tempSpool stores exported data peeked for --vm-native-auto-split-bytes
+// in the temporary directory if --vm-native-dst-buffer-dir isn't set
+var tempSpool = &spool{}
+
+// peekExport writes up to limit+1 bytes of exported data from r into a new file at s,
+// so the data isn't buffered in memory. It returns the file and whether the data exceeds the limit.
+// The rest of exported data is left in r. The returned file must be closed.
+func peekExport(s *spool, r io.Reader, limit int64) (*spoolFile, bool, error) {
+	sf, err := s.create()
+	if err != nil {
+		return nil, false, err
+	}
+	complete, err := sf.write(r, limit+1)
+	if err != nil {
+		_ = sf.Close()
+		return nil, false, err
+	}
+	return sf, !complete, nil
+}
+
+// peekedReader reads data peeked by peekExport followed by the rest of export stream.
+// It removes the peeked data and closes the export stream on Close.
+type peekedReader struct {
+	io.Reader
+	sf     *spoolFile
+	export io.Closer
+}
+
+func newPeekedReader(sf *spoolFile, export io.ReadCloser) (*peekedReader, error) {
+	if err := sf.rewind(); err != nil {
+		return nil, err
+	}
+	return &peekedReader{Reader: io.MultiReader(sf, export), sf: sf, export: export}, nil
+}
+
+// Close implements io.Closer interface
+func (pr *peekedReader) Close() error {
+	err := pr.sf.Close()
+	if cerr := pr.export.Close(); cerr != nil && err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// peekSpool returns the spool for data peeked via peekExport
+func (p *vmNativeProcessor) peekSpool() *spool {
+	if p.spool != nil {
+		return p.spool
+	}
+	return tempSpool
+}
+
+// autoSplit splits the time range of u in half if its export exceeds p.autoSplitBytes.
+// It returns nil if the time range can't be split further, so u is migrated as is.
+func (p *vmNativeProcessor) autoSplit(u *migrationUnit) []*migrationUnit {
+	f := u.filter
+	start, err1 := time.Parse(time.RFC3339, f.TimeStart)
+	end, err2 := time.Parse(time.RFC3339, f.TimeEnd)
+	if err1 != nil || err2 != nil || end.Sub(start) < 2*autoSplitMinRange {
+		logger.Warnf("export of %s between %q and %q exceeds --%s=%d bytes, but its time range can't be split further; migrating it as is",
+			f.Match, f.TimeStart, f.TimeEnd, vmNativeAutoSplitBytes, p.autoSplitBytes)
+		return nil
+	}
+	subUnits, err := splitUnit(u, 2)
+	if err != nil || len(subUnits) < 2 {
+		logger.Warnf("cannot split time range of %s between %q and %q: %v; migrating it as is", f.Match, f.TimeStart, f.TimeEnd, err)
+		return nil
+	}
+	logger.Infof("export of %s between %q and %q exceeds --%s=%d bytes; migrating it in two halves. Consider decreasing --%s",
+		f.Match, f.TimeStart, f.TimeEnd, vmNativeAutoSplitBytes, p.autoSplitBytes, vmNativeStepInterval)
+	p.s.Lock()
+	p.s.autoSplits++
+	p.s.Unlock()
+	return subUnits
+}
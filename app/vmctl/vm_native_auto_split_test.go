@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmctl/native"
+)
+
+func TestPeekExport(t *testing.T) {
+	dir := t.TempDir()
+	s := &spool{dir: dir}
+	f := func(data string, limit int64, expExceeded bool) {
+		t.Helper()
+		sf, exceeded, err := peekExport(s, strings.NewReader(data), limit)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if exceeded != expExceeded {
+			t.Fatalf("unexpected exceeded for %q and limit %d; got %v; want %v", data, limit, exceeded, expExceeded)
+		}
+		// peeked data is stored on disk instead of memory
+		if exp := int64(len(data)); sf.size != exp && sf.size != limit+1 {
+			t.Fatalf("unexpected size of peeked data; got %d", sf.size)
+		}
+		// the whole data is returned in both cases
+		pr, err := newPeekedReader(sf, io.NopCloser(strings.NewReader(data[sf.size:])))
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		got, err := io.ReadAll(pr)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if string(got) != data {
+			t.Fatalf("unexpected data; got %q; want %q", got, data)
+		}
+		if err := pr.Close(); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if len(entries) != 0 || s.used != 0 {
+			t.Fatalf("expecting peeked data to be removed; got %d files and %d bytes", len(entries), s.used)
+		}
+	}
+	f("", 3, false)
+	f("foo", 3, false)
+	f("foobar", 3, true)
+}
+
+func TestRunSingleAutoSplit(t *testing.T) {
+	var exports int32
+	// the size of exported data is proportional to the exported time range
+	src := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&exports, 1)
+		start, _ := time.Parse(time.RFC3339, r.URL.Query().Get("start"))
+		end, _ := time.Parse(time.RFC3339, r.URL.Query().Get("end"))
+		_, _ = w.Write(bytes.Repeat([]byte("x"), int(end.Sub(start).Minutes())*100))
+	}))
+	defer src.Close()
+	var imported int64
+	dst := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n, _ := io.Copy(io.Discard, r.Body)
+		atomic.AddInt64(&imported, n)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer dst.Close()
+
+	p := &vmNativeProcessor{
+		src:            &native.Client{Addr: src.URL},
+		dst:            &native.Client{Addr: dst.URL},
+		s:              &stats{},
+		autoSplitBytes: 1000,
+	}
+	u := newTestUnit("", "foo", "2022-01-01T00:00:00Z", "2022-01-01T00:40:00Z")
+	u.srcURL = src.URL + "/api/v1/export/native"
+	u.dstURL = dst.URL + "/api/v1/import/native"
+	if err := p.runSingle(context.Background(), u); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	// 40m range is split into 20m halves, which are split into 10m quarters fitting the limit
+	if n := atomic.LoadInt32(&exports); n != 7 {
+		t.Fatalf("unexpected number of exports; got %d; want 7", n)
+	}
+	if p.s.autoSplits != 3 {
+		t.Fatalf("unexpected number of splits; got %d; want 3", p.s.autoSplits)
+	}
+	if n := atomic.LoadInt64(&imported); n != 4000 {
+		t.Fatalf("unexpected number of imported bytes; got %d; want 4000", n)
+	}
+
+	// exports fitting the limit are imported from a single spool file
+	atomic.StoreInt64(&imported, 0)
+	dir := t.TempDir()
+	sp, err := newSpool(dir, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	p.spool = sp
+	u = newTestUnit("", "foo", "2022-01-01T00:00:00Z", "2022-01-01T00:40:00Z")
+	u.srcURL = src.URL + "/api/v1/export/native"
+	u.dstURL = dst.URL + "/api/v1/import/native"
+	if err := p.runSingle(context.Background(), u); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if n := atomic.LoadInt64(&imported); n != 4000 {
+		t.Fatalf("unexpected number of imported bytes with spool; got %d; want 4000", n)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 || p.spool.used != 0 {
+		t.Fatalf("expecting spool files to be removed; got %d files and %d bytes", len(entries), p.spool.used)
+	}
+	p.spool = nil
+
+	// ranges shorter than 2*autoSplitMinRange are migrated as is
+	atomic.StoreInt32(&exports, 0)
+	u = newTestUnit("", "foo", "2022-01-01T00:00:00Z", "2022-01-01T00:01:30Z")
+	u.srcURL = src.URL + "/api/v1/export/native"
+	u.dstURL = dst.URL + "/api/v1/import/native"
+	p.autoSplitBytes = 10
+	if err := p.runSingle(context.Background(), u); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if n := atomic.LoadInt32(&exports); n != 1 {
+		t.Fatalf("unexpected number of exports; got %d; want 1", n)
+	}
+}
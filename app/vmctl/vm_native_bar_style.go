@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cheggaaa/pb/v3"
+)
+
+const (
+	// barStyleUnicode renders progress bars with unicode blocks and braille spinner
+	barStyleUnicode = "unicode"
+	// barStyleASCII renders progress bars with ASCII characters only for terminals with broken unicode rendering
+	barStyleASCII = "ascii"
+	// barStyleNone disables progress bars, while the rest of the output is preserved
+	barStyleNone = "none"
+
+	nativeASCIIBarTpl     = `{{ blue "%s:" }} {{ counters . }} {{ bar . "[" "=" ">" "-" "]" }} {{ percent . }} {{ string . "speed" }}`
+	nativeASCIISpinnerTpl = `{{ blue "%s:" }} {{ cycle . "|" "/" "-" "\\" }} {{ counters . }} {{ string . "speed" }}`
+)
+
+// isCustomBarStyle returns true if style is a custom progress bar template instead of a preset
+func isCustomBarStyle(style string) bool {
+	return strings.Contains(style, "{{")
+}
+
+// validateBarStyle validates p.barStyle
+func (p *vmNativeProcessor) validateBarStyle() error {
+	switch {
+	case p.barStyle == "", p.barStyle == barStyleUnicode, p.barStyle == barStyleASCII, p.barStyle == barStyleNone:
+		return nil
+	case isCustomBarStyle(p.barStyle):
+		if err := pb.ProgressBarTemplate(p.barStyle).New(0).Err(); err != nil {
+			return fmt.Errorf("cannot parse --%s template: %w", vmNativeBarStyle, err)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported --%s=%q; supported values: %s, %s, %s or a custom template",
+			vmNativeBarStyle, p.barStyle, barStyleUnicode, barStyleASCII, barStyleNone)
+	}
+}
+
+// showBars returns false if progress bars are disabled via p.barStyle
+func (p *vmNativeProcessor) showBars() bool {
+	return p.barStyle != barStyleNone
+}
+
+// barTemplate returns the template of progress bar with the given prefix according to p.barStyle.
+// The spinner template is returned if the total is unknown.
+// Custom templates may render the prefix via `{{ string . "prefix" }}`.
+func (p *vmNativeProcessor) barTemplate(prefix string, spinner bool) pb.ProgressBarTemplate {
+	tpl := nativeBarTpl
+	switch {
+	case isCustomBarStyle(p.barStyle):
+		return pb.ProgressBarTemplate(p.barStyle)
+	case p.barStyle == barStyleASCII && spinner:
+		tpl = nativeASCIISpinnerTpl
+	case p.barStyle == barStyleASCII:
+		tpl = nativeASCIIBarTpl
+	case spinner:
+		tpl = nativeSpinnerTpl
+	}
+	return pb.ProgressBarTemplate(fmt.Sprintf(tpl, prefix))
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestValidateBarStyle(t *testing.T) {
+	for _, style := range []string{"", barStyleUnicode, barStyleASCII, barStyleNone, `{{ string . "prefix" }} {{ counters . }}`} {
+		p := &vmNativeProcessor{barStyle: style}
+		if err := p.validateBarStyle(); err != nil {
+			t.Fatalf("unexpected error for %q: %s", style, err)
+		}
+	}
+	for _, style := range []string{"fancy", `{{ counters . `, `{{ unknown . }}`} {
+		p := &vmNativeProcessor{barStyle: style}
+		if err := p.validateBarStyle(); err == nil {
+			t.Fatalf("expecting error for %q", style)
+		}
+	}
+}
+
+func TestBarTemplate(t *testing.T) {
+	render := func(style string, spinner bool) string {
+		t.Helper()
+		p := &vmNativeProcessor{barStyle: style}
+		bar := p.barTemplate("Requests to make", spinner).New(10)
+		bar.Set("prefix", "Requests to make")
+		bar.Set("speed", "1.0 kB/s")
+		bar.SetWidth(80)
+		bar.SetCurrent(3)
+		if err := bar.Err(); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		return bar.String()
+	}
+	isASCII := func(s string) bool {
+		for i := 0; i < len(s); i++ {
+			if s[i] >= utf8.RuneSelf {
+				return false
+			}
+		}
+		return true
+	}
+
+	for _, spinner := range []bool{false, true} {
+		if s := render(barStyleASCII, spinner); !isASCII(s) || !strings.Contains(s, "Requests to make:") {
+			t.Fatalf("unexpected ascii bar (spinner: %v): %q", spinner, s)
+		}
+		if s := render(barStyleUnicode, spinner); isASCII(s) {
+			t.Fatalf("expecting unicode characters in the default bar (spinner: %v): %q", spinner, s)
+		}
+	}
+	if s := render(`{{ string . "prefix" }} - {{ counters . }}`, false); s != "Requests to make - 3 / 10" {
+		t.Fatalf("unexpected custom bar: %q", s)
+	}
+}
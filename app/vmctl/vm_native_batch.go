@@ -0,0 +1,279 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"hash"
+	"io"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmctl/limiter"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+)
+
+// batchWait is the max time for waiting for more units to fill a batch,
+// so slow metrics discovery doesn't delay the migration of already received units
+const batchWait = 100 * time.Millisecond
+
+// validateBatch validates options, which can't be used together with p.batchSize
+func (p *vmNativeProcessor) validateBatch() error {
+	if p.batchSize <= 1 {
+		if p.batchMaxBytes > 0 {
+			return fmt.Errorf("--%s requires --%s greater than 1", vmNativeBatchMaxBytes, vmNativeBatchSize)
+		}
+		return nil
+	}
+	switch {
+	case p.tenantRoute != nil || p.dstRemoteWrite || p.dstFile != nil:
+		return fmt.Errorf("--%s can't be used together with --%s, --%s and --%s", vmNativeBatchSize, vmNativeDstTenantFromLabel, vmNativeDstRemoteWrite, vmNativeDstFile)
+	case p.intraUnitParallelism > 1 || p.retrySubRanges > 1 || p.autoSplitBytes > 0:
+		return fmt.Errorf("--%s can't be used together with --%s, --%s and --%s, since they split requests instead of coalescing them",
+			vmNativeBatchSize, vmNativeIntraUnitParallelism, vmNativeRetrySubRanges, vmNativeAutoSplitBytes)
+	case p.spool != nil || p.stickyRouteCfg.by != "":
+		return fmt.Errorf("--%s can't be used together with --%s and --%s", vmNativeBatchSize, vmNativeDstBufferDir, vmNativeStickyRouteBy)
+	case p.skipExistingData || p.metricDeadline > 0:
+		return fmt.Errorf("--%s can't be used together with --%s and --%s", vmNativeBatchSize, vmNativeSkipExisting, vmNativeMetricDeadline)
+	}
+	return nil
+}
+
+// collectBatch returns u together with up to p.batchSize-1 units received from unitsCh.
+func (p *vmNativeProcessor) collectBatch(u *migrationUnit, unitsCh <-chan *migrationUnit) []*migrationUnit {
+	batch := []*migrationUnit{u}
+	t := time.NewTimer(batchWait)
+	defer t.Stop()
+	for len(batch) < p.batchSize {
+		select {
+		case u, ok := <-unitsCh:
+			if !ok {
+				return batch
+			}
+			batch = append(batch, u)
+		case <-t.C:
+			return batch
+		}
+	}
+	return batch
+}
+
+// newBatchUnit returns the unit for migrating units of batch via shared import requests.
+// Units of batch belong to the same tenant, source and destination.
+func newBatchUnit(batch []*migrationUnit) *migrationUnit {
+	if len(batch) == 1 {
+		return batch[0]
+	}
+	u := batch[0]
+	return &migrationUnit{
+		tenantID: u.tenantID,
+		metric:   u.metric,
+		filter:   u.filter,
+		srcURL:   u.srcURL,
+		dstURL:   u.dstURL,
+		batch:    batch,
+	}
+}
+
+// units returns units migrated by u
+func (u *migrationUnit) units() []*migrationUnit {
+	if len(u.batch) > 0 {
+		return u.batch
+	}
+	return []*migrationUnit{u}
+}
+
+// runBatchWorker migrates units received from unitsCh in batches of up to p.batchSize units.
+// incrementBar is called for every processed unit, so progress is still reported per request.
+func (p *vmNativeProcessor) runBatchWorker(ctx context.Context, unitsCh <-chan *migrationUnit, incrementBar func()) error {
+	for u := range unitsCh {
+		batch := p.collectBatch(u, unitsCh)
+		if !p.acquireImportSlot(ctx) {
+			return ctx.Err()
+		}
+		if !p.autoConcurrency.acquire(ctx) {
+			p.releaseImportSlot()
+			return ctx.Err()
+		}
+		err := p.do(ctx, newBatchUnit(batch))
+		p.autoConcurrency.release()
+		p.releaseImportSlot()
+		if err != nil && ctx.Err() != nil {
+			// units are recorded in the failures file, so they could be migrated after the interruption
+			for _, u := range batch {
+				p.failures.add(u, err)
+			}
+			continue
+		}
+		for _, u := range batch {
+			if err != nil {
+				p.unitFailed(ctx, u, err)
+			} else {
+				p.unitDone(ctx, u, nil)
+				p.completed.add(u)
+				p.countVerification.add(u)
+			}
+			if err == nil || p.continueOnError {
+				incrementBar()
+			}
+		}
+		if err == nil {
+			continue
+		}
+		if !p.continueOnError {
+			return err
+		}
+		logger.Errorf("batch of %d requests starting with metric %q failed; the rest of requests proceed: %s", len(batch), u.metric, err)
+	}
+	return nil
+}
+
+// batchMember is a unit exported into batchImport
+type batchMember struct {
+	idx int
+	u   *migrationUnit
+	h   hash.Hash
+	bp  *blockProcessor
+}
+
+// batchImport is an import request shared between units of a batch
+type batchImport struct {
+	pw      *io.PipeWriter
+	w       io.Writer
+	done    chan struct{}
+	err     error
+	written int64
+	members []batchMember
+}
+
+// startBatchImport starts import request to dstURL
+func (p *vmNativeProcessor) startBatchImport(ctx context.Context, dstURL string) *batchImport {
+	pr, pw := io.Pipe()
+	bi := &batchImport{
+		pw:   pw,
+		w:    p.limitWriter(pw, p.newRequestRateLimiter()),
+		done: make(chan struct{}),
+	}
+	go func() {
+		defer close(bi.done)
+		_, importSpan := p.tracer.Start(ctx, "import")
+		bi.err = p.dst.ImportPipe(ctx, dstURL, pr, nil)
+		importSpan.End(bi.err)
+	}()
+	return bi
+}
+
+// finish waits until the import request is finished and returns its error
+func (bi *batchImport) finish() error {
+	_ = bi.pw.Close()
+	<-bi.done
+	return bi.err
+}
+
+// abort cancels the import request because of err
+func (bi *batchImport) abort(err error) {
+	// the import request must be finished before returning, since it reads from the pipe
+	_ = bi.pw.CloseWithError(err)
+	<-bi.done
+}
+
+// runBatch migrates units of bu via shared import requests. Every import request
+// contains exported data of consecutive units until its size exceeds p.batchMaxBytes.
+// Units imported by successful requests are marked as done in sr, so retries skip them.
+func (p *vmNativeProcessor) runBatch(ctx context.Context, bu *migrationUnit, sr *subRanges) error {
+	var bi *batchImport
+	finish := func() error {
+		p.s.countRequest(bu, bi.written)
+		if err := bi.finish(); err != nil {
+			// the error is returned, so the batch is retried
+			return err
+		}
+		for _, m := range bi.members {
+			sr.markDone(m.idx)
+			p.s.digests.add(m.u, m.h)
+			if m.bp != nil {
+				m.bp.flushStats(p.s)
+			}
+		}
+		bi = nil
+		return nil
+	}
+	for i, u := range bu.batch {
+		if sr.isDone(i) {
+			continue
+		}
+		if bi == nil {
+			bi = p.startBatchImport(ctx, bu.dstURL)
+		}
+		m := batchMember{idx: i, u: u, h: p.s.digests.newHash()}
+		if p.needsDecode() {
+			m.bp = p.newBlockProcessor()
+		}
+		if err := p.exportBatchMember(ctx, bi, m); err != nil {
+			bi.abort(err)
+			return err
+		}
+		bi.members = append(bi.members, m)
+		if p.batchMaxBytes > 0 && bi.written >= p.batchMaxBytes {
+			if err := finish(); err != nil {
+				return err
+			}
+		}
+	}
+	if bi == nil {
+		return nil
+	}
+	return finish()
+}
+
+// exportBatchMember exports data of m into the import request bi
+func (p *vmNativeProcessor) exportBatchMember(ctx context.Context, bi *batchImport, m batchMember) (err error) {
+	ctx, span := p.tracer.Start(ctx, "export")
+	span.SetAttr("metric", m.u.metric)
+	defer func() { span.End(err) }()
+
+	var throttledAt time.Time
+	if p.srcThrottle != nil {
+		throttledAt, err = p.srcThrottle.acquire(ctx)
+		if err != nil {
+			return err
+		}
+		defer p.srcThrottle.release()
+	}
+	var sw *stallWatchdog
+	if p.httpTimeout > 0 {
+		ctx, sw = newStallWatchdog(ctx, p.httpTimeout)
+		defer func() { err = sw.stop(err) }()
+	}
+	p.waitSrcQPS("export")
+	exportReader, err := p.src.ExportPipe(ctx, m.u.srcURL, m.u.filter)
+	if p.srcThrottle != nil {
+		p.srcThrottle.observe(throttledAt, err)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to init export pipe: %w", err)
+	}
+	defer func() { _ = exportReader.Close() }()
+
+	var r io.Reader = exportReader
+	if p.srcRateLimiter != nil {
+		r = limiter.NewReadLimiter(r, p.srcRateLimiter)
+	}
+	r = &contextReader{ctx: ctx, r: sw.reader(r)}
+	if m.bp != nil {
+		dr := m.bp.decodePipe(r)
+		defer func() { _ = dr.Close() }()
+		r = dr
+	}
+
+	stopClosing := closePipeOnDone(ctx, bi.pw)
+	written, err := io.Copy(teeDigest(bi.w, m.h), r)
+	stopClosing()
+	bi.written += written
+	span.SetAttr("bytes", written)
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return fmt.Errorf("failed to write into %q: %w", p.dst.Addr, ctxErr)
+		}
+		return fmt.Errorf("failed to write into %q: %s", p.dst.Addr, err)
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmctl/backoff"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmctl/native"
+)
+
+func TestCollectBatch(t *testing.T) {
+	unitsCh := make(chan *migrationUnit, 5)
+	for i := 0; i < 5; i++ {
+		unitsCh <- newTestUnit("", fmt.Sprintf("foo%d", i), "", "")
+	}
+	close(unitsCh)
+	p := &vmNativeProcessor{batchSize: 3}
+	var sizes []int
+	for u := range unitsCh {
+		sizes = append(sizes, len(p.collectBatch(u, unitsCh)))
+	}
+	if len(sizes) != 2 || sizes[0] != 3 || sizes[1] != 2 {
+		t.Fatalf("unexpected batch sizes %v; want [3 2]", sizes)
+	}
+}
+
+func TestValidateBatch(t *testing.T) {
+	f := func(p *vmNativeProcessor, expErr bool) {
+		t.Helper()
+		if err := p.validateBatch(); (err != nil) != expErr {
+			t.Fatalf("unexpected error %v; want error %v", err, expErr)
+		}
+	}
+	f(&vmNativeProcessor{}, false)
+	f(&vmNativeProcessor{batchSize: 10, batchMaxBytes: 1e6}, false)
+	f(&vmNativeProcessor{batchMaxBytes: 1e6}, true)
+	f(&vmNativeProcessor{batchSize: 10, retrySubRanges: 4}, true)
+	f(&vmNativeProcessor{batchSize: 10, dstRemoteWrite: true}, true)
+	f(&vmNativeProcessor{batchSize: 10, skipExistingData: true}, true)
+}
+
+func TestRunBatch(t *testing.T) {
+	var mu sync.Mutex
+	var exported []string
+	src := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		exported = append(exported, r.URL.Query().Get("match[]"))
+		mu.Unlock()
+		_, _ = w.Write(bytes.Repeat([]byte("x"), 100))
+	}))
+	defer src.Close()
+	var imports, imported int
+	dst := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		imports++
+		if imports == 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		imported += len(data)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer dst.Close()
+
+	p := &vmNativeProcessor{
+		src:           &native.Client{Addr: src.URL},
+		dst:           &native.Client{Addr: dst.URL},
+		s:             &stats{},
+		batchSize:     5,
+		batchMaxBytes: 200,
+	}
+	var batch []*migrationUnit
+	for i := 0; i < 5; i++ {
+		u := newTestUnit("", fmt.Sprintf("foo%d", i), "2022-01-01T00:00:00Z", "2022-01-02T00:00:00Z")
+		u.filter.Match = u.metric
+		u.srcURL = src.URL + "/api/v1/export/native"
+		u.dstURL = dst.URL + "/api/v1/import/native"
+		batch = append(batch, u)
+	}
+	bu := newBatchUnit(batch)
+	sr := &subRanges{}
+	// every import request contains two units because of batchMaxBytes, and the second request fails
+	if err := p.runBatch(context.Background(), bu, sr); err == nil {
+		t.Fatalf("expecting error for the failed import request")
+	}
+	// the retry skips units imported by the first request
+	if err := p.runBatch(context.Background(), bu, sr); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	exp := []string{"foo0", "foo1", "foo2", "foo3", "foo2", "foo3", "foo4"}
+	if fmt.Sprint(exported) != fmt.Sprint(exp) {
+		t.Fatalf("unexpected exports; got %q; want %q", exported, exp)
+	}
+	if imports != 4 {
+		t.Fatalf("unexpected number of import requests; got %d; want 4", imports)
+	}
+	if imported != 500 {
+		t.Fatalf("unexpected number of imported bytes; got %d; want 500", imported)
+	}
+	if p.s.requests != 4 {
+		t.Fatalf("unexpected number of requests in stats; got %d; want 4", p.s.requests)
+	}
+}
+
+func TestRunBatchWorker(t *testing.T) {
+	src := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("foo"))
+	}))
+	defer src.Close()
+	var imports int
+	dst := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		imports++
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer dst.Close()
+
+	p := &vmNativeProcessor{
+		src:       &native.Client{Addr: src.URL},
+		dst:       &native.Client{Addr: dst.URL},
+		s:         &stats{},
+		backoff:   backoff.New(),
+		batchSize: 3,
+		completed: newCompletedRanges(),
+	}
+	unitsCh := make(chan *migrationUnit, 3)
+	for i := 0; i < 3; i++ {
+		u := newTestUnit("", fmt.Sprintf("foo%d", i), "2022-01-01T00:00:00Z", "2022-01-02T00:00:00Z")
+		u.srcURL = src.URL + "/api/v1/export/native"
+		u.dstURL = dst.URL + "/api/v1/import/native"
+		unitsCh <- u
+	}
+	close(unitsCh)
+	var processed int
+	if err := p.runBatchWorker(context.Background(), unitsCh, func() { processed++ }); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if processed != 3 {
+		t.Fatalf("unexpected number of processed units; got %d; want 3", processed)
+	}
+	if imports != 1 {
+		t.Fatalf("unexpected number of import requests; got %d; want 1", imports)
+	}
+}
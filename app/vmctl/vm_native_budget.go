@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// budgetLeft counts metrics and requests, which weren't migrated because of the budget
+// set via --vm-native-max-total-bytes or --vm-native-max-total-requests
+type budgetLeft struct {
+	mu       sync.Mutex
+	metrics  int
+	requests int
+}
+
+func (bl *budgetLeft) add(metrics, requests int) {
+	bl.mu.Lock()
+	bl.metrics += metrics
+	bl.requests += requests
+	bl.mu.Unlock()
+}
+
+func (bl *budgetLeft) get() (int, int) {
+	bl.mu.Lock()
+	defer bl.mu.Unlock()
+	return bl.metrics, bl.requests
+}
+
+// leaveTenants registers metrics and requests of tenants, which weren't started because of the budget
+func (p *vmNativeProcessor) leaveTenants(tenants []string, tenantMetrics map[string]map[string]struct{}, ranges [][]time.Time) {
+	for _, tenantID := range tenants {
+		metrics := tenantMetrics[tenantID]
+		p.budgetLeft.add(len(metrics), p.tenantRequests(tenantID, metrics, ranges))
+	}
+}
+
+// budgetMessage returns the message about the reached budget
+func (p *vmNativeProcessor) budgetMessage() string {
+	var msg string
+	if started := atomic.LoadInt64(&p.startedRequests); p.maxTotalRequests > 0 && started >= p.maxTotalRequests {
+		msg = fmt.Sprintf("Request budget reached: started %d requests while --%s=%d; no new requests were started.",
+			started, vmNativeMaxTotalRequests, p.maxTotalRequests)
+	} else {
+		msg = fmt.Sprintf("Byte budget reached: transferred %s while --%s=%s; no new requests were started.",
+			byteCountSI(int64(p.s.bytesTotal())), vmNativeMaxTotalBytes, byteCountSI(p.maxTotalBytes))
+	}
+	metrics, requests := p.budgetLeft.get()
+	if requests > 0 {
+		msg += fmt.Sprintf(" %d requests for %d metrics are left.", requests, metrics)
+	}
+	if p.exploreStream {
+		msg += " Metrics, which weren't discovered before reaching the budget, aren't counted."
+	}
+	return msg
+}
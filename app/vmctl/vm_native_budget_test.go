@@ -0,0 +1,47 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBudget(t *testing.T) {
+	p := &vmNativeProcessor{
+		s:                &stats{},
+		maxTotalRequests: 2,
+	}
+	if p.budgetReached() {
+		t.Fatalf("budget mustn't be reached before requests are started")
+	}
+	p.startedRequests = 2
+	if !p.budgetReached() {
+		t.Fatalf("budget must be reached after %d requests", p.maxTotalRequests)
+	}
+
+	ranges := [][]time.Time{{time.Unix(0, 0), time.Unix(3600, 0)}, {time.Unix(3600, 0), time.Unix(7200, 0)}}
+	tenantMetrics := map[string]map[string]struct{}{
+		"1:0": {"foo": {}, "bar": {}},
+		"2:0": {"baz": {}},
+	}
+	p.budgetLeft.add(1, 1)
+	p.leaveTenants([]string{"1:0", "2:0"}, tenantMetrics, ranges)
+	msg := p.budgetMessage()
+	if !strings.HasPrefix(msg, "Request budget reached: started 2 requests") {
+		t.Fatalf("unexpected message %q", msg)
+	}
+	if !strings.Contains(msg, "7 requests for 4 metrics are left") {
+		t.Fatalf("missing the number of left requests in %q", msg)
+	}
+
+	p = &vmNativeProcessor{
+		s:             &stats{bytes: 2000},
+		maxTotalBytes: 1000,
+	}
+	if !p.budgetReached() {
+		t.Fatalf("budget must be reached after %d bytes", p.maxTotalBytes)
+	}
+	if msg := p.budgetMessage(); !strings.HasPrefix(msg, "Byte budget reached: transferred 2.0 kB") {
+		t.Fatalf("unexpected message %q", msg)
+	}
+}
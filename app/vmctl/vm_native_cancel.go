@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"io"
+)
+
+// contextReader stops reading from r once ctx is canceled.
+// Reads of export responses are already bound to the request context,
+// but spooled data and rate-limited transfers aren't, so a single huge transfer
+// could otherwise ignore cancellation until it is finished.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+// Read implements io.Reader interface
+func (cr *contextReader) Read(p []byte) (int, error) {
+	if err := cr.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return cr.r.Read(p)
+}
+
+// closePipeOnDone closes pw with ctx error once ctx is canceled, so blocked writes to pw are aborted.
+// The returned func must be called when pw is no longer written.
+func closePipeOnDone(ctx context.Context, pw *io.PipeWriter) func() {
+	stopCh := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = pw.CloseWithError(ctx.Err())
+		case <-stopCh:
+		}
+	}()
+	return func() { close(stopCh) }
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmctl/native"
+)
+
+// endlessReader returns data until the end of times ignoring any cancellation,
+// like a reader of spooled data
+type endlessReader struct{}
+
+func (endlessReader) Read(p []byte) (int, error) {
+	time.Sleep(time.Millisecond)
+	return len(p), nil
+}
+
+func TestImportDataCancel(t *testing.T) {
+	dst := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+	}))
+	defer dst.Close()
+
+	p := &vmNativeProcessor{
+		dst: &native.Client{Addr: dst.URL},
+		s:   &stats{},
+	}
+	u := &migrationUnit{dstURL: dst.URL + "/api/v1/import/native"}
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	errCh := make(chan error, 1)
+	go func() {
+		_, err := p.importData(ctx, u, endlessReader{})
+		errCh <- err
+	}()
+	select {
+	case err := <-errCh:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("expecting context.Canceled error; got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("import wasn't canceled")
+	}
+}
+
+func TestClosePipeOnDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	pr, pw := io.Pipe()
+	defer func() { _ = pr.Close() }()
+	stop := closePipeOnDone(ctx, pw)
+	defer stop()
+
+	// the write blocks, since nobody reads from pr
+	errCh := make(chan error, 1)
+	go func() {
+		_, err := pw.Write([]byte("foo"))
+		errCh <- err
+	}()
+	cancel()
+	select {
+	case err := <-errCh:
+		if err == nil {
+			t.Fatalf("expecting error for write to the closed pipe")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("blocked write wasn't aborted")
+	}
+}
@@ -0,0 +1,469 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// checkpointVersion is the version of the checkpoint file format.
+//
+// Version 1 contains a plain list of migrated requests.
+// Version 2 contains merged time intervals of migrated requests per series,
+// so the file stays small and human-readable over long runs.
+const checkpointVersion = 2
+
+// checkpointSeriesKey identifies the series of requests
+// for the same metric with different time ranges
+type checkpointSeriesKey struct {
+	TenantID string
+	Metric   string
+	Bucket   string
+}
+
+// checkpointInterval is a merged time interval of migrated requests
+type checkpointInterval struct {
+	start time.Time
+	end   time.Time
+}
+
+// checkpointSeries contains migrated intervals of the series
+type checkpointSeries struct {
+	intervals []checkpointInterval
+	// requests is the number of migrated requests
+	requests int
+}
+
+// checkpointSeriesEntry is a record of the checkpoint file
+type checkpointSeriesEntry struct {
+	TenantID string      `json:"tenant,omitempty"`
+	Metric   string      `json:"metric"`
+	Bucket   string      `json:"bucket,omitempty"`
+	Requests int         `json:"requests"`
+	Done     [][2]string `json:"done"`
+}
+
+// checkpointEntryV1 is a record of the checkpoint file of version 1
+type checkpointEntryV1 struct {
+	TenantID  string `json:"tenant,omitempty"`
+	Metric    string `json:"metric"`
+	Bucket    string `json:"bucket,omitempty"`
+	TimeStart string `json:"start"`
+	TimeEnd   string `json:"end"`
+}
+
+type checkpointFile struct {
+	Version int `json:"version"`
+	// Total contains the number of requests to make per tenant
+	Total  map[string]int          `json:"total,omitempty"`
+	Series []checkpointSeriesEntry `json:"series,omitempty"`
+	// Done is used by version 1 only
+	Done []checkpointEntryV1 `json:"done,omitempty"`
+}
+
+// checkpoint tracks successfully migrated units, so interrupted
+// migration could be resumed without re-migrating them.
+type checkpoint struct {
+	mu     sync.Mutex
+	path   string
+	total  map[string]int
+	series map[checkpointSeriesKey]*checkpointSeries
+
+	// flushEvery is the number of migrated units to buffer before persisting the checkpoint
+	flushEvery int
+	// flushInterval is the interval for persisting buffered units.
+	// It takes precedence over flushEvery if set.
+	flushInterval time.Duration
+	// pending is the number of migrated units which weren't persisted yet
+	pending int
+}
+
+func newCheckpoint(path string) *checkpoint {
+	return &checkpoint{
+		path:   path,
+		total:  make(map[string]int),
+		series: make(map[checkpointSeriesKey]*checkpointSeries),
+	}
+}
+
+// loadCheckpoint reads checkpoint from the given path.
+// Empty checkpoint is returned if path doesn't exist.
+func loadCheckpoint(path string) (*checkpoint, error) {
+	c := newCheckpoint(path)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return c, nil
+		}
+		return nil, fmt.Errorf("cannot read state file %q: %w", path, err)
+	}
+	var cf checkpointFile
+	if err := json.Unmarshal(data, &cf); err != nil {
+		return nil, fmt.Errorf("cannot parse state file %q: %w", path, err)
+	}
+	switch cf.Version {
+	case 1:
+		for _, e := range cf.Done {
+			iv, err := parseCheckpointInterval(e.TimeStart, e.TimeEnd)
+			if err != nil {
+				return nil, fmt.Errorf("cannot parse state file %q: %w", path, err)
+			}
+			c.addLocked(checkpointSeriesKey{TenantID: e.TenantID, Metric: e.Metric, Bucket: e.Bucket}, iv, 1)
+		}
+	case checkpointVersion:
+		for tenantID, n := range cf.Total {
+			c.total[tenantID] = n
+		}
+		for _, e := range cf.Series {
+			key := checkpointSeriesKey{TenantID: e.TenantID, Metric: e.Metric, Bucket: e.Bucket}
+			for _, d := range e.Done {
+				iv, err := parseCheckpointInterval(d[0], d[1])
+				if err != nil {
+					return nil, fmt.Errorf("cannot parse state file %q: %w", path, err)
+				}
+				c.addLocked(key, iv, 0)
+			}
+			cs := c.series[key]
+			if cs == nil {
+				// the entry has no migrated intervals, e.g. the file was edited by hand
+				cs = &checkpointSeries{}
+				c.series[key] = cs
+			}
+			cs.requests = e.Requests
+		}
+	default:
+		return nil, fmt.Errorf("unsupported version %d of state file %q; supported versions: 1, %d", cf.Version, path, checkpointVersion)
+	}
+	return c, nil
+}
+
+func parseCheckpointInterval(start, end string) (checkpointInterval, error) {
+	s, err := time.Parse(time.RFC3339, start)
+	if err != nil {
+		return checkpointInterval{}, fmt.Errorf("cannot parse start time %q: %w", start, err)
+	}
+	e, err := time.Parse(time.RFC3339, end)
+	if err != nil {
+		return checkpointInterval{}, fmt.Errorf("cannot parse end time %q: %w", end, err)
+	}
+	return checkpointInterval{start: s, end: e}, nil
+}
+
+func unitCheckpointKey(u *migrationUnit) checkpointSeriesKey {
+	return checkpointSeriesKey{
+		TenantID: u.tenantID,
+		Metric:   u.metric,
+		Bucket:   u.bucket,
+	}
+}
+
+func (c *checkpoint) isDone(u *migrationUnit) bool {
+	iv, err := parseCheckpointInterval(u.filter.TimeStart, u.filter.TimeEnd)
+	if err != nil {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.containsLocked(unitCheckpointKey(u), iv)
+}
+
+func (c *checkpoint) containsLocked(key checkpointSeriesKey, iv checkpointInterval) bool {
+	cs := c.series[key]
+	if cs == nil {
+		return false
+	}
+	for _, d := range cs.intervals {
+		if !iv.start.Before(d.start) && !iv.end.After(d.end) {
+			return true
+		}
+	}
+	return false
+}
+
+// len returns the number of migrated requests
+func (c *checkpoint) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := 0
+	for _, cs := range c.series {
+		n += cs.requests
+	}
+	return n
+}
+
+// setTotal sets the number of requests to make for the given tenant
+func (c *checkpoint) setTotal(tenantID string, n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.total[tenantID] = n
+}
+
+// addTotal adds delta to the number of requests to make for the given tenant
+func (c *checkpoint) addTotal(tenantID string, delta int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.total[tenantID] += delta
+}
+
+// markDone marks u as migrated and persists the checkpoint
+func (c *checkpoint) markDone(u *migrationUnit) error {
+	iv, err := parseCheckpointInterval(u.filter.TimeStart, u.filter.TimeEnd)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key := unitCheckpointKey(u)
+	if c.containsLocked(key, iv) {
+		// the unit was re-migrated
+		return nil
+	}
+	c.addLocked(key, iv, 1)
+	c.pending++
+	if c.flushInterval > 0 || c.pending < c.flushEvery {
+		// the unit is persisted later
+		return nil
+	}
+	return c.flushLocked()
+}
+
+// flush persists units buffered since the last flush
+func (c *checkpoint) flush() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.pending == 0 {
+		return nil
+	}
+	return c.flushLocked()
+}
+
+// runFlusher persists buffered units every c.flushInterval until the returned func is called.
+// The returned func persists the remaining units.
+func (c *checkpoint) runFlusher() func() error {
+	if c.flushInterval <= 0 {
+		return c.flush
+	}
+	stopCh := make(chan struct{})
+	doneCh := make(chan struct{})
+	go func() {
+		defer close(doneCh)
+		t := time.NewTicker(c.flushInterval)
+		defer t.Stop()
+		for {
+			select {
+			case <-stopCh:
+				return
+			case <-t.C:
+				if err := c.flush(); err != nil {
+					log.Printf("cannot persist state file: %s", err)
+				}
+			}
+		}
+	}()
+	return func() error {
+		close(stopCh)
+		<-doneCh
+		return c.flush()
+	}
+}
+
+// parseCheckpointFlushInterval parses --vm-native-checkpoint-interval value,
+// which is either the number of units or a duration.
+func parseCheckpointFlushInterval(s string) (int, time.Duration, error) {
+	if s == "" {
+		return 1, 0, nil
+	}
+	if n, err := strconv.Atoi(s); err == nil {
+		if n < 1 {
+			return 0, 0, fmt.Errorf("the number of requests must be positive; got %d", n)
+		}
+		return n, 0, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, 0, fmt.Errorf("expecting the number of requests or a duration; got %q", s)
+	}
+	if d <= 0 {
+		return 0, 0, fmt.Errorf("the duration must be positive; got %s", d)
+	}
+	return 0, d, nil
+}
+
+// addLocked adds iv to the series with the given key
+// and merges overlapping and adjacent intervals
+func (c *checkpoint) addLocked(key checkpointSeriesKey, iv checkpointInterval, requests int) {
+	cs := c.series[key]
+	if cs == nil {
+		cs = &checkpointSeries{}
+		c.series[key] = cs
+	}
+	cs.requests += requests
+	cs.intervals = append(cs.intervals, iv)
+	sort.Slice(cs.intervals, func(i, j int) bool {
+		return cs.intervals[i].start.Before(cs.intervals[j].start)
+	})
+	merged := cs.intervals[:1]
+	for _, d := range cs.intervals[1:] {
+		last := &merged[len(merged)-1]
+		// time ranges are formatted with seconds precision,
+		// so adjacent month ranges may have 1s gap between them
+		if !d.start.After(last.end.Add(time.Second)) {
+			if d.end.After(last.end) {
+				last.end = d.end
+			}
+			continue
+		}
+		merged = append(merged, d)
+	}
+	cs.intervals = merged
+}
+
+func (c *checkpoint) toFileLocked() *checkpointFile {
+	cf := &checkpointFile{
+		Version: checkpointVersion,
+		Series:  make([]checkpointSeriesEntry, 0, len(c.series)),
+	}
+	if len(c.total) > 0 {
+		cf.Total = c.total
+	}
+	for key, cs := range c.series {
+		e := checkpointSeriesEntry{
+			TenantID: key.TenantID,
+			Metric:   key.Metric,
+			Bucket:   key.Bucket,
+			Requests: cs.requests,
+		}
+		for _, d := range cs.intervals {
+			e.Done = append(e.Done, [2]string{d.start.Format(time.RFC3339), d.end.Format(time.RFC3339)})
+		}
+		cf.Series = append(cf.Series, e)
+	}
+	sort.Slice(cf.Series, func(i, j int) bool {
+		a, b := cf.Series[i], cf.Series[j]
+		if a.TenantID != b.TenantID {
+			return a.TenantID < b.TenantID
+		}
+		if a.Metric != b.Metric {
+			return a.Metric < b.Metric
+		}
+		return a.Bucket < b.Bucket
+	})
+	return cf
+}
+
+// flushLocked atomically writes the checkpoint to c.path
+// by writing a temporary file and renaming it.
+func (c *checkpoint) flushLocked() error {
+	data, err := json.MarshalIndent(c.toFileLocked(), "", "  ")
+	if err != nil {
+		return fmt.Errorf("cannot marshal state: %w", err)
+	}
+	if err := writeFileAtomic(c.path, data); err != nil {
+		return err
+	}
+	c.pending = 0
+	return nil
+}
+
+// writeFileAtomic writes data to path via temporary file,
+// so readers never see partially written file.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return fmt.Errorf("cannot create temporary file for %q: %w", path, err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("cannot write file %q: %w", tmp.Name(), err)
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("cannot close file %q: %w", tmp.Name(), err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("cannot move file to %q: %w", path, err)
+	}
+	return nil
+}
+
+// writeSummary writes human-readable summary of the checkpoint to w
+func (c *checkpoint) writeSummary(w io.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	type tenantSummary struct {
+		metrics  map[string]struct{}
+		requests int
+	}
+	tenants := make(map[string]*tenantSummary)
+	getTenant := func(tenantID string) *tenantSummary {
+		ts := tenants[tenantID]
+		if ts == nil {
+			ts = &tenantSummary{metrics: make(map[string]struct{})}
+			tenants[tenantID] = ts
+		}
+		return ts
+	}
+	for key, cs := range c.series {
+		ts := getTenant(key.TenantID)
+		ts.metrics[key.Metric] = struct{}{}
+		ts.requests += cs.requests
+	}
+	for tenantID := range c.total {
+		getTenant(tenantID)
+	}
+	tenantIDs := make([]string, 0, len(tenants))
+	for tenantID := range tenants {
+		tenantIDs = append(tenantIDs, tenantID)
+	}
+	sort.Strings(tenantIDs)
+
+	var done, total int
+	fmt.Fprintf(w, "State file: %s\n", c.path)
+	for _, tenantID := range tenantIDs {
+		ts := tenants[tenantID]
+		done += ts.requests
+		name := tenantID
+		if name == "" {
+			name = "-"
+		}
+		t, ok := c.total[tenantID]
+		if !ok {
+			fmt.Fprintf(w, "  tenant %s: metrics: %d; migrated requests: %d; total requests: unknown\n",
+				name, len(ts.metrics), ts.requests)
+			continue
+		}
+		total += t
+		fmt.Fprintf(w, "  tenant %s: metrics: %d; migrated requests: %d of %d (%s); remaining requests: %d\n",
+			name, len(ts.metrics), ts.requests, t, formatPercent(ts.requests, t), remaining(ts.requests, t))
+	}
+	if total == 0 {
+		fmt.Fprintf(w, "Total: migrated requests: %d; total requests: unknown\n", done)
+		return
+	}
+	fmt.Fprintf(w, "Total: migrated requests: %d of %d (%s); remaining requests: %d\n",
+		done, total, formatPercent(done, total), remaining(done, total))
+}
+
+func formatPercent(done, total int) string {
+	if total <= 0 {
+		return "0.00%"
+	}
+	return fmt.Sprintf("%.2f%%", float64(done)*100/float64(total))
+}
+
+func remaining(done, total int) int {
+	if done >= total {
+		return 0
+	}
+	return total - done
+}
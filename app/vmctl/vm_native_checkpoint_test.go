@@ -0,0 +1,235 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmctl/native"
+)
+
+func newTestUnit(tenantID, metric, start, end string) *migrationUnit {
+	return &migrationUnit{
+		tenantID: tenantID,
+		metric:   metric,
+		filter:   native.Filter{TimeStart: start, TimeEnd: end},
+	}
+}
+
+func TestCheckpoint(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	c, err := loadCheckpoint(path)
+	if err != nil {
+		t.Fatalf("cannot load missing checkpoint: %s", err)
+	}
+	if c.len() != 0 {
+		t.Fatalf("expecting empty checkpoint; got %d entries", c.len())
+	}
+
+	u1 := newTestUnit("", "foo", "2022-01-01T00:00:00Z", "2022-01-02T00:00:00Z")
+	u2 := newTestUnit("1:0", "foo", "2022-01-01T00:00:00Z", "2022-01-02T00:00:00Z")
+	if err := c.markDone(u1); err != nil {
+		t.Fatalf("cannot mark unit as done: %s", err)
+	}
+
+	c, err = loadCheckpoint(path)
+	if err != nil {
+		t.Fatalf("cannot load checkpoint: %s", err)
+	}
+	if !c.isDone(u1) {
+		t.Fatalf("expecting %v to be done", u1)
+	}
+	if c.isDone(u2) {
+		t.Fatalf("expecting %v to be not done", u2)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"version":100}`), 0644); err != nil {
+		t.Fatalf("cannot write state file: %s", err)
+	}
+	if _, err := loadCheckpoint(path); err == nil {
+		t.Fatalf("expecting error for unsupported version")
+	}
+}
+
+func TestCheckpointCompaction(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	c := newCheckpoint(path)
+	c.setTotal("", 4)
+	units := []*migrationUnit{
+		newTestUnit("", "foo", "2022-01-02T00:00:00Z", "2022-01-03T00:00:00Z"),
+		newTestUnit("", "foo", "2022-01-01T00:00:00Z", "2022-01-02T00:00:00Z"),
+		// month ranges have 1s gap between them
+		newTestUnit("", "foo", "2022-01-03T00:00:01Z", "2022-01-04T00:00:00Z"),
+		newTestUnit("", "foo", "2022-02-01T00:00:00Z", "2022-02-02T00:00:00Z"),
+	}
+	for _, u := range units[:3] {
+		if err := c.markDone(u); err != nil {
+			t.Fatalf("cannot mark unit as done: %s", err)
+		}
+	}
+	// re-migration doesn't change the number of migrated requests
+	if err := c.markDone(units[0]); err != nil {
+		t.Fatalf("cannot mark unit as done: %s", err)
+	}
+
+	c, err := loadCheckpoint(path)
+	if err != nil {
+		t.Fatalf("cannot load checkpoint: %s", err)
+	}
+	cs := c.series[checkpointSeriesKey{Metric: "foo"}]
+	if len(cs.intervals) != 1 {
+		t.Fatalf("expecting intervals to be merged into 1; got %d", len(cs.intervals))
+	}
+	if c.len() != 3 {
+		t.Fatalf("expecting 3 migrated requests; got %d", c.len())
+	}
+	for _, u := range units[:3] {
+		if !c.isDone(u) {
+			t.Fatalf("expecting %v to be done", u.filter)
+		}
+	}
+	if c.isDone(units[3]) {
+		t.Fatalf("expecting %v to be not done", units[3].filter)
+	}
+
+	var buf bytes.Buffer
+	c.writeSummary(&buf)
+	if s := buf.String(); !strings.Contains(s, "migrated requests: 3 of 4 (75.00%); remaining requests: 1") {
+		t.Fatalf("unexpected summary:\n%s", s)
+	}
+}
+
+func TestCheckpointLoadV1(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	data := `{"version":1,"done":[{"metric":"foo","start":"2022-01-01T00:00:00Z","end":"2022-01-02T00:00:00Z"}]}`
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatalf("cannot write state file: %s", err)
+	}
+	c, err := loadCheckpoint(path)
+	if err != nil {
+		t.Fatalf("cannot load checkpoint: %s", err)
+	}
+	if !c.isDone(newTestUnit("", "foo", "2022-01-01T00:00:00Z", "2022-01-02T00:00:00Z")) {
+		t.Fatalf("expecting unit to be done")
+	}
+}
+
+func TestCheckpointLoadEmptyDone(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	data := `{"version":2,"series":[{"metric":"foo","requests":0,"done":[]},{"metric":"bar","requests":1,"done":null},` +
+		`{"metric":"baz","requests":1,"done":[["2022-01-01T00:00:00Z","2022-01-02T00:00:00Z"]]}]}`
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatalf("cannot write state file: %s", err)
+	}
+	c, err := loadCheckpoint(path)
+	if err != nil {
+		t.Fatalf("cannot load checkpoint: %s", err)
+	}
+	if c.isDone(newTestUnit("", "foo", "2022-01-01T00:00:00Z", "2022-01-02T00:00:00Z")) {
+		t.Fatalf("unexpected done unit for series without migrated intervals")
+	}
+	if !c.isDone(newTestUnit("", "baz", "2022-01-01T00:00:00Z", "2022-01-02T00:00:00Z")) {
+		t.Fatalf("expecting unit to be done")
+	}
+	if n := c.len(); n != 2 {
+		t.Fatalf("unexpected number of migrated requests; got %d; want 2", n)
+	}
+}
+
+func TestCheckpointFlushEvery(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	c := newCheckpoint(path)
+	c.flushEvery = 3
+
+	loadLen := func() int {
+		t.Helper()
+		loaded, err := loadCheckpoint(path)
+		if err != nil {
+			t.Fatalf("cannot load checkpoint: %s", err)
+		}
+		return loaded.len()
+	}
+
+	for _, m := range []string{"foo", "bar"} {
+		if err := c.markDone(newTestUnit("", m, "2022-01-01T00:00:00Z", "2022-01-02T00:00:00Z")); err != nil {
+			t.Fatalf("cannot mark unit as done: %s", err)
+		}
+	}
+	if n := loadLen(); n != 0 {
+		t.Fatalf("units must be buffered until %d of them are migrated; got %d persisted", c.flushEvery, n)
+	}
+	if err := c.markDone(newTestUnit("", "baz", "2022-01-01T00:00:00Z", "2022-01-02T00:00:00Z")); err != nil {
+		t.Fatalf("cannot mark unit as done: %s", err)
+	}
+	if n := loadLen(); n != 3 {
+		t.Fatalf("expecting 3 persisted units; got %d", n)
+	}
+
+	if err := c.markDone(newTestUnit("", "qux", "2022-01-01T00:00:00Z", "2022-01-02T00:00:00Z")); err != nil {
+		t.Fatalf("cannot mark unit as done: %s", err)
+	}
+	stop := c.runFlusher()
+	if err := stop(); err != nil {
+		t.Fatalf("cannot stop flusher: %s", err)
+	}
+	if n := loadLen(); n != 4 {
+		t.Fatalf("buffered units must be persisted on stop; got %d persisted", n)
+	}
+}
+
+func TestCheckpointFlushInterval(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	c := newCheckpoint(path)
+	c.flushInterval = 10 * time.Millisecond
+	stop := c.runFlusher()
+	defer func() {
+		if err := stop(); err != nil {
+			t.Fatalf("cannot stop flusher: %s", err)
+		}
+	}()
+
+	if err := c.markDone(newTestUnit("", "foo", "2022-01-01T00:00:00Z", "2022-01-02T00:00:00Z")); err != nil {
+		t.Fatalf("cannot mark unit as done: %s", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		loaded, err := loadCheckpoint(path)
+		if err != nil {
+			t.Fatalf("cannot load checkpoint: %s", err)
+		}
+		if loaded.len() == 1 {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("buffered unit wasn't persisted by the flusher")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestParseCheckpointFlushInterval(t *testing.T) {
+	f := func(s string, wantEvery int, wantInterval time.Duration) {
+		t.Helper()
+		every, interval, err := parseCheckpointFlushInterval(s)
+		if err != nil {
+			t.Fatalf("unexpected error for %q: %s", s, err)
+		}
+		if every != wantEvery || interval != wantInterval {
+			t.Fatalf("unexpected result for %q; got %d, %s; want %d, %s", s, every, interval, wantEvery, wantInterval)
+		}
+	}
+	f("", 1, 0)
+	f("1", 1, 0)
+	f("1000", 1000, 0)
+	f("30s", 0, 30*time.Second)
+	f("1m", 0, time.Minute)
+
+	for _, s := range []string{"0", "-5", "0s", "foo", "10x"} {
+		if _, _, err := parseCheckpointFlushInterval(s); err == nil {
+			t.Fatalf("expecting error for %q", s)
+		}
+	}
+}
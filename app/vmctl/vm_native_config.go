@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/urfave/cli/v2"
+	"gopkg.in/yaml.v2"
+)
+
+// nativeConfig contains migration params, which can be set via --vm-native-config file.
+// Zero values mean the param isn't set in the file.
+type nativeConfig struct {
+	Match        []string `yaml:"match,omitempty"`
+	TimeStart    string   `yaml:"time_start,omitempty"`
+	TimeEnd      string   `yaml:"time_end,omitempty"`
+	StepInterval string   `yaml:"step_interval,omitempty"`
+	RateLimit    int64    `yaml:"rate_limit,omitempty"`
+	Concurrency  int      `yaml:"concurrency,omitempty"`
+}
+
+// parseNativeConfig parses and validates nativeConfig from data
+func parseNativeConfig(data []byte) (*nativeConfig, error) {
+	var cfg nativeConfig
+	// unknown keys are reported, so misspelled params aren't silently ignored
+	if err := yaml.UnmarshalStrict(data, &cfg); err != nil {
+		return nil, err
+	}
+	for _, match := range cfg.Match {
+		if match == "" {
+			return nil, fmt.Errorf("match can't contain empty selectors")
+		}
+	}
+	if cfg.RateLimit < 0 {
+		return nil, fmt.Errorf("rate_limit can't be negative; got %d", cfg.RateLimit)
+	}
+	if cfg.Concurrency < 0 {
+		return nil, fmt.Errorf("concurrency can't be negative; got %d", cfg.Concurrency)
+	}
+	return &cfg, nil
+}
+
+// flagValues returns values of flags set in cfg
+func (cfg *nativeConfig) flagValues() map[string][]string {
+	fv := make(map[string][]string)
+	add := func(flag, value string) {
+		if value != "" && value != "0" {
+			fv[flag] = append(fv[flag], value)
+		}
+	}
+	for _, match := range cfg.Match {
+		add(vmNativeFilterMatch, match)
+	}
+	add(vmNativeFilterTimeStart, cfg.TimeStart)
+	add(vmNativeFilterTimeEnd, cfg.TimeEnd)
+	add(vmNativeStepInterval, cfg.StepInterval)
+	add(vmRateLimit, strconv.FormatInt(cfg.RateLimit, 10))
+	add(vmConcurrency, strconv.Itoa(cfg.Concurrency))
+	return fv
+}
+
+// readNativeConfigFile reads migration params from --vm-native-config file
+// and sets the corresponding flags in c, which aren't set via command line.
+func readNativeConfigFile(c *cli.Context) error {
+	path := c.String(vmNativeConfig)
+	if path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("cannot read --%s: %w", vmNativeConfig, err)
+	}
+	cfg, err := parseNativeConfig(data)
+	if err != nil {
+		return fmt.Errorf("cannot parse --%s=%q: %w", vmNativeConfig, path, err)
+	}
+	for flag, values := range cfg.flagValues() {
+		if c.IsSet(flag) {
+			// command-line flags take precedence over the file
+			continue
+		}
+		for _, value := range values {
+			if err := c.Set(flag, value); err != nil {
+				return fmt.Errorf("cannot set --%s from --%s: %w", flag, vmNativeConfig, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/urfave/cli/v2"
+)
+
+func TestParseNativeConfig(t *testing.T) {
+	f := func(data string, expErr bool) {
+		t.Helper()
+		_, err := parseNativeConfig([]byte(data))
+		if (err != nil) != expErr {
+			t.Fatalf("unexpected error for %q; got %v; want error %v", data, err, expErr)
+		}
+	}
+	f("", false)
+	f("match: ['{job=\"foo\"}']\ntime_start: 2023-01-01T00:00:00Z\nrate_limit: 100", false)
+	// unknown key
+	f("time_begin: 2023-01-01T00:00:00Z", true)
+	f("match: ['']", true)
+	f("rate_limit: -1", true)
+	f("concurrency: -1", true)
+	f("concurrency: foo", true)
+}
+
+func TestReadNativeConfigFile(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	for _, f := range vmNativeFlags {
+		if err := f.Apply(fs); err != nil {
+			t.Fatalf("cannot apply flag %s: %s", f.Names()[0], err)
+		}
+	}
+	path := filepath.Join(t.TempDir(), "config.yml")
+	data := `
+match:
+- '{__name__="foo"}'
+- '{__name__="bar",job=~"a|b"}'
+time_start: 2023-01-01T00:00:00Z
+time_end: 2023-02-01T00:00:00Z
+step_interval: day
+concurrency: 4
+`
+	if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatalf("cannot write file: %s", err)
+	}
+	args := []string{"--" + vmNativeConfig + "=" + path, "--" + vmNativeFilterTimeEnd + "=2023-03-01T00:00:00Z"}
+	if err := fs.Parse(args); err != nil {
+		t.Fatalf("cannot parse flags: %s", err)
+	}
+	c := cli.NewContext(cli.NewApp(), fs, nil)
+	if err := readNativeConfigFile(c); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	expMatches := []string{`{__name__="foo"}`, `{__name__="bar",job=~"a|b"}`}
+	if got := filterMatches(c); !reflect.DeepEqual(got, expMatches) {
+		t.Fatalf("unexpected matches; got %q; want %q", got, expMatches)
+	}
+	if got := c.String(vmNativeFilterTimeStart); got != "2023-01-01T00:00:00Z" {
+		t.Fatalf("unexpected time start %q", got)
+	}
+	// the command-line flag takes precedence over the file
+	if got := c.String(vmNativeFilterTimeEnd); got != "2023-03-01T00:00:00Z" {
+		t.Fatalf("unexpected time end %q", got)
+	}
+	if got := c.String(vmNativeStepInterval); got != "day" {
+		t.Fatalf("unexpected step interval %q", got)
+	}
+	if got := c.Int(vmConcurrency); got != 4 {
+		t.Fatalf("unexpected concurrency %d", got)
+	}
+	if got := c.Int64(vmRateLimit); got != 0 {
+		t.Fatalf("unexpected rate limit %d", got)
+	}
+
+	if err := fs.Set(vmNativeConfig, filepath.Join(t.TempDir(), "missing")); err != nil {
+		t.Fatalf("cannot set flag: %s", err)
+	}
+	if err := readNativeConfigFile(c); err == nil {
+		t.Fatalf("expecting error for missing file")
+	}
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmctl/native"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/decimal"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompbmarshal"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promrelabel"
+)
+
+const (
+	// onDuplicateTSKeep passes samples with duplicate timestamps as is
+	onDuplicateTSKeep = "keep"
+	// onDuplicateTSWarn reports series with duplicate timestamps
+	onDuplicateTSWarn = "warn"
+	// onDuplicateTSCollapse leaves only the last sample for duplicate timestamps
+	onDuplicateTSCollapse = "collapse"
+)
+
+func validateOnDuplicateTS(mode string) error {
+	switch mode {
+	case "", onDuplicateTSKeep, onDuplicateTSWarn, onDuplicateTSCollapse:
+		return nil
+	default:
+		return fmt.Errorf("unsupported value %q for --%s; supported values: %q, %q, %q",
+			mode, vmNativeOnDuplicateTS, onDuplicateTSKeep, onDuplicateTSWarn, onDuplicateTSCollapse)
+	}
+}
+
+const (
+	// nonFiniteKeep passes NaN and Inf values as is
+	nonFiniteKeep = "keep"
+	// nonFiniteDrop drops samples with NaN and Inf values
+	nonFiniteDrop = "drop"
+)
+
+func validateNonFinite(mode string) error {
+	switch mode {
+	case "", nonFiniteKeep, nonFiniteDrop:
+		return nil
+	default:
+		return fmt.Errorf("unsupported value %q for --%s; supported values: %q, %q",
+			mode, vmNativeNonFinite, nonFiniteKeep, nonFiniteDrop)
+	}
+}
+
+// needsDecode returns true if exported blocks must be decoded
+// and re-encoded before import
+func (p *vmNativeProcessor) needsDecode() bool {
+	switch p.onDuplicateTS {
+	case onDuplicateTSWarn, onDuplicateTSCollapse:
+		return true
+	}
+	return p.nonFinite == nonFiniteDrop || len(p.valueScales) > 0 || p.relabelConfigs.Len() > 0 || p.downsample != nil || p.dedupStream ||
+		len(p.stampLabels) > 0
+}
+
+// blockProcessor processes decoded blocks of a single migration unit.
+// Counters are added to the global stats only if the unit succeeds,
+// so retried attempts aren't accounted multiple times.
+type blockProcessor struct {
+	onDuplicateTS string
+	nonFinite     string
+	valueScales   []*valueScale
+	downsample    *downsampleConfig
+	streamDedup   *streamDedup
+
+	relabelConfigs *promrelabel.ParsedConfigs
+	// labels is a buffer for relabeling
+	labels []prompbmarshal.Label
+
+	stampLabels        []prompbmarshal.Label
+	stampLabelConflict string
+
+	duplicateSeries  uint64
+	duplicateSamples uint64
+
+	nonFiniteSeries  uint64
+	nonFiniteSamples uint64
+
+	scaledSeries  uint64
+	scaledSamples uint64
+
+	relabelDroppedSeries  uint64
+	relabelDroppedSamples uint64
+
+	downsampledSeries     uint64
+	downsampledSamples    uint64
+	downsampledOutSamples uint64
+
+	dedupSeries  uint64
+	dedupSamples uint64
+
+	stampOverwrittenSeries uint64
+}
+
+func (p *vmNativeProcessor) newBlockProcessor() *blockProcessor {
+	bp := &blockProcessor{
+		onDuplicateTS: p.onDuplicateTS,
+		nonFinite:     p.nonFinite,
+		valueScales:   p.valueScales,
+		downsample:    p.downsample,
+
+		relabelConfigs: p.relabelConfigs,
+
+		stampLabels:        p.stampLabels,
+		stampLabelConflict: p.stampLabelConflict,
+	}
+	if p.dedupStream {
+		bp.streamDedup = newStreamDedup()
+	}
+	return bp
+}
+
+// decodePipe returns reader with the data from r processed by bp
+func (bp *blockProcessor) decodePipe(r io.Reader) io.ReadCloser {
+	pr, pw := io.Pipe()
+	go func() {
+		if bp.downsample != nil {
+			_ = pw.CloseWithError(bp.transformDownsampled(pw, r))
+			return
+		}
+		_ = pw.CloseWithError(native.Transform(pw, r, bp.process))
+	}()
+	return pr
+}
+
+func (bp *blockProcessor) process(b *native.Block) error {
+	bp.handleDuplicates(b)
+	bp.handleStreamDedup(b)
+	bp.handleValueScale(b)
+	bp.handleNonFinite(b)
+	bp.handleRelabel(b)
+	// labels are stamped after relabeling, so relabeling rules can't remove them
+	return bp.handleStampLabels(b)
+}
+
+// handleDuplicates detects samples with duplicate timestamps within b.
+// Timestamps within a block are sorted, so duplicates are adjacent.
+func (bp *blockProcessor) handleDuplicates(b *native.Block) {
+	if bp.onDuplicateTS != onDuplicateTSWarn && bp.onDuplicateTS != onDuplicateTSCollapse {
+		return
+	}
+	duplicates := 0
+	ts, vs := b.Timestamps, b.Values
+	n := 0
+	for i := range ts {
+		if n > 0 && ts[n-1] == ts[i] {
+			duplicates++
+			if bp.onDuplicateTS == onDuplicateTSCollapse {
+				// the last sample wins
+				vs[n-1] = vs[i]
+				continue
+			}
+		}
+		ts[n], vs[n] = ts[i], vs[i]
+		n++
+	}
+	b.Timestamps, b.Values = ts[:n], vs[:n]
+	if duplicates == 0 {
+		return
+	}
+	if bp.duplicateSeries == 0 && bp.onDuplicateTS == onDuplicateTSWarn {
+		logger.Warnf("series %s contains %d samples with duplicate timestamps; see --%s", b.MetricName.String(), duplicates, vmNativeOnDuplicateTS)
+	}
+	bp.duplicateSeries++
+	bp.duplicateSamples += uint64(duplicates)
+}
+
+// handleNonFinite drops samples with NaN and Inf values from b.
+// Staleness markers are special NaN values, which are always kept.
+func (bp *blockProcessor) handleNonFinite(b *native.Block) {
+	if bp.nonFinite != nonFiniteDrop {
+		return
+	}
+	dropped := 0
+	ts, vs := b.Timestamps, b.Values
+	n := 0
+	for i, v := range vs {
+		if (math.IsNaN(v) && !decimal.IsStaleNaN(v)) || math.IsInf(v, 0) {
+			dropped++
+			continue
+		}
+		ts[n], vs[n] = ts[i], v
+		n++
+	}
+	b.Timestamps, b.Values = ts[:n], vs[:n]
+	if dropped == 0 {
+		return
+	}
+	bp.nonFiniteSeries++
+	bp.nonFiniteSamples += uint64(dropped)
+}
+
+func (bp *blockProcessor) flushStats(s *stats) {
+	s.Lock()
+	s.duplicateSeries += bp.duplicateSeries
+	s.duplicateSamples += bp.duplicateSamples
+	s.nonFiniteSeries += bp.nonFiniteSeries
+	s.nonFiniteSamples += bp.nonFiniteSamples
+	s.scaledSeries += bp.scaledSeries
+	s.scaledSamples += bp.scaledSamples
+	s.relabelDroppedSeries += bp.relabelDroppedSeries
+	s.relabelDroppedSamples += bp.relabelDroppedSamples
+	s.downsampledSeries += bp.downsampledSeries
+	s.downsampledSamples += bp.downsampledSamples
+	s.downsampledOutSamples += bp.downsampledOutSamples
+	s.dedupSeries += bp.dedupSeries
+	s.dedupSamples += bp.dedupSamples
+	s.stampOverwrittenSeries += bp.stampOverwrittenSeries
+	s.Unlock()
+}
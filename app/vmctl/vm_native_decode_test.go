@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmctl/native"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/decimal"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/storage"
+)
+
+func TestBlockProcessorDuplicates(t *testing.T) {
+	f := func(mode string, ts []int64, vs []float64, expTS []int64, expVS []float64, expSeries, expSamples uint64) {
+		t.Helper()
+		var b native.Block
+		b.MetricName.MetricGroup = []byte("foo")
+		b.Timestamps = append(b.Timestamps, ts...)
+		b.Values = append(b.Values, vs...)
+
+		bp := &blockProcessor{onDuplicateTS: mode}
+		if err := bp.process(&b); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if !reflect.DeepEqual(b.Timestamps, expTS) {
+			t.Fatalf("unexpected timestamps; got %v; want %v", b.Timestamps, expTS)
+		}
+		if !reflect.DeepEqual(b.Values, expVS) {
+			t.Fatalf("unexpected values; got %v; want %v", b.Values, expVS)
+		}
+		if bp.duplicateSeries != expSeries || bp.duplicateSamples != expSamples {
+			t.Fatalf("unexpected counters; got series=%d, samples=%d; want series=%d, samples=%d",
+				bp.duplicateSeries, bp.duplicateSamples, expSeries, expSamples)
+		}
+	}
+
+	ts := []int64{1, 2, 2, 3, 3, 3}
+	vs := []float64{1, 2, 3, 4, 5, 6}
+	f(onDuplicateTSWarn, ts, vs, ts, vs, 1, 3)
+	f(onDuplicateTSCollapse, ts, vs, []int64{1, 2, 3}, []float64{1, 3, 6}, 1, 3)
+	f(onDuplicateTSCollapse, []int64{1, 2}, []float64{1, 2}, []int64{1, 2}, []float64{1, 2}, 0, 0)
+}
+
+func TestTransformRoundTrip(t *testing.T) {
+	tr := storage.TimeRange{MinTimestamp: 0, MaxTimestamp: 100}
+	var src bytes.Buffer
+	e := native.NewEncoder(&src, tr)
+	var b native.Block
+	b.MetricName.MetricGroup = []byte("foo")
+	b.MetricName.AddTag("job", "bar")
+	b.Timestamps = []int64{10, 20, 20, 30}
+	b.Values = []float64{1, 2.5, 3, 4}
+	if err := e.Encode(&b); err != nil {
+		t.Fatalf("cannot encode block: %s", err)
+	}
+	if err := e.Close(); err != nil {
+		t.Fatalf("cannot close encoder: %s", err)
+	}
+
+	bp := &blockProcessor{onDuplicateTS: onDuplicateTSCollapse}
+	r := bp.decodePipe(&src)
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	d := native.NewDecoder(bytes.NewReader(data))
+	var got native.Block
+	if err := d.Next(&got); err != nil {
+		t.Fatalf("cannot decode block: %s", err)
+	}
+	if s := got.MetricName.String(); s != `foo{job="bar"}` {
+		t.Fatalf("unexpected metric name %s", s)
+	}
+	if !reflect.DeepEqual(got.Timestamps, []int64{10, 20, 30}) {
+		t.Fatalf("unexpected timestamps %v", got.Timestamps)
+	}
+	if !reflect.DeepEqual(got.Values, []float64{1, 3, 4}) {
+		t.Fatalf("unexpected values %v", got.Values)
+	}
+	if err := d.Next(&got); err != io.EOF {
+		t.Fatalf("expecting io.EOF; got %v", err)
+	}
+}
+
+func TestBlockProcessorNonFinite(t *testing.T) {
+	f := func(mode string, vs []float64, expTS []int64, expVS []float64, expSeries, expSamples uint64) {
+		t.Helper()
+		var b native.Block
+		b.MetricName.MetricGroup = []byte("foo")
+		for i, v := range vs {
+			b.Timestamps = append(b.Timestamps, int64(i+1))
+			b.Values = append(b.Values, v)
+		}
+
+		bp := &blockProcessor{nonFinite: mode}
+		if err := bp.process(&b); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if !reflect.DeepEqual(b.Timestamps, expTS) {
+			t.Fatalf("unexpected timestamps; got %v; want %v", b.Timestamps, expTS)
+		}
+		if len(b.Values) != len(expVS) {
+			t.Fatalf("unexpected values; got %v; want %v", b.Values, expVS)
+		}
+		for i := range expVS {
+			if !equalValues(b.Values[i], expVS[i]) {
+				t.Fatalf("unexpected values; got %v; want %v", b.Values, expVS)
+			}
+		}
+		if bp.nonFiniteSeries != expSeries || bp.nonFiniteSamples != expSamples {
+			t.Fatalf("unexpected counters; got series=%d, samples=%d; want series=%d, samples=%d",
+				bp.nonFiniteSeries, bp.nonFiniteSamples, expSeries, expSamples)
+		}
+	}
+
+	nan, inf := math.NaN(), math.Inf(1)
+	stale := decimal.StaleNaN
+	f(nonFiniteKeep, []float64{1, nan, inf}, []int64{1, 2, 3}, []float64{1, nan, inf}, 0, 0)
+	f(nonFiniteDrop, []float64{1, 2, 3}, []int64{1, 2, 3}, []float64{1, 2, 3}, 0, 0)
+	f(nonFiniteDrop, []float64{1, nan, 3, inf, -inf}, []int64{1, 3}, []float64{1, 3}, 1, 3)
+	f(nonFiniteDrop, []float64{nan, inf}, []int64{}, []float64{}, 1, 2)
+	// staleness markers are kept
+	f(nonFiniteDrop, []float64{1, stale, nan}, []int64{1, 2}, []float64{1, stale}, 1, 1)
+}
+
+func TestTransformDropNonFinite(t *testing.T) {
+	src := encodeTestBlocks(t,
+		newTestBlock("", []int64{1, 2, 3}, []float64{1, math.Inf(1), 3}),
+		newTestBlock("instance", []int64{1, 2}, []float64{math.Inf(1), math.Inf(-1)}),
+		newTestBlock("pod", []int64{1, 2}, []float64{1, 2}),
+	)
+	bp := &blockProcessor{nonFinite: nonFiniteDrop}
+	var dst bytes.Buffer
+	if err := native.Transform(&dst, bytes.NewReader(src), bp.process); err != nil {
+		t.Fatalf("cannot transform: %s", err)
+	}
+	d := native.NewDecoder(&dst)
+	samples := map[string]int{}
+	var b native.Block
+	for {
+		if err := d.Next(&b); err != nil {
+			if err == io.EOF {
+				break
+			}
+			t.Fatalf("cannot decode block: %s", err)
+		}
+		samples[seriesKey(&b.MetricName, nil)] += len(b.Values)
+	}
+	exp := map[string]int{`foo{job="bar"}`: 2, `foo{job="bar",pod="baz"}`: 2}
+	if !reflect.DeepEqual(samples, exp) {
+		t.Fatalf("unexpected samples per series; got %v; want %v", samples, exp)
+	}
+	if bp.nonFiniteSeries != 2 || bp.nonFiniteSamples != 3 {
+		t.Fatalf("unexpected counters; got series=%d, samples=%d", bp.nonFiniteSeries, bp.nonFiniteSamples)
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmctl/native"
+)
+
+// maxDedupTimestamps limits the number of timestamps tracked by streamDedup per migration unit.
+// Tracked timestamps are reset after reaching the limit, so dedup is best-effort for big units.
+const maxDedupTimestamps = 4 << 20
+
+// streamDedup tracks timestamps of series seen in exported blocks of a single migration unit.
+// Blocks of the same series may overlap in time, e.g. if the data was written by multiple sources,
+// so timestamps are tracked across all the blocks of the unit.
+type streamDedup struct {
+	// seen contains timestamps per marshaled series name
+	seen map[string]map[int64]struct{}
+	// tracked is the number of timestamps in seen
+	tracked int
+	buf     []byte
+}
+
+func newStreamDedup() *streamDedup {
+	return &streamDedup{
+		seen: make(map[string]map[int64]struct{}),
+	}
+}
+
+// handleStreamDedup drops samples from b with timestamps already seen for the series of b
+func (bp *blockProcessor) handleStreamDedup(b *native.Block) {
+	sd := bp.streamDedup
+	if sd == nil || len(b.Timestamps) == 0 {
+		return
+	}
+	if sd.tracked+len(b.Timestamps) > maxDedupTimestamps {
+		sd.seen = make(map[string]map[int64]struct{})
+		sd.tracked = 0
+	}
+	sd.buf = b.MetricName.Marshal(sd.buf[:0])
+	seen, ok := sd.seen[string(sd.buf)]
+	if !ok {
+		seen = make(map[int64]struct{}, len(b.Timestamps))
+		sd.seen[string(sd.buf)] = seen
+	}
+	dropped := 0
+	ts, vs := b.Timestamps, b.Values
+	n := 0
+	for i, t := range ts {
+		if _, ok := seen[t]; ok {
+			dropped++
+			continue
+		}
+		seen[t] = struct{}{}
+		ts[n], vs[n] = t, vs[i]
+		n++
+	}
+	sd.tracked += n
+	b.Timestamps, b.Values = ts[:n], vs[:n]
+	if dropped == 0 {
+		return
+	}
+	bp.dedupSeries++
+	bp.dedupSamples += uint64(dropped)
+}
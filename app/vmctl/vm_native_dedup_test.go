@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmctl/native"
+)
+
+func TestBlockProcessorStreamDedup(t *testing.T) {
+	src := encodeTestBlocks(t,
+		newTestBlock("", []int64{1, 2, 3}, []float64{1, 2, 3}),
+		// overlapping block of the same series
+		newTestBlock("", []int64{2, 3, 4}, []float64{20, 30, 40}),
+		// timestamps of other series aren't affected
+		newTestBlock("instance", []int64{1, 2}, []float64{5, 6}),
+		// all the samples are duplicates, so the block is skipped
+		newTestBlock("", []int64{1, 4}, []float64{10, 40}),
+	)
+	bp := &blockProcessor{streamDedup: newStreamDedup()}
+	data, err := io.ReadAll(bp.decodePipe(bytes.NewReader(src)))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	var got []string
+	d := native.NewDecoder(bytes.NewReader(data))
+	var b native.Block
+	for {
+		if err := d.Next(&b); err != nil {
+			if err != io.EOF {
+				t.Fatalf("cannot decode block: %s", err)
+			}
+			break
+		}
+		got = append(got, fmt.Sprintf("%s %v %v", b.MetricName.String(), b.Timestamps, b.Values))
+	}
+	exp := []string{
+		`foo{job="bar"} [1 2 3] [1 2 3]`,
+		`foo{job="bar"} [4] [40]`,
+		`foo{job="bar",instance="baz"} [1 2] [5 6]`,
+	}
+	if !reflect.DeepEqual(got, exp) {
+		t.Fatalf("unexpected blocks;\ngot\n%q\nwant\n%q", got, exp)
+	}
+	if bp.dedupSeries != 2 || bp.dedupSamples != 4 {
+		t.Fatalf("unexpected stats: series=%d, samples=%d", bp.dedupSeries, bp.dedupSamples)
+	}
+}
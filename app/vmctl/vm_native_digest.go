@@ -0,0 +1,108 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// transferDigests collects sha256 digests of data written to the destination per migrated unit.
+//
+// Units are migrated concurrently in arbitrary order, so the digest of a tenant is calculated
+// over the digests of its units sorted by unit keys. This makes the digest independent of
+// the order of requests and of retries, since only the last successful attempt of a unit is kept.
+type transferDigests struct {
+	mu sync.Mutex
+	// tenants maps tenant ID to digests of units keyed by unitDigestKey
+	tenants map[string]map[string][]byte
+}
+
+func newTransferDigests() *transferDigests {
+	return &transferDigests{
+		tenants: make(map[string]map[string][]byte),
+	}
+}
+
+// newHash returns hash for data of a single unit. It returns nil if digests are disabled.
+func (td *transferDigests) newHash() hash.Hash {
+	if td == nil {
+		return nil
+	}
+	return sha256.New()
+}
+
+// add registers the digest h of data written for u
+func (td *transferDigests) add(u *migrationUnit, h hash.Hash) {
+	if td == nil || h == nil {
+		return
+	}
+	td.mu.Lock()
+	defer td.mu.Unlock()
+	units, ok := td.tenants[u.tenantID]
+	if !ok {
+		units = make(map[string][]byte)
+		td.tenants[u.tenantID] = units
+	}
+	units[unitDigestKey(u)] = h.Sum(nil)
+}
+
+// unitDigestKey returns the key identifying data of u
+func unitDigestKey(u *migrationUnit) string {
+	return strings.Join([]string{u.metric, u.bucket, u.filter.Match, u.filter.TimeStart, u.filter.TimeEnd}, "\x00")
+}
+
+// digests returns hex-encoded sha256 digest per tenant
+func (td *transferDigests) digests() map[string]string {
+	td.mu.Lock()
+	defer td.mu.Unlock()
+
+	result := make(map[string]string, len(td.tenants))
+	for tenantID, units := range td.tenants {
+		keys := make([]string, 0, len(units))
+		for k := range units {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		h := sha256.New()
+		for _, k := range keys {
+			h.Write([]byte(k))
+			h.Write([]byte{0})
+			h.Write(units[k])
+		}
+		result[tenantID] = hex.EncodeToString(h.Sum(nil))
+	}
+	return result
+}
+
+// String returns digests of all the tenants sorted by tenant ID
+func (td *transferDigests) String() string {
+	digests := td.digests()
+	tenants := make([]string, 0, len(digests))
+	for tenantID := range digests {
+		tenants = append(tenants, tenantID)
+	}
+	sort.Strings(tenants)
+	var sb strings.Builder
+	sb.WriteString("\n  sha256 digests of imported data:")
+	for _, tenantID := range tenants {
+		name := tenantID
+		if name == "" {
+			name = "all"
+		}
+		fmt.Fprintf(&sb, "\n    %s: %s;", name, digests[tenantID])
+	}
+	return sb.String()
+}
+
+// teeDigest returns writer, which writes data to both w and h if h isn't nil
+func teeDigest(w io.Writer, h hash.Hash) io.Writer {
+	if h == nil {
+		return w
+	}
+	return io.MultiWriter(w, h)
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestTransferDigests(t *testing.T) {
+	write := func(td *transferDigests, u *migrationUnit, data string) {
+		t.Helper()
+		h := td.newHash()
+		var bb bytes.Buffer
+		if _, err := io.Copy(teeDigest(&bb, h), strings.NewReader(data)); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if bb.String() != data {
+			t.Fatalf("unexpected data written; got %q; want %q", bb.String(), data)
+		}
+		td.add(u, h)
+	}
+	foo := newTestUnit("1:0", "foo", "2022-01-01T00:00:00Z", "2022-01-01T01:00:00Z")
+	bar := newTestUnit("1:0", "bar", "2022-01-01T00:00:00Z", "2022-01-01T01:00:00Z")
+	baz := newTestUnit("2:0", "baz", "2022-01-01T00:00:00Z", "2022-01-01T01:00:00Z")
+
+	td1 := newTransferDigests()
+	write(td1, foo, "foo data")
+	write(td1, bar, "bar data")
+	write(td1, baz, "baz data")
+
+	// digests mustn't depend on the order of requests and on failed attempts
+	td2 := newTransferDigests()
+	write(td2, baz, "baz data")
+	write(td2, bar, "partial")
+	write(td2, bar, "bar data")
+	write(td2, foo, "foo data")
+
+	d1, d2 := td1.digests(), td2.digests()
+	if len(d1) != 2 {
+		t.Fatalf("expecting digests for 2 tenants; got %v", d1)
+	}
+	for tenantID, digest := range d1 {
+		if d2[tenantID] != digest {
+			t.Fatalf("unexpected digest for tenant %q; got %s; want %s", tenantID, d2[tenantID], digest)
+		}
+	}
+
+	td3 := newTransferDigests()
+	write(td3, foo, "foo data")
+	write(td3, bar, "other data")
+	if d3 := td3.digests(); d3["1:0"] == d1["1:0"] {
+		t.Fatalf("digests must differ for different data")
+	}
+
+	if s := td1.String(); !strings.Contains(s, "1:0: "+d1["1:0"]) || !strings.Contains(s, "2:0: "+d1["2:0"]) {
+		t.Fatalf("missing tenant digests in %q", s)
+	}
+
+	// disabled digests must be ignored
+	var td *transferDigests
+	if h := td.newHash(); h != nil {
+		t.Fatalf("expecting nil hash for disabled digests")
+	}
+	td.add(foo, nil)
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmctl/native"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/decimal"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promutils"
+)
+
+const (
+	downsampleAvg  = "avg"
+	downsampleMin  = "min"
+	downsampleMax  = "max"
+	downsampleLast = "last"
+
+	// maxDownsampledBlockSamples limits the number of aggregated samples buffered per series
+	maxDownsampledBlockSamples = 8192
+)
+
+// downsampleConfig defines aggregation of samples of every series over interval
+type downsampleConfig struct {
+	// interval is the bucket size in milliseconds
+	interval int64
+	fn       string
+}
+
+// parseDownsample parses `interval[:func]` string, e.g. `5m` or `1h:max`.
+// Empty string means downsampling is disabled.
+func parseDownsample(s string) (*downsampleConfig, error) {
+	if s == "" {
+		return nil, nil
+	}
+	intervalStr, fn := s, downsampleAvg
+	if n := strings.IndexByte(s, ':'); n >= 0 {
+		intervalStr, fn = s[:n], s[n+1:]
+	}
+	switch fn {
+	case downsampleAvg, downsampleMin, downsampleMax, downsampleLast:
+	default:
+		return nil, fmt.Errorf("unsupported aggregation function %q in --%s=%q; supported functions: %q, %q, %q, %q",
+			fn, vmNativeDownsample, s, downsampleAvg, downsampleMin, downsampleMax, downsampleLast)
+	}
+	interval, err := promutils.ParseDuration(intervalStr)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse interval in --%s=%q: %w", vmNativeDownsample, s, err)
+	}
+	if interval.Milliseconds() <= 0 {
+		return nil, fmt.Errorf("interval in --%s=%q must be at least 1ms", vmNativeDownsample, s)
+	}
+	return &downsampleConfig{
+		interval: interval.Milliseconds(),
+		fn:       fn,
+	}, nil
+}
+
+// bucketStart returns the start of the bucket containing ts.
+// Buckets are aligned to Unix epoch, so they are the same for all the requests.
+func (dc *downsampleConfig) bucketStart(ts int64) int64 {
+	mod := ts % dc.interval
+	if mod < 0 {
+		mod += dc.interval
+	}
+	return ts - mod
+}
+
+// downsampleBucket aggregates samples within a single interval
+type downsampleBucket struct {
+	ts int64
+	// samples is the number of added samples including staleness markers
+	samples int
+	// n is the number of added samples excluding staleness markers
+	n                   int
+	sum, min, max, last float64
+}
+
+func (db *downsampleBucket) add(v float64) {
+	db.samples++
+	if decimal.IsStaleNaN(v) {
+		return
+	}
+	if db.n == 0 {
+		db.min, db.max = v, v
+	}
+	db.n++
+	db.sum += v
+	db.min = math.Min(db.min, v)
+	db.max = math.Max(db.max, v)
+	db.last = v
+}
+
+// value returns the aggregated value of the bucket.
+// Buckets with staleness markers only are aggregated into staleness marker.
+func (db *downsampleBucket) value(fn string) float64 {
+	if db.n == 0 {
+		return decimal.StaleNaN
+	}
+	switch fn {
+	case downsampleMin:
+		return db.min
+	case downsampleMax:
+		return db.max
+	case downsampleLast:
+		return db.last
+	default:
+		return db.sum / float64(db.n)
+	}
+}
+
+// downsampler aggregates samples of the series from consecutive blocks and encodes aggregated blocks.
+// Blocks of the same series are adjacent in export responses, so only the current series is buffered.
+type downsampler struct {
+	cfg *downsampleConfig
+	e   *native.Encoder
+
+	// key is the marshaled name of the current series
+	key []byte
+	buf []byte
+	out native.Block
+
+	bucket downsampleBucket
+
+	series     uint64
+	inSamples  uint64
+	outSamples uint64
+}
+
+func (ds *downsampler) add(b *native.Block) error {
+	if len(b.Timestamps) == 0 {
+		return nil
+	}
+	ds.buf = b.MetricName.Marshal(ds.buf[:0])
+	if !bytes.Equal(ds.buf, ds.key) {
+		if err := ds.flush(); err != nil {
+			return err
+		}
+		ds.key = append(ds.key[:0], ds.buf...)
+		ds.out.MetricName.CopyFrom(&b.MetricName)
+		ds.series++
+	}
+	for i, ts := range b.Timestamps {
+		start := ds.cfg.bucketStart(ts)
+		if ds.bucket.samples > 0 && start != ds.bucket.ts {
+			ds.appendBucket()
+			if len(ds.out.Timestamps) >= maxDownsampledBlockSamples {
+				if err := ds.encode(); err != nil {
+					return err
+				}
+			}
+		}
+		if ds.bucket.samples == 0 {
+			ds.bucket.ts = start
+		}
+		ds.bucket.add(b.Values[i])
+	}
+	ds.inSamples += uint64(len(b.Timestamps))
+	return nil
+}
+
+func (ds *downsampler) appendBucket() {
+	ds.out.Timestamps = append(ds.out.Timestamps, ds.bucket.ts)
+	ds.out.Values = append(ds.out.Values, ds.bucket.value(ds.cfg.fn))
+	ds.bucket = downsampleBucket{}
+}
+
+func (ds *downsampler) encode() error {
+	ds.outSamples += uint64(len(ds.out.Timestamps))
+	err := ds.e.Encode(&ds.out)
+	ds.out.Timestamps = ds.out.Timestamps[:0]
+	ds.out.Values = ds.out.Values[:0]
+	if err != nil {
+		return fmt.Errorf("cannot encode downsampled block: %w", err)
+	}
+	return nil
+}
+
+// flush encodes aggregated samples of the current series
+func (ds *downsampler) flush() error {
+	if ds.bucket.samples > 0 {
+		ds.appendBucket()
+	}
+	return ds.encode()
+}
+
+// transformDownsampled is like native.Transform, but aggregates samples of the blocks processed by bp
+// according to bp.downsample before encoding them to dst.
+func (bp *blockProcessor) transformDownsampled(dst io.Writer, src io.Reader) error {
+	d := native.NewDecoder(src)
+	tr, err := d.TimeRange()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			// empty export response
+			return nil
+		}
+		return err
+	}
+	ds := &downsampler{
+		cfg: bp.downsample,
+		e:   native.NewEncoder(dst, tr),
+	}
+	var b native.Block
+	for {
+		if err := d.Next(&b); err != nil {
+			if err != io.EOF {
+				return err
+			}
+			if err := ds.flush(); err != nil {
+				return err
+			}
+			bp.downsampledSeries += ds.series
+			bp.downsampledSamples += ds.inSamples
+			bp.downsampledOutSamples += ds.outSamples
+			return ds.e.Close()
+		}
+		if err := bp.process(&b); err != nil {
+			return err
+		}
+		if err := ds.add(&b); err != nil {
+			return err
+		}
+	}
+}
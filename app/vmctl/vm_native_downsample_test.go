@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmctl/native"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/decimal"
+)
+
+func TestParseDownsample(t *testing.T) {
+	f := func(s string, expInterval int64, expFn string) {
+		t.Helper()
+		dc, err := parseDownsample(s)
+		if err != nil {
+			t.Fatalf("unexpected error for %q: %s", s, err)
+		}
+		if dc.interval != expInterval || dc.fn != expFn {
+			t.Fatalf("unexpected result for %q; got interval=%d, fn=%q", s, dc.interval, dc.fn)
+		}
+	}
+	f("5m", 300e3, downsampleAvg)
+	f("1h:max", 3600e3, downsampleMax)
+	f("1d:last", 86400e3, downsampleLast)
+
+	if dc, err := parseDownsample(""); err != nil || dc != nil {
+		t.Fatalf("expecting disabled downsampling; got %v, %v", dc, err)
+	}
+	fErr := func(s string) {
+		t.Helper()
+		if _, err := parseDownsample(s); err == nil {
+			t.Fatalf("expecting error for %q", s)
+		}
+	}
+	fErr("foo")
+	fErr("5m:sum")
+	fErr("0s")
+	fErr(":avg")
+}
+
+func TestDownsampleBucketStart(t *testing.T) {
+	dc := &downsampleConfig{interval: 10}
+	f := func(ts, exp int64) {
+		t.Helper()
+		if got := dc.bucketStart(ts); got != exp {
+			t.Fatalf("unexpected bucket start for %d; got %d; want %d", ts, got, exp)
+		}
+	}
+	f(0, 0)
+	f(9, 0)
+	f(10, 10)
+	f(-1, -10)
+	f(-10, -10)
+}
+
+func TestBlockProcessorDownsample(t *testing.T) {
+	stale := decimal.StaleNaN
+	f := func(fn string, blocks []*native.Block, exp []string) {
+		t.Helper()
+		src := encodeTestBlocks(t, blocks...)
+		bp := &blockProcessor{downsample: &downsampleConfig{interval: 10, fn: fn}}
+		data, err := io.ReadAll(bp.decodePipe(bytes.NewReader(src)))
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		var got []string
+		d := native.NewDecoder(bytes.NewReader(data))
+		var b native.Block
+		for {
+			if err := d.Next(&b); err != nil {
+				if err != io.EOF {
+					t.Fatalf("cannot decode block: %s", err)
+				}
+				break
+			}
+			got = append(got, fmt.Sprintf("%s %v %v", b.MetricName.String(), b.Timestamps, b.Values))
+		}
+		if !reflect.DeepEqual(got, exp) {
+			t.Fatalf("unexpected blocks for %q;\ngot\n%q\nwant\n%q", fn, got, exp)
+		}
+	}
+
+	blocks := func() []*native.Block {
+		return []*native.Block{
+			newTestBlock("", []int64{1, 5, 12, 18}, []float64{1, 3, 10, 20}),
+			// the bucket [20..30) is split between blocks of the same series
+			newTestBlock("", []int64{25, 28, 45}, []float64{4, 2, 7}),
+			newTestBlock("instance", []int64{3, 7}, []float64{5, stale}),
+		}
+	}
+	f(downsampleAvg, blocks(), []string{
+		`foo{job="bar"} [0 10 20 40] [2 15 3 7]`,
+		`foo{job="bar",instance="baz"} [0] [5]`,
+	})
+	f(downsampleMin, blocks(), []string{
+		`foo{job="bar"} [0 10 20 40] [1 10 2 7]`,
+		`foo{job="bar",instance="baz"} [0] [5]`,
+	})
+	f(downsampleMax, blocks(), []string{
+		`foo{job="bar"} [0 10 20 40] [3 20 4 7]`,
+		`foo{job="bar",instance="baz"} [0] [5]`,
+	})
+	f(downsampleLast, blocks(), []string{
+		`foo{job="bar"} [0 10 20 40] [3 20 2 7]`,
+		`foo{job="bar",instance="baz"} [0] [5]`,
+	})
+
+	// buckets with staleness markers only are aggregated into staleness marker
+	src := encodeTestBlocks(t, newTestBlock("", []int64{1, 15}, []float64{1, stale}))
+	bp := &blockProcessor{downsample: &downsampleConfig{interval: 10, fn: downsampleAvg}}
+	data, err := io.ReadAll(bp.decodePipe(bytes.NewReader(src)))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	var b native.Block
+	if err := native.NewDecoder(bytes.NewReader(data)).Next(&b); err != nil {
+		t.Fatalf("cannot decode block: %s", err)
+	}
+	if len(b.Values) != 2 || b.Values[0] != 1 || !decimal.IsStaleNaN(b.Values[1]) {
+		t.Fatalf("unexpected values %v", b.Values)
+	}
+	if bp.downsampledSeries != 1 || bp.downsampledSamples != 2 || bp.downsampledOutSamples != 2 {
+		t.Fatalf("unexpected stats: series=%d, samples=%d, out=%d", bp.downsampledSeries, bp.downsampledSamples, bp.downsampledOutSamples)
+	}
+}
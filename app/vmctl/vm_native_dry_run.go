@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// dryRunRecorder collects units, which would be migrated, without migrating them
+type dryRunRecorder struct {
+	// path is an optional path for writing the list of units to. "-" means stdout.
+	path string
+	// topMetrics is the number of the heaviest metrics to print after estimating samples of units.
+	// Zero disables the estimation.
+	topMetrics int
+
+	mu      sync.Mutex
+	metrics map[string]struct{}
+	units   []dryRunUnit
+	// estimatedBytes and estimatedSamples accumulate transfer estimates
+	// for calculating the size of estimated samples
+	estimatedBytes   float64
+	estimatedSamples float64
+}
+
+// dryRunUnit is a record of the list written to --vm-native-dry-run-out
+type dryRunUnit struct {
+	TenantID  string `json:"tenant,omitempty"`
+	Metric    string `json:"metric"`
+	Match     string `json:"match"`
+	TimeStart string `json:"start"`
+	TimeEnd   string `json:"end"`
+}
+
+func newDryRunRecorder(path string, topMetrics int) *dryRunRecorder {
+	return &dryRunRecorder{
+		path:       path,
+		topMetrics: topMetrics,
+		metrics:    make(map[string]struct{}),
+	}
+}
+
+// add records u as the unit, which would be migrated
+func (dr *dryRunRecorder) add(u *migrationUnit) {
+	dr.mu.Lock()
+	defer dr.mu.Unlock()
+	dr.metrics[u.tenantID+"/"+u.metric] = struct{}{}
+	dr.units = append(dr.units, dryRunUnit{
+		TenantID:  u.tenantID,
+		Metric:    u.metric,
+		Match:     u.filter.Match,
+		TimeStart: u.filter.TimeStart,
+		TimeEnd:   u.filter.TimeEnd,
+	})
+}
+
+// finish logs the summary of recorded units and writes them to dr.path if set.
+// Units are sorted, so lists of different runs could be compared via diff.
+func (dr *dryRunRecorder) finish() error {
+	dr.mu.Lock()
+	defer dr.mu.Unlock()
+
+	log.Printf("Dry run finished: %d metrics and %d requests would be migrated; no data was written to destination",
+		len(dr.metrics), len(dr.units))
+	if dr.path == "" {
+		return nil
+	}
+	sort.Slice(dr.units, func(i, j int) bool {
+		a, b := dr.units[i], dr.units[j]
+		if a.TenantID != b.TenantID {
+			return a.TenantID < b.TenantID
+		}
+		if a.Metric != b.Metric {
+			return a.Metric < b.Metric
+		}
+		if a.Match != b.Match {
+			return a.Match < b.Match
+		}
+		return a.TimeStart < b.TimeStart
+	})
+	var sb strings.Builder
+	for _, u := range dr.units {
+		data, err := json.Marshal(u)
+		if err != nil {
+			return fmt.Errorf("cannot marshal request: %w", err)
+		}
+		sb.Write(data)
+		sb.WriteByte('\n')
+	}
+	if dr.path == "-" {
+		fmt.Print(sb.String())
+		return nil
+	}
+	if err := writeFileAtomic(dr.path, []byte(sb.String())); err != nil {
+		return fmt.Errorf("cannot write the list of requests: %w", err)
+	}
+	log.Printf("The list of requests is written to %q", dr.path)
+	return nil
+}
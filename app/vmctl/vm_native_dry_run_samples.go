@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmctl/native"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+)
+
+// metricSamples is the estimated number of samples of a single metric
+type metricSamples struct {
+	tenantID string
+	metric   string
+	samples  int64
+}
+
+// addEstimate records te for calculating the size of samples estimated during dry run.
+// It is no-op if dr or te is nil.
+func (dr *dryRunRecorder) addEstimate(te *transferEstimate) {
+	if dr == nil || te == nil {
+		return
+	}
+	dr.mu.Lock()
+	dr.estimatedBytes += te.bytes
+	dr.estimatedSamples += te.samples
+	dr.mu.Unlock()
+}
+
+// estimateSamples queries the source for the number of samples of every request recorded during dry run
+// and logs the total number of samples together with the dr.topMetrics heaviest metrics.
+// Only count_over_time queries are sent to the source, so no data is exported.
+// It returns the estimated metrics ranked by the number of samples.
+func (p *vmNativeProcessor) estimateSamples(ctx context.Context) []metricSamples {
+	dr := p.dryRun
+	dr.mu.Lock()
+	units := append([]dryRunUnit(nil), dr.units...)
+	dr.mu.Unlock()
+	if len(units) == 0 {
+		return nil
+	}
+
+	log.Printf("Estimating samples of %d requests...", len(units))
+	var mu sync.Mutex
+	counts := make(map[metricSamples]int64)
+	failed := 0
+	unitsCh := make(chan dryRunUnit)
+	var wg sync.WaitGroup
+	for i := 0; i < p.cc; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for du := range unitsCh {
+				n, err := p.unitSamples(ctx, du)
+				mu.Lock()
+				if err != nil {
+					failed++
+				} else {
+					counts[metricSamples{tenantID: du.TenantID, metric: du.Metric}] += n
+				}
+				mu.Unlock()
+				if err != nil {
+					logger.Errorf("cannot estimate samples of metric %q for time range %s - %s: %s", du.Metric, du.TimeStart, du.TimeEnd, err)
+				}
+			}
+		}()
+	}
+	for _, du := range units {
+		if ctx.Err() != nil {
+			break
+		}
+		unitsCh <- du
+	}
+	close(unitsCh)
+	wg.Wait()
+
+	dr.mu.Lock()
+	bytesPerSample := 0.0
+	if dr.estimatedSamples > 0 {
+		bytesPerSample = dr.estimatedBytes / dr.estimatedSamples
+	}
+	dr.mu.Unlock()
+	ranked := rankMetricSamples(counts)
+	log.Print(samplesMessage(ranked, dr.topMetrics, bytesPerSample))
+	if failed > 0 {
+		logger.Warnf("samples of %d requests weren't estimated because of errors; the total is underestimated", failed)
+	}
+	return ranked
+}
+
+// unitSamples returns the number of samples of du at the source
+func (p *vmNativeProcessor) unitSamples(ctx context.Context, du dryRunUnit) (int64, error) {
+	query, ts, err := countQuery(&migrationUnit{
+		tenantID: du.TenantID,
+		metric:   du.Metric,
+		filter:   native.Filter{Match: du.Match, TimeStart: du.TimeStart, TimeEnd: du.TimeEnd},
+	})
+	if err != nil {
+		return 0, err
+	}
+	p.waitSrcQPS("estimate")
+	n, err := p.src.QueryValue(ctx, p.srcQueryAddr(du.TenantID), query, ts)
+	if err != nil {
+		return 0, fmt.Errorf("cannot query source: %w", err)
+	}
+	return int64(n), nil
+}
+
+// rankMetricSamples returns metrics from counts sorted by the number of samples in descending order
+func rankMetricSamples(counts map[metricSamples]int64) []metricSamples {
+	ranked := make([]metricSamples, 0, len(counts))
+	for ms, n := range counts {
+		ms.samples = n
+		ranked = append(ranked, ms)
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		a, b := ranked[i], ranked[j]
+		if a.samples != b.samples {
+			return a.samples > b.samples
+		}
+		if a.tenantID != b.tenantID {
+			return a.tenantID < b.tenantID
+		}
+		return a.metric < b.metric
+	})
+	return ranked
+}
+
+// samplesMessage returns the total number of samples of ranked metrics followed by top heaviest metrics.
+// The size of samples is shown if bytesPerSample is known.
+func samplesMessage(ranked []metricSamples, top int, bytesPerSample float64) string {
+	var total int64
+	for _, ms := range ranked {
+		total += ms.samples
+	}
+	size := func(samples int64) string {
+		if bytesPerSample <= 0 {
+			return ""
+		}
+		return fmt.Sprintf(" (~%s)", byteCountSI(int64(float64(samples)*bytesPerSample)))
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Estimated samples to migrate: ~%d%s for %d metrics", total, size(total), len(ranked))
+	if len(ranked) > top {
+		ranked = ranked[:top]
+	}
+	if len(ranked) > 0 {
+		sb.WriteString("; the heaviest metrics:")
+	}
+	for i, ms := range ranked {
+		name := ms.metric
+		if ms.tenantID != "" {
+			name = fmt.Sprintf("%s (tenant %s)", ms.metric, ms.tenantID)
+		}
+		share := 0.0
+		if total > 0 {
+			share = float64(ms.samples) / float64(total) * 100
+		}
+		fmt.Fprintf(&sb, "\n  %d. %s: ~%d samples%s, %.1f%%", i+1, name, ms.samples, size(ms.samples), share)
+	}
+	return sb.String()
+}
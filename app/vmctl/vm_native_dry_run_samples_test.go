@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmctl/native"
+)
+
+func TestSamplesMessage(t *testing.T) {
+	ranked := rankMetricSamples(map[metricSamples]int64{
+		{metric: "foo"}:                  100,
+		{metric: "bar"}:                  300,
+		{metric: "baz"}:                  100,
+		{tenantID: "1:0", metric: "qux"}: 500,
+	})
+	exp := []metricSamples{
+		{tenantID: "1:0", metric: "qux", samples: 500},
+		{metric: "bar", samples: 300},
+		{metric: "baz", samples: 100},
+		{metric: "foo", samples: 100},
+	}
+	if !reflect.DeepEqual(ranked, exp) {
+		t.Fatalf("unexpected ranking; got %v; want %v", ranked, exp)
+	}
+
+	f := func(top int, bytesPerSample float64, exp string) {
+		t.Helper()
+		if got := samplesMessage(ranked, top, bytesPerSample); got != exp {
+			t.Fatalf("unexpected message; got\n%s\nwant\n%s", got, exp)
+		}
+	}
+	f(2, 0, "Estimated samples to migrate: ~1000 for 4 metrics; the heaviest metrics:"+
+		"\n  1. qux (tenant 1:0): ~500 samples, 50.0%"+
+		"\n  2. bar: ~300 samples, 30.0%")
+	f(1, 2, "Estimated samples to migrate: ~1000 (~2.0 kB) for 4 metrics; the heaviest metrics:"+
+		"\n  1. qux (tenant 1:0): ~500 samples (~1.0 kB), 50.0%")
+
+	if got, exp := samplesMessage(nil, 10, 0), "Estimated samples to migrate: ~0 for 0 metrics"; got != exp {
+		t.Fatalf("unexpected message for empty list; got %q; want %q", got, exp)
+	}
+}
+
+func TestEstimateSamples(t *testing.T) {
+	counts := map[string]string{
+		`sum(count_over_time({__name__="foo"}[3600001ms]))`: "10",
+		`sum(count_over_time({__name__="bar"}[3600001ms]))`: "20",
+	}
+	var queries int
+	src := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		queries++
+		if !strings.HasSuffix(r.URL.Path, "/api/v1/query") {
+			t.Errorf("unexpected request to %q", r.URL.Path)
+		}
+		count, ok := counts[r.URL.Query().Get("query")]
+		if !ok {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[0,"` + count + `"]}]}}`))
+	}))
+	defer src.Close()
+
+	p := &vmNativeProcessor{
+		src:    &native.Client{Addr: src.URL},
+		cc:     1,
+		dryRun: newDryRunRecorder("", 10),
+	}
+	for _, metric := range []string{"foo", "bar", "bar", "broken"} {
+		u := newTestUnit("", metric, "2022-01-01T00:00:00Z", "2022-01-01T01:00:00Z")
+		u.filter.Match = `{__name__="` + metric + `"}`
+		p.dryRun.add(u)
+	}
+	ranked := p.estimateSamples(context.Background())
+	if queries != 4 {
+		t.Fatalf("unexpected number of queries; got %d; want 4", queries)
+	}
+	// samples of requests for the same metric are summed, failed requests are skipped
+	exp := []metricSamples{{metric: "bar", samples: 40}, {metric: "foo", samples: 10}}
+	if !reflect.DeepEqual(ranked, exp) {
+		t.Fatalf("unexpected ranking; got %v; want %v", ranked, exp)
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmctl/native"
+)
+
+func TestDryRunRecorder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "requests.jsonl")
+	dr := newDryRunRecorder(path, 0)
+	add := func(tenantID, metric, start, end string) {
+		dr.add(&migrationUnit{
+			tenantID: tenantID,
+			metric:   metric,
+			filter: native.Filter{
+				Match:     `{__name__="` + metric + `"}`,
+				TimeStart: start,
+				TimeEnd:   end,
+			},
+		})
+	}
+	add("1:0", "foo", "2022-01-02T00:00:00Z", "2022-01-03T00:00:00Z")
+	add("0:0", "foo", "2022-01-01T00:00:00Z", "2022-01-02T00:00:00Z")
+	add("1:0", "bar", "2022-01-01T00:00:00Z", "2022-01-02T00:00:00Z")
+	add("1:0", "foo", "2022-01-01T00:00:00Z", "2022-01-02T00:00:00Z")
+	if len(dr.metrics) != 3 {
+		t.Fatalf("expecting 3 metrics; got %d", len(dr.metrics))
+	}
+	if err := dr.finish(); err != nil {
+		t.Fatalf("cannot finish dry run: %s", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("cannot read the list of requests: %s", err)
+	}
+	want := `{"tenant":"0:0","metric":"foo","match":"{__name__=\"foo\"}","start":"2022-01-01T00:00:00Z","end":"2022-01-02T00:00:00Z"}
+{"tenant":"1:0","metric":"bar","match":"{__name__=\"bar\"}","start":"2022-01-01T00:00:00Z","end":"2022-01-02T00:00:00Z"}
+{"tenant":"1:0","metric":"foo","match":"{__name__=\"foo\"}","start":"2022-01-01T00:00:00Z","end":"2022-01-02T00:00:00Z"}
+{"tenant":"1:0","metric":"foo","match":"{__name__=\"foo\"}","start":"2022-01-02T00:00:00Z","end":"2022-01-03T00:00:00Z"}
+`
+	if string(data) != want {
+		t.Fatalf("unexpected list of requests;\ngot\n%s\nwant\n%s", data, want)
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// dstFileWriter writes exported data to local files instead of importing it into the destination.
+// Every unit is written into a separate file, since native export streams can't be concatenated.
+type dstFileWriter struct {
+	// template is the path of the file with {tenant}, {metric}, {bucket}, {start} and {end} placeholders
+	template string
+
+	mu sync.Mutex
+	// paths contains requests per written file for detecting name collisions.
+	// Requests are identified by value, since retries of sub-ranges create new units.
+	paths map[string]string
+}
+
+func newDstFileWriter(template string) *dstFileWriter {
+	return &dstFileWriter{
+		template: template,
+		paths:    make(map[string]string),
+	}
+}
+
+// path returns the path of the file for u
+func (dw *dstFileWriter) path(u *migrationUnit) string {
+	r := strings.NewReplacer(
+		"{tenant}", sanitizeFileName(u.tenantID),
+		"{metric}", sanitizeFileName(u.metric),
+		"{bucket}", sanitizeFileName(u.bucket),
+		"{start}", sanitizeFileName(u.filter.TimeStart),
+		"{end}", sanitizeFileName(u.filter.TimeEnd),
+	)
+	return r.Replace(dw.template)
+}
+
+// write atomically writes data read from r to the file for u
+// and returns the number of written bytes. Optional limit wraps the file writer
+// in order to limit the write rate.
+func (dw *dstFileWriter) write(u *migrationUnit, r io.Reader, limit func(w io.Writer) io.Writer) (int64, error) {
+	path := dw.path(u)
+	key := strings.Join([]string{u.tenantID, u.metric, u.bucket, u.filter.Match, u.filter.TimeStart, u.filter.TimeEnd}, "\x00")
+	dw.mu.Lock()
+	prev, ok := dw.paths[path]
+	if !ok {
+		dw.paths[path] = key
+	}
+	dw.mu.Unlock()
+	if ok && prev != key {
+		return 0, fmt.Errorf("file %q is already written for another request; add {tenant}, {metric}, {bucket}, {start} or {end} placeholders to --%s, so file names are unique",
+			path, vmNativeDstFile)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return 0, fmt.Errorf("cannot create directory for %q: %w", path, err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return 0, fmt.Errorf("cannot create temporary file for %q: %w", path, err)
+	}
+	w := io.Writer(tmp)
+	if limit != nil {
+		w = limit(w)
+	}
+	written, err := io.Copy(w, r)
+	if err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return written, fmt.Errorf("cannot write file %q: %w", tmp.Name(), err)
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return written, fmt.Errorf("cannot close file %q: %w", tmp.Name(), err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		_ = os.Remove(tmp.Name())
+		return written, fmt.Errorf("cannot move file to %q: %w", path, err)
+	}
+	return written, nil
+}
+
+// sanitizeFileName replaces path separators in s, so it can be used as a part of file name
+func sanitizeFileName(s string) string {
+	return strings.ReplaceAll(s, string(os.PathSeparator), "_")
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDstFileWriter(t *testing.T) {
+	dir := t.TempDir()
+	dw := newDstFileWriter(filepath.Join(dir, "{tenant}", "{metric}_{bucket}_{start}.bin"))
+
+	u := newTestUnit("1:0", "foo", "2022-01-01T00:00:00Z", "2022-01-02T00:00:00Z")
+	u.bucket = "job:1/2"
+	n, err := dw.write(u, strings.NewReader("foo data"), nil)
+	if err != nil {
+		t.Fatalf("cannot write file: %s", err)
+	}
+	if n != 8 {
+		t.Fatalf("unexpected number of written bytes: %d", n)
+	}
+	path := filepath.Join(dir, "1:0", "foo_job:1_2_2022-01-01T00:00:00Z.bin")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("cannot read file: %s", err)
+	}
+	if string(data) != "foo data" {
+		t.Fatalf("unexpected file contents %q", data)
+	}
+
+	// retry of the same request overwrites the file
+	retry := *u
+	if _, err := dw.write(&retry, strings.NewReader("retried data"), nil); err != nil {
+		t.Fatalf("cannot overwrite file on retry: %s", err)
+	}
+	data, err = os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("cannot read file: %s", err)
+	}
+	if string(data) != "retried data" {
+		t.Fatalf("unexpected file contents %q", data)
+	}
+
+	// another request mustn't overwrite the file
+	other := newTestUnit("1:0", "foo", "2022-01-01T00:00:00Z", "2022-01-03T00:00:00Z")
+	other.bucket = u.bucket
+	if _, err := dw.write(other, strings.NewReader("other data"), nil); err == nil {
+		t.Fatalf("expecting error on file name collision")
+	}
+
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatalf("cannot read dir: %s", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("temporary files must be removed; got %d entries", len(entries))
+	}
+}
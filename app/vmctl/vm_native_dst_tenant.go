@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// readDstTenantMap reads JSON object mapping source tenants to destination tenants from path,
+// e.g. `{"1:0": "0:0", "2": "0:0"}`. Source tenants without projectID are normalized to `accountID:0`,
+// so they match tenants returned by tenants discovery.
+// It returns nil if path is empty.
+func readDstTenantMap(path string) (map[string]string, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read --%s: %w", vmNativeDstTenantMap, err)
+	}
+	var m map[string]string
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("cannot parse --%s=%q: %w; expecting JSON object like {\"1:0\": \"0:0\"}", vmNativeDstTenantMap, path, err)
+	}
+	if len(m) == 0 {
+		return nil, fmt.Errorf("--%s=%q contains no tenants", vmNativeDstTenantMap, path)
+	}
+	tenants := make(map[string]string, len(m))
+	for src, dst := range m {
+		if !isValidTenant(src) {
+			return nil, fmt.Errorf("invalid source tenant %q in --%s=%q; expecting `accountID` or `accountID:projectID`", src, vmNativeDstTenantMap, path)
+		}
+		if !isValidTenant(dst) {
+			return nil, fmt.Errorf("invalid destination tenant %q for source tenant %q in --%s=%q; expecting `accountID` or `accountID:projectID`",
+				dst, src, vmNativeDstTenantMap, path)
+		}
+		src = normalizeTenant(src)
+		if prev, ok := tenants[src]; ok && prev != dst {
+			return nil, fmt.Errorf("source tenant %q is mapped to both %q and %q in --%s=%q", src, prev, dst, vmNativeDstTenantMap, path)
+		}
+		tenants[src] = dst
+	}
+	return tenants, nil
+}
+
+// normalizeTenant returns tenant in `accountID:projectID` form
+func normalizeTenant(tenant string) string {
+	if strings.Contains(tenant, ":") {
+		return tenant
+	}
+	return tenant + ":0"
+}
+
+// dstTenant returns the destination tenant for the given source tenant.
+// Source tenants without explicit mapping are imported into the same tenant at the destination.
+func (p *vmNativeProcessor) dstTenant(tenantID string) string {
+	if p.dstTenantOverride != "" {
+		return p.dstTenantOverride
+	}
+	if dst, ok := p.dstTenantMap[normalizeTenant(tenantID)]; ok {
+		return dst
+	}
+	return tenantID
+}
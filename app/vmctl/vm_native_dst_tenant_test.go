@@ -0,0 +1,63 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestReadDstTenantMap(t *testing.T) {
+	f := func(data string, exp map[string]string, expErr bool) {
+		t.Helper()
+		path := filepath.Join(t.TempDir(), "tenants.json")
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatalf("cannot write file: %s", err)
+		}
+		m, err := readDstTenantMap(path)
+		if expErr {
+			if err == nil {
+				t.Fatalf("expecting error for %q", data)
+			}
+			return
+		}
+		if err != nil {
+			t.Fatalf("unexpected error for %q: %s", data, err)
+		}
+		if !reflect.DeepEqual(m, exp) {
+			t.Fatalf("unexpected map for %q; got %v; want %v", data, m, exp)
+		}
+	}
+	f(`{"1:0": "0:0", "2": "0", "3:1": "5:5"}`, map[string]string{"1:0": "0:0", "2:0": "0", "3:1": "5:5"}, false)
+	f(`{}`, nil, true)
+	f(`["1:0"]`, nil, true)
+	f(`{"foo": "0:0"}`, nil, true)
+	f(`{"1:0": "0:bar"}`, nil, true)
+	// the same source tenant is mapped to different destination tenants
+	f(`{"1": "0:0", "1:0": "2:0"}`, nil, true)
+
+	if m, err := readDstTenantMap(""); err != nil || m != nil {
+		t.Fatalf("expecting empty map; got %v, %v", m, err)
+	}
+	if _, err := readDstTenantMap(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Fatalf("expecting error for missing file")
+	}
+}
+
+func TestDstTenant(t *testing.T) {
+	p := &vmNativeProcessor{dstTenantMap: map[string]string{"1:0": "0:0"}}
+	f := func(src, exp string) {
+		t.Helper()
+		if got := p.dstTenant(src); got != exp {
+			t.Fatalf("unexpected destination tenant for %q; got %q; want %q", src, got, exp)
+		}
+	}
+	f("1:0", "0:0")
+	f("1", "0:0")
+	// tenants without mapping are imported into the same tenant
+	f("2:0", "2:0")
+
+	p = &vmNativeProcessor{dstTenantOverride: "7:0"}
+	f("1:0", "7:0")
+	f("2:0", "7:0")
+}
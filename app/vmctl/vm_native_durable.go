@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+)
+
+// durableConfig defines waiting for persistence of imported data
+// before marking migration units as done in the state file.
+type durableConfig struct {
+	// addrs contains addresses of components with /internal/force_flush handler
+	addrs []string
+	// authKey must match -forceFlushAuthKey at addrs
+	authKey string
+	// delay is the time needed by addrs for saving searchable data to disk.
+	// It must be not less than -inmemoryDataFlushInterval at addrs.
+	delay time.Duration
+
+	wg sync.WaitGroup
+}
+
+// flushDurable makes the imported data searchable at all the durable.addrs
+func (p *vmNativeProcessor) flushDurable(ctx context.Context) error {
+	for _, addr := range p.durable.addrs {
+		if err := p.dst.ForceFlush(ctx, addr, p.durable.authKey); err != nil {
+			return fmt.Errorf("cannot confirm persistence of imported data: %w", err)
+		}
+	}
+	return nil
+}
+
+// flushOnFinish makes the imported data searchable at p.flushAddrs once the migration is finished,
+// so verification and queries right after the migration see all the migrated data.
+// Addresses without /internal/force_flush handler are reported with warning.
+func (p *vmNativeProcessor) flushOnFinish(ctx context.Context) {
+	for _, addr := range p.flushAddrs {
+		if err := p.dst.ForceFlush(ctx, addr, p.flushAuthKey); err != nil {
+			logger.Warnf("cannot flush imported data at %q: %s; the recently imported data may be invisible for queries for a few seconds", addr, err)
+			continue
+		}
+		log.Printf("Flushed imported data at %q", addr)
+	}
+}
+
+// markDoneDurable marks u as done in the state file after the durable.delay,
+// so the unit is migrated again on resume if the destination crashes before saving its data.
+// Workers aren't blocked while waiting.
+func (p *vmNativeProcessor) markDoneDurable(u *migrationUnit) {
+	p.durable.wg.Add(1)
+	time.AfterFunc(p.durable.delay, func() {
+		defer p.durable.wg.Done()
+		if err := p.checkpoint.markDone(u); err != nil {
+			logger.Errorf("failed to update state: %s", err)
+		}
+	})
+}
+
+// waitDurable waits until all the pending units are marked as done
+func (p *vmNativeProcessor) waitDurable() {
+	if p.durable == nil {
+		return
+	}
+	p.durable.wg.Wait()
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmctl/native"
+)
+
+func TestDurableMarkDone(t *testing.T) {
+	var flushes int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/internal/force_flush" || r.URL.Query().Get("authKey") != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		atomic.AddInt32(&flushes, 1)
+	}))
+	defer srv.Close()
+
+	c, err := loadCheckpoint(filepath.Join(t.TempDir(), "state.json"))
+	if err != nil {
+		t.Fatalf("cannot load checkpoint: %s", err)
+	}
+	p := &vmNativeProcessor{
+		dst:        &native.Client{Addr: srv.URL},
+		checkpoint: c,
+		durable: &durableConfig{
+			addrs:   []string{srv.URL, srv.URL},
+			authKey: "secret",
+			delay:   50 * time.Millisecond,
+		},
+	}
+	if err := p.flushDurable(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if n := atomic.LoadInt32(&flushes); n != 2 {
+		t.Fatalf("expecting 2 flushes; got %d", n)
+	}
+
+	u := newTestUnit("", "foo", "2022-01-01T00:00:00Z", "2022-01-02T00:00:00Z")
+	p.markDoneDurable(u)
+	if c.isDone(u) {
+		t.Fatalf("unit mustn't be marked as done before the delay")
+	}
+	p.waitDurable()
+	if !c.isDone(u) {
+		t.Fatalf("unit must be marked as done after the delay")
+	}
+
+	p.durable.authKey = "invalid"
+	if err := p.flushDurable(context.Background()); err == nil {
+		t.Fatalf("expecting error for rejected flush")
+	}
+}
+
+func TestFlushOnFinish(t *testing.T) {
+	var flushes int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/internal/force_flush" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		atomic.AddInt32(&flushes, 1)
+	}))
+	defer srv.Close()
+
+	// the destination without the handler doesn't stop the flush of other addresses
+	p := &vmNativeProcessor{
+		dst:        &native.Client{Addr: srv.URL},
+		flushAddrs: []string{srv.URL + "/missing", srv.URL},
+	}
+	p.flushOnFinish(context.Background())
+	if n := atomic.LoadInt32(&flushes); n != 1 {
+		t.Fatalf("expecting 1 flush; got %d", n)
+	}
+}
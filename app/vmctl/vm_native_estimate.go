@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmctl/native"
+)
+
+// estimateWindow is the time window at the end of the migrated time range
+// exported for every sampled metric in order to estimate the transfer size
+const estimateWindow = 10 * time.Minute
+
+// transferEstimate is a rough estimate of the data to migrate for a tenant
+type transferEstimate struct {
+	bytes   float64
+	samples float64
+	// sampled is the number of metrics the estimate is based on
+	sampled int
+}
+
+// estimateTransfer estimates the size of data to migrate for the given metrics between start and end.
+// It exports the last estimateWindow of up to p.estimateMetrics randomly chosen metrics
+// and extrapolates the exported size to the whole time range and all the metrics.
+func (p *vmNativeProcessor) estimateTransfer(ctx context.Context, srcURL, tenantID string, metrics map[string]struct{}, start, end time.Time) (*transferEstimate, error) {
+	windowStart := end.Add(-estimateWindow)
+	if windowStart.Before(start) {
+		windowStart = start
+	}
+	window := end.Sub(windowStart)
+	if window <= 0 {
+		return nil, fmt.Errorf("empty time range")
+	}
+
+	var bytes, samples int64
+	sampled := 0
+	// map iteration order is random, so metrics are chosen randomly
+	for metric := range metrics {
+		if sampled >= p.estimateMetrics {
+			break
+		}
+		matches, err := p.metricMatches(tenantID, metric)
+		if err != nil {
+			return nil, err
+		}
+		for _, match := range matches {
+			p.waitSrcQPS("export")
+			r, err := p.src.ExportPipe(ctx, srcURL, native.Filter{
+				Match:     p.filter.WithExclude(match),
+				TimeStart: windowStart.Format(time.RFC3339),
+				TimeEnd:   end.Format(time.RFC3339),
+			})
+			if err != nil {
+				return nil, err
+			}
+			tr := io.TeeReader(r, countingWriter{w: io.Discard, n: &bytes})
+			err = native.Transform(io.Discard, tr, func(b *native.Block) error {
+				samples += int64(len(b.Timestamps))
+				return nil
+			})
+			_ = r.Close()
+			if err != nil {
+				return nil, err
+			}
+		}
+		sampled++
+	}
+	if sampled == 0 {
+		return nil, fmt.Errorf("no metrics to sample")
+	}
+
+	scale := float64(end.Sub(start)) / float64(window) * float64(len(metrics)) / float64(sampled)
+	return &transferEstimate{
+		bytes:   float64(bytes) * scale,
+		samples: float64(samples) * scale,
+		sampled: sampled,
+	}, nil
+}
+
+// transferRate returns the max transfer rate in bytes per second according to the configured rate limits.
+// It returns zero if the rate isn't limited.
+func (p *vmNativeProcessor) transferRate() int64 {
+	var rate int64
+	limit := func(n int64) {
+		if n > 0 && (rate == 0 || n < rate) {
+			rate = n
+		}
+	}
+	if p.rateLimit > 0 {
+		// the limit is applied to every request independently
+		limit(p.rateLimit * int64(p.cc))
+	}
+	limit(p.globalRateLimiter.Limit())
+	limit(p.srcRateLimiter.Limit())
+	return rate
+}
+
+// message returns human-readable estimate with ETA according to rate in bytes per second
+func (te *transferEstimate) message(rate int64) string {
+	msg := fmt.Sprintf("Estimated transfer size: ~%s (~%.0f samples) based on %d sampled metrics",
+		byteCountSI(int64(te.bytes)), te.samples, te.sampled)
+	if rate <= 0 {
+		return msg + "; ETA isn't estimated, since the transfer rate isn't limited"
+	}
+	eta := time.Duration(te.bytes / float64(rate) * float64(time.Second))
+	return msg + fmt.Sprintf("; ETA at %s/s: ~%s", byteCountSI(rate), eta.Truncate(time.Second))
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmctl/limiter"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmctl/native"
+)
+
+func TestEstimateTransfer(t *testing.T) {
+	// every metric has 40 samples within 10m window
+	var ts []int64
+	var vs []float64
+	for j := 0; j < 40; j++ {
+		ts = append(ts, int64(j)*15e3)
+		vs = append(vs, float64(j))
+	}
+	data := encodeTestBlocks(t, newTestBlock("", ts, vs))
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		_, _ = w.Write(data)
+	}))
+	defer srv.Close()
+
+	p := &vmNativeProcessor{
+		src:             &native.Client{Addr: srv.URL},
+		filter:          native.Filter{Match: `{__name__!=""}`},
+		estimateMetrics: 2,
+	}
+	metrics := map[string]struct{}{"foo": {}, "bar": {}, "baz": {}, "qux": {}}
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := start.Add(time.Hour)
+
+	te, err := p.estimateTransfer(context.Background(), srv.URL, "", metrics, start, end)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if requests != 2 || te.sampled != 2 {
+		t.Fatalf("expecting 2 sampled metrics; got %d metrics via %d requests", te.sampled, requests)
+	}
+	// 40 samples per 10m for 4 metrics over 1h
+	if te.samples != 40*6*4 {
+		t.Fatalf("unexpected number of samples %v", te.samples)
+	}
+	if exp := float64(len(data) * 6 * 4); te.bytes != exp {
+		t.Fatalf("unexpected number of bytes %v; want %v", te.bytes, exp)
+	}
+
+	// the window is limited by the time range
+	te, err = p.estimateTransfer(context.Background(), srv.URL, "", metrics, start, start.Add(5*time.Minute))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if te.samples != 40*4 {
+		t.Fatalf("unexpected number of samples %v", te.samples)
+	}
+
+	if _, err := p.estimateTransfer(context.Background(), srv.URL, "", metrics, start, start); err == nil {
+		t.Fatalf("expecting error for empty time range")
+	}
+}
+
+func TestTransferRate(t *testing.T) {
+	f := func(p *vmNativeProcessor, exp int64) {
+		t.Helper()
+		if got := p.transferRate(); got != exp {
+			t.Fatalf("unexpected rate %d; want %d", got, exp)
+		}
+	}
+	f(&vmNativeProcessor{cc: 2}, 0)
+	f(&vmNativeProcessor{cc: 2, rateLimit: 100}, 200)
+	f(&vmNativeProcessor{cc: 2, rateLimit: 100, globalRateLimiter: limiter.NewLimiter(150)}, 150)
+	f(&vmNativeProcessor{cc: 2, srcRateLimiter: limiter.NewLimiter(50), globalRateLimiter: limiter.NewLimiter(150)}, 50)
+}
+
+func TestTransferEstimateMessage(t *testing.T) {
+	te := &transferEstimate{bytes: 6e9, samples: 3e9, sampled: 5}
+	if got, exp := te.message(0), "Estimated transfer size: ~6.0 GB (~3000000000 samples) based on 5 sampled metrics; "+
+		"ETA isn't estimated, since the transfer rate isn't limited"; got != exp {
+		t.Fatalf("unexpected message\ngot\n%s\nwant\n%s", got, exp)
+	}
+	if got, exp := te.message(1e6), "Estimated transfer size: ~6.0 GB (~3000000000 samples) based on 5 sampled metrics; "+
+		"ETA at 1.0 MB/s: ~1h40m0s"; got != exp {
+		t.Fatalf("unexpected message\ngot\n%s\nwant\n%s", got, exp)
+	}
+}
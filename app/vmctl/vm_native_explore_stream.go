@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sort"
+)
+
+// metricStream provides names of metrics to migrate for a single tenant
+type metricStream struct {
+	ch     chan string
+	cancel context.CancelFunc
+	done   chan struct{}
+	err    error
+}
+
+// streamMetrics returns stream of metrics to migrate for the given tenant.
+//
+// If p.exploreStream is set, metrics are discovered at the source while they are migrated.
+// Otherwise, metrics are sent from the already discovered metrics,
+// in the order of their names if p.sortedMetrics is set.
+func (p *vmNativeProcessor) streamMetrics(ctx context.Context, tenantID string, metrics map[string]struct{}) *metricStream {
+	ctx, cancel := context.WithCancel(ctx)
+	ms := &metricStream{
+		ch:     make(chan string),
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	go func() {
+		defer close(ms.done)
+		defer close(ms.ch)
+		if p.exploreStream {
+			ms.err = p.exploreStreamed(ctx, tenantID, ms.ch)
+			return
+		}
+		names := make([]string, 0, len(metrics))
+		for name := range metrics {
+			names = append(names, name)
+		}
+		if p.sortedMetrics {
+			sort.Strings(names)
+		}
+		for _, name := range names {
+			select {
+			case <-ctx.Done():
+				return
+			case ms.ch <- name:
+			}
+		}
+	}()
+	return ms
+}
+
+// stop stops the stream and returns the error of metrics discovery if any.
+// It is safe to call stop multiple times.
+func (ms *metricStream) stop() error {
+	ms.cancel()
+	<-ms.done
+	if errors.Is(ms.err, context.Canceled) {
+		// the stream was stopped before all the metrics were discovered
+		return nil
+	}
+	return ms.err
+}
+
+// exploreStreamed discovers metrics for the given tenant and sends them to out.
+//
+// Discovered names are queued in memory, so api/v1/series response is read
+// without waiting for the migration of already discovered metrics.
+// Only metric names are kept in memory instead of all the series from the response.
+func (p *vmNativeProcessor) exploreStreamed(ctx context.Context, tenantID string, out chan<- string) error {
+	names := make(chan string)
+	errCh := make(chan error, 1)
+	f := p.filter
+	f.Match = p.labelFilters.addTo(f.Match)
+	go func() {
+		p.waitSrcQPS("explore")
+		errCh <- p.src.ExploreStream(ctx, f, tenantID, names)
+		close(names)
+	}()
+
+	var queue []string
+	var discovered, filtered, sampled int
+	in := names
+	for in != nil || len(queue) > 0 {
+		var send chan<- string
+		var next string
+		if len(queue) > 0 {
+			send, next = out, queue[0]
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case name, ok := <-in:
+			if !ok {
+				if err := <-errCh; err != nil {
+					return err
+				}
+				if p.metricNameFilter != nil {
+					logMetricsFilteredOut(tenantID, filtered, discovered)
+				}
+				if p.metricSampler != nil {
+					p.metricSampler.logSampled(tenantID, sampled, discovered-filtered)
+				}
+				in = nil
+				continue
+			}
+			discovered++
+			if !p.metricNameFilter.match(name) {
+				filtered++
+				continue
+			}
+			if !p.metricSampler.match(name) {
+				continue
+			}
+			sampled++
+			queue = append(queue, name)
+		case send <- next:
+			queue = queue[1:]
+		}
+	}
+	return nil
+}
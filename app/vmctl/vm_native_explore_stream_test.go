@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmctl/native"
+)
+
+func TestStreamMetrics(t *testing.T) {
+	src := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("match[]") == `{__name__="broken"}` {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		_, _ = w.Write([]byte(`{"status":"success","data":[{"__name__":"foo","job":"a"},{"__name__":"bar"},{"__name__":"foo","job":"b"}]}`))
+	}))
+	defer src.Close()
+
+	f := func(p *vmNativeProcessor, metrics map[string]struct{}, expNames []string, expErr bool) {
+		t.Helper()
+		ms := p.streamMetrics(context.Background(), "", metrics)
+		var got []string
+		for name := range ms.ch {
+			got = append(got, name)
+		}
+		err := ms.stop()
+		if (err != nil) != expErr {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		sort.Strings(got)
+		if strings.Join(got, ",") != strings.Join(expNames, ",") {
+			t.Fatalf("unexpected names; got %q; want %q", got, expNames)
+		}
+	}
+
+	// already discovered metrics
+	p := &vmNativeProcessor{}
+	f(p, map[string]struct{}{"foo": {}, "bar": {}}, []string{"bar", "foo"}, false)
+
+	// metrics discovered while streaming
+	p = &vmNativeProcessor{
+		src:           &native.Client{Addr: src.URL},
+		filter:        native.Filter{Match: `{__name__!=""}`},
+		exploreStream: true,
+	}
+	f(p, nil, []string{"bar", "foo"}, false)
+
+	p.filter.Match = `{__name__="broken"}`
+	f(p, nil, nil, true)
+}
+
+func TestStreamMetricsSorted(t *testing.T) {
+	metrics := map[string]struct{}{"foo": {}, "bar": {}, "baz": {}, "a": {}, "qux": {}}
+	p := &vmNativeProcessor{sortedMetrics: true}
+	for i := 0; i < 3; i++ {
+		ms := p.streamMetrics(context.Background(), "", metrics)
+		var got []string
+		for name := range ms.ch {
+			got = append(got, name)
+		}
+		if err := ms.stop(); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if exp := "a,bar,baz,foo,qux"; strings.Join(got, ",") != exp {
+			t.Fatalf("unexpected order of names at run %d; got %q; want %q", i, got, exp)
+		}
+	}
+}
+
+func TestStreamMetricsStop(t *testing.T) {
+	src := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"status":"success","data":[{"__name__":"foo"},{"__name__":"bar"},{"__name__":"baz"}]}`))
+	}))
+	defer src.Close()
+
+	p := &vmNativeProcessor{
+		src:           &native.Client{Addr: src.URL},
+		filter:        native.Filter{Match: `{__name__!=""}`},
+		exploreStream: true,
+	}
+	ms := p.streamMetrics(context.Background(), "", nil)
+	if name := <-ms.ch; name != "foo" {
+		t.Fatalf("unexpected first name %q", name)
+	}
+	// stopping the stream before all the metrics are read isn't an error
+	if err := ms.stop(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := ms.stop(); err != nil {
+		t.Fatalf("unexpected error on the second stop: %s", err)
+	}
+}
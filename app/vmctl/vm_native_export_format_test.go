@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmctl/native"
+)
+
+func TestCheckFormatInterCluster(t *testing.T) {
+	var paths []string
+	newServer := func(page string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			paths = append(paths, r.URL.Path)
+			if page == "" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			_, _ = w.Write([]byte(page))
+		}))
+	}
+	vmselect := newServer(`vm_app_version{version="vmselect-20230501-000000-tags-v1.91.0-cluster-0-g0", short_version="v1.91.0"} 1`)
+	defer vmselect.Close()
+	// vminsert behind a proxy without /metrics page
+	vminsert := newServer("")
+	defer vminsert.Close()
+
+	p := &vmNativeProcessor{
+		src:          &native.Client{Addr: vmselect.URL, Format: native.FormatV2},
+		dst:          &native.Client{Addr: vminsert.URL, Format: native.FormatV2},
+		interCluster: true,
+	}
+	// the unknown version of destination results in a warning
+	if err := p.checkFormat(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(paths) != 2 || paths[0] != "/metrics" || paths[1] != "/metrics" {
+		t.Fatalf("unexpected requests: %q", paths)
+	}
+
+	p.src.Format, p.dst.Format = "native-v3", "native-v3"
+	if err := p.checkFormat(context.Background()); err == nil {
+		t.Fatalf("expecting error for unsupported format")
+	}
+}
@@ -0,0 +1,309 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+)
+
+// failedUnit is a record of the failures manifest
+type failedUnit struct {
+	TenantID  string `json:"tenant,omitempty"`
+	Metric    string `json:"metric"`
+	Bucket    string `json:"bucket,omitempty"`
+	Match     string `json:"match"`
+	TimeStart string `json:"start"`
+	TimeEnd   string `json:"end"`
+	Error     string `json:"error"`
+
+	u   *migrationUnit
+	err error
+	// retry is set if u is going to be retried at the end of migration
+	retry bool
+}
+
+// failures collects units which failed to migrate
+// when continue-on-error mode is enabled
+type failures struct {
+	mu    sync.Mutex
+	units []*failedUnit
+
+	// path is an optional path to the failures file. Failed units are appended
+	// to it as soon as they fail, so they aren't lost if vmctl crashes.
+	path string
+	f    *os.File
+	enc  *json.Encoder
+}
+
+func newFailedUnit(u *migrationUnit, err error) *failedUnit {
+	return &failedUnit{
+		TenantID:  u.tenantID,
+		Metric:    u.metric,
+		Bucket:    u.bucket,
+		Match:     u.filter.Match,
+		TimeStart: u.filter.TimeStart,
+		TimeEnd:   u.filter.TimeEnd,
+		Error:     err.Error(),
+		u:         u,
+		err:       err,
+	}
+}
+
+func (fs *failures) add(u *migrationUnit, err error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	fu := newFailedUnit(u, err)
+	fs.units = append(fs.units, fu)
+	fs.appendLocked(fu)
+}
+
+// addRetry records u for retrying at the end of migration.
+// Unlike add, u isn't written to the failures file until it fails finally.
+func (fs *failures) addRetry(u *migrationUnit, err error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	fu := newFailedUnit(u, err)
+	fu.retry = true
+	fs.units = append(fs.units, fu)
+}
+
+// finishRetries marks units left for retrying as failed finally,
+// appends them to the failures file and returns them.
+func (fs *failures) finishRetries() []*failedUnit {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	var units []*failedUnit
+	for _, fu := range fs.units {
+		if !fu.retry {
+			continue
+		}
+		fu.retry = false
+		fs.appendLocked(fu)
+		units = append(units, fu)
+	}
+	return units
+}
+
+// appendLocked appends fu to the failures file if it is configured.
+// The file is truncated on the first failure, so it doesn't contain failures of the previous run.
+func (fs *failures) appendLocked(fu *failedUnit) {
+	if fs.path == "" {
+		return
+	}
+	if fs.f == nil {
+		f, err := os.Create(fs.path)
+		if err != nil {
+			logger.Errorf("cannot create failures file %q: %s; failed requests will be written to it at the end of migration", fs.path, err)
+			fs.path = ""
+			return
+		}
+		fs.f = f
+		fs.enc = json.NewEncoder(f)
+	}
+	if err := fs.enc.Encode(fu); err != nil {
+		logger.Errorf("cannot write to failures file %q: %s", fs.path, err)
+	}
+}
+
+// addFailed adds previously collected fu back to fs
+func (fs *failures) addFailed(fu *failedUnit) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	fs.units = append(fs.units, fu)
+}
+
+// reset returns collected failures and forgets them
+func (fs *failures) reset() []*failedUnit {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	units := fs.units
+	fs.units = nil
+	return units
+}
+
+func (fs *failures) len() int {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	return len(fs.units)
+}
+
+// writeFile writes collected failures to the given path as JSON lines.
+// It replaces the failures appended during the migration, since some of them
+// could be successfully retried since then.
+func (fs *failures) writeFile(path string) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if fs.f != nil {
+		_ = fs.f.Close()
+		fs.f, fs.enc = nil, nil
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("cannot create failures file %q: %w", path, err)
+	}
+	enc := json.NewEncoder(f)
+	for _, fu := range fs.units {
+		if err := enc.Encode(fu); err != nil {
+			_ = f.Close()
+			return fmt.Errorf("cannot write to failures file %q: %w", path, err)
+		}
+	}
+	return f.Close()
+}
+
+// retries returns the number of units waiting for retry
+func (fs *failures) retries() int {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	n := 0
+	for _, fu := range fs.units {
+		if fu.retry {
+			n++
+		}
+	}
+	return n
+}
+
+// unitFailed records u failed with err. If failed units are retried at the end of migration,
+// u is reported as done only once it fails finally, so its metric is verified after the retries.
+func (p *vmNativeProcessor) unitFailed(ctx context.Context, u *migrationUnit, err error) {
+	if p.continueOnError && p.retryPasses > 0 {
+		p.failures.addRetry(u, err)
+		return
+	}
+	p.unitDone(ctx, u, err)
+	p.failures.add(u, err)
+}
+
+// retryFailed performs p.retryPasses passes over failed units after
+// all other units were processed, since transient issues often resolve by then.
+// Retried units are subject to the same concurrency limits as the rest of migration.
+func (p *vmNativeProcessor) retryFailed(ctx context.Context) error {
+	for pass := 1; pass <= p.retryPasses; pass++ {
+		if p.failures.retries() == 0 {
+			break
+		}
+		if p.retryPassDelay > 0 {
+			log.Printf("Waiting %s before retry pass %d of %d", p.retryPassDelay, pass, p.retryPasses)
+			t := time.NewTimer(p.retryPassDelay)
+			select {
+			case <-ctx.Done():
+				t.Stop()
+				return ctx.Err()
+			case <-t.C:
+			}
+		}
+
+		var retries []*failedUnit
+		tenantSlots := make(map[string]chan struct{})
+		for _, fu := range p.failures.reset() {
+			if !fu.retry || fu.u.deadline.expired() {
+				// retrying of the metric with exceeded deadline would block the run again
+				p.failures.addFailed(fu)
+				continue
+			}
+			retries = append(retries, fu)
+			if p.importSem != nil && tenantSlots[fu.u.tenantID] == nil {
+				// tenants are migrated concurrently, so every tenant is limited to p.perTenantCC requests
+				tenantSlots[fu.u.tenantID] = make(chan struct{}, p.perTenantCC)
+			}
+		}
+		log.Printf("Retry pass %d of %d: retrying %d failed requests", pass, p.retryPasses, len(retries))
+		var succeeded int32
+		unitsCh := make(chan *migrationUnit)
+		var wg sync.WaitGroup
+		for i := 0; i < p.cc; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for u := range unitsCh {
+					if p.retryUnit(ctx, u, tenantSlots[u.tenantID]) {
+						atomic.AddInt32(&succeeded, 1)
+					}
+				}
+			}()
+		}
+		for _, fu := range retries {
+			unitsCh <- fu.u
+		}
+		close(unitsCh)
+		wg.Wait()
+		n := int(atomic.LoadInt32(&succeeded))
+		log.Printf("Retry pass %d of %d: %d requests succeeded, %d still failing", pass, p.retryPasses, n, len(retries)-n)
+	}
+	p.finishRetries(ctx)
+	return nil
+}
+
+// retryUnit migrates u once the import slots are acquired.
+// tenantSlot is an optional semaphore limiting the number of concurrent requests of u tenant.
+// It returns true if u is migrated successfully.
+func (p *vmNativeProcessor) retryUnit(ctx context.Context, u *migrationUnit, tenantSlot chan struct{}) bool {
+	if tenantSlot != nil {
+		select {
+		case <-ctx.Done():
+			p.failures.add(u, ctx.Err())
+			return false
+		case tenantSlot <- struct{}{}:
+		}
+		defer func() { <-tenantSlot }()
+	}
+	if !p.acquireImportSlot(ctx) {
+		p.failures.add(u, ctx.Err())
+		return false
+	}
+	if !p.autoConcurrency.acquire(ctx) {
+		p.releaseImportSlot()
+		p.failures.add(u, ctx.Err())
+		return false
+	}
+	uctx, cancel := u.deadline.context(ctx)
+	err := p.do(uctx, u)
+	cancel()
+	p.autoConcurrency.release()
+	p.releaseImportSlot()
+	switch {
+	case err == nil:
+		p.unitDone(ctx, u, nil)
+		p.completed.add(u)
+		p.countVerification.add(u)
+		return true
+	case ctx.Err() != nil:
+		// the unit is recorded in the failures file, so it could be migrated after the interruption
+		p.failures.add(u, err)
+	default:
+		p.failures.addRetry(u, err)
+	}
+	return false
+}
+
+// finishRetries reports units left for retrying as failed finally
+func (p *vmNativeProcessor) finishRetries(ctx context.Context) {
+	for _, fu := range p.failures.finishRetries() {
+		p.unitDone(ctx, fu.u, fu.err)
+	}
+}
+
+// reportFailures writes the failures manifest and returns an error
+// if there are units failed to migrate
+func (p *vmNativeProcessor) reportFailures() error {
+	n := p.failures.len()
+	if n == 0 {
+		return nil
+	}
+	if p.failuresFile == "" {
+		return fmt.Errorf("migration finished with %d failed requests; set --%s to get the list of them", n, vmNativeFailuresFile)
+	}
+	if err := p.failures.writeFile(p.failuresFile); err != nil {
+		return err
+	}
+	return fmt.Errorf("migration finished with %d failed requests; see the list of them at %q", n, p.failuresFile)
+}
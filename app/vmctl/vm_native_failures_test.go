@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmctl/backoff"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmctl/native"
+	"github.com/VictoriaMetrics/metrics"
+)
+
+func TestFailuresFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "failures.jsonl")
+	if err := os.WriteFile(path, []byte("stale failures of the previous run\n"), 0644); err != nil {
+		t.Fatalf("cannot write file: %s", err)
+	}
+	readLines := func() []string {
+		t.Helper()
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("cannot read failures file: %s", err)
+		}
+		return strings.Split(strings.TrimSpace(string(data)), "\n")
+	}
+
+	fs := &failures{path: path}
+	u1 := newTestUnit("", "foo", "2022-01-01T00:00:00Z", "2022-01-02T00:00:00Z")
+	u2 := newTestUnit("", "bar", "2022-01-01T00:00:00Z", "2022-01-02T00:00:00Z")
+	fs.add(u1, fmt.Errorf("error 1"))
+	fs.add(u2, fmt.Errorf("error 2"))
+
+	// failures must be appended as soon as they happen
+	lines := readLines()
+	if len(lines) != 2 || !strings.Contains(lines[0], `"metric":"foo"`) || !strings.Contains(lines[1], `"error":"error 2"`) {
+		t.Fatalf("unexpected failures file contents:\n%s", strings.Join(lines, "\n"))
+	}
+
+	// the final manifest contains only failures left after retries
+	failed := fs.reset()
+	fs.addFailed(failed[1])
+	if err := fs.writeFile(path); err != nil {
+		t.Fatalf("cannot write failures file: %s", err)
+	}
+	lines = readLines()
+	if len(lines) != 1 || !strings.Contains(lines[0], `"metric":"bar"`) {
+		t.Fatalf("unexpected failures file contents:\n%s", strings.Join(lines, "\n"))
+	}
+}
+
+func TestRetryFailed(t *testing.T) {
+	var barExports int32
+	src := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.FormValue("match[]"), "bar") {
+			atomic.AddInt32(&barExports, 1)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		_, _ = w.Write([]byte("foo"))
+	}))
+	defer src.Close()
+	var inflight, maxInflight int32
+	dst := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&inflight, 1)
+		defer atomic.AddInt32(&inflight, -1)
+		if n > atomic.LoadInt32(&maxInflight) {
+			atomic.StoreInt32(&maxInflight, n)
+		}
+		_, _ = io.Copy(io.Discard, r.Body)
+		time.Sleep(10 * time.Millisecond)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer dst.Close()
+
+	bf, err := backoff.NewWithParams(1, time.Millisecond, 0)
+	if err != nil {
+		t.Fatalf("cannot create backoff: %s", err)
+	}
+	path := filepath.Join(t.TempDir(), "failures.jsonl")
+	p := &vmNativeProcessor{
+		src:             &native.Client{Addr: src.URL},
+		dst:             &native.Client{Addr: dst.URL},
+		s:               &stats{},
+		backoff:         bf,
+		cc:              3,
+		perTenantCC:     1,
+		importSem:       make(chan struct{}, 3),
+		continueOnError: true,
+		retryPasses:     2,
+		failures:        failures{path: path},
+		completed:       newCompletedRanges(),
+		progress:        newProgressMetrics(metrics.NewSet(), 0),
+	}
+	for _, metric := range []string{"foo1", "foo2", "bar"} {
+		u := newTestUnit("", metric, "2022-01-01T00:00:00Z", "2022-01-02T00:00:00Z")
+		u.filter.Match = `{__name__="` + metric + `"}`
+		u.srcURL = src.URL + "/api/v1/export/native"
+		u.dstURL = dst.URL + "/api/v1/import/native"
+		u.progress = &metricProgress{pending: 1}
+		p.unitFailed(context.Background(), u, fmt.Errorf("transient error"))
+	}
+	// units waiting for retry are neither completed nor written to the failures file
+	if n := atomic.LoadUint64(&p.progress.completedMetrics); n != 0 {
+		t.Fatalf("unexpected number of completed metrics before retries; got %d; want 0", n)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("expecting no failures file before retries; got %v", err)
+	}
+
+	if err := p.retryFailed(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if n := atomic.LoadInt32(&barExports); n != 2 {
+		t.Fatalf("unexpected number of retries of the failing unit; got %d; want 2", n)
+	}
+	// requests of the same tenant are limited by perTenantCC
+	if n := atomic.LoadInt32(&maxInflight); n != 1 {
+		t.Fatalf("unexpected number of concurrent import requests; got %d; want 1", n)
+	}
+	if n := atomic.LoadUint64(&p.progress.completedMetrics); n != 3 {
+		t.Fatalf("unexpected number of completed metrics; got %d; want 3", n)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("cannot read failures file: %s", err)
+	}
+	// the finally failed unit is written only once
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 1 || !strings.Contains(lines[0], `"metric":"bar"`) {
+		t.Fatalf("unexpected failures file contents:\n%s", data)
+	}
+	if n := p.failures.len(); n != 1 {
+		t.Fatalf("unexpected number of failures; got %d; want 1", n)
+	}
+}
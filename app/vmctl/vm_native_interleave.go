@@ -0,0 +1,64 @@
+package main
+
+// unitInterleaver interleaves units of up to size metrics in round-robin order,
+// so consecutive units belong to different metrics. Otherwise, all the workers
+// could be busy with slow ranges of a single metric, while other metrics wait.
+// Units of every metric keep their order.
+type unitInterleaver struct {
+	size int
+	// queues contains pending units per metric
+	queues [][]*migrationUnit
+	// next is the index of the queue to take the next unit from
+	next int
+}
+
+// newUnitInterleaver returns unitInterleaver for size metrics.
+// Metrics aren't interleaved if size is lower than 2.
+func newUnitInterleaver(size int) *unitInterleaver {
+	if size < 1 {
+		size = 1
+	}
+	return &unitInterleaver{size: size}
+}
+
+// full returns true if no more metrics can be added to ui
+func (ui *unitInterleaver) full() bool {
+	return len(ui.queues) >= ui.size
+}
+
+// add adds units of a single metric to ui
+func (ui *unitInterleaver) add(units []*migrationUnit) {
+	if len(units) == 0 {
+		return
+	}
+	ui.queues = append(ui.queues, units)
+}
+
+// len returns the number of metrics with pending units
+func (ui *unitInterleaver) len() int {
+	return len(ui.queues)
+}
+
+// peek returns the next unit without removing it from ui.
+// It returns nil if there are no pending units.
+func (ui *unitInterleaver) peek() *migrationUnit {
+	if len(ui.queues) == 0 {
+		return nil
+	}
+	if ui.next >= len(ui.queues) {
+		ui.next = 0
+	}
+	return ui.queues[ui.next][0]
+}
+
+// pop removes the unit returned by the last peek call
+func (ui *unitInterleaver) pop() {
+	q := ui.queues[ui.next][1:]
+	if len(q) > 0 {
+		ui.queues[ui.next] = q
+		ui.next++
+		return
+	}
+	// the metric is finished, so the next metric takes its place
+	ui.queues = append(ui.queues[:ui.next], ui.queues[ui.next+1:]...)
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestUnitInterleaver(t *testing.T) {
+	f := func(size int, metricUnits []int, exp []string) {
+		t.Helper()
+		ui := newUnitInterleaver(size)
+		var got []string
+		metric := 0
+		for {
+			for metric < len(metricUnits) && !ui.full() {
+				var units []*migrationUnit
+				for i := 0; i < metricUnits[metric]; i++ {
+					units = append(units, &migrationUnit{metric: fmt.Sprintf("m%d", metric), bucket: fmt.Sprintf("%d", i)})
+				}
+				ui.add(units)
+				metric++
+			}
+			u := ui.peek()
+			if u == nil {
+				break
+			}
+			got = append(got, u.metric+"/"+u.bucket)
+			ui.pop()
+		}
+		if !reflect.DeepEqual(got, exp) {
+			t.Fatalf("unexpected order of units\ngot\n%q\nwant\n%q", got, exp)
+		}
+	}
+	// units of different metrics are interleaved instead of grouped by metric
+	f(3, []int{3, 3, 3}, []string{"m0/0", "m1/0", "m2/0", "m0/1", "m1/1", "m2/1", "m0/2", "m1/2", "m2/2"})
+	// finished metrics are replaced by the next ones, so a metric with many ranges doesn't starve others
+	f(2, []int{4, 1, 2}, []string{"m0/0", "m1/0", "m2/0", "m0/1", "m2/1", "m0/2", "m0/3"})
+	// metrics without units are skipped
+	f(2, []int{1, 0, 2}, []string{"m0/0", "m2/0", "m2/1"})
+	// metrics aren't interleaved for a single worker
+	f(1, []int{2, 2}, []string{"m0/0", "m0/1", "m1/0", "m1/1"})
+	f(0, []int{2, 1}, []string{"m0/0", "m0/1", "m1/0"})
+	f(4, nil, nil)
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxReportedMetrics limits the number of metrics with completed time ranges
+// reported after the migration is interrupted
+const maxReportedMetrics = 20
+
+// completedRanges collects time ranges migrated during the run,
+// so the progress could be reported if the migration is interrupted
+type completedRanges struct {
+	mu sync.Mutex
+	// ranges contains completed time ranges per series key of the unit
+	ranges map[string][][2]time.Time
+	n      int
+}
+
+func newCompletedRanges() *completedRanges {
+	return &completedRanges{ranges: make(map[string][][2]time.Time)}
+}
+
+// add records successfully migrated u. It is no-op if cr is nil.
+func (cr *completedRanges) add(u *migrationUnit) {
+	if cr == nil {
+		return
+	}
+	start, err := time.Parse(time.RFC3339, u.filter.TimeStart)
+	if err != nil {
+		return
+	}
+	end, err := time.Parse(time.RFC3339, u.filter.TimeEnd)
+	if err != nil {
+		return
+	}
+	key := u.metric
+	if u.bucket != "" {
+		key += fmt.Sprintf(" (bucket %s)", u.bucket)
+	}
+	if u.tenantID != "" {
+		key = fmt.Sprintf("tenant %s: %s", u.tenantID, key)
+	}
+
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+	cr.ranges[key] = append(cr.ranges[key], [2]time.Time{start, end})
+	cr.n++
+}
+
+// String returns completed time ranges per metric. Adjacent ranges are merged.
+func (cr *completedRanges) String() string {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+
+	if cr.n == 0 {
+		return "No requests were completed"
+	}
+	keys := make([]string, 0, len(cr.ranges))
+	for k := range cr.ranges {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%d requests for %d metrics were completed:", cr.n, len(keys))
+	for i, k := range keys {
+		if i == maxReportedMetrics {
+			fmt.Fprintf(&sb, "\n  and %d more metrics", len(keys)-i)
+			break
+		}
+		var a []string
+		for _, r := range mergeRanges(cr.ranges[k]) {
+			a = append(a, fmt.Sprintf("%s - %s", r[0].Format(time.RFC3339), r[1].Format(time.RFC3339)))
+		}
+		fmt.Fprintf(&sb, "\n  %s: %s", k, strings.Join(a, ", "))
+	}
+	return sb.String()
+}
+
+// mergeRanges returns sorted ranges with overlapping and adjacent ones merged
+func mergeRanges(ranges [][2]time.Time) [][2]time.Time {
+	sorted := append([][2]time.Time{}, ranges...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i][0].Before(sorted[j][0])
+	})
+	var merged [][2]time.Time
+	for _, r := range sorted {
+		if n := len(merged); n > 0 && !r[0].After(merged[n-1][1]) {
+			if r[1].After(merged[n-1][1]) {
+				merged[n-1][1] = r[1]
+			}
+			continue
+		}
+		merged = append(merged, r)
+	}
+	return merged
+}
+
+// reportInterrupted reports the progress made before the migration was interrupted
+// and returns an error, so vmctl exits with non-zero code
+func (p *vmNativeProcessor) reportInterrupted() error {
+	log.Println("Import interrupted!")
+	log.Print(p.completed)
+	if err := p.printStats(); err != nil {
+		return err
+	}
+	if n := p.failures.len(); n > 0 && p.failuresFile != "" {
+		if err := p.failures.writeFile(p.failuresFile); err != nil {
+			return err
+		}
+		log.Printf("%d interrupted and failed requests are written to %q", n, p.failuresFile)
+	}
+	if p.checkpoint != nil {
+		log.Printf("Run vmctl with the same --%s in order to migrate the remaining data", vmNativeStateFile)
+	}
+	return fmt.Errorf("migration was interrupted")
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestCompletedRanges(t *testing.T) {
+	cr := newCompletedRanges()
+	if s := cr.String(); s != "No requests were completed" {
+		t.Fatalf("unexpected report for empty ranges: %q", s)
+	}
+
+	cr.add(newTestUnit("", "foo", "2022-01-02T00:00:00Z", "2022-01-03T00:00:00Z"))
+	cr.add(newTestUnit("", "foo", "2022-01-01T00:00:00Z", "2022-01-02T00:00:00Z"))
+	cr.add(newTestUnit("", "foo", "2022-01-05T00:00:00Z", "2022-01-06T00:00:00Z"))
+	cr.add(newTestUnit("1:0", "bar", "2022-01-01T00:00:00Z", "2022-01-02T00:00:00Z"))
+	var nilRanges *completedRanges
+	nilRanges.add(newTestUnit("", "foo", "2022-01-01T00:00:00Z", "2022-01-02T00:00:00Z"))
+
+	exp := `4 requests for 2 metrics were completed:
+  foo: 2022-01-01T00:00:00Z - 2022-01-03T00:00:00Z, 2022-01-05T00:00:00Z - 2022-01-06T00:00:00Z
+  tenant 1:0: bar: 2022-01-01T00:00:00Z - 2022-01-02T00:00:00Z`
+	if s := cr.String(); s != exp {
+		t.Fatalf("unexpected report;\ngot\n%s\nwant\n%s", s, exp)
+	}
+}
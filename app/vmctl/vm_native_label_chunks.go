@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// labelChunkAlphabet is the set of label value first characters
+// split between buckets. Values starting with any other character
+// belong to the last bucket, while empty or missing values belong to the first one.
+const labelChunkAlphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// labelChunkConfig defines splitting of a metric export
+// into buckets by the first character of the label value.
+type labelChunkConfig struct {
+	label   string
+	buckets int
+}
+
+// parseLabelChunkConfig parses `name:buckets` string
+func parseLabelChunkConfig(s string) (*labelChunkConfig, error) {
+	if s == "" {
+		return nil, nil
+	}
+	n := strings.LastIndexByte(s, ':')
+	if n <= 0 {
+		return nil, fmt.Errorf("cannot parse --%s=%q; expecting `name:buckets` format", vmNativeChunkByLabel, s)
+	}
+	buckets, err := strconv.Atoi(s[n+1:])
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse the number of buckets in --%s=%q: %w", vmNativeChunkByLabel, s, err)
+	}
+	if buckets < 2 || buckets > len(labelChunkAlphabet) {
+		return nil, fmt.Errorf("the number of buckets in --%s=%q must be in range [2..%d]", vmNativeChunkByLabel, s, len(labelChunkAlphabet))
+	}
+	return &labelChunkConfig{
+		label:   s[:n],
+		buckets: buckets,
+	}, nil
+}
+
+// regexps returns regular expressions for matching label values of every bucket.
+// Every label value, including empty one, is matched by exactly one of them.
+func (lc *labelChunkConfig) regexps() []string {
+	res := make([]string, 0, lc.buckets)
+	for i := 0; i < lc.buckets; i++ {
+		start := i * len(labelChunkAlphabet) / lc.buckets
+		end := (i + 1) * len(labelChunkAlphabet) / lc.buckets
+		var alts []string
+		if i == 0 {
+			// empty value matches series without the label
+			alts = append(alts, "")
+		}
+		alts = append(alts, "["+labelChunkAlphabet[start:end]+"].*")
+		if i == lc.buckets-1 {
+			alts = append(alts, "[^"+labelChunkAlphabet+"].*")
+		}
+		// (?s) makes `.` to match new lines in label values
+		res = append(res, "(?s)("+strings.Join(alts, "|")+")")
+	}
+	return res
+}
+
+// addLabelMatcher adds `label=~"re"` matcher to the given series selector
+func addLabelMatcher(match, label, re string) string {
+	matcher := label + "=~" + strconv.Quote(re)
+	match = strings.TrimSuffix(match, "}")
+	if strings.HasSuffix(match, "{") {
+		return match + matcher + "}"
+	}
+	return match + "," + matcher + "}"
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestParseLabelChunkConfig(t *testing.T) {
+	f := func(s string, expLabel string, expBuckets int, expErr bool) {
+		t.Helper()
+		lc, err := parseLabelChunkConfig(s)
+		if expErr {
+			if err == nil {
+				t.Fatalf("expecting error for %q", s)
+			}
+			return
+		}
+		if err != nil {
+			t.Fatalf("unexpected error for %q: %s", s, err)
+		}
+		if lc.label != expLabel || lc.buckets != expBuckets {
+			t.Fatalf("unexpected config for %q; got %s:%d; want %s:%d", s, lc.label, lc.buckets, expLabel, expBuckets)
+		}
+	}
+	f("instance:16", "instance", 16, false)
+	f("instance", "", 0, true)
+	f(":16", "", 0, true)
+	f("instance:foo", "", 0, true)
+	f("instance:1", "", 0, true)
+	f("instance:100", "", 0, true)
+}
+
+func TestLabelChunkRegexps(t *testing.T) {
+	values := []string{"", "0", "9abc", "A", "Zz", "a", "localhost:9090", "z", "_foo", "-", "фу", "\nfoo", "foo\nbar"}
+	for _, buckets := range []int{2, 3, 7, 16, 62} {
+		lc := &labelChunkConfig{label: "instance", buckets: buckets}
+		var res []*regexp.Regexp
+		for _, re := range lc.regexps() {
+			res = append(res, regexp.MustCompile("^(?:"+re+")$"))
+		}
+		for _, v := range values {
+			matched := 0
+			for _, re := range res {
+				if re.MatchString(v) {
+					matched++
+				}
+			}
+			if matched != 1 {
+				t.Fatalf("value %q is matched by %d buckets out of %d; want 1", v, matched, buckets)
+			}
+		}
+	}
+}
+
+func TestAddLabelMatcher(t *testing.T) {
+	f := func(match, exp string) {
+		t.Helper()
+		got := addLabelMatcher(match, "instance", "[0-9].*")
+		if got != exp {
+			t.Fatalf("unexpected match; got %s; want %s", got, exp)
+		}
+	}
+	f(`{__name__="foo"}`, `{__name__="foo",instance=~"[0-9].*"}`)
+	f(`{}`, `{instance=~"[0-9].*"}`)
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// labelFilters holds values of --vm-native-label-filter flag.
+//
+// Every occurrence of the flag adds `label=value` constraint. Values of the same label
+// are matched via regexp OR, while constraints on distinct labels must all match.
+type labelFilters struct {
+	// labels contains filtered labels in the order of their first occurrence
+	labels []string
+	values map[string][]string
+}
+
+// Set implements flag.Value interface
+func (lf *labelFilters) Set(s string) error {
+	label, value, ok := strings.Cut(s, "=")
+	label = strings.TrimSpace(label)
+	if !ok || label == "" {
+		return fmt.Errorf("cannot parse label filter %q; it must have `label=value` form", s)
+	}
+	if lf.values == nil {
+		lf.values = make(map[string][]string)
+	}
+	values, ok := lf.values[label]
+	if !ok {
+		lf.labels = append(lf.labels, label)
+	}
+	for _, v := range values {
+		if v == value {
+			return nil
+		}
+	}
+	lf.values[label] = append(values, value)
+	return nil
+}
+
+// String implements flag.Value interface
+func (lf *labelFilters) String() string {
+	if lf == nil {
+		return ""
+	}
+	return lf.matchers()
+}
+
+// matchers returns comma-separated label matchers for lf, e.g. `job="foo",instance=~"bar|baz"`
+func (lf *labelFilters) matchers() string {
+	if lf == nil {
+		return ""
+	}
+	var matchers []string
+	for _, label := range lf.labels {
+		values := lf.values[label]
+		if len(values) == 1 {
+			matchers = append(matchers, label+"="+strconv.Quote(values[0]))
+			continue
+		}
+		alts := make([]string, len(values))
+		for i, v := range values {
+			alts[i] = regexp.QuoteMeta(v)
+		}
+		matchers = append(matchers, label+"=~"+strconv.Quote(strings.Join(alts, "|")))
+	}
+	return strings.Join(matchers, ",")
+}
+
+// addTo returns the series selector match with lf matchers added to it
+func (lf *labelFilters) addTo(match string) string {
+	matchers := lf.matchers()
+	if matchers == "" {
+		return match
+	}
+	if !strings.HasSuffix(match, "}") {
+		// metric name selector, e.g. `foo`
+		return match + "{" + matchers + "}"
+	}
+	match = strings.TrimSuffix(match, "}")
+	if strings.HasSuffix(match, "{") {
+		return match + matchers + "}"
+	}
+	return match + "," + matchers + "}"
+}
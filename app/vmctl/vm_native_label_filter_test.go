@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmctl/native"
+)
+
+func TestLabelFilters(t *testing.T) {
+	var lf labelFilters
+	for _, s := range []string{"job=node", "instance=a:9100", "job=vm.agent", "job=node", "env=a,b"} {
+		if err := lf.Set(s); err != nil {
+			t.Fatalf("unexpected error for %q: %s", s, err)
+		}
+	}
+	// values of the same label are joined via regexp OR, duplicates are dropped
+	exp := `job=~"node|vm\\.agent",instance="a:9100",env="a,b"`
+	if got := lf.matchers(); got != exp {
+		t.Fatalf("unexpected matchers; got %s; want %s", got, exp)
+	}
+
+	f := func(match, exp string) {
+		t.Helper()
+		if got := lf.addTo(match); got != exp {
+			t.Fatalf("unexpected selector for %s; got %s; want %s", match, got, exp)
+		}
+	}
+	f(`{__name__!=""}`, `{__name__!="",`+exp+`}`)
+	f(`{}`, `{`+exp+`}`)
+	f(`foo`, `foo{`+exp+`}`)
+
+	var nilFilters *labelFilters
+	if got := nilFilters.addTo(`foo`); got != `foo` {
+		t.Fatalf("unexpected selector for nil filters; got %s", got)
+	}
+
+	for _, s := range []string{"job", "=node", " =node"} {
+		if err := lf.Set(s); err == nil {
+			t.Fatalf("expecting error for %q", s)
+		}
+	}
+}
+
+func TestBuildMatchWithLabelFilters(t *testing.T) {
+	var lf labelFilters
+	for _, s := range []string{"env=prod", "job=a", "job=b"} {
+		if err := lf.Set(s); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+	got, err := buildMatchWithFilter(`{cluster="kube1"}`, "foo", &lf)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if exp := `{cluster="kube1",__name__="foo",env="prod",job=~"a|b"}`; got != exp {
+		t.Fatalf("unexpected match; got %s; want %s", got, exp)
+	}
+
+	matches, err := buildMatchesWithFilters([]string{`{job="a",env="prod"}`, `{job="a"}`, `{env="dev"}`}, "foo", &lf)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	exp := []string{
+		`{env="dev",__name__="foo",env="prod",job=~"a|b"}`,
+		`{job="a",__name__="foo",env="prod",job=~"a|b"}`,
+	}
+	if !reflect.DeepEqual(matches, exp) {
+		t.Fatalf("unexpected matches; got %q; want %q", matches, exp)
+	}
+}
+
+func TestExploreWithLabelFilters(t *testing.T) {
+	var gotMatch string
+	src := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotMatch = r.URL.Query().Get("match[]")
+		_, _ = w.Write([]byte(`{"status":"success","data":[{"__name__":"foo","job":"a"}]}`))
+	}))
+	defer src.Close()
+
+	lf := &labelFilters{}
+	if err := lf.Set("job=a"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	p := &vmNativeProcessor{
+		src:          &native.Client{Addr: src.URL},
+		matches:      []string{`{env="prod"}`},
+		filter:       native.Filter{Match: `{env="prod"}`},
+		labelFilters: lf,
+	}
+	if _, err := p.exploreTenant(context.Background(), ""); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if exp := `{env="prod",job="a"}`; gotMatch != exp {
+		t.Fatalf("unexpected discovery selector; got %s; want %s", gotMatch, exp)
+	}
+	matches, err := p.metricMatches("", "foo")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if exp := []string{`{env="prod",__name__="foo",job="a"}`}; !reflect.DeepEqual(matches, exp) {
+		t.Fatalf("unexpected export selectors; got %q; want %q", matches, exp)
+	}
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompbmarshal"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promutils"
+	"github.com/urfave/cli/v2"
+)
+
+// defaultFilterMatch is the default value of --vm-native-filter-match flag
+const defaultFilterMatch = `{__name__!=""}`
+
+// filterMatches returns selectors set via --vm-native-filter-match flag or the default selector
+func filterMatches(c *cli.Context) []string {
+	if ms, ok := c.Generic(vmNativeFilterMatch).(*matchSelectors); ok && len(ms.selectors) > 0 {
+		return ms.selectors
+	}
+	return []string{defaultFilterMatch}
+}
+
+// matchSelectors holds values of --vm-native-filter-match flag.
+//
+// It implements flag.Value, so every occurrence of the flag adds the whole value
+// as a selector. cli.StringSliceFlag can't be used, since it splits values by commas,
+// which separate label matchers of selectors as well.
+type matchSelectors struct {
+	selectors []string
+}
+
+// Set implements flag.Value interface
+func (ms *matchSelectors) Set(s string) error {
+	ms.selectors = append(ms.selectors, s)
+	return nil
+}
+
+// String implements flag.Value interface
+func (ms *matchSelectors) String() string {
+	if ms == nil {
+		return ""
+	}
+	return strings.Join(ms.selectors, " or ")
+}
+
+// matchString returns all the series selectors of p as a string
+func (p *vmNativeProcessor) matchString() string {
+	if len(p.matches) > 1 {
+		return strings.Join(p.matches, " or ")
+	}
+	return p.filter.Match
+}
+
+// exploreMatches discovers metrics matching any of p.matches for the given tenant.
+// It records selectors which matched every metric, so per-metric filters
+// preserve label matchers of those selectors only.
+func (p *vmNativeProcessor) exploreMatches(ctx context.Context, tenantID string) (map[string]struct{}, error) {
+	metrics := make(map[string]struct{})
+	selectors := make(map[string][]string)
+	for _, match := range p.matches {
+		f := p.filter
+		f.Match = match
+		names, err := p.exploreSelector(ctx, f, tenantID)
+		if err != nil {
+			return nil, fmt.Errorf("cannot explore metrics for selector %s: %w", match, err)
+		}
+		for name := range names {
+			metrics[name] = struct{}{}
+			selectors[name] = append(selectors[name], match)
+		}
+	}
+
+	p.metricSelectorsMu.Lock()
+	if p.metricSelectors == nil {
+		p.metricSelectors = make(map[string]map[string][]string)
+	}
+	p.metricSelectors[tenantID] = selectors
+	p.metricSelectorsMu.Unlock()
+	return metrics, nil
+}
+
+// metricMatches returns series selectors for exporting metric of the given tenant.
+//
+// Every selector, which matched the metric during exploration, results in a separate selector.
+// Selectors covered by other selectors are dropped, since their series are exported anyway.
+func (p *vmNativeProcessor) metricMatches(tenantID, metric string) ([]string, error) {
+	if len(p.matches) <= 1 {
+		match, err := buildMatchWithFilter(p.filter.Match, metric, p.labelFilters)
+		if err != nil {
+			return nil, err
+		}
+		return []string{match}, nil
+	}
+
+	p.metricSelectorsMu.Lock()
+	selectors := p.metricSelectors[tenantID][metric]
+	p.metricSelectorsMu.Unlock()
+	if len(selectors) == 0 {
+		// the metric wasn't discovered during exploration, e.g. it is set in migration plan
+		selectors = p.matches
+	}
+	return buildMatchesWithFilters(selectors, metric, p.labelFilters)
+}
+
+// metricRequests returns the number of requests per time range and bucket for migrating metrics of the given tenant
+func (p *vmNativeProcessor) metricRequests(tenantID string, metrics map[string]struct{}) int {
+	if len(p.matches) <= 1 {
+		return len(metrics)
+	}
+	var n int
+	for metric := range metrics {
+		matches, err := p.metricMatches(tenantID, metric)
+		if err != nil {
+			// the metric is skipped with error during migration
+			continue
+		}
+		n += len(matches)
+	}
+	return n
+}
+
+// buildMatchesWithFilters returns selectors for metricName built from filters.
+// Duplicate selectors and selectors with all the label matchers of another selector
+// are dropped, since all their series are matched by another selector.
+// Matchers from optional lf are added to every returned selector.
+func buildMatchesWithFilters(filters []string, metricName string, lf *labelFilters) ([]string, error) {
+	var all []string
+	var matchers [][]prompbmarshal.Label
+	for _, filter := range filters {
+		// lf is added after checking for covered selectors, since it is the same for all of them
+		match, err := buildMatchWithFilter(filter, metricName, nil)
+		if err != nil {
+			return nil, err
+		}
+		labels, err := promutils.NewLabelsFromString(match)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, match)
+		matchers = append(matchers, labels.GetLabels())
+	}
+
+	var matches []string
+	for i := range all {
+		covered := false
+		for j := range all {
+			if i == j || !isLabelsSubset(matchers[j], matchers[i]) {
+				continue
+			}
+			// equal selectors are covered by the first of them
+			if !isLabelsSubset(matchers[i], matchers[j]) || j < i {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			matches = append(matches, lf.addTo(all[i]))
+		}
+	}
+	sort.Strings(matches)
+	return matches, nil
+}
+
+// isLabelsSubset returns true if all the labels from a are contained in b
+func isLabelsSubset(a, b []prompbmarshal.Label) bool {
+	for _, la := range a {
+		found := false
+		for _, lb := range b {
+			if la.Name == lb.Name && la.Value == lb.Value {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmctl/native"
+)
+
+func TestMatchSelectors(t *testing.T) {
+	var ms matchSelectors
+	for _, s := range []string{`{job="a",env="prod"}`, `foo`} {
+		if err := ms.Set(s); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+	// values mustn't be split by commas
+	if exp := []string{`{job="a",env="prod"}`, `foo`}; !reflect.DeepEqual(ms.selectors, exp) {
+		t.Fatalf("unexpected selectors; got %q; want %q", ms.selectors, exp)
+	}
+	if exp := `{job="a",env="prod"} or foo`; ms.String() != exp {
+		t.Fatalf("unexpected string; got %q; want %q", ms.String(), exp)
+	}
+}
+
+func TestBuildMatchesWithFilters(t *testing.T) {
+	f := func(filters []string, exp []string) {
+		t.Helper()
+		got, err := buildMatchesWithFilters(filters, "foo", nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if !reflect.DeepEqual(got, exp) {
+			t.Fatalf("unexpected matches for %q; got %q; want %q", filters, got, exp)
+		}
+	}
+	f([]string{`{job="a"}`}, []string{`{job="a",__name__="foo"}`})
+	// different label matchers are preserved
+	f([]string{`{job="a"}`, `{env="prod"}`}, []string{`{env="prod",__name__="foo"}`, `{job="a",__name__="foo"}`})
+	// duplicate selectors are dropped
+	f([]string{`{job="a"}`, `{job="a"}`}, []string{`{job="a",__name__="foo"}`})
+	// selectors covered by broader selectors are dropped
+	f([]string{`{job="a",env="prod"}`, `{job="a"}`}, []string{`{job="a",__name__="foo"}`})
+	f([]string{`{job="a"}`, `{env="prod"}`, `foo`}, []string{`{__name__="foo"}`})
+
+	if _, err := buildMatchesWithFilters([]string{`{job="a"`}, "foo", nil); err == nil {
+		t.Fatalf("expecting error for invalid selector")
+	}
+}
+
+func TestExploreMatches(t *testing.T) {
+	series := map[string]string{
+		`{job="a"}`:    `[{"__name__":"foo","job":"a"},{"__name__":"bar","job":"a"}]`,
+		`{env="prod"}`: `[{"__name__":"foo","env":"prod"},{"__name__":"baz","env":"prod"}]`,
+	}
+	src := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data := series[r.URL.Query().Get("match[]")]
+		if data == "" {
+			data = "[]"
+		}
+		_, _ = w.Write([]byte(`{"status":"success","data":` + data + `}`))
+	}))
+	defer src.Close()
+
+	p := &vmNativeProcessor{
+		src:     &native.Client{Addr: src.URL},
+		matches: []string{`{job="a"}`, `{env="prod"}`},
+		filter:  native.Filter{Match: `{job="a"}`},
+	}
+	metrics, err := p.exploreTenant(context.Background(), "")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	// metrics matched by both selectors are counted once
+	if exp := map[string]struct{}{"foo": {}, "bar": {}, "baz": {}}; !reflect.DeepEqual(metrics, exp) {
+		t.Fatalf("unexpected metrics; got %v; want %v", metrics, exp)
+	}
+
+	f := func(metric string, exp []string) {
+		t.Helper()
+		got, err := p.metricMatches("", metric)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if !reflect.DeepEqual(got, exp) {
+			t.Fatalf("unexpected matches for metric %q; got %q; want %q", metric, got, exp)
+		}
+	}
+	f("bar", []string{`{job="a",__name__="bar"}`})
+	f("baz", []string{`{env="prod",__name__="baz"}`})
+	f("foo", []string{`{env="prod",__name__="foo"}`, `{job="a",__name__="foo"}`})
+
+	ranges := [][]time.Time{{time.Unix(0, 0), time.Unix(3600, 0)}, {time.Unix(3600, 0), time.Unix(7200, 0)}}
+	if got := p.totalRequests(map[string]map[string]struct{}{"": metrics}, ranges); got != 8 {
+		t.Fatalf("unexpected number of requests; got %d; want 8", got)
+	}
+}
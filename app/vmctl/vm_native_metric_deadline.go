@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+)
+
+var errMetricDeadline = errors.New("metric deadline exceeded")
+
+// metricDeadline bounds the overall time of migrating a single metric.
+// The clock starts when the first unit of the metric starts migrating.
+type metricDeadline struct {
+	metric  string
+	timeout time.Duration
+	// units is the number of units of the metric
+	units int
+
+	mu       sync.Mutex
+	deadline time.Time
+
+	// skipped is the number of units skipped or interrupted because of the deadline
+	skipped int32
+}
+
+func newMetricDeadline(metric string, timeout time.Duration) *metricDeadline {
+	if timeout <= 0 {
+		return nil
+	}
+	return &metricDeadline{
+		metric:  metric,
+		timeout: timeout,
+	}
+}
+
+// context returns ctx bounded by the deadline of the metric
+func (md *metricDeadline) context(ctx context.Context) (context.Context, context.CancelFunc) {
+	if md == nil {
+		return ctx, func() {}
+	}
+	md.mu.Lock()
+	if md.deadline.IsZero() {
+		md.deadline = time.Now().Add(md.timeout)
+	}
+	deadline := md.deadline
+	md.mu.Unlock()
+	return context.WithDeadline(ctx, deadline)
+}
+
+// expired returns true if the deadline of the metric is exceeded
+func (md *metricDeadline) expired() bool {
+	if md == nil {
+		return false
+	}
+	md.mu.Lock()
+	defer md.mu.Unlock()
+	return !md.deadline.IsZero() && time.Now().After(md.deadline)
+}
+
+// skipUnit records u as skipped because of the exceeded deadline of its metric
+func (p *vmNativeProcessor) skipUnit(u *migrationUnit) {
+	atomic.AddInt32(&u.deadline.skipped, 1)
+	p.failures.add(u, fmt.Errorf("skipped because --%s=%s is exceeded for metric %q", vmNativeMetricDeadline, u.deadline.timeout, u.metric))
+}
+
+// reportDeadlines logs metrics with units skipped because of exceeded deadline
+func reportDeadlines(deadlines []*metricDeadline) {
+	for _, md := range deadlines {
+		if n := atomic.LoadInt32(&md.skipped); n > 0 {
+			logger.Warnf("metric %q exceeded --%s=%s: %d of %d time ranges were skipped and recorded as failed requests",
+				md.metric, vmNativeMetricDeadline, md.timeout, n, md.units)
+		}
+	}
+}
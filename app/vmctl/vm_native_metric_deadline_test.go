@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMetricDeadline(t *testing.T) {
+	if md := newMetricDeadline("foo", 0); md != nil {
+		t.Fatalf("expecting nil deadline for zero timeout")
+	}
+	var nilDeadline *metricDeadline
+	if nilDeadline.expired() {
+		t.Fatalf("nil deadline mustn't expire")
+	}
+
+	md := newMetricDeadline("foo", 50*time.Millisecond)
+	time.Sleep(60 * time.Millisecond)
+	if md.expired() {
+		t.Fatalf("deadline mustn't expire before the first unit starts")
+	}
+
+	ctx, cancel := md.context(context.Background())
+	defer cancel()
+	if md.expired() {
+		t.Fatalf("deadline mustn't expire right after the start")
+	}
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatalf("context must be canceled after the deadline")
+	}
+	if !md.expired() {
+		t.Fatalf("deadline must expire after the timeout")
+	}
+
+	// units started after the first one share the same deadline
+	ctx2, cancel2 := md.context(context.Background())
+	defer cancel2()
+	if ctx2.Err() == nil {
+		t.Fatalf("context of the next unit must be already canceled")
+	}
+
+	p := &vmNativeProcessor{}
+	u := newTestUnit("", "foo", "2022-01-01T00:00:00Z", "2022-01-02T00:00:00Z")
+	u.deadline = md
+	p.skipUnit(u)
+	if md.skipped != 1 || p.failures.len() != 1 {
+		t.Fatalf("unexpected number of skipped units: %d; failures: %d", md.skipped, p.failures.len())
+	}
+}
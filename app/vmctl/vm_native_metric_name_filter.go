@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+)
+
+// metricNameFilter filters discovered metric names on the client side,
+// so a subset of metrics matching a broad series selector can be migrated.
+type metricNameFilter struct {
+	include *regexp.Regexp
+	exclude *regexp.Regexp
+}
+
+// newMetricNameFilter returns metricNameFilter for the given regexps matching the whole metric name.
+// It returns nil if both regexps are empty.
+func newMetricNameFilter(include, exclude string) (*metricNameFilter, error) {
+	if include == "" && exclude == "" {
+		return nil, nil
+	}
+	var mf metricNameFilter
+	var err error
+	if mf.include, err = compileMetricNameRegexp(vmNativeMetricNameFilter, include); err != nil {
+		return nil, err
+	}
+	if mf.exclude, err = compileMetricNameRegexp(vmNativeMetricNameExclude, exclude); err != nil {
+		return nil, err
+	}
+	return &mf, nil
+}
+
+func compileMetricNameRegexp(flag, expr string) (*regexp.Regexp, error) {
+	if expr == "" {
+		return nil, nil
+	}
+	re, err := regexp.Compile("^(?:" + expr + ")$")
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse --%s=%q: %w", flag, expr, err)
+	}
+	return re, nil
+}
+
+// match returns true if name must be migrated.
+// It returns true for nil mf.
+func (mf *metricNameFilter) match(name string) bool {
+	if mf == nil {
+		return true
+	}
+	if mf.include != nil && !mf.include.MatchString(name) {
+		return false
+	}
+	return mf.exclude == nil || !mf.exclude.MatchString(name)
+}
+
+// filter returns metrics matching mf and logs the number of filtered out metrics.
+// It returns metrics as is for nil mf.
+func (mf *metricNameFilter) filter(tenantID string, metrics map[string]struct{}) map[string]struct{} {
+	if mf == nil || len(metrics) == 0 {
+		return metrics
+	}
+	filtered := make(map[string]struct{}, len(metrics))
+	for name := range metrics {
+		if mf.match(name) {
+			filtered[name] = struct{}{}
+		}
+	}
+	logMetricsFilteredOut(tenantID, len(metrics)-len(filtered), len(metrics))
+	return filtered
+}
+
+func logMetricsFilteredOut(tenantID string, filtered, total int) {
+	msg := fmt.Sprintf("%d of %d discovered metrics were filtered out by --%s and --%s", filtered, total, vmNativeMetricNameFilter, vmNativeMetricNameExclude)
+	if tenantID != "" {
+		msg += " for tenant " + tenantID
+	}
+	log.Print(msg)
+}
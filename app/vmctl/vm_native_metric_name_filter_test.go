@@ -0,0 +1,52 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMetricNameFilter(t *testing.T) {
+	metrics := map[string]struct{}{
+		"node_cpu":         {},
+		"node_scrape_time": {},
+		"go_goroutines":    {},
+		"xnode_cpu":        {},
+	}
+	f := func(include, exclude string, exp []string) {
+		t.Helper()
+		mf, err := newMetricNameFilter(include, exclude)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		got := mf.filter("", metrics)
+		if len(got) != len(exp) {
+			t.Fatalf("unexpected metrics for include=%q, exclude=%q; got %v; want %q", include, exclude, got, exp)
+		}
+		for _, name := range exp {
+			if _, ok := got[name]; !ok {
+				t.Fatalf("missing metric %q for include=%q, exclude=%q; got %v", name, include, exclude, got)
+			}
+		}
+	}
+	f("", "", []string{"node_cpu", "node_scrape_time", "go_goroutines", "xnode_cpu"})
+	// the regexps match the whole name
+	f("node_.*", "", []string{"node_cpu", "node_scrape_time"})
+	f("", "node_.*", []string{"go_goroutines", "xnode_cpu"})
+	f("node_.*", "node_scrape_.*", []string{"node_cpu"})
+	f("node_.*|go_.*", "go_goroutines", []string{"node_cpu", "node_scrape_time"})
+	f("foo", "", nil)
+
+	if _, err := newMetricNameFilter("node_(", ""); err == nil {
+		t.Fatalf("expecting error for invalid regexp")
+	}
+	if _, err := newMetricNameFilter("", "node_("); err == nil {
+		t.Fatalf("expecting error for invalid regexp")
+	}
+	var mf *metricNameFilter
+	if got := mf.filter("", metrics); !reflect.DeepEqual(got, metrics) {
+		t.Fatalf("nil filter must return metrics as is; got %v", got)
+	}
+	if !mf.match("foo") {
+		t.Fatalf("nil filter must match any name")
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/metrics"
+)
+
+// progressMetrics exposes the progress of the native migration
+// in Prometheus text exposition format. It is nil if disabled.
+type progressMetrics struct {
+	bytes    *metrics.Counter
+	requests *metrics.Counter
+	retries  *metrics.Counter
+
+	// tenantIndex is the 1-based index of the last started tenant
+	tenantIndex      uint64
+	completedMetrics uint64
+	totalMetrics     uint64
+}
+
+// newProgressMetrics registers progress metrics in set.
+// source is the 1-based index of the source in migration from multiple sources,
+// it is added as a label to all the metrics if greater than 0.
+func newProgressMetrics(set *metrics.Set, source int) *progressMetrics {
+	var labels string
+	if source > 0 {
+		labels = fmt.Sprintf(`{source="%d"}`, source)
+	}
+	pm := &progressMetrics{
+		bytes:    set.NewCounter("vmctl_native_bytes_total" + labels),
+		requests: set.NewCounter("vmctl_native_requests_total" + labels),
+		retries:  set.NewCounter("vmctl_native_retries_total" + labels),
+	}
+	set.NewGauge("vmctl_native_tenant_index"+labels, func() float64 {
+		return float64(atomic.LoadUint64(&pm.tenantIndex))
+	})
+	set.NewGauge("vmctl_native_metrics_completed"+labels, func() float64 {
+		return float64(atomic.LoadUint64(&pm.completedMetrics))
+	})
+	set.NewGauge("vmctl_native_metrics_total"+labels, func() float64 {
+		return float64(atomic.LoadUint64(&pm.totalMetrics))
+	})
+	return pm
+}
+
+// update sets counters according to s
+func (pm *progressMetrics) update(s *stats) {
+	if pm == nil {
+		return
+	}
+	bytes, requests, retries := s.counters()
+	pm.bytes.Set(bytes)
+	pm.requests.Set(requests)
+	pm.retries.Set(retries)
+}
+
+func (pm *progressMetrics) setTenantIndex(idx int) {
+	if pm == nil {
+		return
+	}
+	atomic.StoreUint64(&pm.tenantIndex, uint64(idx))
+}
+
+func (pm *progressMetrics) addTotalMetrics(n int) {
+	if pm == nil {
+		return
+	}
+	atomic.AddUint64(&pm.totalMetrics, uint64(n))
+}
+
+func (pm *progressMetrics) metricCompleted() {
+	if pm == nil {
+		return
+	}
+	atomic.AddUint64(&pm.completedMetrics, 1)
+}
+
+// metricProgress tracks the number of not processed units of a metric
+type metricProgress struct {
+	pending int32
+}
+
+// unitProcessed must be called once u is processed successfully or not
+func (pm *progressMetrics) unitProcessed(u *migrationUnit) {
+	if pm == nil || u.progress == nil {
+		return
+	}
+	if atomic.AddInt32(&u.progress.pending, -1) == 0 {
+		pm.metricCompleted()
+	}
+}
+
+// startMetricsServer starts HTTP server exposing metrics from set at /metrics path of addr
+func startMetricsServer(addr string, set *metrics.Set) (*http.Server, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("cannot listen on --%s=%q: %w", vmNativeMetricsListenAddr, addr, err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, _ *http.Request) {
+		set.WritePrometheus(w)
+		metrics.WriteProcessMetrics(w)
+	})
+	srv := &http.Server{Handler: mux}
+	go func() {
+		if err := srv.Serve(ln); err != nil && err != http.ErrServerClosed {
+			logger.Errorf("metrics server at %q stopped: %s", addr, err)
+		}
+	}()
+	log.Printf("Exposing migration progress metrics at http://%s/metrics", ln.Addr())
+	return srv, nil
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/VictoriaMetrics/metrics"
+)
+
+func TestProgressMetrics(t *testing.T) {
+	set := metrics.NewSet()
+	pm := newProgressMetrics(set, 2)
+
+	s := &stats{bytes: 1024, requests: 3, retries: 1}
+	pm.update(s)
+	pm.setTenantIndex(1)
+	pm.addTotalMetrics(2)
+
+	mp := &metricProgress{pending: 2}
+	u1, u2 := &migrationUnit{progress: mp}, &migrationUnit{progress: mp}
+	pm.unitProcessed(u1)
+	if pm.completedMetrics != 0 {
+		t.Fatalf("metric mustn't be completed until all its units are processed")
+	}
+	pm.unitProcessed(u2)
+	// units without progress don't affect completed metrics
+	pm.unitProcessed(&migrationUnit{})
+
+	var bb bytes.Buffer
+	set.WritePrometheus(&bb)
+	got := bb.String()
+	for _, exp := range []string{
+		`vmctl_native_bytes_total{source="2"} 1024`,
+		`vmctl_native_requests_total{source="2"} 3`,
+		`vmctl_native_retries_total{source="2"} 1`,
+		`vmctl_native_tenant_index{source="2"} 1`,
+		`vmctl_native_metrics_completed{source="2"} 1`,
+		`vmctl_native_metrics_total{source="2"} 2`,
+	} {
+		if !strings.Contains(got, exp+"\n") {
+			t.Fatalf("missing %q in exposed metrics:\n%s", exp, got)
+		}
+	}
+
+	// nil progress metrics must be ignored
+	var nilPM *progressMetrics
+	nilPM.update(s)
+	nilPM.setTenantIndex(1)
+	nilPM.unitProcessed(u1)
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// sourceFilePath returns path of the file or directory for the given source
+// in multi-source migration, so sources don't share state with each other.
+// path is returned as is if source is zero.
+func sourceFilePath(path string, source int) string {
+	if path == "" || source == 0 {
+		return path
+	}
+	return fmt.Sprintf("%s.source-%d", path, source)
+}
+
+// runMultiSource runs migration from all the ps concurrently into a single destination.
+// It prints stats per each source and the combined summary.
+// Success marker is written to successFile only if migration from all the sources succeeded.
+func runMultiSource(ctx context.Context, ps []*vmNativeProcessor, successFile string) error {
+	if successFile != "" {
+		if err := os.Remove(successFile); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("cannot remove %q: %w", successFile, err)
+		}
+	}
+
+	startTime := time.Now()
+	errs := make([]error, len(ps))
+	var wg sync.WaitGroup
+	for i := range ps {
+		i := i
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			log.Printf("Starting migration from source #%d %q", i+1, ps[i].src.Addr)
+			errs[i] = ps[i].run(ctx, true)
+			if errs[i] != nil {
+				log.Printf("migration from source #%d %q failed: %s", i+1, ps[i].src.Addr, errs[i])
+			}
+		}()
+	}
+	wg.Wait()
+
+	log.Print(multiSourceSummary(ps, errs, time.Since(startTime)))
+
+	var failed []string
+	for i, err := range errs {
+		if err != nil {
+			failed = append(failed, ps[i].src.Addr)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("migration failed for %d of %d sources: %s", len(failed), len(ps), strings.Join(failed, ", "))
+	}
+	for _, p := range ps {
+		if p.budgetReached() {
+			// migration isn't complete
+			return nil
+		}
+	}
+	return writeMultiSourceSuccessFile(successFile, ps, startTime)
+}
+
+// multiSourceSummary returns stats per each source followed by the combined stats
+func multiSourceSummary(ps []*vmNativeProcessor, errs []error, duration time.Duration) string {
+	var bytes, requests, retries uint64
+	var sb strings.Builder
+	sb.WriteString("Multi-source migration stats:\n")
+	for i, p := range ps {
+		status := "ok"
+		if errs[i] != nil {
+			status = "failed"
+		}
+		var b, r, rt uint64
+		if p.s != nil {
+			p.s.Lock()
+			b, r, rt = p.s.bytes, p.s.requests, p.s.retries
+			p.s.Unlock()
+		}
+		bytes += b
+		requests += r
+		retries += rt
+		fmt.Fprintf(&sb, "  source #%d %q: %s; total bytes: %s; requests: %d; requests retries: %d;\n",
+			i+1, p.src.Addr, status, byteCountSI(int64(b)), r, rt)
+	}
+	bytesPerS := byteCountSI(0)
+	if s := duration.Seconds(); bytes > 0 && s > 0 {
+		bytesPerS = byteCountSI(int64(float64(bytes) / s))
+	}
+	fmt.Fprintf(&sb, "  combined: time spent while importing: %v; total bytes: %s; bytes/s: %s; requests: %d; requests retries: %d;",
+		duration, byteCountSI(int64(bytes)), bytesPerS, requests, retries)
+	return sb.String()
+}
+
+// writeMultiSourceSuccessFile atomically writes the marker of successful migration from all the ps to path
+func writeMultiSourceSuccessFile(path string, ps []*vmNativeProcessor, startTime time.Time) error {
+	if path == "" {
+		return nil
+	}
+	m := successMarker{
+		StartedAt:  startTime.UTC().Format(time.RFC3339),
+		FinishedAt: time.Now().UTC().Format(time.RFC3339),
+	}
+	h := sha256.New()
+	for _, p := range ps {
+		h.Write([]byte(p.paramsHash()))
+		p.s.Lock()
+		m.Stats.Bytes += p.s.bytes
+		m.Stats.Requests += p.s.requests
+		m.Stats.Retries += p.s.retries
+		p.s.Unlock()
+	}
+	m.ParamsHash = hex.EncodeToString(h.Sum(nil))
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("cannot marshal success marker: %w", err)
+	}
+	if err := writeFileAtomic(path, data); err != nil {
+		return fmt.Errorf("cannot write success marker: %w", err)
+	}
+	return nil
+}
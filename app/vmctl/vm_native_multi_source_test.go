@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmctl/native"
+)
+
+func TestSourceFilePath(t *testing.T) {
+	f := func(path string, source int, want string) {
+		t.Helper()
+		if got := sourceFilePath(path, source); got != want {
+			t.Fatalf("unexpected path for %q and source %d; got %q; want %q", path, source, got, want)
+		}
+	}
+	f("", 0, "")
+	f("", 2, "")
+	f("state.json", 0, "state.json")
+	f("state.json", 1, "state.json.source-1")
+	f("/tmp/spool", 3, "/tmp/spool.source-3")
+}
+
+func TestMultiSourceSummary(t *testing.T) {
+	ps := []*vmNativeProcessor{
+		{
+			src: &native.Client{Addr: "http://src1:8428"},
+			s:   &stats{bytes: 100, requests: 2, retries: 1},
+		},
+		{
+			src: &native.Client{Addr: "http://src2:8428"},
+			s:   &stats{bytes: 50, requests: 3},
+		},
+		{
+			// failed before the migration started
+			src: &native.Client{Addr: "http://src3:8428"},
+		},
+	}
+	summary := multiSourceSummary(ps, []error{nil, nil, errors.New("connection refused")}, time.Second)
+	for _, want := range []string{
+		`source #1 "http://src1:8428": ok; total bytes: 100 B; requests: 2; requests retries: 1;`,
+		`source #2 "http://src2:8428": ok; total bytes: 50 B; requests: 3; requests retries: 0;`,
+		`source #3 "http://src3:8428": failed;`,
+		`total bytes: 150 B; bytes/s: 150 B; requests: 5; requests retries: 1;`,
+	} {
+		if !strings.Contains(summary, want) {
+			t.Fatalf("summary must contain %q; got\n%s", want, summary)
+		}
+	}
+}
+
+func TestMultiSourceSuccessFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "success.json")
+	newProcessor := func(addr string, bytes uint64) *vmNativeProcessor {
+		return &vmNativeProcessor{
+			src:    &native.Client{Addr: addr},
+			dst:    &native.Client{Addr: "http://dst:8428"},
+			filter: native.Filter{Match: `{__name__!=""}`, TimeStart: "2022-01-01T00:00:00Z"},
+			s:      &stats{bytes: bytes, requests: 1},
+		}
+	}
+	ps := []*vmNativeProcessor{newProcessor("http://src1:8428", 10), newProcessor("http://src2:8428", 20)}
+	if err := writeMultiSourceSuccessFile(path, ps, time.Now()); err != nil {
+		t.Fatalf("cannot write success file: %s", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("cannot read success file: %s", err)
+	}
+	var m successMarker
+	if err := json.Unmarshal(data, &m); err != nil {
+		t.Fatalf("cannot parse success file: %s", err)
+	}
+	if m.Stats.Bytes != 30 || m.Stats.Requests != 2 {
+		t.Fatalf("unexpected stats in success file: %+v", m.Stats)
+	}
+	if m.ParamsHash == ps[0].paramsHash() || len(m.ParamsHash) != 64 {
+		t.Fatalf("unexpected params hash %q", m.ParamsHash)
+	}
+}
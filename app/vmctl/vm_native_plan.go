@@ -0,0 +1,192 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"time"
+)
+
+// planVersion is the version of the migration plan file format
+const planVersion = 1
+
+// migrationPlan describes the migration to review before its execution
+type migrationPlan struct {
+	Version   int          `json:"version"`
+	CreatedAt string       `json:"createdAt"`
+	Settings  planSettings `json:"settings"`
+	Ranges    [][2]string  `json:"ranges"`
+	Tenants   []planTenant `json:"tenants"`
+	// TotalRequests is the estimated number of export/import requests
+	TotalRequests int `json:"totalRequests"`
+}
+
+// planSettings contains the settings defining the scope of migration
+type planSettings struct {
+	Src          string `json:"src"`
+	Dst          string `json:"dst"`
+	Match        string `json:"match"`
+	TimeStart    string `json:"start"`
+	TimeEnd      string `json:"end"`
+	Step         string `json:"step,omitempty"`
+	ChunkByLabel string `json:"chunkByLabel,omitempty"`
+	InterCluster bool   `json:"interCluster,omitempty"`
+}
+
+type planTenant struct {
+	TenantID string   `json:"tenant,omitempty"`
+	Metrics  []string `json:"metrics"`
+	Requests int      `json:"requests"`
+}
+
+func (p *vmNativeProcessor) planSettings() planSettings {
+	ps := planSettings{
+		Src:          p.src.Addr,
+		Dst:          p.dst.Addr,
+		Match:        p.matchString(),
+		TimeStart:    p.filter.TimeStart,
+		TimeEnd:      p.filter.TimeEnd,
+		Step:         p.filter.Chunk,
+		InterCluster: p.interCluster,
+	}
+	if p.labelChunks != nil {
+		ps.ChunkByLabel = fmt.Sprintf("%s:%d", p.labelChunks.label, p.labelChunks.buckets)
+	}
+	return ps
+}
+
+// writePlan writes the plan of migration for the given tenants and ranges to p.planOut
+func (p *vmNativeProcessor) writePlan(tenants []string, tenantMetrics map[string]map[string]struct{}, ranges [][]time.Time) error {
+	plan := &migrationPlan{
+		Version:   planVersion,
+		CreatedAt: time.Now().UTC().Format(time.RFC3339),
+		Settings:  p.planSettings(),
+	}
+	for _, r := range ranges {
+		plan.Ranges = append(plan.Ranges, [2]string{r[0].Format(time.RFC3339), r[1].Format(time.RFC3339)})
+	}
+	buckets := 1
+	if p.labelChunks != nil {
+		buckets = p.labelChunks.buckets
+	}
+	for _, tenantID := range tenants {
+		metrics := sampleMetrics(tenantMetrics[tenantID], len(tenantMetrics[tenantID]))
+		pt := planTenant{
+			TenantID: tenantID,
+			Metrics:  metrics,
+			Requests: p.metricRequests(tenantID, tenantMetrics[tenantID]) * len(ranges) * buckets,
+		}
+		plan.TotalRequests += pt.Requests
+		plan.Tenants = append(plan.Tenants, pt)
+	}
+
+	data, err := json.MarshalIndent(plan, "", "  ")
+	if err != nil {
+		return fmt.Errorf("cannot marshal migration plan: %w", err)
+	}
+	if err := os.WriteFile(p.planOut, data, 0644); err != nil {
+		return fmt.Errorf("cannot write migration plan to %q: %w", p.planOut, err)
+	}
+	metrics := 0
+	for _, pt := range plan.Tenants {
+		metrics += len(pt.Metrics)
+	}
+	log.Printf("Migration plan with %d tenants, %d metrics, %d time ranges and %d requests is written to %q. "+
+		"Review it and execute via --%s=%s", len(plan.Tenants), metrics, len(plan.Ranges), plan.TotalRequests, p.planOut, vmNativePlanIn, p.planOut)
+	return nil
+}
+
+// loadPlan reads migration plan from the given path
+func loadPlan(path string) (*migrationPlan, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read migration plan: %w", err)
+	}
+	var plan migrationPlan
+	if err := json.Unmarshal(data, &plan); err != nil {
+		return nil, fmt.Errorf("cannot parse migration plan %q: %w", path, err)
+	}
+	if plan.Version != planVersion {
+		return nil, fmt.Errorf("unsupported version %d of migration plan %q; supported version: %d", plan.Version, path, planVersion)
+	}
+	return &plan, nil
+}
+
+// checkPlanSettings verifies that the current settings match the settings of p.planIn
+func (p *vmNativeProcessor) checkPlanSettings() error {
+	if got, want := p.planSettings(), p.planIn.Settings; got != want {
+		return fmt.Errorf("settings differ from the migration plan; got %+v; plan contains %+v", got, want)
+	}
+	return nil
+}
+
+// applyPlan compares the discovered tenants and metrics with p.planIn
+// and returns the tenants and metrics from the plan.
+func (p *vmNativeProcessor) applyPlan(tenants []string, tenantMetrics map[string]map[string]struct{}) ([]string, map[string]map[string]struct{}, error) {
+	planTenants := make([]string, 0, len(p.planIn.Tenants))
+	planMetrics := make(map[string]map[string]struct{}, len(p.planIn.Tenants))
+	for _, pt := range p.planIn.Tenants {
+		planTenants = append(planTenants, pt.TenantID)
+		metrics := make(map[string]struct{}, len(pt.Metrics))
+		for _, m := range pt.Metrics {
+			metrics[m] = struct{}{}
+		}
+		planMetrics[pt.TenantID] = metrics
+	}
+
+	diff := diffPlan(planTenants, planMetrics, tenants, tenantMetrics)
+	if len(diff) > 0 {
+		msg := fmt.Sprintf("current source state doesn't match the migration plan: %s", diff[0])
+		if len(diff) > 1 {
+			msg += fmt.Sprintf(" and %d more differences", len(diff)-1)
+		}
+		if !p.planForce {
+			return nil, nil, fmt.Errorf("%s; create a new plan or set --%s in order to execute the plan anyway", msg, vmNativePlanForce)
+		}
+		log.Printf("%s; executing the plan anyway because of --%s", msg, vmNativePlanForce)
+	}
+	return planTenants, planMetrics, nil
+}
+
+// diffPlan returns human-readable differences between planned and discovered tenants and metrics
+func diffPlan(planTenants []string, planMetrics map[string]map[string]struct{}, tenants []string, tenantMetrics map[string]map[string]struct{}) []string {
+	var diff []string
+	discovered := make(map[string]struct{}, len(tenants))
+	for _, tenantID := range tenants {
+		discovered[tenantID] = struct{}{}
+	}
+	for _, tenantID := range planTenants {
+		if _, ok := discovered[tenantID]; !ok {
+			diff = append(diff, fmt.Sprintf("planned tenant %q is missing at source", tenantID))
+		}
+	}
+	for _, tenantID := range tenants {
+		planned, ok := planMetrics[tenantID]
+		if !ok {
+			diff = append(diff, fmt.Sprintf("tenant %q isn't in the plan", tenantID))
+			continue
+		}
+		var missing, added []string
+		for m := range planned {
+			if _, ok := tenantMetrics[tenantID][m]; !ok {
+				missing = append(missing, m)
+			}
+		}
+		for m := range tenantMetrics[tenantID] {
+			if _, ok := planned[m]; !ok {
+				added = append(added, m)
+			}
+		}
+		sort.Strings(missing)
+		sort.Strings(added)
+		for _, m := range missing {
+			diff = append(diff, fmt.Sprintf("planned metric %q of tenant %q is missing at source", m, tenantID))
+		}
+		for _, m := range added {
+			diff = append(diff, fmt.Sprintf("metric %q of tenant %q isn't in the plan", m, tenantID))
+		}
+	}
+	return diff
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmctl/native"
+)
+
+func newTestMetrics(names ...string) map[string]struct{} {
+	m := make(map[string]struct{}, len(names))
+	for _, name := range names {
+		m[name] = struct{}{}
+	}
+	return m
+}
+
+func TestMigrationPlan(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "plan.json")
+	p := &vmNativeProcessor{
+		src:     &native.Client{Addr: "http://src:8428"},
+		dst:     &native.Client{Addr: "http://dst:8428"},
+		filter:  native.Filter{Match: `{__name__!=""}`, TimeStart: "2022-01-01T00:00:00Z", TimeEnd: "2022-01-03T00:00:00Z"},
+		planOut: path,
+	}
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	ranges := [][]time.Time{
+		{start, start.Add(24 * time.Hour)},
+		{start.Add(24 * time.Hour), start.Add(48 * time.Hour)},
+	}
+	tenants := []string{"", "1:0"}
+	tenantMetrics := map[string]map[string]struct{}{
+		"":    newTestMetrics("foo", "bar"),
+		"1:0": newTestMetrics("baz"),
+	}
+	if err := p.writePlan(tenants, tenantMetrics, ranges); err != nil {
+		t.Fatalf("cannot write plan: %s", err)
+	}
+
+	plan, err := loadPlan(path)
+	if err != nil {
+		t.Fatalf("cannot load plan: %s", err)
+	}
+	if plan.TotalRequests != 6 {
+		t.Fatalf("expecting 6 requests in plan; got %d", plan.TotalRequests)
+	}
+	if len(plan.Tenants) != 2 || len(plan.Ranges) != 2 {
+		t.Fatalf("unexpected plan: %+v", plan)
+	}
+
+	p.planOut = ""
+	p.planIn = plan
+	if err := p.checkPlanSettings(); err != nil {
+		t.Fatalf("unexpected settings mismatch: %s", err)
+	}
+	gotTenants, gotMetrics, err := p.applyPlan(tenants, tenantMetrics)
+	if err != nil {
+		t.Fatalf("unexpected error when applying matching plan: %s", err)
+	}
+	if len(gotTenants) != 2 || len(gotMetrics[""]) != 2 || len(gotMetrics["1:0"]) != 1 {
+		t.Fatalf("unexpected tenants %q and metrics %v from plan", gotTenants, gotMetrics)
+	}
+
+	// new metric appeared at source
+	tenantMetrics["1:0"] = newTestMetrics("baz", "qux")
+	if _, _, err := p.applyPlan(tenants, tenantMetrics); err == nil {
+		t.Fatalf("expecting error for plan not matching the source")
+	}
+	p.planForce = true
+	_, gotMetrics, err = p.applyPlan(tenants, tenantMetrics)
+	if err != nil {
+		t.Fatalf("unexpected error with forced plan: %s", err)
+	}
+	if _, ok := gotMetrics["1:0"]["qux"]; ok {
+		t.Fatalf("metric missing in the plan mustn't be migrated")
+	}
+
+	p.filter.Match = `{job="foo"}`
+	if err := p.checkPlanSettings(); err == nil {
+		t.Fatalf("expecting error for changed settings")
+	}
+}
+
+func TestDiffPlan(t *testing.T) {
+	f := func(planMetrics, tenantMetrics map[string]map[string]struct{}, expDiffs int) {
+		t.Helper()
+		var planTenants, tenants []string
+		for tenantID := range planMetrics {
+			planTenants = append(planTenants, tenantID)
+		}
+		for tenantID := range tenantMetrics {
+			tenants = append(tenants, tenantID)
+		}
+		diff := diffPlan(planTenants, planMetrics, tenants, tenantMetrics)
+		if len(diff) != expDiffs {
+			t.Fatalf("expecting %d differences; got %q", expDiffs, diff)
+		}
+	}
+	f(map[string]map[string]struct{}{"": newTestMetrics("foo")},
+		map[string]map[string]struct{}{"": newTestMetrics("foo")}, 0)
+	f(map[string]map[string]struct{}{"": newTestMetrics("foo", "bar")},
+		map[string]map[string]struct{}{"": newTestMetrics("foo", "baz")}, 2)
+	f(map[string]map[string]struct{}{"0:0": newTestMetrics("foo")},
+		map[string]map[string]struct{}{"1:0": newTestMetrics("foo")}, 2)
+}
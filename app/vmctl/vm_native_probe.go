@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// probeSrcPath is a cheap endpoint served by single-node VictoriaMetrics and vmselect
+const probeSrcPath = "api/v1/status/buildinfo"
+
+// probe verifies that src and dst are reachable and accept the configured credentials,
+// so typos in addresses and bad credentials fail the migration before it starts.
+//
+// The source is probed via buildinfo request, while the destination is probed
+// via import request with empty body, so both requests pass the same auth as the migration.
+func (p *vmNativeProcessor) probe(ctx context.Context) error {
+	srcURL := fmt.Sprintf("%s/%s", p.src.Addr, probeSrcPath)
+	if p.interCluster {
+		// the probe doesn't depend on tenant, so the default one is used
+		srcURL = fmt.Sprintf("%s/select/0/prometheus/%s", p.src.Addr, probeSrcPath)
+	}
+	if err := p.src.Probe(ctx, http.MethodGet, srcURL); err != nil {
+		return fmt.Errorf("source check failed: %w; set --%s in order to skip the check", err, vmNativeSkipProbe)
+	}
+
+	if p.dstFile != nil || p.dstRemoteWrite {
+		// empty remote write requests may be rejected by receivers other than VictoriaMetrics
+		return nil
+	}
+	dstURL := fmt.Sprintf("%s/%s", p.dst.Addr, p.dstImportPath())
+	switch {
+	case p.interCluster:
+		dstURL = fmt.Sprintf("%s/insert/%s/prometheus/%s", p.dst.Addr, p.dstTenant("0:0"), p.dstImportPath())
+	case p.tenantRoute != nil:
+		dstURL = fmt.Sprintf("%s/insert/0/prometheus/%s", p.dst.Addr, p.dstImportPath())
+	}
+	if err := p.dst.Probe(ctx, http.MethodPost, dstURL); err != nil {
+		return fmt.Errorf("destination check failed: %w; set --%s in order to skip the check", err, vmNativeSkipProbe)
+	}
+	return nil
+}
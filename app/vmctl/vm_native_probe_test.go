@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmctl/native"
+)
+
+func TestProbe(t *testing.T) {
+	var mu sync.Mutex
+	var requests []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		mu.Unlock()
+		if strings.Contains(r.URL.Path, "/forbidden/") {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	f := func(p *vmNativeProcessor, expRequests []string, expErr string) {
+		t.Helper()
+		requests = nil
+		err := p.probe(context.Background())
+		if expErr == "" && err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if expErr != "" && (err == nil || !strings.Contains(err.Error(), expErr)) {
+			t.Fatalf("expecting error containing %q; got %v", expErr, err)
+		}
+		if !reflect.DeepEqual(requests, expRequests) {
+			t.Fatalf("unexpected requests; got %q; want %q", requests, expRequests)
+		}
+	}
+	f(&vmNativeProcessor{
+		src: &native.Client{Addr: srv.URL},
+		dst: &native.Client{Addr: srv.URL},
+	}, []string{"GET /api/v1/status/buildinfo", "POST /api/v1/import/native"}, "")
+	f(&vmNativeProcessor{
+		src:               &native.Client{Addr: srv.URL},
+		dst:               &native.Client{Addr: srv.URL},
+		interCluster:      true,
+		dstTenantOverride: "1:0",
+	}, []string{"GET /select/0/prometheus/api/v1/status/buildinfo", "POST /insert/1:0/prometheus/api/v1/import/native"}, "")
+	// the destination isn't checked for remote write
+	f(&vmNativeProcessor{
+		src:            &native.Client{Addr: srv.URL},
+		dst:            &native.Client{Addr: srv.URL},
+		dstRemoteWrite: true,
+	}, []string{"GET /api/v1/status/buildinfo"}, "")
+
+	f(&vmNativeProcessor{
+		src: &native.Client{Addr: srv.URL + "/forbidden"},
+		dst: &native.Client{Addr: srv.URL},
+	}, []string{"GET /forbidden/api/v1/status/buildinfo"}, "source check failed: GET \""+srv.URL+"/forbidden/api/v1/status/buildinfo\" responded with status code 403")
+	f(&vmNativeProcessor{
+		src: &native.Client{Addr: srv.URL},
+		dst: &native.Client{Addr: srv.URL + "/forbidden"},
+	}, []string{"GET /api/v1/status/buildinfo", "POST /forbidden/api/v1/import/native"}, "destination check failed")
+}
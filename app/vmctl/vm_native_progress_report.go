@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/VictoriaMetrics/metrics"
+)
+
+// startProgressReport starts printing a single-line progress summary to stdout every p.progressInterval,
+// so unattended runs without progress bars have a heartbeat in their output.
+// It returns a function for stopping the report. It is no-op if p.progressInterval isn't set.
+func (p *vmNativeProcessor) startProgressReport() func() {
+	if p.progressInterval <= 0 {
+		return func() {}
+	}
+	if p.progress == nil {
+		// the numbers of completed and total metrics are tracked by progress metrics,
+		// which aren't exposed if --vm-native-metrics-listen-addr isn't set
+		p.progress = newProgressMetrics(metrics.NewSet(), 0)
+	}
+	stopCh := make(chan struct{})
+	doneCh := make(chan struct{})
+	go func() {
+		defer close(doneCh)
+		p.reportProgress(os.Stdout, stopCh)
+	}()
+	return func() {
+		close(stopCh)
+		<-doneCh
+	}
+}
+
+// reportProgress writes progress lines to w every p.progressInterval until stopCh is closed
+func (p *vmNativeProcessor) reportProgress(w io.Writer, stopCh <-chan struct{}) {
+	t := time.NewTicker(p.progressInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-t.C:
+			_, _ = fmt.Fprintln(w, p.progressLine())
+		}
+	}
+}
+
+// progressLine returns the summary of the current migration progress
+func (p *vmNativeProcessor) progressLine() string {
+	completed := atomic.LoadUint64(&p.progress.completedMetrics)
+	total := atomic.LoadUint64(&p.progress.totalMetrics)
+	var percent float64
+	if total > 0 {
+		percent = float64(completed) / float64(total) * 100
+	}
+	bytes, requests, _ := p.s.counters()
+	elapsed := time.Since(p.s.startTime).Truncate(time.Second)
+	return fmt.Sprintf("Progress: %d/%d metrics (%.1f%%); %d requests; %s transferred; elapsed %s",
+		completed, total, percent, requests, byteCountSI(int64(bytes)), elapsed)
+}
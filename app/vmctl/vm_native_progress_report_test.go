@@ -0,0 +1,44 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/metrics"
+)
+
+func TestProgressLine(t *testing.T) {
+	p := &vmNativeProcessor{
+		s:        &stats{startTime: time.Now().Add(-time.Minute), bytes: 2000, requests: 3},
+		progress: newProgressMetrics(metrics.NewSet(), 0),
+	}
+	p.progress.addTotalMetrics(4)
+	p.progress.metricCompleted()
+	exp := "Progress: 1/4 metrics (25.0%); 3 requests; 2.0 kB transferred; elapsed 1m0s"
+	if got := p.progressLine(); got != exp {
+		t.Fatalf("unexpected progress line;\ngot\n%s\nwant\n%s", got, exp)
+	}
+}
+
+func TestReportProgress(t *testing.T) {
+	p := &vmNativeProcessor{
+		s:                &stats{startTime: time.Now()},
+		progress:         newProgressMetrics(metrics.NewSet(), 0),
+		progressInterval: 10 * time.Millisecond,
+	}
+	var buf bytes.Buffer
+	stopCh := make(chan struct{})
+	doneCh := make(chan struct{})
+	go func() {
+		defer close(doneCh)
+		p.reportProgress(&buf, stopCh)
+	}()
+	time.Sleep(100 * time.Millisecond)
+	close(stopCh)
+	<-doneCh
+	if n := strings.Count(buf.String(), "Progress: 0/0 metrics"); n == 0 {
+		t.Fatalf("expecting progress lines; got %q", buf.String())
+	}
+}
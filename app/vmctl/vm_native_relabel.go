@@ -0,0 +1,44 @@
+package main
+
+import (
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmctl/native"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompbmarshal"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promrelabel"
+)
+
+// handleRelabel applies bp.relabelConfigs to the metric name of b.
+// Series left without metric name, e.g. because of `action: drop` rule, are dropped.
+func (bp *blockProcessor) handleRelabel(b *native.Block) {
+	if bp.relabelConfigs.Len() == 0 || len(b.Timestamps) == 0 {
+		return
+	}
+	mn := &b.MetricName
+	labels := bp.labels[:0]
+	labels = append(labels, prompbmarshal.Label{
+		Name:  "__name__",
+		Value: string(mn.MetricGroup),
+	})
+	for _, tag := range mn.Tags {
+		labels = append(labels, prompbmarshal.Label{
+			Name:  string(tag.Key),
+			Value: string(tag.Value),
+		})
+	}
+	labels = bp.relabelConfigs.Apply(labels, 0)
+	labels = promrelabel.FinalizeLabels(labels[:0], labels)
+	bp.labels = labels
+
+	mn.Reset()
+	for _, label := range labels {
+		if label.Name == "__name__" {
+			mn.MetricGroup = append(mn.MetricGroup, label.Value...)
+			continue
+		}
+		mn.AddTag(label.Name, label.Value)
+	}
+	if len(mn.MetricGroup) == 0 {
+		bp.relabelDroppedSeries++
+		bp.relabelDroppedSamples += uint64(len(b.Timestamps))
+		b.Timestamps, b.Values = b.Timestamps[:0], b.Values[:0]
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmctl/native"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promrelabel"
+)
+
+func TestBlockProcessorRelabel(t *testing.T) {
+	pcs, err := promrelabel.ParseRelabelConfigsData([]byte(`
+- source_labels: [__name__]
+  regex: "(.+)"
+  target_label: __name__
+  replacement: legacy_$1
+- action: drop
+  source_labels: [__name__]
+  regex: "legacy_go_.+"
+- action: labeldrop
+  regex: "pod"
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	f := func(series, expSeries string) {
+		t.Helper()
+		var b native.Block
+		b.MetricName.MetricGroup = []byte(series)
+		b.MetricName.AddTag("job", "foo")
+		b.MetricName.AddTag("pod", "bar")
+		b.Timestamps = []int64{1, 2}
+		b.Values = []float64{1, 2}
+		bp := &blockProcessor{relabelConfigs: pcs}
+		if err := bp.process(&b); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if expSeries == "" {
+			if len(b.Timestamps) > 0 || bp.relabelDroppedSeries != 1 || bp.relabelDroppedSamples != 2 {
+				t.Fatalf("expecting series %q to be dropped; got %s with %d samples", series, b.MetricName.String(), len(b.Timestamps))
+			}
+			return
+		}
+		if len(b.Timestamps) != 2 || len(b.Values) != 2 {
+			t.Fatalf("unexpected number of samples; got %d timestamps and %d values", len(b.Timestamps), len(b.Values))
+		}
+		if got := b.MetricName.String(); got != expSeries {
+			t.Fatalf("unexpected series; got %s; want %s", got, expSeries)
+		}
+	}
+	f("http_requests_total", `legacy_http_requests_total{job="foo"}`)
+	f("go_goroutines", "")
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmctl/native"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompbmarshal"
+	"github.com/golang/snappy"
+)
+
+// remoteWriteMaxSamples is the maximum number of samples per remote write request.
+// Bigger blocks are sent in a single request.
+const remoteWriteMaxSamples = 10000
+
+// importRemoteWrite decodes native blocks from r and sends them to u.dstURL
+// as snappy-compressed Prometheus remote write requests.
+// It returns the number of sent bytes.
+func (p *vmNativeProcessor) importRemoteWrite(ctx context.Context, u *migrationUnit, r io.Reader) (int64, error) {
+	var bp *blockProcessor
+	if p.needsDecode() {
+		bp = p.newBlockProcessor()
+	}
+	extraLabels, err := parseExtraLabels(p.dst.ExtraLabels)
+	if err != nil {
+		return 0, err
+	}
+	rl := p.newRequestRateLimiter()
+	dstURL, header := p.stickyRoute(u.dstURL, u.metric)
+
+	var (
+		wr      prompbmarshal.WriteRequest
+		samples int
+		written int64
+		buf     []byte
+		body    []byte
+	)
+	flush := func() error {
+		if len(wr.Timeseries) == 0 {
+			return nil
+		}
+		buf = prompbmarshal.MarshalWriteRequest(buf[:0], &wr)
+		body = snappy.Encode(body[:cap(body)], buf)
+		// rate limits are applied to the compressed data sent over the network
+		if rl != nil {
+			rl.Register(len(body))
+		}
+		if p.globalRateLimiter != nil {
+			p.globalRateLimiter.Register(len(body))
+		}
+		if err := p.dst.RemoteWrite(ctx, dstURL, body, header); err != nil {
+			return err
+		}
+		written += int64(len(body))
+		wr.Timeseries = wr.Timeseries[:0]
+		samples = 0
+		return nil
+	}
+
+	d := native.NewDecoder(r)
+	if _, err := d.TimeRange(); err != nil {
+		if errors.Is(err, io.EOF) {
+			// empty export response
+			return 0, nil
+		}
+		return 0, err
+	}
+	var b native.Block
+	for {
+		if err := d.Next(&b); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return written, err
+		}
+		if bp != nil {
+			if err := bp.process(&b); err != nil {
+				return written, err
+			}
+		}
+		if len(b.Timestamps) == 0 {
+			continue
+		}
+		if samples > 0 && samples+len(b.Timestamps) > remoteWriteMaxSamples {
+			if err := flush(); err != nil {
+				return written, fmt.Errorf("failed to write into %q: %w", dstURL, err)
+			}
+		}
+		wr.Timeseries = append(wr.Timeseries, blockToTimeSeries(&b, extraLabels))
+		samples += len(b.Timestamps)
+	}
+	if err := flush(); err != nil {
+		return written, fmt.Errorf("failed to write into %q: %w", dstURL, err)
+	}
+
+	p.s.countRequest(u, written)
+	if bp != nil {
+		bp.flushStats(p.s)
+	}
+	return written, nil
+}
+
+// blockToTimeSeries converts b to remote write time series with extraLabels
+// overriding the labels of b with the same names
+func blockToTimeSeries(b *native.Block, extraLabels []prompbmarshal.Label) prompbmarshal.TimeSeries {
+	mn := &b.MetricName
+	labels := make([]prompbmarshal.Label, 0, len(mn.Tags)+len(extraLabels)+1)
+	labels = append(labels, prompbmarshal.Label{
+		Name:  "__name__",
+		Value: string(mn.MetricGroup),
+	})
+	for _, tag := range mn.Tags {
+		if hasLabel(extraLabels, string(tag.Key)) {
+			continue
+		}
+		labels = append(labels, prompbmarshal.Label{
+			Name:  string(tag.Key),
+			Value: string(tag.Value),
+		})
+	}
+	labels = append(labels, extraLabels...)
+
+	samples := make([]prompbmarshal.Sample, len(b.Timestamps))
+	for i, ts := range b.Timestamps {
+		samples[i] = prompbmarshal.Sample{
+			Timestamp: ts,
+			Value:     b.Values[i],
+		}
+	}
+	return prompbmarshal.TimeSeries{
+		Labels:  labels,
+		Samples: samples,
+	}
+}
+
+// parseExtraLabels parses extra labels in `name=value` format
+func parseExtraLabels(extraLabels []string) ([]prompbmarshal.Label, error) {
+	var labels []prompbmarshal.Label
+	for _, l := range extraLabels {
+		name, value, ok := strings.Cut(l, "=")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("bad format for extra_label flag, it must be `key=value`, got: %q", l)
+		}
+		labels = append(labels, prompbmarshal.Label{
+			Name:  name,
+			Value: value,
+		})
+	}
+	return labels, nil
+}
+
+func hasLabel(labels []prompbmarshal.Label, name string) bool {
+	for _, l := range labels {
+		if l.Name == name {
+			return true
+		}
+	}
+	return false
+}
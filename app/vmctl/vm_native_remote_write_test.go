@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmctl/native"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompb"
+	"github.com/golang/snappy"
+)
+
+func TestImportRemoteWrite(t *testing.T) {
+	var requests int
+	series := make(map[string]int)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ce := r.Header.Get("Content-Encoding"); ce != "snappy" {
+			t.Errorf("unexpected Content-Encoding %q", ce)
+		}
+		compressed, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("cannot read request body: %s", err)
+		}
+		data, err := snappy.Decode(nil, compressed)
+		if err != nil {
+			t.Errorf("cannot decompress request body: %s", err)
+		}
+		var wr prompb.WriteRequest
+		if err := wr.Unmarshal(data); err != nil {
+			t.Errorf("cannot unmarshal write request: %s", err)
+		}
+		requests++
+		for _, ts := range wr.Timeseries {
+			var key string
+			for _, l := range ts.Labels {
+				key += string(l.Name) + "=" + string(l.Value) + ","
+			}
+			series[key] += len(ts.Samples)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	ts := make([]int64, remoteWriteMaxSamples)
+	vs := make([]float64, remoteWriteMaxSamples)
+	for i := range ts {
+		ts[i] = int64(i)
+	}
+	data := encodeTestBlocks(t,
+		newTestBlock("", ts[:10], vs[:10]),
+		newTestBlock("env", ts, vs),
+	)
+
+	p := &vmNativeProcessor{
+		dst: &native.Client{Addr: srv.URL, ExtraLabels: []string{"job=baz"}},
+		s:   &stats{},
+	}
+	u := newTestUnit("", "foo", "", "")
+	u.dstURL = srv.URL
+	written, err := p.importRemoteWrite(context.Background(), u, bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if written == 0 || p.s.bytes != uint64(written) || p.s.requests != 1 {
+		t.Fatalf("unexpected stats; written: %d; bytes: %d; requests: %d", written, p.s.bytes, p.s.requests)
+	}
+	// the second block doesn't fit the first request
+	if requests != 2 {
+		t.Fatalf("expecting 2 remote write requests; got %d", requests)
+	}
+	// extra label overrides the label of the series
+	exp := map[string]int{
+		"__name__=foo,job=baz,":         10,
+		"__name__=foo,env=baz,job=baz,": remoteWriteMaxSamples,
+	}
+	for k, n := range exp {
+		if series[k] != n {
+			t.Fatalf("unexpected number of samples for series %q; got %d; want %d; all series: %v", k, series[k], n, series)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// requestTimeout limits the duration of every attempt to migrate a unit.
+// The timeout is multiplied by factor on every retry up to max,
+// so genuinely slow time ranges get more time on later attempts.
+type requestTimeout struct {
+	timeout time.Duration
+	factor  float64
+	// max is the cap for the escalated timeout; zero means no cap
+	max time.Duration
+}
+
+// attemptTimeout returns the timeout for the given zero-based attempt
+func (rt *requestTimeout) attemptTimeout(attempt int) time.Duration {
+	d := rt.timeout
+	for i := 0; i < attempt; i++ {
+		d = time.Duration(float64(d) * rt.factor)
+		if rt.max > 0 && d >= rt.max {
+			return rt.max
+		}
+	}
+	return d
+}
+
+// run calls f with ctx limited by the timeout for the given attempt.
+// It calls f with ctx as is if rt is nil.
+func (rt *requestTimeout) run(ctx context.Context, attempt int, f func(ctx context.Context) error) error {
+	if rt == nil {
+		return f(ctx)
+	}
+	timeout := rt.attemptTimeout(attempt)
+	attemptCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	err := f(attemptCtx)
+	if err != nil && ctx.Err() == nil && errors.Is(attemptCtx.Err(), context.DeadlineExceeded) {
+		// the error wraps context.DeadlineExceeded instead of context.Canceled, so the request is retried
+		return fmt.Errorf("request didn't finish within %s; see --%s: %w", timeout, vmNativeRequestTimeout, err)
+	}
+	return err
+}
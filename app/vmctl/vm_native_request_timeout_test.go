@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRequestTimeoutAttemptTimeout(t *testing.T) {
+	f := func(rt *requestTimeout, attempt int, exp time.Duration) {
+		t.Helper()
+		if got := rt.attemptTimeout(attempt); got != exp {
+			t.Fatalf("unexpected timeout for attempt %d; got %s; want %s", attempt, got, exp)
+		}
+	}
+	rt := &requestTimeout{timeout: time.Second, factor: 2, max: 5 * time.Second}
+	f(rt, 0, time.Second)
+	f(rt, 1, 2*time.Second)
+	f(rt, 2, 4*time.Second)
+	f(rt, 3, 5*time.Second)
+	f(rt, 10, 5*time.Second)
+
+	rt = &requestTimeout{timeout: time.Second, factor: 1.5}
+	f(rt, 2, 2250*time.Millisecond)
+	rt = &requestTimeout{timeout: time.Second, factor: 1}
+	f(rt, 5, time.Second)
+}
+
+func TestRequestTimeoutRun(t *testing.T) {
+	rt := &requestTimeout{timeout: 10 * time.Millisecond, factor: 10}
+	// the request needs more time than the first attempt has
+	slow := func(ctx context.Context) error {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(50 * time.Millisecond):
+			return nil
+		}
+	}
+	err := rt.run(context.Background(), 0, slow)
+	if !errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		t.Fatalf("expecting retryable deadline error; got %v", err)
+	}
+	if err := rt.run(context.Background(), 1, slow); err != nil {
+		t.Fatalf("unexpected error for escalated timeout: %s", err)
+	}
+
+	// cancellation of the parent context isn't reported as timeout
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := rt.run(ctx, 0, slow); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expecting context.Canceled error; got %v", err)
+	}
+
+	var nilTimeout *requestTimeout
+	if err := nilTimeout.run(context.Background(), 0, slow); err != nil {
+		t.Fatalf("unexpected error for nil timeout: %s", err)
+	}
+}
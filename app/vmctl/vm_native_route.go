@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/cespare/xxhash/v2"
+)
+
+const (
+	// stickyRouteByMetric routes all the data of a metric to the same destination backend
+	stickyRouteByMetric = "metric"
+
+	// stickyRouteViaHeader passes routing key via HTTP header
+	stickyRouteViaHeader = "header"
+	// stickyRouteViaQuery passes routing key via query arg
+	stickyRouteViaQuery = "query"
+
+	defaultStickyRouteHeader   = "X-Route-Key"
+	defaultStickyRouteQueryArg = "route_key"
+)
+
+// stickyRouteConfig defines how import requests are routed
+// via write-path load balancer in front of the destination.
+type stickyRouteConfig struct {
+	// by defines the entity used for building the routing key. Empty value disables routing.
+	by string
+	// via defines the mechanism of passing the routing key: header or query arg
+	via string
+	// key is the name of header or query arg
+	key string
+}
+
+func (rc *stickyRouteConfig) validate() error {
+	switch rc.by {
+	case "":
+		return nil
+	case stickyRouteByMetric:
+	default:
+		return fmt.Errorf("unsupported value %q for sticky routing; supported values: %q", rc.by, stickyRouteByMetric)
+	}
+	switch rc.via {
+	case stickyRouteViaHeader:
+		if rc.key == "" {
+			rc.key = defaultStickyRouteHeader
+		}
+	case stickyRouteViaQuery:
+		if rc.key == "" {
+			rc.key = defaultStickyRouteQueryArg
+		}
+	default:
+		return fmt.Errorf("unsupported sticky routing mechanism %q; supported values: %q, %q", rc.via, stickyRouteViaHeader, stickyRouteViaQuery)
+	}
+	return nil
+}
+
+// stickyRoute returns dstURL and header for import request of the given metric
+// according to p.stickyRouteCfg. The routing key is a hash of the metric name,
+// so all the data of the metric goes to the same backend behind consistent-hashing balancer.
+func (p *vmNativeProcessor) stickyRoute(dstURL, metric string) (string, http.Header) {
+	rc := p.stickyRouteCfg
+	if rc.by == "" {
+		return dstURL, nil
+	}
+	routeKey := fmt.Sprintf("%016x", xxhash.Sum64String(metric))
+	if rc.via == stickyRouteViaHeader {
+		return dstURL, http.Header{rc.key: []string{routeKey}}
+	}
+	u, err := url.Parse(dstURL)
+	if err != nil {
+		// dstURL is validated when building import request, so just skip the routing here
+		return dstURL, nil
+	}
+	q := u.Query()
+	q.Set(rc.key, routeKey)
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestStickyRoute(t *testing.T) {
+	f := func(rc stickyRouteConfig, metric string, wantQueryKey, wantHeaderKey string) {
+		t.Helper()
+		if err := rc.validate(); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		p := &vmNativeProcessor{stickyRouteCfg: rc}
+		dstURL := "http://localhost:8428/api/v1/import/native?extra_label=foo=bar"
+		gotURL, header := p.stickyRoute(dstURL, metric)
+
+		u, err := url.Parse(gotURL)
+		if err != nil {
+			t.Fatalf("cannot parse url %q: %s", gotURL, err)
+		}
+		if u.Query().Get("extra_label") != "foo=bar" {
+			t.Fatalf("expected extra_label to be preserved in %q", gotURL)
+		}
+		gotQueryKey := u.Query().Get(rc.key)
+		if wantQueryKey == "" && gotQueryKey != "" {
+			t.Fatalf("unexpected routing query arg in %q", gotURL)
+		}
+		if wantQueryKey != "" && gotQueryKey == "" {
+			t.Fatalf("expected routing query arg %q in %q", wantQueryKey, gotURL)
+		}
+		gotHeaderKey := header.Get(rc.key)
+		if wantHeaderKey == "" && gotHeaderKey != "" {
+			t.Fatalf("unexpected routing header %v", header)
+		}
+		if wantHeaderKey != "" && gotHeaderKey == "" {
+			t.Fatalf("expected routing header %q; got %v", wantHeaderKey, header)
+		}
+
+		// the same metric must be always routed with the same key
+		gotURL2, header2 := p.stickyRoute(dstURL, metric)
+		if gotURL != gotURL2 || header.Get(rc.key) != header2.Get(rc.key) {
+			t.Fatalf("routing key must be stable for the same metric")
+		}
+	}
+
+	f(stickyRouteConfig{}, "foo", "", "")
+	f(stickyRouteConfig{by: stickyRouteByMetric, via: stickyRouteViaHeader}, "foo", "", defaultStickyRouteHeader)
+	f(stickyRouteConfig{by: stickyRouteByMetric, via: stickyRouteViaQuery}, "foo", defaultStickyRouteQueryArg, "")
+	f(stickyRouteConfig{by: stickyRouteByMetric, via: stickyRouteViaQuery, key: "shard"}, "foo", "shard", "")
+
+	p := &vmNativeProcessor{stickyRouteCfg: stickyRouteConfig{by: stickyRouteByMetric, via: stickyRouteViaHeader, key: "X-Shard"}}
+	_, h1 := p.stickyRoute("http://localhost", "foo")
+	_, h2 := p.stickyRoute("http://localhost", "bar")
+	if h1.Get("X-Shard") == h2.Get("X-Shard") {
+		t.Fatalf("expected different routing keys for different metrics")
+	}
+
+	bad := stickyRouteConfig{by: "series"}
+	if err := bad.validate(); err == nil {
+		t.Fatalf("expected error for unsupported routing entity")
+	}
+	bad = stickyRouteConfig{by: stickyRouteByMetric, via: "path"}
+	if err := bad.validate(); err == nil {
+		t.Fatalf("expected error for unsupported routing mechanism")
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+
+	"github.com/cespare/xxhash/v2"
+)
+
+// metricSampler selects a fraction of discovered metrics for test migrations.
+// Metrics are selected by the hash of their names and seed, so the same metrics
+// are selected by runs with the same seed, including runs with streamed discovery.
+type metricSampler struct {
+	percentage float64
+	seed       uint64
+}
+
+// newMetricSampler returns metricSampler selecting the given percentage of metrics.
+// It returns nil if percentage is zero.
+func newMetricSampler(percentage float64, seed uint64) (*metricSampler, error) {
+	if percentage == 0 {
+		return nil, nil
+	}
+	if percentage < 0 || percentage > 100 {
+		return nil, fmt.Errorf("--%s must be in range (0..100]; got %v", vmNativeSamplePercentage, percentage)
+	}
+	return &metricSampler{percentage: percentage, seed: seed}, nil
+}
+
+// match returns true if name is selected for migration.
+// It returns true for nil ms.
+func (ms *metricSampler) match(name string) bool {
+	if ms == nil {
+		return true
+	}
+	h := xxhash.Sum64String(strconv.FormatUint(ms.seed, 10) + ":" + name)
+	return float64(h)/math.MaxUint64*100 < ms.percentage
+}
+
+// sample returns metrics selected by ms and logs the number of selected metrics.
+// It returns metrics as is for nil ms.
+func (ms *metricSampler) sample(tenantID string, metrics map[string]struct{}) map[string]struct{} {
+	if ms == nil || len(metrics) == 0 {
+		return metrics
+	}
+	sampled := make(map[string]struct{})
+	for name := range metrics {
+		if ms.match(name) {
+			sampled[name] = struct{}{}
+		}
+	}
+	ms.logSampled(tenantID, len(sampled), len(metrics))
+	return sampled
+}
+
+func (ms *metricSampler) logSampled(tenantID string, sampled, total int) {
+	msg := fmt.Sprintf("Sampled %d of %d metrics via --%s=%v and --%s=%d", sampled, total, vmNativeSamplePercentage, ms.percentage, vmNativeSampleSeed, ms.seed)
+	if tenantID != "" {
+		msg += " for tenant " + tenantID
+	}
+	log.Print(msg)
+}
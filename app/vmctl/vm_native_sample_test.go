@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestNewMetricSampler(t *testing.T) {
+	f := func(percentage float64, expErr bool) {
+		t.Helper()
+		_, err := newMetricSampler(percentage, 0)
+		if (err != nil) != expErr {
+			t.Fatalf("unexpected error for %v; got %v; want error %v", percentage, err, expErr)
+		}
+	}
+	f(0, false)
+	f(1, false)
+	f(100, false)
+	f(-1, true)
+	f(101, true)
+
+	if ms, _ := newMetricSampler(0, 0); ms != nil {
+		t.Fatalf("expecting nil sampler for zero percentage")
+	}
+}
+
+func TestMetricSamplerSample(t *testing.T) {
+	metrics := make(map[string]struct{})
+	for i := 0; i < 10000; i++ {
+		metrics[fmt.Sprintf("metric_%d", i)] = struct{}{}
+	}
+	ms, err := newMetricSampler(10, 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	sampled := ms.sample("", metrics)
+	if n := len(sampled); n < 900 || n > 1100 {
+		t.Fatalf("unexpected number of sampled metrics %d; want about 1000", n)
+	}
+	// sampling is deterministic for the same seed
+	if !reflect.DeepEqual(ms.sample("", metrics), sampled) {
+		t.Fatalf("unexpected metrics sampled with the same seed")
+	}
+	other, _ := newMetricSampler(10, 2)
+	if reflect.DeepEqual(other.sample("", metrics), sampled) {
+		t.Fatalf("expecting other metrics sampled with another seed")
+	}
+	all, _ := newMetricSampler(100, 1)
+	if n := len(all.sample("", metrics)); n != len(metrics) {
+		t.Fatalf("unexpected number of metrics sampled with 100%%; got %d; want %d", n, len(metrics))
+	}
+
+	var nilSampler *metricSampler
+	if !nilSampler.match("foo") {
+		t.Fatalf("nil sampler must match all the metrics")
+	}
+}
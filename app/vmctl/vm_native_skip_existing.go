@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+)
+
+// existsAtDst returns true if the destination already contains samples
+// matching u in the time range of u
+func (p *vmNativeProcessor) existsAtDst(ctx context.Context, u *migrationUnit) (bool, error) {
+	query, ts, err := countQuery(u)
+	if err != nil {
+		return false, err
+	}
+	n, err := p.dstReader.QueryValue(ctx, p.dstQueryAddr(u.tenantID), query, ts)
+	if err != nil {
+		return false, fmt.Errorf("cannot query destination: %w", err)
+	}
+	return n > 0, nil
+}
+
+// skipExisting returns true if u must be skipped, since its data is already present at the destination.
+// Units are migrated if the destination can't be checked.
+func (p *vmNativeProcessor) skipExisting(ctx context.Context, u *migrationUnit) bool {
+	if !p.skipExistingData {
+		return false
+	}
+	exists, err := p.existsAtDst(ctx, u)
+	if err != nil {
+		logger.Warnf("cannot check existing data of metric %q for time range %s - %s: %s; migrating it",
+			u.metric, u.filter.TimeStart, u.filter.TimeEnd, err)
+		return false
+	}
+	if !exists {
+		return false
+	}
+	p.s.Lock()
+	p.s.skippedExisting++
+	p.s.Unlock()
+	return true
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmctl/native"
+)
+
+func TestSkipExisting(t *testing.T) {
+	counts := map[string]string{
+		`sum(count_over_time({__name__="foo"}[3600001ms]))`: "10",
+	}
+	dst := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query().Get("query")
+		if query == `sum(count_over_time({__name__="broken"}[3600001ms]))` {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		v, ok := counts[query]
+		if !ok {
+			_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[]}}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[0,"` + v + `"]}]}}`))
+	}))
+	defer dst.Close()
+
+	p := &vmNativeProcessor{
+		dstReader:        &native.Client{Addr: dst.URL},
+		s:                &stats{},
+		skipExistingData: true,
+	}
+	f := func(metric string, exp bool) {
+		t.Helper()
+		u := newTestUnit("", metric, "2022-01-01T00:00:00Z", "2022-01-01T01:00:00Z")
+		u.filter.Match = `{__name__="` + metric + `"}`
+		if got := p.skipExisting(context.Background(), u); got != exp {
+			t.Fatalf("unexpected result for metric %q; got %v; want %v", metric, got, exp)
+		}
+	}
+	f("foo", true)
+	f("bar", false)
+	// units must be migrated if destination can't be checked
+	f("broken", false)
+
+	if p.s.skippedExisting != 1 {
+		t.Fatalf("unexpected number of skipped requests; got %d; want 1", p.s.skippedExisting)
+	}
+
+	p.skipExistingData = false
+	f("foo", false)
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmctl/limiter"
+)
+
+// slowStartInterval is the interval for increasing limits during slow start
+const slowStartInterval = time.Second
+
+// slowStart ramps up write rate limits from a fraction of the configured limits
+// to the configured limits over the duration since the first request.
+type slowStart struct {
+	duration time.Duration
+	fraction float64
+
+	mu sync.Mutex
+	// startTime is the time of the first registered limiter
+	startTime time.Time
+	// limiters contains the configured limits of limiters registered during slow start
+	limiters map[*limiter.Limiter]int64
+	finished bool
+}
+
+func newSlowStart(duration time.Duration, fraction float64) *slowStart {
+	return &slowStart{
+		duration: duration,
+		fraction: fraction,
+		limiters: make(map[*limiter.Limiter]int64),
+	}
+}
+
+// limit returns the limit for the configured limit full after the elapsed time since slow start
+func (ss *slowStart) limit(full int64, elapsed time.Duration) int64 {
+	if elapsed >= ss.duration {
+		return full
+	}
+	f := ss.fraction + (1-ss.fraction)*float64(elapsed)/float64(ss.duration)
+	n := int64(float64(full) * f)
+	if n < 1 {
+		n = 1
+	}
+	return n
+}
+
+// register applies slow start to l until the slow start is finished.
+// The first registered limiter starts the slow start.
+// It is no-op for nil ss or l.
+func (ss *slowStart) register(l *limiter.Limiter) {
+	if ss == nil || l == nil {
+		return
+	}
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	if ss.finished {
+		return
+	}
+	if _, ok := ss.limiters[l]; ok {
+		return
+	}
+	full := l.Limit()
+	if ss.startTime.IsZero() {
+		ss.startTime = time.Now()
+		go ss.run()
+	}
+	ss.limiters[l] = full
+	l.SetLimit(ss.limit(full, time.Since(ss.startTime)))
+}
+
+// run increases limits of the registered limiters until the slow start is finished
+func (ss *slowStart) run() {
+	t := time.NewTicker(slowStartInterval)
+	defer t.Stop()
+	for range t.C {
+		ss.mu.Lock()
+		elapsed := time.Since(ss.startTime)
+		for l, full := range ss.limiters {
+			l.SetLimit(ss.limit(full, elapsed))
+		}
+		if elapsed >= ss.duration {
+			// limiters are restored to the configured limits
+			ss.finished = true
+			ss.limiters = nil
+			ss.mu.Unlock()
+			return
+		}
+		ss.mu.Unlock()
+	}
+}
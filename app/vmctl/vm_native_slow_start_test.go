@@ -0,0 +1,54 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmctl/limiter"
+)
+
+func TestSlowStartLimit(t *testing.T) {
+	ss := newSlowStart(10*time.Second, 0.1)
+	f := func(full int64, elapsed time.Duration, exp int64) {
+		t.Helper()
+		if got := ss.limit(full, elapsed); got != exp {
+			t.Fatalf("unexpected limit for %d after %s; got %d; want %d", full, elapsed, got, exp)
+		}
+	}
+	f(1000, 0, 100)
+	f(1000, 5*time.Second, 550)
+	f(1000, 10*time.Second, 1000)
+	f(1000, time.Minute, 1000)
+	// the limit is never zero
+	f(1, 0, 1)
+}
+
+func TestSlowStartRegister(t *testing.T) {
+	ss := newSlowStart(2*slowStartInterval, 0.5)
+	l := limiter.NewLimiter(1000)
+	ss.register(l)
+	if got := l.Limit(); got != 500 {
+		t.Fatalf("unexpected initial limit %d", got)
+	}
+	// repeated registration doesn't change the configured limit
+	ss.register(l)
+	if got := l.Limit(); got != 500 {
+		t.Fatalf("unexpected limit %d after repeated registration", got)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for l.Limit() != 1000 {
+		if time.Now().After(deadline) {
+			t.Fatalf("the limit wasn't restored; got %d", l.Limit())
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	// limiters registered after the slow start are left as is
+	l = limiter.NewLimiter(1000)
+	ss.register(l)
+	if got := l.Limit(); got != 1000 {
+		t.Fatalf("unexpected limit %d after slow start", got)
+	}
+
+	var nilSS *slowStart
+	nilSS.register(l)
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync/atomic"
+)
+
+// spool stores exported data on disk before importing it to destination.
+// It decouples export speed from import speed without buffering data in memory.
+type spool struct {
+	dir string
+	// maxSize limits the total size of all the spool files in bytes. Zero means no limit.
+	maxSize int64
+	used    int64
+}
+
+func newSpool(dir string, maxSize int64) (*spool, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("cannot create spool dir %q: %w", dir, err)
+	}
+	return &spool{dir: dir, maxSize: maxSize}, nil
+}
+
+// store writes all the data from r into a new spool file and returns it opened for reading.
+// The returned spoolFile must be closed, which removes it from disk.
+func (s *spool) store(r io.Reader) (*spoolFile, error) {
+	sf, err := s.create()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := sf.write(r, -1); err != nil {
+		_ = sf.Close()
+		return nil, err
+	}
+	if err := sf.rewind(); err != nil {
+		_ = sf.Close()
+		return nil, err
+	}
+	return sf, nil
+}
+
+// create returns a new empty spool file opened for writing.
+// The returned spoolFile must be closed, which removes it from disk.
+func (s *spool) create() (*spoolFile, error) {
+	f, err := os.CreateTemp(s.dir, "vmctl-spool-*")
+	if err != nil {
+		return nil, fmt.Errorf("cannot create spool file in %q: %w", s.dir, err)
+	}
+	return &spoolFile{File: f, s: s}, nil
+}
+
+// write appends up to n bytes from r to sf. All the data from r is appended if n is negative.
+// It returns true if r was read till the end.
+func (sf *spoolFile) write(r io.Reader, n int64) (bool, error) {
+	var err error
+	if n < 0 {
+		_, err = io.Copy(&spoolWriter{sf: sf}, r)
+	} else {
+		_, err = io.CopyN(&spoolWriter{sf: sf}, r, n)
+		if errors.Is(err, io.EOF) {
+			return true, nil
+		}
+		if err == nil {
+			return false, nil
+		}
+	}
+	if err != nil {
+		return false, fmt.Errorf("cannot write to spool file %q: %w", sf.Name(), err)
+	}
+	return true, nil
+}
+
+// rewind prepares sf for reading the written data from the start
+func (sf *spoolFile) rewind() error {
+	if _, err := sf.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("cannot seek spool file %q: %w", sf.Name(), err)
+	}
+	return nil
+}
+
+// spoolFile is a file in spool dir
+type spoolFile struct {
+	*os.File
+	s    *spool
+	size int64
+}
+
+// Close closes the file, removes it from disk and releases its space in spool
+func (sf *spoolFile) Close() error {
+	atomic.AddInt64(&sf.s.used, -sf.size)
+	sf.size = 0
+	name := sf.Name()
+	err := sf.File.Close()
+	if rmErr := os.Remove(name); rmErr != nil && err == nil {
+		err = rmErr
+	}
+	return err
+}
+
+// spoolWriter writes data to spoolFile respecting spool.maxSize
+type spoolWriter struct {
+	sf *spoolFile
+}
+
+func (sw *spoolWriter) Write(p []byte) (int, error) {
+	s := sw.sf.s
+	n := int64(len(p))
+	if used := atomic.AddInt64(&s.used, n); s.maxSize > 0 && used > s.maxSize {
+		atomic.AddInt64(&s.used, -n)
+		return 0, fmt.Errorf("spool size exceeds %s; consider increasing --%s or reducing --%s",
+			byteCountSI(s.maxSize), vmNativeDstBufferMaxSize, vmConcurrency)
+	}
+	written, err := sw.sf.File.Write(p)
+	sw.sf.size += int64(written)
+	if int64(written) < n {
+		atomic.AddInt64(&s.used, int64(written)-n)
+	}
+	return written, err
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"testing"
+)
+
+func TestSpool(t *testing.T) {
+	dir := t.TempDir()
+	s, err := newSpool(dir, 10)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	sf, err := s.store(bytes.NewBufferString("foobar"))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	got, err := io.ReadAll(sf)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if string(got) != "foobar" {
+		t.Fatalf("unexpected spooled data; got %q; want %q", got, "foobar")
+	}
+
+	// the second file exceeds the total spool size
+	if _, err := s.store(bytes.NewBufferString("foobar")); err == nil {
+		t.Fatalf("expected error when exceeding spool size")
+	}
+
+	if err := sf.Close(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if s.used != 0 {
+		t.Fatalf("expected spool to be empty; got %d bytes used", s.used)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(entries) != 0 {
+		t.Fatalf("expected spool files to be removed; got %d files", len(entries))
+	}
+
+	// space is released after closing the first file
+	sf, err = s.store(bytes.NewBufferString("foobar"))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	_ = sf.Close()
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmctl/vm"
+	"github.com/cheggaaa/pb/v3"
+)
+
+// runFromFile imports the native data from p.srcFile into the destination.
+// The file is expected to contain the response of /api/v1/export/native handler,
+// so discovery of tenants and metrics is skipped and the file is streamed as is.
+func (p *vmNativeProcessor) runFromFile(ctx context.Context, silent bool) error {
+	if p.dryRun != nil {
+		return fmt.Errorf("--%s isn't supported with --%s", vmNativeDryRun, vmNativeSrcFile)
+	}
+	f, err := os.Open(p.srcFile)
+	if err != nil {
+		return fmt.Errorf("cannot open source file: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+	fi, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("cannot stat source file: %w", err)
+	}
+
+	if err := p.validate(); err != nil {
+		return err
+	}
+	if err := p.removeSuccessFile(); err != nil {
+		return err
+	}
+	if err := p.dst.CheckFormat(ctx, p.dst.Addr); err != nil {
+		return fmt.Errorf("failed to verify import format at destination: %w", err)
+	}
+
+	importAddr, err := vm.AddExtraLabelsToImportPath(p.dstImportPath(), p.dst.ExtraLabels)
+	if err != nil {
+		return fmt.Errorf("failed to add labels to import path: %s", err)
+	}
+	u := &migrationUnit{
+		srcURL: p.srcFile,
+		dstURL: fmt.Sprintf("%s/%s", p.dst.Addr, importAddr),
+	}
+
+	fmt.Println("") // extra line for better output formatting
+	log.Printf("Initing import process from %q to %q; file size: %s", u.srcURL, u.dstURL, byteCountSI(fi.Size()))
+
+	var bar *pb.ProgressBar
+	if !silent && p.showBars() {
+		bar = pb.Full.Start64(fi.Size())
+		bar.Set(pb.Bytes, true)
+		defer bar.Finish()
+	}
+
+	attempts, err := p.backoff.Retry(ctx, func() error {
+		// the file is re-read from the start on every attempt
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return fmt.Errorf("cannot seek source file: %w", err)
+		}
+		r := io.Reader(f)
+		if bar != nil {
+			bar.SetCurrent(0)
+			r = bar.NewProxyReader(f)
+		}
+		_, err := p.importData(ctx, u, r)
+		return err
+	})
+	p.s.Lock()
+	p.s.retries += attempts
+	p.s.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to import %q into %q (retry attempts: %d): %w", u.srcURL, u.dstURL, attempts, err)
+	}
+	p.flushOnFinish(ctx)
+
+	log.Println("Import finished!")
+	if err := p.printStats(); err != nil {
+		return err
+	}
+	return p.writeSuccessFile()
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmctl/backoff"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmctl/native"
+)
+
+func TestRunFromFile(t *testing.T) {
+	data := bytes.Repeat([]byte("native data "), 1000)
+	path := filepath.Join(t.TempDir(), "export.bin")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("cannot write source file: %s", err)
+	}
+
+	var (
+		mu       sync.Mutex
+		requests int
+		imported []byte
+	)
+	dst := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/import/native" {
+			t.Errorf("unexpected request path %q", r.URL.Path)
+		}
+		if got := r.URL.Query()["extra_label"]; len(got) != 1 || got[0] != "source=file" {
+			t.Errorf("unexpected extra labels %q", got)
+		}
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("cannot read request body: %s", err)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		requests++
+		if requests == 1 {
+			// the first attempt fails, so the file must be re-read from the start
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		imported = body
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer dst.Close()
+
+	bf, err := backoff.NewWithParams(3, 10*time.Millisecond, 0)
+	if err != nil {
+		t.Fatalf("cannot create backoff: %s", err)
+	}
+	p := &vmNativeProcessor{
+		src:     &native.Client{},
+		dst:     &native.Client{Addr: dst.URL, ExtraLabels: []string{"source=file"}},
+		backoff: bf,
+		srcFile: path,
+	}
+	if err := p.run(context.Background(), true); err != nil {
+		t.Fatalf("cannot import file: %s", err)
+	}
+	if requests != 2 {
+		t.Fatalf("expecting 2 import requests; got %d", requests)
+	}
+	if !bytes.Equal(imported, data) {
+		t.Fatalf("unexpected imported data of %d bytes; want %d bytes", len(imported), len(data))
+	}
+	if p.s.bytes != uint64(2*len(data)) || p.s.retries != 1 {
+		t.Fatalf("unexpected stats: bytes %d, retries %d", p.s.bytes, p.s.retries)
+	}
+}
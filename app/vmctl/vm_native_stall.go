@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+// stallWatchdog cancels the context of streaming export and import requests
+// if no data is transferred between them during the timeout.
+type stallWatchdog struct {
+	timeout time.Duration
+	cancel  context.CancelFunc
+	timer   *time.Timer
+	stalled int32
+}
+
+// newStallWatchdog returns ctx, which is canceled if sw.progress isn't called during the timeout
+func newStallWatchdog(ctx context.Context, timeout time.Duration) (context.Context, *stallWatchdog) {
+	ctx, cancel := context.WithCancel(ctx)
+	sw := &stallWatchdog{
+		timeout: timeout,
+		cancel:  cancel,
+	}
+	sw.timer = time.AfterFunc(timeout, func() {
+		atomic.StoreInt32(&sw.stalled, 1)
+		cancel()
+	})
+	return ctx, sw
+}
+
+// progress must be called every time data is transferred
+func (sw *stallWatchdog) progress() {
+	if atomic.LoadInt32(&sw.stalled) == 0 {
+		sw.timer.Reset(sw.timeout)
+	}
+}
+
+// stop stops sw and returns err of the request watched by sw.
+// The error of the stalled request doesn't wrap context.Canceled,
+// so the request is retried.
+func (sw *stallWatchdog) stop(err error) error {
+	sw.timer.Stop()
+	sw.cancel()
+	if err != nil && atomic.LoadInt32(&sw.stalled) == 1 {
+		return fmt.Errorf("no data was transferred during %s; see --%s: %s", sw.timeout, vmNativeHTTPTimeout, err)
+	}
+	return err
+}
+
+// reader returns r, which reports progress to sw on every read.
+// It returns r as is if sw is nil.
+func (sw *stallWatchdog) reader(r io.Reader) io.Reader {
+	if sw == nil {
+		return r
+	}
+	return &stallReader{r: r, sw: sw}
+}
+
+type stallReader struct {
+	r  io.Reader
+	sw *stallWatchdog
+}
+
+// Read implements io.Reader interface
+func (sr *stallReader) Read(p []byte) (int, error) {
+	n, err := sr.r.Read(p)
+	if n > 0 {
+		sr.sw.progress()
+	}
+	return n, err
+}
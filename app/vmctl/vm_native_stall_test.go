@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStallWatchdog(t *testing.T) {
+	// data is transferred, so the context isn't canceled
+	ctx, sw := newStallWatchdog(context.Background(), 200*time.Millisecond)
+	pr, pw := io.Pipe()
+	go func() {
+		for i := 0; i < 5; i++ {
+			time.Sleep(20 * time.Millisecond)
+			_, _ = pw.Write([]byte("data"))
+		}
+		_ = pw.Close()
+	}()
+	data, err := io.ReadAll(sw.reader(pr))
+	if err := sw.stop(err); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if string(data) != strings.Repeat("data", 5) {
+		t.Fatalf("unexpected data %q", data)
+	}
+	if ctx.Err() == nil {
+		t.Fatalf("expecting the context to be canceled after stop")
+	}
+
+	// no data is transferred, so the context is canceled
+	ctx, sw = newStallWatchdog(context.Background(), 50*time.Millisecond)
+	pr, pw = io.Pipe()
+	go func() {
+		<-ctx.Done()
+		_ = pw.CloseWithError(ctx.Err())
+	}()
+	_, err = io.ReadAll(sw.reader(pr))
+	err = sw.stop(err)
+	if err == nil || !strings.Contains(err.Error(), vmNativeHTTPTimeout) {
+		t.Fatalf("expecting stall error; got %v", err)
+	}
+	if errors.Is(err, context.Canceled) {
+		t.Fatalf("stall error mustn't wrap context.Canceled, since it prevents retries: %s", err)
+	}
+
+	// nil watchdog returns reader as is
+	var nilSW *stallWatchdog
+	r := strings.NewReader("foo")
+	if nilSW.reader(r) != io.Reader(r) {
+		t.Fatalf("expecting the reader to be returned as is")
+	}
+}
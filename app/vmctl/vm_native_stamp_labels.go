@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmctl/native"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompbmarshal"
+)
+
+const (
+	// stampLabelConflictOverwrite overwrites the existing label with the stamped value
+	stampLabelConflictOverwrite = "overwrite"
+	// stampLabelConflictError fails the migration if series already has the stamped label with another value
+	stampLabelConflictError = "error"
+)
+
+// parseStampLabels parses `name=value` labels set via --vm-native-stamp-label
+func parseStampLabels(ss []string) ([]prompbmarshal.Label, error) {
+	var labels []prompbmarshal.Label
+	for _, s := range ss {
+		name, value, ok := strings.Cut(s, "=")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("bad format for --%s, it must be `name=value`; got %q", vmNativeStampLabel, s)
+		}
+		if value == "" {
+			return nil, fmt.Errorf("empty value for label %q in --%s", name, vmNativeStampLabel)
+		}
+		if name == "__name__" {
+			return nil, fmt.Errorf("--%s can't change metric name; got %q", vmNativeStampLabel, s)
+		}
+		if hasLabel(labels, name) {
+			return nil, fmt.Errorf("duplicate label %q in --%s", name, vmNativeStampLabel)
+		}
+		labels = append(labels, prompbmarshal.Label{Name: name, Value: value})
+	}
+	return labels, nil
+}
+
+func validateStampLabelConflict(mode string) error {
+	switch mode {
+	case "", stampLabelConflictOverwrite, stampLabelConflictError:
+		return nil
+	default:
+		return fmt.Errorf("unsupported value %q for --%s; supported values: %q, %q",
+			mode, vmNativeStampLabelConflict, stampLabelConflictOverwrite, stampLabelConflictError)
+	}
+}
+
+// handleStampLabels adds bp.stampLabels to the metric name of b.
+// Unlike extra labels of import requests, the labels are added to the data itself,
+// so they are present regardless of the destination.
+func (bp *blockProcessor) handleStampLabels(b *native.Block) error {
+	if len(bp.stampLabels) == 0 || len(b.Timestamps) == 0 {
+		return nil
+	}
+	mn := &b.MetricName
+	for _, l := range bp.stampLabels {
+		if v := mn.GetTagValue(l.Name); len(v) > 0 {
+			if string(v) == l.Value {
+				continue
+			}
+			if bp.stampLabelConflict == stampLabelConflictError {
+				return fmt.Errorf("series %s already has label %s=%q conflicting with --%s=%s=%s; set --%s=%s in order to overwrite it",
+					mn.String(), l.Name, v, vmNativeStampLabel, l.Name, l.Value, vmNativeStampLabelConflict, stampLabelConflictOverwrite)
+			}
+			bp.stampOverwrittenSeries++
+			mn.RemoveTag(l.Name)
+		}
+		mn.AddTag(l.Name, l.Value)
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmctl/native"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promrelabel"
+)
+
+func TestParseStampLabels(t *testing.T) {
+	labels, err := parseStampLabels([]string{"source_cluster=east", "dc=us=1"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(labels) != 2 || labels[0].Name != "source_cluster" || labels[0].Value != "east" || labels[1].Name != "dc" || labels[1].Value != "us=1" {
+		t.Fatalf("unexpected labels: %v", labels)
+	}
+	for _, s := range []string{"foo", "=bar", "foo=", "__name__=bar"} {
+		if _, err := parseStampLabels([]string{s}); err == nil {
+			t.Fatalf("expecting error for %q", s)
+		}
+	}
+	if _, err := parseStampLabels([]string{"foo=bar", "foo=baz"}); err == nil {
+		t.Fatalf("expecting error for duplicate labels")
+	}
+}
+
+func TestBlockProcessorStampLabels(t *testing.T) {
+	stampLabels, err := parseStampLabels([]string{"source_cluster=east", "job=bar"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	f := func(b *native.Block, conflict, expSeries string, expOverwritten uint64) {
+		t.Helper()
+		bp := &blockProcessor{stampLabels: stampLabels, stampLabelConflict: conflict}
+		if err := bp.process(b); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if got := b.MetricName.String(); got != expSeries {
+			t.Fatalf("unexpected series; got %s; want %s", got, expSeries)
+		}
+		if bp.stampOverwrittenSeries != expOverwritten {
+			t.Fatalf("unexpected number of overwritten series; got %d; want %d", bp.stampOverwrittenSeries, expOverwritten)
+		}
+	}
+	// the same value of job label isn't a conflict
+	f(newTestBlock("", []int64{1}, []float64{1}), stampLabelConflictError, `foo{job="bar",source_cluster="east"}`, 0)
+
+	conflicting := func() *native.Block {
+		return newTestBlock("source_cluster", []int64{1}, []float64{1})
+	}
+	f(conflicting(), stampLabelConflictOverwrite, `foo{job="bar",source_cluster="east"}`, 1)
+
+	bp := &blockProcessor{stampLabels: stampLabels, stampLabelConflict: stampLabelConflictError}
+	err = bp.process(conflicting())
+	if err == nil || !strings.Contains(err.Error(), `already has label source_cluster="baz"`) {
+		t.Fatalf("expecting conflict error; got %v", err)
+	}
+
+	// labels are stamped after relabeling
+	pcs, err := promrelabel.ParseRelabelConfigsData([]byte(`
+- action: labeldrop
+  regex: "source_cluster"
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	b := newTestBlock("", []int64{1}, []float64{1})
+	bp = &blockProcessor{stampLabels: stampLabels, relabelConfigs: pcs}
+	if err := bp.process(b); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got, exp := b.MetricName.String(), `foo{job="bar",source_cluster="east"}`; got != exp {
+		t.Fatalf("unexpected series; got %s; want %s", got, exp)
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// tenantStats contains per-tenant statistics for the breakdown in the final stats
+type tenantStats struct {
+	bytes    uint64
+	requests uint64
+	// durations contains durations of migration units including retries
+	durations []time.Duration
+}
+
+// countRequest accounts the request of u with the given number of imported bytes
+func (s *stats) countRequest(u *migrationUnit, written int64) {
+	s.Lock()
+	defer s.Unlock()
+	s.bytes += uint64(written)
+	s.requests++
+	ts := s.tenant(u.tenantID)
+	ts.bytes += uint64(written)
+	ts.requests++
+}
+
+// countUnitDuration accounts the duration of migrating u including retries
+func (s *stats) countUnitDuration(u *migrationUnit, d time.Duration) {
+	s.Lock()
+	defer s.Unlock()
+	ts := s.tenant(u.tenantID)
+	ts.durations = append(ts.durations, d)
+}
+
+// tenant returns stats for tenantID. It must be called under s lock.
+func (s *stats) tenant(tenantID string) *tenantStats {
+	if s.tenants == nil {
+		s.tenants = make(map[string]*tenantStats)
+	}
+	ts, ok := s.tenants[tenantID]
+	if !ok {
+		ts = &tenantStats{}
+		s.tenants[tenantID] = ts
+	}
+	return ts
+}
+
+// breakdown returns per-tenant stats and the histogram of migration units durations.
+// It is empty unless there are multiple tenants or s.verbose is set.
+// It must be called under s lock.
+func (s *stats) breakdown() string {
+	if len(s.tenants) == 0 || (len(s.tenants) == 1 && !s.verbose) {
+		return ""
+	}
+	tenants := make([]string, 0, len(s.tenants))
+	var durations []time.Duration
+	for tenantID, ts := range s.tenants {
+		tenants = append(tenants, tenantID)
+		durations = append(durations, ts.durations...)
+	}
+	sort.Strings(tenants)
+
+	str := "\n  request durations: " + durationsHistogram(durations) + ";"
+	for _, tenantID := range tenants {
+		ts := s.tenants[tenantID]
+		name := tenantID
+		if name == "" {
+			name = "default"
+		}
+		var total time.Duration
+		for _, d := range ts.durations {
+			total += d
+		}
+		bytesPerS := byteCountSI(0)
+		if total > 0 {
+			bytesPerS = byteCountSI(int64(float64(ts.bytes) / total.Seconds()))
+		}
+		str += fmt.Sprintf("\n  tenant %s: %d requests; %s; %s/s per request; durations: %s;",
+			name, ts.requests, byteCountSI(int64(ts.bytes)), bytesPerS, durationsHistogram(ts.durations))
+	}
+	return str
+}
+
+// durationsHistogram returns min, p50, p90 and max of durations
+func durationsHistogram(durations []time.Duration) string {
+	if len(durations) == 0 {
+		return "n/a"
+	}
+	sorted := append([]time.Duration(nil), durations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	q := func(phi float64) time.Duration {
+		return sorted[int(phi*float64(len(sorted)-1))]
+	}
+	return fmt.Sprintf("min=%s, p50=%s, p90=%s, max=%s",
+		q(0).Truncate(time.Millisecond), q(0.5).Truncate(time.Millisecond),
+		q(0.9).Truncate(time.Millisecond), q(1).Truncate(time.Millisecond))
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDurationsHistogram(t *testing.T) {
+	f := func(durations []time.Duration, exp string) {
+		t.Helper()
+		if got := durationsHistogram(durations); got != exp {
+			t.Fatalf("unexpected histogram; got %q; want %q", got, exp)
+		}
+	}
+	f(nil, "n/a")
+	f([]time.Duration{time.Second}, "min=1s, p50=1s, p90=1s, max=1s")
+	var durations []time.Duration
+	for i := 10; i > 0; i-- {
+		durations = append(durations, time.Duration(i)*time.Second)
+	}
+	f(durations, "min=1s, p50=5s, p90=9s, max=10s")
+	if durations[0] != 10*time.Second {
+		t.Fatalf("durations must be left unsorted")
+	}
+}
+
+func TestStatsBreakdown(t *testing.T) {
+	s := &stats{}
+	u := &migrationUnit{tenantID: "0:0"}
+	s.countRequest(u, 1000)
+	s.countUnitDuration(u, time.Second)
+	if got := s.breakdown(); got != "" {
+		t.Fatalf("unexpected breakdown for a single tenant: %q", got)
+	}
+	s.verbose = true
+	exp := "\n  request durations: min=1s, p50=1s, p90=1s, max=1s;" +
+		"\n  tenant 0:0: 1 requests; 1.0 kB; 1.0 kB/s per request; durations: min=1s, p50=1s, p90=1s, max=1s;"
+	if got := s.breakdown(); got != exp {
+		t.Fatalf("unexpected breakdown\ngot\n%s\nwant\n%s", got, exp)
+	}
+
+	s = &stats{}
+	for _, tenantID := range []string{"1:0", "0:0"} {
+		u := &migrationUnit{tenantID: tenantID}
+		s.countRequest(u, 100)
+		s.countUnitDuration(u, 2*time.Second)
+	}
+	got := s.breakdown()
+	if !strings.Contains(got, "tenant 0:0: 1 requests") || strings.Index(got, "tenant 0:0") > strings.Index(got, "tenant 1:0") {
+		t.Fatalf("expecting sorted breakdown for multiple tenants; got %q", got)
+	}
+	if s.bytes != 200 || s.requests != 2 {
+		t.Fatalf("unexpected totals: bytes %d, requests %d", s.bytes, s.requests)
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmctl/native"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmctl/stepper"
+)
+
+// subRanges tracks sub-ranges of a single unit migrated during previous attempts,
+// so retries migrate only the sub-ranges which weren't migrated yet.
+//
+// Native export streams series one by one, so the max written timestamp doesn't mean
+// that all the samples before it were imported. That's why retries are resumed
+// from the first failed sub-range rather than from the last written timestamp.
+type subRanges struct {
+	mu sync.Mutex
+	// done contains indexes of migrated sub-ranges
+	done map[int]struct{}
+}
+
+func (sr *subRanges) isDone(i int) bool {
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+	_, ok := sr.done[i]
+	return ok
+}
+
+func (sr *subRanges) markDone(i int) {
+	sr.mu.Lock()
+	if sr.done == nil {
+		sr.done = make(map[int]struct{})
+	}
+	sr.done[i] = struct{}{}
+	sr.mu.Unlock()
+}
+
+// splitUnit splits the time range of u into n sub-units
+func splitUnit(u *migrationUnit, n int) ([]*migrationUnit, error) {
+	f := u.filter
+	start, err := time.Parse(time.RFC3339, f.TimeStart)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse start time %q: %s", f.TimeStart, err)
+	}
+	end, err := time.Parse(time.RFC3339, f.TimeEnd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse end time %q: %s", f.TimeEnd, err)
+	}
+	ranges, err := stepper.SplitDateRangeEvenly(start, end, n)
+	if err != nil {
+		return nil, fmt.Errorf("failed to split time range for filter %s: %s", f, err)
+	}
+	units := make([]*migrationUnit, 0, len(ranges))
+	for _, times := range ranges {
+		subUnit := *u
+		subUnit.filter = native.Filter{
+			Match:     f.Match,
+			TimeStart: times[0].Format(time.RFC3339),
+			TimeEnd:   times[1].Format(time.RFC3339),
+		}
+		units = append(units, &subUnit)
+	}
+	return units, nil
+}
+
+// runParallel splits the time range of the given filter into p.intraUnitParallelism
+// sub-ranges and migrates them concurrently via separate export/import pipes.
+// The order of imported data doesn't matter, since VictoriaMetrics import is order-independent.
+// Sub-ranges marked as done in sr are skipped.
+func (p *vmNativeProcessor) runParallel(ctx context.Context, u *migrationUnit, sr *subRanges) error {
+	units, err := splitUnit(u, p.intraUnitParallelism)
+	if err != nil {
+		return err
+	}
+	if len(units) == 1 {
+		return p.runSingle(ctx, u)
+	}
+
+	errCh := make(chan error, len(units))
+	var wg sync.WaitGroup
+	for i, subUnit := range units {
+		if sr.isDone(i) {
+			p.countResumedSubRange()
+			continue
+		}
+		wg.Add(1)
+		go func(i int, subUnit *migrationUnit) {
+			defer wg.Done()
+			if err := p.runSingle(ctx, subUnit); err != nil {
+				errCh <- err
+				return
+			}
+			sr.markDone(i)
+		}(i, subUnit)
+	}
+	wg.Wait()
+	close(errCh)
+
+	for err := range errCh {
+		return err
+	}
+	return nil
+}
+
+// runSequential splits the time range of the given filter into p.retrySubRanges
+// sub-ranges and migrates them one by one. Sub-ranges marked as done in sr are skipped,
+// so a retry doesn't transfer the already migrated sub-ranges again.
+func (p *vmNativeProcessor) runSequential(ctx context.Context, u *migrationUnit, sr *subRanges) error {
+	units, err := splitUnit(u, p.retrySubRanges)
+	if err != nil {
+		return err
+	}
+	for i, subUnit := range units {
+		if sr.isDone(i) {
+			p.countResumedSubRange()
+			continue
+		}
+		if err := p.runSingle(ctx, subUnit); err != nil {
+			return err
+		}
+		sr.markDone(i)
+	}
+	return nil
+}
+
+// countResumedSubRange registers sub-range skipped on retry
+func (p *vmNativeProcessor) countResumedSubRange() {
+	p.s.Lock()
+	p.s.resumedSubRanges++
+	p.s.Unlock()
+}
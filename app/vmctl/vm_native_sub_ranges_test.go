@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmctl/native"
+)
+
+func TestRunSubRangesResume(t *testing.T) {
+	data := encodeTestBlocks(t, newTestBlock("", []int64{1, 2}, []float64{1, 2}))
+	var mu sync.Mutex
+	var exported []string
+	failStart := "2022-01-01T12:00:00Z"
+	src := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := r.URL.Query().Get("start")
+		mu.Lock()
+		defer mu.Unlock()
+		exported = append(exported, start)
+		if start == failStart {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write(data)
+	}))
+	defer src.Close()
+	dst := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer dst.Close()
+
+	f := func(parallel bool) {
+		t.Helper()
+		exported = nil
+		failStart = "2022-01-01T12:00:00Z"
+		p := &vmNativeProcessor{
+			src:                  &native.Client{Addr: src.URL},
+			dst:                  &native.Client{Addr: dst.URL},
+			s:                    &stats{},
+			retrySubRanges:       4,
+			intraUnitParallelism: 1,
+		}
+		run := p.runSequential
+		if parallel {
+			p.retrySubRanges, p.intraUnitParallelism = 1, 4
+			run = p.runParallel
+		}
+		u := newTestUnit("", "foo", "2022-01-01T00:00:00Z", "2022-01-02T00:00:00Z")
+		u.srcURL = src.URL + "/api/v1/export/native"
+		u.dstURL = dst.URL + "/api/v1/import/native"
+		sr := &subRanges{}
+		if err := run(context.Background(), u, sr); err == nil {
+			t.Fatalf("expecting error for the failed sub-range")
+		}
+		mu.Lock()
+		failStart = ""
+		firstAttempt := len(exported)
+		mu.Unlock()
+		if err := run(context.Background(), u, sr); err != nil {
+			t.Fatalf("unexpected error on retry: %s", err)
+		}
+		// only the failed sub-range and the sub-ranges which weren't started are exported on retry
+		retried := exported[firstAttempt:]
+		exp := []string{"2022-01-01T12:00:00Z", "2022-01-01T18:00:00Z"}
+		if parallel {
+			exp = []string{"2022-01-01T12:00:00Z"}
+		}
+		if !reflect.DeepEqual(retried, exp) {
+			t.Fatalf("unexpected sub-ranges exported on retry; got %q; want %q", retried, exp)
+		}
+		if want := uint64(4 - len(exp)); p.s.resumedSubRanges != want {
+			t.Fatalf("unexpected number of resumed sub-ranges %d; want %d", p.s.resumedSubRanges, want)
+		}
+	}
+	f(false)
+	f(true)
+}
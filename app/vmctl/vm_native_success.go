@@ -0,0 +1,71 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// successMarker is written to --vm-native-success-file on fully successful migration
+type successMarker struct {
+	StartedAt  string `json:"startedAt"`
+	FinishedAt string `json:"finishedAt"`
+	// ParamsHash is a hash of settings defining the scope of migration
+	ParamsHash string       `json:"paramsHash"`
+	Stats      successStats `json:"stats"`
+}
+
+type successStats struct {
+	Bytes    uint64 `json:"bytes"`
+	Requests uint64 `json:"requests"`
+	Retries  uint64 `json:"retries"`
+}
+
+// paramsHash returns a hash of settings defining the scope of migration
+func (p *vmNativeProcessor) paramsHash() string {
+	data, _ := json.Marshal(p.planSettings())
+	h := sha256.Sum256(data)
+	return hex.EncodeToString(h[:])
+}
+
+// removeSuccessFile removes the marker left by the previous run,
+// so the marker is absent unless the current run succeeds
+func (p *vmNativeProcessor) removeSuccessFile() error {
+	if p.successFile == "" {
+		return nil
+	}
+	if err := os.Remove(p.successFile); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("cannot remove %q: %w", p.successFile, err)
+	}
+	return nil
+}
+
+// writeSuccessFile atomically writes the marker of successful migration to p.successFile
+func (p *vmNativeProcessor) writeSuccessFile() error {
+	if p.successFile == "" {
+		return nil
+	}
+	p.s.Lock()
+	m := successMarker{
+		StartedAt:  p.s.startTime.UTC().Format(time.RFC3339),
+		FinishedAt: time.Now().UTC().Format(time.RFC3339),
+		ParamsHash: p.paramsHash(),
+		Stats: successStats{
+			Bytes:    p.s.bytes,
+			Requests: p.s.requests,
+			Retries:  p.s.retries,
+		},
+	}
+	p.s.Unlock()
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("cannot marshal success marker: %w", err)
+	}
+	if err := writeFileAtomic(p.successFile, data); err != nil {
+		return fmt.Errorf("cannot write success marker: %w", err)
+	}
+	return nil
+}
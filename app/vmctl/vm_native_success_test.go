@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmctl/native"
+)
+
+func TestSuccessFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "success.json")
+	p := &vmNativeProcessor{
+		src:         &native.Client{Addr: "http://src:8428"},
+		dst:         &native.Client{Addr: "http://dst:8428"},
+		filter:      native.Filter{Match: `{__name__!=""}`, TimeStart: "2022-01-01T00:00:00Z"},
+		successFile: path,
+		s:           &stats{startTime: time.Now(), bytes: 100, requests: 2, retries: 1},
+	}
+	if err := p.writeSuccessFile(); err != nil {
+		t.Fatalf("cannot write success file: %s", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("cannot read success file: %s", err)
+	}
+	var m successMarker
+	if err := json.Unmarshal(data, &m); err != nil {
+		t.Fatalf("cannot parse success file: %s", err)
+	}
+	if m.Stats.Bytes != 100 || m.Stats.Requests != 2 || m.Stats.Retries != 1 {
+		t.Fatalf("unexpected stats in success file: %+v", m.Stats)
+	}
+	hash := p.paramsHash()
+	if m.ParamsHash != hash || len(hash) != 64 {
+		t.Fatalf("unexpected params hash %q; want %q", m.ParamsHash, hash)
+	}
+	p.filter.Match = `{job="foo"}`
+	if p.paramsHash() == hash {
+		t.Fatalf("params hash must change with migration parameters")
+	}
+
+	if err := p.removeSuccessFile(); err != nil {
+		t.Fatalf("cannot remove success file: %s", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("success file must be removed; got %v", err)
+	}
+	// removing missing file isn't an error
+	if err := p.removeSuccessFile(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+}
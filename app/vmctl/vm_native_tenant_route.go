@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmctl/native"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/storage"
+)
+
+// tenantRouteConfig defines routing of series to destination tenants
+// by the value of the given label
+type tenantRouteConfig struct {
+	// label contains destination tenant
+	label string
+	// strip defines whether to remove label from imported series
+	strip bool
+	// defaultTenant is used for series without label or with invalid tenant in it
+	defaultTenant string
+
+	// importPath is the import path with extra labels
+	importPath string
+}
+
+func (rc *tenantRouteConfig) validate() error {
+	if !isValidTenant(rc.defaultTenant) {
+		return fmt.Errorf("invalid value %q for --%s; expecting `accountID` or `accountID:projectID`", rc.defaultTenant, vmNativeDstTenantDefault)
+	}
+	return nil
+}
+
+// isValidTenant checks whether s is in `accountID` or `accountID:projectID` format
+func isValidTenant(s string) bool {
+	accountID, projectID, ok := strings.Cut(s, ":")
+	if _, err := strconv.ParseUint(accountID, 10, 32); err != nil {
+		return false
+	}
+	if !ok {
+		return true
+	}
+	_, err := strconv.ParseUint(projectID, 10, 32)
+	return err == nil
+}
+
+// tenant returns destination tenant for mn and strips the label if needed
+func (rc *tenantRouteConfig) tenant(mn *storage.MetricName) string {
+	tenant := string(mn.GetTagValue(rc.label))
+	if tenant == "" {
+		return rc.defaultTenant
+	}
+	if !isValidTenant(tenant) {
+		// the label is left as is in order to not lose its value
+		logger.WithThrottler("invalid-dst-tenant", 5*time.Second).Warnf("series %s has invalid tenant %q in %q label; routing it to default tenant %q",
+			mn.String(), tenant, rc.label, rc.defaultTenant)
+		return rc.defaultTenant
+	}
+	if rc.strip {
+		mn.RemoveTag(rc.label)
+	}
+	return tenant
+}
+
+// routedImport is an import request to a single destination tenant
+type routedImport struct {
+	pw   *io.PipeWriter
+	enc  *native.Encoder
+	done chan struct{}
+	err  error
+}
+
+// countingWriter counts the number of bytes written to w
+type countingWriter struct {
+	w io.Writer
+	n *int64
+}
+
+func (cw countingWriter) Write(b []byte) (int, error) {
+	n, err := cw.w.Write(b)
+	*cw.n += int64(n)
+	return n, err
+}
+
+// importRouted decodes exported data from r and imports every series
+// into destination tenant from p.tenantRoute label.
+// Import requests to tenants are started lazily once the first series for them is met.
+func (p *vmNativeProcessor) importRouted(ctx context.Context, u *migrationUnit, r io.Reader) (int64, error) {
+	rc := p.tenantRoute
+	bp := p.newBlockProcessor()
+	d := native.NewDecoder(r)
+	tr, err := d.TimeRange()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			// empty export response
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	// the per-request limit is shared between tenants of the request
+	rl := p.newRequestRateLimiter()
+	var written int64
+	imports := make(map[string]*routedImport)
+	getImport := func(tenant string) *routedImport {
+		ri := imports[tenant]
+		if ri != nil {
+			return ri
+		}
+		dstURL := fmt.Sprintf("%s/insert/%s/prometheus/%s", p.dst.Addr, tenant, rc.importPath)
+		dstURL, header := p.stickyRoute(dstURL, u.metric)
+		pr, pw := io.Pipe()
+		ri = &routedImport{pw: pw, done: make(chan struct{})}
+		go func() {
+			defer close(ri.done)
+			if err := p.dst.ImportPipe(ctx, dstURL, pr, header); err != nil {
+				ri.err = fmt.Errorf("import to tenant %s failed: %w", tenant, err)
+				_ = pr.CloseWithError(ri.err)
+			}
+		}()
+		w := p.limitWriter(pw, rl)
+		ri.enc = native.NewEncoder(countingWriter{w: w, n: &written}, tr)
+		imports[tenant] = ri
+		return ri
+	}
+	finish := func(err error) error {
+		var wg sync.WaitGroup
+		for _, ri := range imports {
+			if err == nil {
+				err = ri.enc.Close()
+			}
+			_ = ri.pw.CloseWithError(err)
+			wg.Add(1)
+			go func(ri *routedImport) {
+				defer wg.Done()
+				<-ri.done
+			}(ri)
+		}
+		wg.Wait()
+		if err != nil {
+			return err
+		}
+		for _, ri := range imports {
+			if ri.err != nil {
+				return ri.err
+			}
+		}
+		return nil
+	}
+
+	var b native.Block
+	for {
+		if err := d.Next(&b); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return written, finish(err)
+		}
+		if err := bp.process(&b); err != nil {
+			return written, finish(err)
+		}
+		if len(b.Timestamps) == 0 {
+			continue
+		}
+		tenant := rc.tenant(&b.MetricName)
+		if err := getImport(tenant).enc.Encode(&b); err != nil {
+			return written, finish(fmt.Errorf("failed to write into tenant %s: %w", tenant, err))
+		}
+	}
+	if err := finish(nil); err != nil {
+		return written, err
+	}
+	bp.flushStats(p.s)
+	return written, nil
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmctl/native"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/storage"
+)
+
+func TestIsValidTenant(t *testing.T) {
+	f := func(s string, exp bool) {
+		t.Helper()
+		if got := isValidTenant(s); got != exp {
+			t.Fatalf("unexpected result for %q; got %v; want %v", s, got, exp)
+		}
+	}
+	f("0", true)
+	f("1:2", true)
+	f("", false)
+	f("foo", false)
+	f("1:", false)
+	f("1:foo", false)
+	f("-1", false)
+}
+
+func TestImportRouted(t *testing.T) {
+	var mu sync.Mutex
+	received := make(map[string][]string)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		d := native.NewDecoder(r.Body)
+		var b native.Block
+		for {
+			if err := d.Next(&b); err != nil {
+				if err != io.EOF {
+					t.Errorf("cannot decode import request: %s", err)
+				}
+				break
+			}
+			mu.Lock()
+			received[r.URL.Path] = append(received[r.URL.Path], b.MetricName.String())
+			mu.Unlock()
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	var src bytes.Buffer
+	e := native.NewEncoder(&src, storage.TimeRange{MinTimestamp: 0, MaxTimestamp: 100})
+	for _, tenant := range []string{"1", "2:3", "", "1", "foo"} {
+		var b native.Block
+		b.MetricName.MetricGroup = []byte("metric")
+		b.MetricName.AddTag("job", "test"+tenant)
+		if tenant != "" {
+			b.MetricName.AddTag("tenant_id", tenant)
+		}
+		b.Timestamps = []int64{10}
+		b.Values = []float64{1}
+		if err := e.Encode(&b); err != nil {
+			t.Fatalf("cannot encode block: %s", err)
+		}
+	}
+	if err := e.Close(); err != nil {
+		t.Fatalf("cannot close encoder: %s", err)
+	}
+
+	p := &vmNativeProcessor{
+		dst: &native.Client{Addr: srv.URL},
+		s:   &stats{},
+		tenantRoute: &tenantRouteConfig{
+			label:         "tenant_id",
+			strip:         true,
+			defaultTenant: "0",
+			importPath:    nativeImportAddr,
+		},
+	}
+	written, err := p.importRouted(context.Background(), &migrationUnit{metric: "metric"}, &src)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if written == 0 {
+		t.Fatalf("expecting non-zero written bytes")
+	}
+
+	exp := map[string][]string{
+		"/insert/0/prometheus/api/v1/import/native":   {`metric{job="test"}`, `metric{job="testfoo",tenant_id="foo"}`},
+		"/insert/1/prometheus/api/v1/import/native":   {`metric{job="test1"}`, `metric{job="test1"}`},
+		"/insert/2:3/prometheus/api/v1/import/native": {`metric{job="test2:3"}`},
+	}
+	if len(received) != len(exp) {
+		t.Fatalf("unexpected number of import requests; got %d; want %d: %v", len(received), len(exp), received)
+	}
+	for path, series := range exp {
+		got := received[path]
+		sort.Strings(got)
+		if len(got) != len(series) {
+			t.Fatalf("unexpected series for %q; got %v; want %v", path, got, series)
+		}
+		for i := range got {
+			if got[i] != series[i] {
+				t.Fatalf("unexpected series for %q; got %v; want %v", path, got, series)
+			}
+		}
+	}
+}
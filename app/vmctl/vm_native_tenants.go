@@ -0,0 +1,226 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmctl/native"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+)
+
+// discoverTenants returns tenants to migrate from the source.
+// If the source doesn't support tenants discovery, e.g. it is an old cluster version
+// without tenants API, p.srcTenants are returned instead.
+// Tenants are read from p.tenantsCache if it is set and contains tenants discovered for the same source and time range.
+func (p *vmNativeProcessor) discoverTenants(ctx context.Context) ([]string, error) {
+	if tenants := p.tenantsCache.load(p.src.Addr, p.filter, time.Now()); tenants != nil {
+		log.Printf("Using %d tenants discovered at %s from --%s; set --%s to discover them again",
+			len(tenants), p.tenantsCache.discoveredAt.Format(time.RFC3339), vmNativeTenantsCacheFile, vmNativeRefreshTenants)
+		return tenants, nil
+	}
+	tenants, err := p.src.GetSourceTenants(ctx, p.filter)
+	if err == nil {
+		if err := p.tenantsCache.store(p.src.Addr, p.filter, tenants, time.Now()); err != nil {
+			// the cache only speeds up subsequent runs, so the migration can proceed without it
+			logger.Warnf("cannot update --%s: %s", vmNativeTenantsCacheFile, err)
+		}
+		return tenants, nil
+	}
+	if !isUnsupportedError(err) {
+		return nil, fmt.Errorf("failed to get tenants: %w", err)
+	}
+	if len(p.srcTenants) == 0 {
+		return nil, fmt.Errorf("failed to get tenants: %w; the source probably doesn't support tenants discovery, "+
+			"so set the list of tenants to migrate via --%s, e.g. --%s=0:0,1:0", err, vmNativeSrcTenants, vmNativeSrcTenants)
+	}
+	logger.Warnf("cannot discover tenants: %s; falling back to --%s=%s", err, vmNativeSrcTenants, p.srcTenants)
+	return p.srcTenants, nil
+}
+
+// errNoMetrics is returned if there are no metrics to migrate
+var errNoMetrics = errors.New("no metrics found")
+
+// skipEmptyTenant returns true if err means that the tenant has no metrics to migrate
+// and such tenants must be skipped according to p.skipEmptyTenants.
+func (p *vmNativeProcessor) skipEmptyTenant(tenantID string, err error) bool {
+	if !p.skipEmptyTenants || !errors.Is(err, errNoMetrics) {
+		return false
+	}
+	logger.Warnf("skipping tenant %s: %s", tenantID, err)
+	p.s.Lock()
+	p.s.emptyTenants++
+	p.s.Unlock()
+	return true
+}
+
+// parseTenantFilter returns regexp matching tenants by any of the given filters.
+// Every filter is either a tenant, e.g. `1:0`, or a regexp matching the whole tenant, e.g. `1[0-9]:0`.
+// It returns nil if filters are empty.
+func parseTenantFilter(filters []string) (*regexp.Regexp, error) {
+	if len(filters) == 0 {
+		return nil, nil
+	}
+	exprs := make([]string, 0, len(filters))
+	for _, f := range filters {
+		if _, err := regexp.Compile(f); err != nil {
+			return nil, fmt.Errorf("cannot parse --%s=%q: %w", vmNativeTenantFilter, f, err)
+		}
+		exprs = append(exprs, "(?:"+f+")")
+	}
+	return regexp.Compile("^(?:" + strings.Join(exprs, "|") + ")$")
+}
+
+// filterTenants returns tenants matching p.tenantFilter
+func (p *vmNativeProcessor) filterTenants(tenants []string) ([]string, error) {
+	if p.tenantFilter == nil {
+		return tenants, nil
+	}
+	var filtered []string
+	for _, tenant := range tenants {
+		if p.tenantFilter.MatchString(tenant) {
+			filtered = append(filtered, tenant)
+		}
+	}
+	if len(filtered) == 0 {
+		return nil, fmt.Errorf("none of discovered tenants %s match --%s", tenants, vmNativeTenantFilter)
+	}
+	log.Printf("%d of %d discovered tenants match --%s", len(filtered), len(tenants), vmNativeTenantFilter)
+	return filtered, nil
+}
+
+func logTenantMigrated(tenantID string) {
+	logEvent(fmt.Sprintf("Tenant %s migrated", tenantID), logFields{"event": "tenant_finish", "tenant": tenantID})
+}
+
+// isUnsupportedError returns true if err means that the requested API isn't supported by the server
+func isUnsupportedError(err error) bool {
+	var sce *native.StatusCodeError
+	if !errors.As(err, &sce) {
+		return false
+	}
+	switch sce.StatusCode {
+	case http.StatusNotFound, http.StatusBadRequest, http.StatusNotImplemented:
+		return true
+	}
+	return false
+}
+
+// runTenants migrates the given tenants sequentially or concurrently
+// with p.tenantCC workers if it is greater than 1.
+func (p *vmNativeProcessor) runTenants(ctx context.Context, tenants []string, tenantMetrics map[string]map[string]struct{}, ranges [][]time.Time, silent bool) error {
+	if p.tenantCC <= 1 || len(tenants) <= 1 {
+		for i, tenantID := range tenants {
+			if p.budgetReached() {
+				p.leaveTenants(tenants[i:], tenantMetrics, ranges)
+				break
+			}
+			p.progress.setTenantIndex(i + 1)
+			if err := p.runBackfilling(ctx, tenantID, tenantMetrics[tenantID], ranges, silent); err != nil {
+				if p.skipEmptyTenant(tenantID, err) {
+					continue
+				}
+				return err
+			}
+			if p.interCluster {
+				logTenantMigrated(tenantID)
+			}
+		}
+		return nil
+	}
+
+	cc := p.tenantCC
+	if cc > len(tenants) {
+		cc = len(tenants)
+	}
+	if p.perTenantCC <= 0 {
+		// split the global concurrency evenly between concurrently migrated tenants
+		p.perTenantCC = p.cc / cc
+		if p.perTenantCC < 1 {
+			p.perTenantCC = 1
+		}
+	}
+	if p.perTenantCC > p.cc {
+		p.perTenantCC = p.cc
+	}
+	// importSem limits the total number of concurrent requests across all tenants
+	p.importSem = make(chan struct{}, p.cc)
+	log.Printf("Migrating %d tenants with concurrency %d; import concurrency: %d in total, %d per tenant",
+		len(tenants), cc, p.cc, p.perTenantCC)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		mu       sync.Mutex
+		firstErr error
+	)
+	tenantsCh := make(chan string)
+	var wg sync.WaitGroup
+	for i := 0; i < cc; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for tenantID := range tenantsCh {
+				if err := p.runBackfilling(ctx, tenantID, tenantMetrics[tenantID], ranges, silent); err != nil {
+					if p.skipEmptyTenant(tenantID, err) {
+						continue
+					}
+					mu.Lock()
+					if firstErr == nil {
+						firstErr = fmt.Errorf("tenant %s: %w", tenantID, err)
+					}
+					mu.Unlock()
+					cancel()
+					return
+				}
+				logTenantMigrated(tenantID)
+			}
+		}()
+	}
+
+feed:
+	for i, tenantID := range tenants {
+		if p.budgetReached() {
+			p.leaveTenants(tenants[i:], tenantMetrics, ranges)
+			break
+		}
+		select {
+		case <-ctx.Done():
+			break feed
+		case tenantsCh <- tenantID:
+			p.progress.setTenantIndex(i + 1)
+		}
+	}
+	close(tenantsCh)
+	wg.Wait()
+
+	return firstErr
+}
+
+// acquireImportSlot blocks until a slot in the shared import pool is available.
+// It returns false if ctx is canceled.
+func (p *vmNativeProcessor) acquireImportSlot(ctx context.Context) bool {
+	if p.importSem == nil {
+		return true
+	}
+	select {
+	case <-ctx.Done():
+		return false
+	case p.importSem <- struct{}{}:
+		return true
+	}
+}
+
+func (p *vmNativeProcessor) releaseImportSlot() {
+	if p.importSem == nil {
+		return
+	}
+	<-p.importSem
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmctl/native"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+)
+
+// tenantsCache persists discovered tenants between runs,
+// since tenants discovery may be slow on large clusters,
+// while the set of tenants usually stays the same during phased migration.
+type tenantsCache struct {
+	path string
+	// ttl is the max age of the cached tenants; zero means no limit
+	ttl time.Duration
+	// refresh forces discovering tenants again
+	refresh bool
+
+	// discoveredAt is the discovery time of the tenants returned by the last load
+	discoveredAt time.Time
+}
+
+// tenantsCacheEntry is the content of tenantsCache file
+type tenantsCacheEntry struct {
+	Src          string    `json:"src"`
+	TimeStart    string    `json:"time_start,omitempty"`
+	TimeEnd      string    `json:"time_end,omitempty"`
+	DiscoveredAt time.Time `json:"discovered_at"`
+	Tenants      []string  `json:"tenants"`
+}
+
+// load returns tenants cached for the given src and filter.
+// It returns nil if tc is nil, the cache is missing, stale, refresh is forced
+// or tenants were discovered for another source or time range.
+func (tc *tenantsCache) load(src string, f native.Filter, now time.Time) []string {
+	if tc == nil || tc.refresh {
+		return nil
+	}
+	data, err := os.ReadFile(tc.path)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			logger.Warnf("cannot read --%s: %s; tenants will be discovered again", vmNativeTenantsCacheFile, err)
+		}
+		return nil
+	}
+	var e tenantsCacheEntry
+	if err := json.Unmarshal(data, &e); err != nil {
+		logger.Warnf("cannot parse --%s=%q: %s; tenants will be discovered again", vmNativeTenantsCacheFile, tc.path, err)
+		return nil
+	}
+	switch {
+	case e.Src != src || e.TimeStart != f.TimeStart || e.TimeEnd != f.TimeEnd:
+		return nil
+	case tc.ttl > 0 && now.Sub(e.DiscoveredAt) > tc.ttl:
+		return nil
+	case len(e.Tenants) == 0:
+		return nil
+	}
+	tc.discoveredAt = e.DiscoveredAt
+	return e.Tenants
+}
+
+// store writes tenants discovered for the given src and filter to the cache.
+// It is no-op if tc is nil.
+func (tc *tenantsCache) store(src string, f native.Filter, tenants []string, now time.Time) error {
+	if tc == nil {
+		return nil
+	}
+	data, err := json.Marshal(&tenantsCacheEntry{
+		Src:          src,
+		TimeStart:    f.TimeStart,
+		TimeEnd:      f.TimeEnd,
+		DiscoveredAt: now.UTC(),
+		Tenants:      tenants,
+	})
+	if err != nil {
+		return fmt.Errorf("cannot marshal tenants: %w", err)
+	}
+	return writeFileAtomic(tc.path, data)
+}
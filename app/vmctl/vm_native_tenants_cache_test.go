@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmctl/native"
+)
+
+func TestTenantsCache(t *testing.T) {
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	f := native.Filter{TimeStart: "2022-01-01T00:00:00Z"}
+	tc := &tenantsCache{path: filepath.Join(t.TempDir(), "tenants.json"), ttl: time.Hour}
+
+	if tenants := tc.load("http://src", f, now); tenants != nil {
+		t.Fatalf("unexpected tenants for missing cache: %q", tenants)
+	}
+	if err := tc.store("http://src", f, []string{"0:0", "1:0"}, now); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	exp := []string{"0:0", "1:0"}
+	if tenants := tc.load("http://src", f, now.Add(time.Minute)); !reflect.DeepEqual(tenants, exp) {
+		t.Fatalf("unexpected tenants; got %q; want %q", tenants, exp)
+	}
+	if !tc.discoveredAt.Equal(now) {
+		t.Fatalf("unexpected discovery time %s; want %s", tc.discoveredAt, now)
+	}
+
+	// the cache is ignored for another source, time range or after ttl
+	if tenants := tc.load("http://other", f, now); tenants != nil {
+		t.Fatalf("unexpected tenants for another source: %q", tenants)
+	}
+	if tenants := tc.load("http://src", native.Filter{TimeStart: "2022-06-01T00:00:00Z"}, now); tenants != nil {
+		t.Fatalf("unexpected tenants for another time range: %q", tenants)
+	}
+	if tenants := tc.load("http://src", f, now.Add(2*time.Hour)); tenants != nil {
+		t.Fatalf("unexpected tenants for stale cache: %q", tenants)
+	}
+	tc.refresh = true
+	if tenants := tc.load("http://src", f, now); tenants != nil {
+		t.Fatalf("unexpected tenants for forced refresh: %q", tenants)
+	}
+
+	var nilCache *tenantsCache
+	if tenants := nilCache.load("http://src", f, now); tenants != nil {
+		t.Fatalf("unexpected tenants for nil cache: %q", tenants)
+	}
+	if err := nilCache.store("http://src", f, exp, now); err != nil {
+		t.Fatalf("unexpected error for nil cache: %s", err)
+	}
+}
+
+func TestDiscoverTenantsCached(t *testing.T) {
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		_, _ = w.Write([]byte(`{"status":"success","data":["0:0","1:0"]}`))
+	}))
+	defer srv.Close()
+
+	path := filepath.Join(t.TempDir(), "tenants.json")
+	discover := func(refresh bool) []string {
+		t.Helper()
+		p := &vmNativeProcessor{
+			src:          &native.Client{Addr: srv.URL},
+			tenantsCache: &tenantsCache{path: path, refresh: refresh},
+		}
+		tenants, err := p.discoverTenants(context.Background())
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		return tenants
+	}
+	exp := []string{"0:0", "1:0"}
+	for i, refresh := range []bool{false, false, true} {
+		if tenants := discover(refresh); !reflect.DeepEqual(tenants, exp) {
+			t.Fatalf("unexpected tenants at run %d; got %q; want %q", i, tenants, exp)
+		}
+	}
+	// the second run uses the cache
+	if n := atomic.LoadInt32(&requests); n != 2 {
+		t.Fatalf("unexpected number of discovery requests; got %d; want 2", n)
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmctl/native"
+)
+
+func TestDiscoverTenants(t *testing.T) {
+	f := func(statusCode int, srcTenants, expTenants []string, expErr bool) {
+		t.Helper()
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if statusCode != http.StatusOK {
+				w.WriteHeader(statusCode)
+				return
+			}
+			_, _ = w.Write([]byte(`{"status":"success","data":["0:0","1:0"]}`))
+		}))
+		defer srv.Close()
+
+		p := &vmNativeProcessor{
+			src:        &native.Client{Addr: srv.URL},
+			srcTenants: srcTenants,
+		}
+		tenants, err := p.discoverTenants(context.Background())
+		if (err != nil) != expErr {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !reflect.DeepEqual(tenants, expTenants) {
+			t.Fatalf("unexpected tenants; got %q; want %q", tenants, expTenants)
+		}
+	}
+	// discovered tenants take precedence over the list
+	f(http.StatusOK, nil, []string{"0:0", "1:0"}, false)
+	f(http.StatusOK, []string{"2:0"}, []string{"0:0", "1:0"}, false)
+	// fallback to the list if discovery isn't supported
+	f(http.StatusNotFound, []string{"2:0", "3"}, []string{"2:0", "3"}, false)
+	f(http.StatusBadRequest, []string{"2:0"}, []string{"2:0"}, false)
+	f(http.StatusNotFound, nil, nil, true)
+	// other errors aren't hidden by the list
+	f(http.StatusServiceUnavailable, []string{"2:0"}, nil, true)
+}
+
+func TestFilterTenants(t *testing.T) {
+	tenants := []string{"0:0", "1:0", "10:0", "11:1", "2:0"}
+	f := func(filters, expTenants []string, expErr bool) {
+		t.Helper()
+		re, err := parseTenantFilter(filters)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		p := &vmNativeProcessor{tenantFilter: re}
+		got, err := p.filterTenants(tenants)
+		if (err != nil) != expErr {
+			t.Fatalf("unexpected error for %q: %v", filters, err)
+		}
+		if !reflect.DeepEqual(got, expTenants) {
+			t.Fatalf("unexpected tenants for %q; got %q; want %q", filters, got, expTenants)
+		}
+	}
+	f(nil, tenants, false)
+	// the whole tenant must match
+	f([]string{"1:0"}, []string{"1:0"}, false)
+	f([]string{"0:0", "2:0"}, []string{"0:0", "2:0"}, false)
+	f([]string{"1[0-9]:.*"}, []string{"10:0", "11:1"}, false)
+	f([]string{"3:0"}, nil, true)
+
+	if _, err := parseTenantFilter([]string{"1:0", "("}); err == nil {
+		t.Fatalf("expecting error for invalid regexp")
+	}
+}
+
+func TestRunTenantsSkipEmpty(t *testing.T) {
+	f := func(skipEmptyTenants bool, tenantCC int) error {
+		t.Helper()
+		p := &vmNativeProcessor{
+			src:              &native.Client{Addr: "http://src"},
+			dst:              &native.Client{Addr: "http://dst"},
+			s:                &stats{},
+			cc:               1,
+			tenantCC:         tenantCC,
+			interCluster:     true,
+			skipEmptyTenants: skipEmptyTenants,
+		}
+		ranges := [][]time.Time{{time.Unix(0, 0), time.Unix(3600, 0)}}
+		// none of the tenants has metrics
+		err := p.runTenants(context.Background(), []string{"0:0", "1:0"}, map[string]map[string]struct{}{}, ranges, true)
+		if skipEmptyTenants && p.s.emptyTenants != 2 {
+			t.Fatalf("unexpected number of skipped tenants; got %d; want 2", p.s.emptyTenants)
+		}
+		return err
+	}
+	for _, tenantCC := range []int{1, 2} {
+		if err := f(true, tenantCC); err != nil {
+			t.Fatalf("unexpected error with tenant concurrency %d: %s", tenantCC, err)
+		}
+		if err := f(false, tenantCC); !errors.Is(err, errNoMetrics) {
+			t.Fatalf("expecting errNoMetrics with tenant concurrency %d; got %v", tenantCC, err)
+		}
+	}
+}
@@ -2,14 +2,18 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmctl/backoff"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmctl/limiter"
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmctl/native"
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmctl/stepper"
 	remote_read_integration "github.com/VictoriaMetrics/VictoriaMetrics/app/vmctl/testdata/servers_integration_test"
@@ -348,7 +352,7 @@ func Test_buildMatchWithFilter(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := buildMatchWithFilter(tt.filter, tt.metricName)
+			got, err := buildMatchWithFilter(tt.filter, tt.metricName, nil)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("buildMatchWithFilter() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -359,3 +363,150 @@ func Test_buildMatchWithFilter(t *testing.T) {
 		})
 	}
 }
+
+func TestParseExcludeMatch(t *testing.T) {
+	f := func(s, exp string, expErr bool) {
+		t.Helper()
+		got, err := parseExcludeMatch(s)
+		if (err != nil) != expErr {
+			t.Fatalf("unexpected error for %q: %v", s, err)
+		}
+		if got != exp {
+			t.Fatalf("unexpected result for %q; got %s; want %s", s, got, exp)
+		}
+	}
+	f(`{__name__!~"go_.*"}`, `{__name__!~"go_.*"}`, false)
+	f(`{__name__!~"go_.*", job!="test"}`, `{__name__!~"go_.*", job!="test"}`, false)
+	f(`{__name__=~"go_.*"}`, "", true)
+	f(`go_gc_duration_seconds`, "", true)
+	f(`{}`, "", true)
+	f(`sum(foo)`, "", true)
+	f(`{`, "", true)
+}
+
+func TestLimitWriter(t *testing.T) {
+	f := func(p *vmNativeProcessor, minDuration time.Duration) {
+		t.Helper()
+		data := make([]byte, 1000)
+		start := time.Now()
+		var wg sync.WaitGroup
+		for i := 0; i < 2; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				w := p.limitWriter(io.Discard, p.newRequestRateLimiter())
+				if _, err := w.Write(data); err != nil {
+					t.Errorf("unexpected error: %s", err)
+				}
+			}()
+		}
+		wg.Wait()
+		if d := time.Since(start); d < minDuration {
+			t.Fatalf("writes finished too fast; got %s; want at least %s", d, minDuration)
+		}
+	}
+	// per-request limits don't affect concurrent requests
+	f(&vmNativeProcessor{rateLimit: 1000}, 0)
+	// the global limit is shared between concurrent requests
+	f(&vmNativeProcessor{globalRateLimiter: limiter.NewLimiter(1000)}, 900*time.Millisecond)
+}
+
+func TestStatsMarshalJSON(t *testing.T) {
+	s := &stats{
+		startTime: time.Now().Add(-10 * time.Second),
+		bytes:     1000,
+		requests:  5,
+		retries:   2,
+	}
+	data, err := json.Marshal(s)
+	if err != nil {
+		t.Fatalf("cannot marshal stats: %s", err)
+	}
+	var got map[string]interface{}
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("cannot unmarshal stats %s: %s", data, err)
+	}
+	for _, key := range []string{"duration_seconds", "total_bytes", "bytes_per_second", "requests", "retries"} {
+		if _, ok := got[key]; !ok {
+			t.Fatalf("missing %q in %s", key, data)
+		}
+	}
+	if got["total_bytes"] != float64(1000) || got["requests"] != float64(5) || got["retries"] != float64(2) {
+		t.Fatalf("unexpected stats %s", data)
+	}
+	if d := got["duration_seconds"].(float64); d < 10 {
+		t.Fatalf("unexpected duration_seconds %v", d)
+	}
+	if bps := got["bytes_per_second"].(float64); bps <= 0 || bps > 100 {
+		t.Fatalf("unexpected bytes_per_second %v", bps)
+	}
+
+	if err := validateStatsFormat("yaml"); err == nil {
+		t.Fatalf("expecting error for unsupported stats format")
+	}
+}
+
+func TestOrderUnits(t *testing.T) {
+	units := []*migrationUnit{
+		newTestUnit("", "foo", "2022-01-01T00:00:00Z", "2022-01-02T00:00:00Z"),
+		newTestUnit("", "foo", "2022-01-02T00:00:00Z", "2022-01-03T00:00:00Z"),
+		newTestUnit("", "foo", "2022-01-03T00:00:00Z", "2022-01-04T00:00:00Z"),
+	}
+	f := func(order string, want ...string) {
+		t.Helper()
+		got := orderUnits(units, order)
+		if len(got) != len(want) {
+			t.Fatalf("unexpected number of units; got %d; want %d", len(got), len(want))
+		}
+		for i, u := range got {
+			if u.filter.TimeStart != want[i] {
+				t.Fatalf("unexpected unit #%d for order %q; got start %s; want %s", i, order, u.filter.TimeStart, want[i])
+			}
+		}
+	}
+	f("", "2022-01-01T00:00:00Z", "2022-01-02T00:00:00Z", "2022-01-03T00:00:00Z")
+	f(chunkOrderAsc, "2022-01-01T00:00:00Z", "2022-01-02T00:00:00Z", "2022-01-03T00:00:00Z")
+	f(chunkOrderDesc, "2022-01-03T00:00:00Z", "2022-01-02T00:00:00Z", "2022-01-01T00:00:00Z")
+	if units[0].filter.TimeStart != "2022-01-01T00:00:00Z" {
+		t.Fatalf("the original units must not be modified")
+	}
+	if err := validateChunkOrder("random"); err == nil {
+		t.Fatalf("expecting error for unsupported chunk order")
+	}
+}
+
+func TestTotalRequests(t *testing.T) {
+	tenantMetrics := map[string]map[string]struct{}{
+		"0:0": {"foo": {}, "bar": {}},
+		"1:0": {"foo": {}},
+	}
+	ranges := make([][]time.Time, 3)
+
+	p := &vmNativeProcessor{}
+	if n := p.totalRequests(tenantMetrics, ranges); n != 9 {
+		t.Fatalf("unexpected number of requests; got %d; want %d", n, 9)
+	}
+	p.labelChunks = &labelChunkConfig{label: "instance", buckets: 4}
+	if n := p.totalRequests(tenantMetrics, ranges); n != 36 {
+		t.Fatalf("unexpected number of requests with label chunks; got %d; want %d", n, 36)
+	}
+}
+
+func TestNativeEndpointPaths(t *testing.T) {
+	p := &vmNativeProcessor{}
+	if p.srcExportPath() != nativeExportAddr || p.dstImportPath() != nativeImportAddr {
+		t.Fatalf("expecting default paths; got %q and %q", p.srcExportPath(), p.dstImportPath())
+	}
+	p = &vmNativeProcessor{exportPath: "vm/export", importPath: "vm/import"}
+	if p.srcExportPath() != "vm/export" || p.dstImportPath() != "vm/import" {
+		t.Fatalf("unexpected paths %q and %q", p.srcExportPath(), p.dstImportPath())
+	}
+}
+
+func TestConfirmAssumeYes(t *testing.T) {
+	p := &vmNativeProcessor{assumeYes: true}
+	// the prompt would block reading stdin if the question wasn't confirmed automatically
+	if !p.confirm("Continue?") {
+		t.Fatalf("expecting the question to be confirmed")
+	}
+}
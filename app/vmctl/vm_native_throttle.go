@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmctl/native"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+)
+
+// sourceThrottle adaptively limits the number of concurrent export requests
+// to the source. Similar to TCP congestion control, the limit is reduced
+// multiplicatively on 5xx responses and recovers additively on successful requests.
+type sourceThrottle struct {
+	// factor is the multiplier of the limit on 5xx response
+	factor float64
+	// recovery is the number of successful requests needed to increase the limit by one
+	recovery int
+	max      int
+
+	mu        sync.Mutex
+	limit     int
+	inflight  int
+	successes int
+	// reducedAt is the time of the last reduction. Requests started before it
+	// don't reduce the limit again, since they were sent at the previous rate.
+	reducedAt time.Time
+	// changed is closed and re-created when a slot becomes free
+	changed chan struct{}
+}
+
+func newSourceThrottle(max int, factor float64, recovery int) *sourceThrottle {
+	if recovery < 1 {
+		recovery = 1
+	}
+	return &sourceThrottle{
+		factor:   factor,
+		recovery: recovery,
+		max:      max,
+		limit:    max,
+		changed:  make(chan struct{}),
+	}
+}
+
+// acquire waits for a free slot and returns the time the slot was acquired at
+func (st *sourceThrottle) acquire(ctx context.Context) (time.Time, error) {
+	for {
+		st.mu.Lock()
+		if st.inflight < st.limit {
+			st.inflight++
+			st.mu.Unlock()
+			return time.Now(), nil
+		}
+		changed := st.changed
+		st.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			return time.Time{}, ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+func (st *sourceThrottle) release() {
+	st.mu.Lock()
+	st.inflight--
+	st.notifyLocked()
+	st.mu.Unlock()
+}
+
+func (st *sourceThrottle) notifyLocked() {
+	close(st.changed)
+	st.changed = make(chan struct{})
+}
+
+// observe adjusts the limit according to err of the request started at startedAt
+func (st *sourceThrottle) observe(startedAt time.Time, err error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	var sce *native.StatusCodeError
+	if errors.As(err, &sce) && sce.StatusCode >= http.StatusInternalServerError {
+		st.successes = 0
+		if startedAt.Before(st.reducedAt) {
+			return
+		}
+		limit := int(float64(st.limit) * st.factor)
+		if limit < 1 {
+			limit = 1
+		}
+		st.reducedAt = time.Now()
+		if limit < st.limit {
+			logger.Warnf("reducing concurrency of requests to the source from %d to %d because of error: %s", st.limit, limit, err)
+			st.limit = limit
+		}
+		return
+	}
+	if err != nil || st.limit >= st.max {
+		return
+	}
+	st.successes++
+	if st.successes < st.recovery {
+		return
+	}
+	st.successes = 0
+	st.limit++
+	logger.Infof("increasing concurrency of requests to the source to %d of %d after %d successful requests", st.limit, st.max, st.recovery)
+	st.notifyLocked()
+}
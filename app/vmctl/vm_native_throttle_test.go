@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmctl/native"
+)
+
+func TestSourceThrottle(t *testing.T) {
+	st := newSourceThrottle(8, 0.5, 2)
+	err5xx := fmt.Errorf("export request failed: %w", &native.StatusCodeError{StatusCode: 503, Body: "overloaded"})
+	err4xx := &native.StatusCodeError{StatusCode: 400, Body: "bad request"}
+
+	f := func(expLimit int) {
+		t.Helper()
+		st.mu.Lock()
+		limit := st.limit
+		st.mu.Unlock()
+		if limit != expLimit {
+			t.Fatalf("unexpected limit; got %d; want %d", limit, expLimit)
+		}
+	}
+
+	startedAt := time.Now()
+	st.observe(startedAt, err5xx)
+	f(4)
+	// requests started before the reduction don't reduce the limit again
+	st.observe(startedAt, err5xx)
+	f(4)
+	time.Sleep(time.Millisecond)
+	st.observe(time.Now(), err5xx)
+	f(2)
+	// non-5xx errors don't affect the limit
+	st.observe(time.Now(), err4xx)
+	st.observe(time.Now(), errors.New("connection reset"))
+	f(2)
+
+	// gradual recovery
+	st.observe(time.Now(), nil)
+	f(2)
+	st.observe(time.Now(), nil)
+	f(3)
+	for i := 0; i < 20; i++ {
+		st.observe(time.Now(), nil)
+	}
+	f(8)
+
+	// the limit is never below 1
+	for i := 0; i < 10; i++ {
+		time.Sleep(time.Millisecond)
+		st.observe(time.Now(), err5xx)
+	}
+	f(1)
+}
+
+func TestSourceThrottleAcquire(t *testing.T) {
+	st := newSourceThrottle(1, 0.5, 1)
+	ctx := context.Background()
+	if _, err := st.acquire(ctx); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	acquired := make(chan struct{})
+	go func() {
+		if _, err := st.acquire(ctx); err != nil {
+			t.Errorf("unexpected error: %s", err)
+		}
+		close(acquired)
+	}()
+	select {
+	case <-acquired:
+		t.Fatalf("slot mustn't be acquired while the limit is reached")
+	case <-time.After(50 * time.Millisecond):
+	}
+	st.release()
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatalf("slot must be acquired after release")
+	}
+
+	cctx, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := st.acquire(cctx); err == nil {
+		t.Fatalf("expecting error for canceled context")
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promutils"
+)
+
+// parseTimeFilter parses the value of time filter flag.
+//
+// The value may be either RFC3339 time, Unix timestamp in seconds or milliseconds
+// or relative expression such as `now` or `now-7d`, which is resolved against now.
+func parseTimeFilter(flagName, s string, now time.Time) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	if t, ok := parseUnixTimeFilter(s); ok {
+		return t, nil
+	}
+	if s == "now" {
+		return now, nil
+	}
+	if d := strings.TrimPrefix(s, "now-"); d != s && d != "" {
+		offset, err := promutils.ParseDuration(d)
+		if err == nil && offset > 0 {
+			return now.Add(-offset), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("failed to parse %s, provided: %s, expected either RFC3339 format, e.g. %s, "+
+		"Unix timestamp in seconds or milliseconds, e.g. %d or %d, or relative format, e.g. now, now-12h, now-7d",
+		flagName, s, now.Format(time.RFC3339), now.Unix(), now.UnixMilli())
+}
+
+// unixMillisThreshold is the minimum Unix timestamp treated as milliseconds.
+// Timestamps in seconds reach it only in year 5138, while timestamps
+// in milliseconds exceed it since 1973.
+const unixMillisThreshold = 1e11
+
+// parseUnixTimeFilter parses s as Unix timestamp in seconds or milliseconds.
+// Time filters have second precision, so milliseconds are truncated to seconds.
+func parseUnixTimeFilter(s string) (time.Time, bool) {
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n < 0 {
+		return time.Time{}, false
+	}
+	if n >= unixMillisThreshold {
+		return time.UnixMilli(n).Truncate(time.Second).UTC(), true
+	}
+	return time.Unix(n, 0).UTC(), true
+}
+
+// isResolvedTimeFilter returns true if s must be converted to RFC3339 after parsing,
+// so requests, state file and migration plan get the same time.
+func isResolvedTimeFilter(s string) bool {
+	if isRelativeTimeFilter(s) {
+		return true
+	}
+	_, ok := parseUnixTimeFilter(s)
+	return ok
+}
+
+// isRelativeTimeFilter returns true if s is a relative time expression
+func isRelativeTimeFilter(s string) bool {
+	return strings.HasPrefix(s, "now")
+}
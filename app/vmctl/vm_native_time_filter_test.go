@@ -0,0 +1,55 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseTimeFilter(t *testing.T) {
+	now := time.Date(2023, 3, 10, 12, 0, 0, 0, time.UTC)
+	f := func(s string, want time.Time) {
+		t.Helper()
+		got, err := parseTimeFilter("time-start", s, now)
+		if err != nil {
+			t.Fatalf("unexpected error for %q: %s", s, err)
+		}
+		if !got.Equal(want) {
+			t.Fatalf("unexpected time for %q; got %s; want %s", s, got, want)
+		}
+	}
+	f("2023-01-01T00:00:00Z", time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC))
+	f("now", now)
+	f("now-12h", now.Add(-12*time.Hour))
+	f("now-7d", now.Add(-7*24*time.Hour))
+	f("now-1h30m", now.Add(-90*time.Minute))
+	f("1678449600", time.Date(2023, 3, 10, 12, 0, 0, 0, time.UTC))
+	f("1678449600000", time.Date(2023, 3, 10, 12, 0, 0, 0, time.UTC))
+	// milliseconds are truncated to seconds
+	f("1678449600999", time.Date(2023, 3, 10, 12, 0, 0, 0, time.UTC))
+	f("0", time.Unix(0, 0))
+
+	for _, s := range []string{"", "now-", "now+1h", "now-foo", "now-0s", "yesterday", "-1678449600", "1678449600.5", "0x10"} {
+		_, err := parseTimeFilter("time-start", s, now)
+		if err == nil {
+			t.Fatalf("expecting error for %q", s)
+		}
+		if !strings.Contains(err.Error(), "RFC3339") || !strings.Contains(err.Error(), "Unix timestamp") || !strings.Contains(err.Error(), "now-7d") {
+			t.Fatalf("error must mention all the supported formats; got %s", err)
+		}
+	}
+}
+
+func TestIsResolvedTimeFilter(t *testing.T) {
+	f := func(s string, want bool) {
+		t.Helper()
+		if got := isResolvedTimeFilter(s); got != want {
+			t.Fatalf("unexpected result for %q; got %v; want %v", s, got, want)
+		}
+	}
+	f("2023-01-01T00:00:00Z", false)
+	f("now-7d", true)
+	f("1678449600", true)
+	f("1678449600000", true)
+	f("yesterday", false)
+}
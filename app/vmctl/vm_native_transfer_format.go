@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+)
+
+const (
+	// transferFormatNative transfers data in VictoriaMetrics native format
+	transferFormatNative = "native"
+	// transferFormatJSONL transfers data in JSON line format, which can be inspected and transformed with standard tools
+	transferFormatJSONL = "jsonl"
+
+	jsonlExportAddr = "api/v1/export"
+	jsonlImportAddr = "api/v1/import"
+)
+
+// validateTransferFormat validates p.transferFormat and options depending on it
+func (p *vmNativeProcessor) validateTransferFormat() error {
+	switch p.transferFormat {
+	case "", transferFormatNative:
+		if p.src.MaxRowsPerLine > 0 {
+			return fmt.Errorf("--%s requires --%s=%s, since native export doesn't support it", vmNativeExportMaxRowsPerLine, vmNativeTransferFormat, transferFormatJSONL)
+		}
+		return nil
+	case transferFormatJSONL:
+	default:
+		return fmt.Errorf("unsupported --%s=%q; supported values: %s, %s", vmNativeTransferFormat, p.transferFormat, transferFormatNative, transferFormatJSONL)
+	}
+	// the options below decode exported data, which is supported for native format only
+	switch {
+	case p.needsDecode():
+		return fmt.Errorf("--%s=%s can't be used together with options transforming exported data, such as --%s, --%s, --%s, --%s and --%s",
+			vmNativeTransferFormat, transferFormatJSONL, vmNativeRelabelConfig, vmNativeValueScale, vmNativeDownsample, vmNativeDedupStream, vmNativeNonFinite)
+	case p.tenantRoute != nil || p.dstRemoteWrite:
+		return fmt.Errorf("--%s=%s can't be used together with --%s and --%s", vmNativeTransferFormat, transferFormatJSONL, vmNativeDstTenantFromLabel, vmNativeDstRemoteWrite)
+	case p.verifyPerMetric > 0 || p.autoChunkSamples > 0:
+		return fmt.Errorf("--%s=%s can't be used together with --%s and --%s", vmNativeTransferFormat, transferFormatJSONL, vmNativeVerifyPerMetric, vmNativeAutoChunk)
+	case p.src.Format != "":
+		return fmt.Errorf("--%s can't be used together with --%s=%s", vmNativeExportFormat, vmNativeTransferFormat, transferFormatJSONL)
+	}
+	return nil
+}
+
+// isJSONL returns true if data is transferred in JSON line format
+func (p *vmNativeProcessor) isJSONL() bool {
+	return p.transferFormat == transferFormatJSONL
+}
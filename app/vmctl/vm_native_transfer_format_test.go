@@ -0,0 +1,49 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmctl/native"
+)
+
+func TestTransferFormatPaths(t *testing.T) {
+	p := &vmNativeProcessor{transferFormat: transferFormatJSONL}
+	if p.srcExportPath() != jsonlExportAddr || p.dstImportPath() != jsonlImportAddr {
+		t.Fatalf("unexpected paths %q and %q", p.srcExportPath(), p.dstImportPath())
+	}
+	// explicitly set paths take precedence
+	p.exportPath, p.importPath = "foo/export", "foo/import"
+	if p.srcExportPath() != "foo/export" || p.dstImportPath() != "foo/import" {
+		t.Fatalf("unexpected paths %q and %q", p.srcExportPath(), p.dstImportPath())
+	}
+	p = &vmNativeProcessor{transferFormat: transferFormatNative}
+	if p.srcExportPath() != nativeExportAddr || p.dstImportPath() != nativeImportAddr {
+		t.Fatalf("unexpected paths %q and %q", p.srcExportPath(), p.dstImportPath())
+	}
+}
+
+func TestValidateTransferFormat(t *testing.T) {
+	f := func(p *vmNativeProcessor, expErr bool) {
+		t.Helper()
+		if p.src == nil {
+			p.src = &native.Client{}
+		}
+		err := p.validateTransferFormat()
+		if expErr && err == nil {
+			t.Fatalf("expecting error for format %q", p.transferFormat)
+		}
+		if !expErr && err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+	f(&vmNativeProcessor{}, false)
+	f(&vmNativeProcessor{transferFormat: transferFormatNative, dedupStream: true}, false)
+	f(&vmNativeProcessor{transferFormat: transferFormatJSONL}, false)
+	f(&vmNativeProcessor{transferFormat: "csv"}, true)
+	f(&vmNativeProcessor{transferFormat: transferFormatJSONL, dedupStream: true}, true)
+	f(&vmNativeProcessor{transferFormat: transferFormatJSONL, dstRemoteWrite: true}, true)
+	f(&vmNativeProcessor{transferFormat: transferFormatJSONL, verifyPerMetric: 1}, true)
+	f(&vmNativeProcessor{transferFormat: transferFormatJSONL, src: &native.Client{Format: "v1"}}, true)
+	f(&vmNativeProcessor{transferFormat: transferFormatJSONL, src: &native.Client{MaxRowsPerLine: 100}}, false)
+	f(&vmNativeProcessor{src: &native.Client{MaxRowsPerLine: 100}}, true)
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmctl/native"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/decimal"
+)
+
+// valueScale defines linear transformation `value*factor+offset`
+// of sample values for metrics with names matching re.
+type valueScale struct {
+	re     *regexp.Regexp
+	factor float64
+	offset float64
+}
+
+// parseValueScales parses `metricRegex:factor[:offset]` strings.
+// The regex may contain colons, so the string is parsed from the end.
+func parseValueScales(ss []string) ([]*valueScale, error) {
+	var res []*valueScale
+	for _, s := range ss {
+		vs, err := parseValueScale(s)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse --%s=%q: %w", vmNativeValueScale, s, err)
+		}
+		res = append(res, vs)
+	}
+	return res, nil
+}
+
+func parseValueScale(s string) (*valueScale, error) {
+	n := strings.LastIndexByte(s, ':')
+	if n <= 0 {
+		return nil, fmt.Errorf("expecting `metricRegex:factor[:offset]` format")
+	}
+	expr, last := s[:n], s[n+1:]
+	factorStr, offsetStr := last, ""
+	if m := strings.LastIndexByte(expr, ':'); m > 0 {
+		if _, err := strconv.ParseFloat(expr[m+1:], 64); err == nil {
+			expr, factorStr, offsetStr = expr[:m], expr[m+1:], last
+		}
+	}
+	vs := &valueScale{}
+	var err error
+	vs.factor, err = strconv.ParseFloat(factorStr, 64)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse factor: %w", err)
+	}
+	if offsetStr != "" {
+		vs.offset, err = strconv.ParseFloat(offsetStr, 64)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse offset: %w", err)
+		}
+	}
+	// the regex must match the whole metric name, like in relabeling rules
+	vs.re, err = regexp.Compile("^(?:" + expr + ")$")
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse metric regex: %w", err)
+	}
+	return vs, nil
+}
+
+// handleValueScale applies the first of bp.valueScales matching the metric name of b.
+// NaN and Inf values are transformed according to float arithmetics,
+// while staleness markers are left as is.
+func (bp *blockProcessor) handleValueScale(b *native.Block) {
+	for _, vs := range bp.valueScales {
+		if !vs.re.Match(b.MetricName.MetricGroup) {
+			continue
+		}
+		for i, v := range b.Values {
+			if decimal.IsStaleNaN(v) {
+				continue
+			}
+			b.Values[i] = v*vs.factor + vs.offset
+		}
+		bp.scaledSeries++
+		bp.scaledSamples += uint64(len(b.Values))
+		return
+	}
+}
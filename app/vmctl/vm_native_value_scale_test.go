@@ -0,0 +1,69 @@
+package main
+
+import (
+	"math"
+	"testing"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmctl/native"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/decimal"
+)
+
+func TestParseValueScale(t *testing.T) {
+	f := func(s, expRe string, expFactor, expOffset float64) {
+		t.Helper()
+		vs, err := parseValueScale(s)
+		if err != nil {
+			t.Fatalf("unexpected error for %q: %s", s, err)
+		}
+		if vs.re.String() != expRe || vs.factor != expFactor || vs.offset != expOffset {
+			t.Fatalf("unexpected result for %q; got re=%q, factor=%v, offset=%v", s, vs.re, vs.factor, vs.offset)
+		}
+	}
+	f("foo:8", "^(?:foo)$", 8, 0)
+	f("foo_.+:0.001:-273.15", "^(?:foo_.+)$", 0.001, -273.15)
+	f("job:foo:bar:2", "^(?:job:foo:bar)$", 2, 0)
+	f("job:foo:2:1", "^(?:job:foo)$", 2, 1)
+
+	fErr := func(s string) {
+		t.Helper()
+		if _, err := parseValueScale(s); err == nil {
+			t.Fatalf("expecting error for %q", s)
+		}
+	}
+	fErr("")
+	fErr("foo")
+	fErr(":2")
+	fErr("foo:bar")
+	fErr("foo(:2")
+}
+
+func TestBlockProcessorValueScale(t *testing.T) {
+	scales, err := parseValueScales([]string{"foo_bytes:8", "temp_.+:1:-273.15"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	f := func(metric string, vs, expVS []float64) {
+		t.Helper()
+		var b native.Block
+		b.MetricName.MetricGroup = []byte(metric)
+		for i, v := range vs {
+			b.Timestamps = append(b.Timestamps, int64(i+1))
+			b.Values = append(b.Values, v)
+		}
+		bp := &blockProcessor{valueScales: scales}
+		if err := bp.process(&b); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		for i := range expVS {
+			if !equalValues(b.Values[i], expVS[i]) && math.Abs(b.Values[i]-expVS[i]) > 1e-9 {
+				t.Fatalf("unexpected values; got %v; want %v", b.Values, expVS)
+			}
+		}
+	}
+	stale := decimal.StaleNaN
+	f("foo_bytes", []float64{1, 2.5, stale, math.Inf(1)}, []float64{8, 20, stale, math.Inf(1)})
+	f("temp_kelvin", []float64{273.15, 300}, []float64{0, 26.85})
+	// regex must match the whole name
+	f("foo_bytes_total", []float64{1, 2}, []float64{1, 2})
+	f("bar", []float64{1, 2}, []float64{1, 2})
+}
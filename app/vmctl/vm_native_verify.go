@@ -0,0 +1,314 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmctl/native"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/storage"
+)
+
+const (
+	// verifyAttempts is the number of attempts to read sampled points from destination,
+	// since freshly imported data may become visible with a delay
+	verifyAttempts = 3
+	// maxLoggedMismatches limits the number of divergent points in log message
+	maxLoggedMismatches = 10
+)
+
+// verifyDelay is the delay between attempts to read from destination.
+// It is a variable in order to speed up tests.
+var verifyDelay = 2 * time.Second
+
+// metricTracker tracks the units of a single metric in order to verify
+// the metric once all of its units are finished. If some units failed,
+// only the successfully migrated ones are verified.
+type metricTracker struct {
+	tenantID string
+	metric   string
+	units    []*migrationUnit
+
+	// pending is the number of units being migrated
+	pending int32
+	// failed is the number of units failed to migrate
+	failed int32
+}
+
+// unitDone must be called once u migration is finished with the given err
+func (p *vmNativeProcessor) unitDone(ctx context.Context, u *migrationUnit, err error) {
+	p.progress.unitProcessed(u)
+	mt := u.tracker
+	if mt == nil {
+		return
+	}
+	if err != nil {
+		atomic.AddInt32(&mt.failed, 1)
+	} else {
+		atomic.StoreInt32(&u.migrated, 1)
+	}
+	if atomic.AddInt32(&mt.pending, -1) > 0 {
+		return
+	}
+	failed := int(atomic.LoadInt32(&mt.failed))
+	if failed == len(mt.units) {
+		return
+	}
+	if failed > 0 {
+		logger.Warnf("%d of %d requests for metric %q failed; verifying only the migrated time ranges", failed, len(mt.units), mt.metric)
+	}
+	p.verifyMetric(ctx, mt)
+}
+
+// migratedUnits returns successfully migrated units of mt
+func (mt *metricTracker) migratedUnits() []*migrationUnit {
+	var units []*migrationUnit
+	for _, u := range mt.units {
+		if atomic.LoadInt32(&u.migrated) == 1 {
+			units = append(units, u)
+		}
+	}
+	return units
+}
+
+// verifyMetric compares up to p.verifyPerMetric randomly sampled points of mt
+// between source and destination and optionally re-migrates the metric on mismatch
+func (p *vmNativeProcessor) verifyMetric(ctx context.Context, mt *metricTracker) {
+	mismatches, sampled, err := p.compareSamples(ctx, mt)
+	if err != nil {
+		logger.Errorf("cannot verify metric %q: %s", mt.metric, err)
+		return
+	}
+	if len(mismatches) > 0 && p.verifyReimport {
+		logger.Errorf("verification of metric %q failed: %d of %d sampled points diverge: %s; re-migrating the metric",
+			mt.metric, len(mismatches), sampled, formatMismatches(mismatches))
+		for _, u := range mt.migratedUnits() {
+			if err := p.do(ctx, u); err != nil {
+				logger.Errorf("cannot re-migrate metric %q: %s", mt.metric, err)
+				break
+			}
+		}
+		mismatches, sampled, err = p.compareSamples(ctx, mt)
+		if err != nil {
+			logger.Errorf("cannot verify metric %q: %s", mt.metric, err)
+			return
+		}
+	}
+
+	p.s.Lock()
+	defer p.s.Unlock()
+	if len(mismatches) == 0 {
+		p.s.verifiedMetrics++
+		return
+	}
+	p.s.mismatchedMetrics++
+	logger.Errorf("verification of metric %q failed: %d of %d sampled points diverge: %s",
+		mt.metric, len(mismatches), sampled, formatMismatches(mismatches))
+}
+
+// compareSamples samples points from the last migrated unit of mt at source
+// and returns the points missing or different at destination
+func (p *vmNativeProcessor) compareSamples(ctx context.Context, mt *metricTracker) ([]verifyMismatch, int, error) {
+	units := mt.migratedUnits()
+	u := units[len(units)-1]
+	dropLabels := extraLabelNames(p.dst.ExtraLabels)
+
+	p.waitSrcQPS("export")
+	r, err := p.src.ExportPipe(ctx, u.srcURL, u.filter)
+	if err != nil {
+		return nil, 0, fmt.Errorf("cannot export from source: %w", err)
+	}
+	// source samples are processed the same way as during migration,
+	// so they are comparable with the destination ones
+	var process func(b *native.Block) error
+	if p.needsDecode() {
+		process = p.newBlockProcessor().process
+	}
+	rnd := rand.New(rand.NewSource(time.Now().UnixNano()))
+	samples, err := collectSamples(r, p.verifyPerMetric, rnd, dropLabels, process)
+	_ = r.Close()
+	if err != nil {
+		return nil, 0, fmt.Errorf("cannot read samples from source: %w", err)
+	}
+	if len(samples) == 0 {
+		return nil, 0, nil
+	}
+
+	dstURL := fmt.Sprintf("%s/%s", p.dstReader.Addr, nativeExportAddr)
+	if p.interCluster {
+		dstURL = fmt.Sprintf("%s/select/%s/prometheus/%s", p.dstReader.Addr, p.dstTenant(mt.tenantID), nativeExportAddr)
+	}
+	var mismatches []verifyMismatch
+	for i := 0; i < verifyAttempts; i++ {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return nil, 0, ctx.Err()
+			case <-time.After(verifyDelay):
+			}
+		}
+		r, err := p.dstReader.ExportPipe(ctx, dstURL, u.filter)
+		if err != nil {
+			return nil, 0, fmt.Errorf("cannot export from destination: %w", err)
+		}
+		mismatches, err = findMismatches(r, samples, dropLabels)
+		_ = r.Close()
+		if err != nil {
+			return nil, 0, fmt.Errorf("cannot read samples from destination: %w", err)
+		}
+		if len(mismatches) == 0 {
+			break
+		}
+	}
+	return mismatches, len(samples), nil
+}
+
+type verifySample struct {
+	series    string
+	timestamp int64
+	value     float64
+}
+
+type verifyMismatch struct {
+	verifySample
+	found bool
+	got   float64
+}
+
+func (vm verifyMismatch) String() string {
+	ts := time.UnixMilli(vm.timestamp).UTC().Format(time.RFC3339Nano)
+	if !vm.found {
+		return fmt.Sprintf("%s at %s: want %v, missing", vm.series, ts, vm.value)
+	}
+	return fmt.Sprintf("%s at %s: want %v, got %v", vm.series, ts, vm.value, vm.got)
+}
+
+func formatMismatches(mismatches []verifyMismatch) string {
+	var a []string
+	for i, m := range mismatches {
+		if i == maxLoggedMismatches {
+			a = append(a, fmt.Sprintf("and %d more", len(mismatches)-i))
+			break
+		}
+		a = append(a, m.String())
+	}
+	return strings.Join(a, "; ")
+}
+
+// collectSamples returns up to n random samples from native stream r
+// via reservoir sampling, so the stream isn't buffered in memory.
+// Blocks are processed by optional process func before sampling.
+func collectSamples(r io.Reader, n int, rnd *rand.Rand, dropLabels []string, process func(b *native.Block) error) ([]verifySample, error) {
+	var samples []verifySample
+	seen := 0
+	err := native.Transform(io.Discard, r, func(b *native.Block) error {
+		if process != nil {
+			if err := process(b); err != nil {
+				return err
+			}
+		}
+		series := seriesKey(&b.MetricName, dropLabels)
+		for i, ts := range b.Timestamps {
+			s := verifySample{series: series, timestamp: ts, value: b.Values[i]}
+			seen++
+			if len(samples) < n {
+				samples = append(samples, s)
+				continue
+			}
+			if j := rnd.Intn(seen); j < n {
+				samples[j] = s
+			}
+		}
+		return nil
+	})
+	return samples, err
+}
+
+// findMismatches returns samples which are missing or have different values in native stream r
+func findMismatches(r io.Reader, samples []verifySample, dropLabels []string) ([]verifyMismatch, error) {
+	type point struct {
+		found bool
+		value float64
+	}
+	want := make(map[string]map[int64]*point)
+	for _, s := range samples {
+		m := want[s.series]
+		if m == nil {
+			m = make(map[int64]*point)
+			want[s.series] = m
+		}
+		m[s.timestamp] = &point{}
+	}
+	err := native.Transform(io.Discard, r, func(b *native.Block) error {
+		m := want[seriesKey(&b.MetricName, dropLabels)]
+		if m == nil {
+			return nil
+		}
+		for i, ts := range b.Timestamps {
+			if p := m[ts]; p != nil {
+				p.found = true
+				p.value = b.Values[i]
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	var mismatches []verifyMismatch
+	for _, s := range samples {
+		p := want[s.series][s.timestamp]
+		if p.found && equalValues(p.value, s.value) {
+			continue
+		}
+		mismatches = append(mismatches, verifyMismatch{verifySample: s, found: p.found, got: p.value})
+	}
+	return mismatches, nil
+}
+
+func equalValues(a, b float64) bool {
+	if math.IsNaN(a) || math.IsNaN(b) {
+		return math.IsNaN(a) && math.IsNaN(b)
+	}
+	return a == b
+}
+
+// seriesKey returns string representation of mn with sorted labels except of dropLabels
+func seriesKey(mn *storage.MetricName, dropLabels []string) string {
+	labels := make([]string, 0, len(mn.Tags))
+	for _, tag := range mn.Tags {
+		if containsString(dropLabels, string(tag.Key)) {
+			continue
+		}
+		labels = append(labels, fmt.Sprintf("%s=%q", tag.Key, tag.Value))
+	}
+	sort.Strings(labels)
+	return string(mn.MetricGroup) + "{" + strings.Join(labels, ",") + "}"
+}
+
+// extraLabelNames returns label names from extra labels in `name=value` format
+func extraLabelNames(extraLabels []string) []string {
+	var names []string
+	for _, l := range extraLabels {
+		if n := strings.IndexByte(l, '='); n > 0 {
+			names = append(names, l[:n])
+		}
+	}
+	return names
+}
+
+func containsString(a []string, s string) bool {
+	for _, v := range a {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
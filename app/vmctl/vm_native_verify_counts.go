@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+)
+
+// countVerification collects units migrated during the run in order to compare
+// the number of their samples between source and destination after the migration
+type countVerification struct {
+	mu    sync.Mutex
+	units []*migrationUnit
+}
+
+// add records successfully migrated u. It is no-op if cv is nil.
+func (cv *countVerification) add(u *migrationUnit) {
+	if cv == nil {
+		return
+	}
+	cv.mu.Lock()
+	cv.units = append(cv.units, u)
+	cv.mu.Unlock()
+}
+
+// verifyCounts compares the number of samples of every migrated unit between
+// source and destination. Units with mismatching counts are added to p.failures,
+// so they are recorded in the failures file.
+func (p *vmNativeProcessor) verifyCounts(ctx context.Context) {
+	cv := p.countVerification
+	cv.mu.Lock()
+	units := cv.units
+	cv.units = nil
+	cv.mu.Unlock()
+	if len(units) == 0 {
+		return
+	}
+
+	log.Printf("Verifying sample counts of %d migrated requests...", len(units))
+	unitsCh := make(chan *migrationUnit)
+	var wg sync.WaitGroup
+	for i := 0; i < p.cc; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for u := range unitsCh {
+				p.verifyUnitCount(ctx, u)
+			}
+		}()
+	}
+	for _, u := range units {
+		if ctx.Err() != nil {
+			break
+		}
+		unitsCh <- u
+	}
+	close(unitsCh)
+	wg.Wait()
+}
+
+func (p *vmNativeProcessor) verifyUnitCount(ctx context.Context, u *migrationUnit) {
+	want, got, err := p.compareCounts(ctx, u)
+	if err != nil {
+		logger.Errorf("cannot verify sample count of metric %q for time range %s - %s: %s",
+			u.metric, u.filter.TimeStart, u.filter.TimeEnd, err)
+		return
+	}
+
+	p.s.Lock()
+	defer p.s.Unlock()
+	if want == got {
+		p.s.countVerifiedRequests++
+		return
+	}
+	p.s.countMismatchedRequests++
+	err = fmt.Errorf("sample count mismatch: %d samples at source, %d samples at destination", want, got)
+	logger.Errorf("verification of metric %q for time range %s - %s failed: %s",
+		u.metric, u.filter.TimeStart, u.filter.TimeEnd, err)
+	p.failures.add(u, err)
+}
+
+// compareCounts returns the number of samples of u at source and destination.
+// Destination is re-queried on mismatch, since freshly imported data may become visible with a delay.
+func (p *vmNativeProcessor) compareCounts(ctx context.Context, u *migrationUnit) (int64, int64, error) {
+	query, ts, err := countQuery(u)
+	if err != nil {
+		return 0, 0, err
+	}
+	dstAddr := p.dstQueryAddr(u.tenantID)
+	p.waitSrcQPS("verify")
+	want, err := p.src.QueryValue(ctx, p.srcQueryAddr(u.tenantID), query, ts)
+	if err != nil {
+		return 0, 0, fmt.Errorf("cannot query source: %w", err)
+	}
+	var got float64
+	for i := 0; i < verifyAttempts; i++ {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return 0, 0, ctx.Err()
+			case <-time.After(verifyDelay):
+			}
+		}
+		got, err = p.dstReader.QueryValue(ctx, dstAddr, query, ts)
+		if err != nil {
+			return 0, 0, fmt.Errorf("cannot query destination: %w", err)
+		}
+		if got == want {
+			break
+		}
+	}
+	return int64(want), int64(got), nil
+}
+
+// srcQueryAddr returns the address for querying the source for the given tenant
+func (p *vmNativeProcessor) srcQueryAddr(tenantID string) string {
+	if p.interCluster {
+		return fmt.Sprintf("%s/select/%s/prometheus", p.src.Addr, tenantID)
+	}
+	return p.src.Addr
+}
+
+// dstQueryAddr returns the address for querying the destination via p.dstReader
+// for the given source tenant
+func (p *vmNativeProcessor) dstQueryAddr(tenantID string) string {
+	if p.interCluster {
+		return fmt.Sprintf("%s/select/%s/prometheus", p.dstReader.Addr, p.dstTenant(tenantID))
+	}
+	return p.dstReader.Addr
+}
+
+// countQuery returns the query for counting samples of u and the time to execute it at.
+// The lookbehind window of count_over_time is left-open, so it is extended by 1ms
+// in order to include samples at the start of the time range.
+func countQuery(u *migrationUnit) (string, string, error) {
+	start, err := time.Parse(time.RFC3339, u.filter.TimeStart)
+	if err != nil {
+		return "", "", fmt.Errorf("cannot parse start of time range: %s", err)
+	}
+	end, err := time.Parse(time.RFC3339, u.filter.TimeEnd)
+	if err != nil {
+		return "", "", fmt.Errorf("cannot parse end of time range: %s", err)
+	}
+	window := end.Sub(start) + time.Millisecond
+	query := fmt.Sprintf("sum(count_over_time(%s[%dms]))", u.filter.Match, window.Milliseconds())
+	return query, u.filter.TimeEnd, nil
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmctl/native"
+)
+
+func TestCountQuery(t *testing.T) {
+	u := newTestUnit("", "foo", "2022-01-01T00:00:00Z", "2022-01-01T01:00:00Z")
+	u.filter.Match = `{__name__="foo"}`
+	query, ts, err := countQuery(u)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if exp := `sum(count_over_time({__name__="foo"}[3600001ms]))`; query != exp {
+		t.Fatalf("unexpected query; got %q; want %q", query, exp)
+	}
+	if ts != "2022-01-01T01:00:00Z" {
+		t.Fatalf("unexpected query time %q", ts)
+	}
+
+	u.filter.TimeEnd = ""
+	if _, _, err := countQuery(u); err == nil {
+		t.Fatalf("expecting error for missing end of time range")
+	}
+}
+
+func TestVerifyCounts(t *testing.T) {
+	defer func(d time.Duration) { verifyDelay = d }(verifyDelay)
+	verifyDelay = time.Millisecond
+
+	newServer := func(counts map[string]string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			query := r.URL.Query().Get("query")
+			_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[0,"` + counts[query] + `"]}]}}`))
+		}))
+	}
+	fooQuery := `sum(count_over_time({__name__="foo"}[3600001ms]))`
+	barQuery := `sum(count_over_time({__name__="bar"}[3600001ms]))`
+	src := newServer(map[string]string{fooQuery: "10", barQuery: "20"})
+	defer src.Close()
+	dst := newServer(map[string]string{fooQuery: "10", barQuery: "15"})
+	defer dst.Close()
+
+	p := &vmNativeProcessor{
+		src:               &native.Client{Addr: src.URL},
+		dstReader:         &native.Client{Addr: dst.URL},
+		cc:                2,
+		s:                 &stats{},
+		countVerification: &countVerification{},
+	}
+	for _, metric := range []string{"foo", "bar"} {
+		u := newTestUnit("", metric, "2022-01-01T00:00:00Z", "2022-01-01T01:00:00Z")
+		u.filter.Match = `{__name__="` + metric + `"}`
+		p.countVerification.add(u)
+	}
+	p.verifyCounts(context.Background())
+
+	if p.s.countVerifiedRequests != 1 || p.s.countMismatchedRequests != 1 {
+		t.Fatalf("unexpected verification stats; verified: %d; mismatched: %d",
+			p.s.countVerifiedRequests, p.s.countMismatchedRequests)
+	}
+	failed := p.failures.reset()
+	if len(failed) != 1 || failed[0].Metric != "bar" {
+		t.Fatalf("expecting the only failed request for metric %q; got %v", "bar", failed)
+	}
+}
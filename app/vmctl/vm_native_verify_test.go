@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmctl/native"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/storage"
+)
+
+func encodeTestBlocks(t *testing.T, blocks ...*native.Block) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	e := native.NewEncoder(&buf, storage.TimeRange{MinTimestamp: 0, MaxTimestamp: math.MaxInt64})
+	for _, b := range blocks {
+		if err := e.Encode(b); err != nil {
+			t.Fatalf("cannot encode block: %s", err)
+		}
+	}
+	if err := e.Close(); err != nil {
+		t.Fatalf("cannot close encoder: %s", err)
+	}
+	return buf.Bytes()
+}
+
+func newTestBlock(extraLabel string, ts []int64, vs []float64) *native.Block {
+	b := &native.Block{Timestamps: ts, Values: vs}
+	b.MetricName.MetricGroup = []byte("foo")
+	b.MetricName.AddTag("job", "bar")
+	if extraLabel != "" {
+		b.MetricName.AddTag(extraLabel, "baz")
+	}
+	return b
+}
+
+func TestVerifySamples(t *testing.T) {
+	src := encodeTestBlocks(t, newTestBlock("", []int64{10, 20, 30, 40}, []float64{1, 2, 3, 4}))
+	rnd := rand.New(rand.NewSource(1))
+
+	samples, err := collectSamples(bytes.NewReader(src), 2, rnd, nil, nil)
+	if err != nil {
+		t.Fatalf("cannot collect samples: %s", err)
+	}
+	if len(samples) != 2 {
+		t.Fatalf("expecting 2 samples; got %d", len(samples))
+	}
+	samples, err = collectSamples(bytes.NewReader(src), 10, rnd, nil, nil)
+	if err != nil {
+		t.Fatalf("cannot collect samples: %s", err)
+	}
+	if len(samples) != 4 {
+		t.Fatalf("expecting 4 samples; got %d", len(samples))
+	}
+
+	f := func(dst []byte, dropLabels []string, expMismatches int) {
+		t.Helper()
+		mismatches, err := findMismatches(bytes.NewReader(dst), samples, dropLabels)
+		if err != nil {
+			t.Fatalf("cannot find mismatches: %s", err)
+		}
+		if len(mismatches) != expMismatches {
+			t.Fatalf("expecting %d mismatches; got %d: %s", expMismatches, len(mismatches), formatMismatches(mismatches))
+		}
+	}
+	// identical data
+	f(src, nil, 0)
+	// extra label is ignored
+	f(encodeTestBlocks(t, newTestBlock("env", []int64{10, 20, 30, 40}, []float64{1, 2, 3, 4})), []string{"env"}, 0)
+	// extra label isn't ignored
+	f(encodeTestBlocks(t, newTestBlock("env", []int64{10, 20, 30, 40}, []float64{1, 2, 3, 4})), nil, 4)
+	// missing and different points
+	f(encodeTestBlocks(t, newTestBlock("", []int64{10, 20, 30}, []float64{1, 2, 5})), nil, 2)
+}
+
+func TestVerifyPartiallyMigratedMetric(t *testing.T) {
+	data := encodeTestBlocks(t, newTestBlock("", []int64{10, 20}, []float64{1, 2}))
+	var exportedStarts []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		exportedStarts = append(exportedStarts, r.URL.Query().Get("start"))
+		_, _ = w.Write(data)
+	}))
+	defer srv.Close()
+
+	c := &native.Client{Addr: srv.URL}
+	p := &vmNativeProcessor{
+		src:             c,
+		dst:             c,
+		dstReader:       c,
+		verifyPerMetric: 10,
+		s:               &stats{},
+	}
+	mt := &metricTracker{metric: "foo"}
+	for _, start := range []string{"2022-01-01T00:00:00Z", "2022-01-02T00:00:00Z", "2022-01-03T00:00:00Z"} {
+		u := newTestUnit("", "foo", start, "")
+		u.srcURL = srv.URL + "/api/v1/export/native"
+		u.tracker = mt
+		mt.units = append(mt.units, u)
+	}
+	mt.pending = int32(len(mt.units))
+
+	ctx := context.Background()
+	p.unitDone(ctx, mt.units[0], nil)
+	p.unitDone(ctx, mt.units[2], errors.New("corrupted data"))
+	if len(exportedStarts) > 0 {
+		t.Fatalf("metric mustn't be verified until all the units are finished")
+	}
+	p.unitDone(ctx, mt.units[1], nil)
+
+	if n := len(mt.migratedUnits()); n != 2 {
+		t.Fatalf("expecting 2 migrated units; got %d", n)
+	}
+	// the last migrated unit is verified instead of the failed one
+	if len(exportedStarts) != 2 || exportedStarts[0] != "2022-01-02T00:00:00Z" {
+		t.Fatalf("unexpected verification requests for time ranges starting at %q", exportedStarts)
+	}
+	if p.s.verifiedMetrics != 1 || p.s.mismatchedMetrics != 0 {
+		t.Fatalf("unexpected verification stats; verified: %d; mismatched: %d", p.s.verifiedMetrics, p.s.mismatchedMetrics)
+	}
+}
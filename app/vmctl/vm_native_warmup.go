@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+)
+
+// warmup executes p.warmupQueries against the destination in order to prime its caches.
+// Errors are logged, since warmup is optional for the migration.
+func (p *vmNativeProcessor) warmup(ctx context.Context, tenants []string) {
+	if len(p.warmupQueries) == 0 {
+		return
+	}
+	addrs := []string{p.dstReader.Addr}
+	if p.interCluster {
+		addrs = addrs[:0]
+		seen := make(map[string]bool)
+		for _, tenantID := range tenants {
+			// multiple source tenants may be imported into the same destination tenant
+			addr := p.dstQueryAddr(tenantID)
+			if !seen[addr] {
+				seen[addr] = true
+				addrs = append(addrs, addr)
+			}
+		}
+	}
+	log.Printf("Warming up destination with %d queries", len(p.warmupQueries))
+	start := time.Now()
+	for _, addr := range addrs {
+		for _, q := range p.warmupQueries {
+			if ctx.Err() != nil {
+				return
+			}
+			qStart := time.Now()
+			n, err := p.dstReader.Query(ctx, addr, q)
+			if err != nil {
+				logger.Warnf("warmup query %q to %q failed: %s", q, addr, err)
+				continue
+			}
+			log.Printf("Warmup query %q to %q returned %d series in %s", q, addr, n, time.Since(qStart).Truncate(time.Millisecond))
+		}
+	}
+	log.Printf("Warmup finished in %s", time.Since(start).Truncate(time.Millisecond))
+}